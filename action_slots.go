@@ -0,0 +1,128 @@
+package alphacats
+
+import (
+	"fmt"
+
+	"github.com/timpalpant/go-cfr"
+
+	"github.com/timpalpant/alphacats/cards"
+	"github.com/timpalpant/alphacats/gamestate"
+)
+
+// The number of positions (from the top of the draw pile) at which a player
+// may choose to insert the exploding kitten, in addition to the bottom.
+const maxInsertKittenPositions = 6
+
+// MaxActions is the number of slots in the canonical action layout.
+// Every action a player can choose maps to a fixed slot (see ActionSlot),
+// so that policies over a variable number of children can be aligned to
+// a fixed-size vector, for example for the outputs of a neural network.
+//
+// The slots are laid out as:
+//
+//	[0] Draw a card (corresponds to the "Unknown" card enum)
+//	[1 - NumTypes) Play each type of card
+//	[NumTypes + 1 - 2*NumTypes) Give each type of card
+//	[2*NumTypes] Insert the exploding kitten on the bottom of the draw pile
+//	[2*NumTypes + 1] Insert the exploding kitten randomly
+//	[2*NumTypes + 2 - MaxActions) Insert the exploding kitten in the nth position
+const MaxActions = 2*cards.NumTypes + 2 + maxInsertKittenPositions
+
+// ActionSlot returns the canonical slot in [0, MaxActions) of the given
+// player action, when there are nDrawPileCards cards in the draw pile.
+func ActionSlot(action gamestate.Action, nDrawPileCards int) int {
+	switch action.Type {
+	case gamestate.DrawCard:
+		return 0
+	case gamestate.PlayCard:
+		return int(action.Card)
+	case gamestate.GiveCard:
+		return cards.NumTypes + int(action.Card)
+	case gamestate.InsertExplodingKitten:
+		pos := int(action.PositionInDrawPile)
+		if pos == nDrawPileCards+1 {
+			return 2 * cards.NumTypes
+		} else if pos > maxInsertKittenPositions {
+			panic(fmt.Errorf("no action slot to insert kitten at position %d of %d cards",
+				pos, nDrawPileCards))
+		}
+
+		return 2*cards.NumTypes + 1 + pos
+	default:
+		panic(fmt.Errorf("unsupported action: %v", action))
+	}
+}
+
+// ActionSlots returns the canonical slot of the action leading to each child
+// of this node. It is only valid for player nodes.
+func (gn *GameNode) ActionSlots() []int {
+	if gn.Type() != cfr.PlayerNodeType {
+		panic(fmt.Errorf("action slots are only defined for player nodes: %v", gn))
+	}
+
+	if len(gn.children) == 0 {
+		gn.buildChildren()
+	}
+
+	nDrawPileCards := gn.state.GetDrawPile().Len()
+	result := make([]int, len(gn.actions))
+	for i, action := range gn.actions {
+		result[i] = ActionSlot(action, nDrawPileCards)
+	}
+
+	return result
+}
+
+// PaddedPolicy maps the given policy over the children of this node
+// to the canonical action layout of length MaxActions.
+func (gn *GameNode) PaddedPolicy(p []float32) []float32 {
+	slots := gn.ActionSlots()
+	if len(p) != len(slots) {
+		panic(fmt.Errorf("policy has %d actions, but node has %d children", len(p), len(slots)))
+	}
+
+	result := make([]float32, MaxActions)
+	for i, slot := range slots {
+		result[slot] = p[i]
+	}
+
+	return result
+}
+
+// UnpadPolicy is the inverse of PaddedPolicy: it selects the entries of
+// the given canonical policy corresponding to the children of this node.
+func (gn *GameNode) UnpadPolicy(padded []float32) []float32 {
+	slots := gn.ActionSlots()
+	result := make([]float32, len(slots))
+	for i, slot := range slots {
+		result[i] = padded[slot]
+	}
+
+	return result
+}
+
+// ActionMask returns which slots of the canonical action layout (see
+// ActionSlot) are legal actions at this node, for example to mask the logits
+// of a policy over all MaxActions. The child reached by each legal slot is
+// given by ChildIndex. It is only valid for player nodes.
+func (gn *GameNode) ActionMask() []bool {
+	result := make([]bool, MaxActions)
+	for _, slot := range gn.ActionSlots() {
+		result[slot] = true
+	}
+
+	return result
+}
+
+// ChildIndex returns the index of the child (see GetChild) reached by the
+// action in the given canonical slot, and whether that action is legal at
+// this node. It is only valid for player nodes.
+func (gn *GameNode) ChildIndex(slot int) (int, bool) {
+	for i, s := range gn.ActionSlots() {
+		if s == slot {
+			return i, true
+		}
+	}
+
+	return 0, false
+}
@@ -0,0 +1,118 @@
+package alphacats
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/timpalpant/go-cfr"
+
+	"github.com/timpalpant/alphacats/cards"
+	"github.com/timpalpant/alphacats/gamestate"
+)
+
+func TestActionSlot(t *testing.T) {
+	testCases := []struct {
+		action gamestate.Action
+		slot   int
+	}{
+		{gamestate.Action{Type: gamestate.DrawCard}, 0},
+		{gamestate.Action{Type: gamestate.PlayCard, Card: cards.Skip}, 3},
+		{gamestate.Action{Type: gamestate.PlayCard, Card: cards.Cat}, 9},
+		{gamestate.Action{Type: gamestate.GiveCard, Card: cards.Defuse}, 14},
+		{gamestate.Action{Type: gamestate.InsertExplodingKitten, PositionInDrawPile: 11}, 24},
+		{gamestate.Action{Type: gamestate.InsertExplodingKitten}, 25},
+		{gamestate.Action{Type: gamestate.InsertExplodingKitten, PositionInDrawPile: 1}, 26},
+		{gamestate.Action{Type: gamestate.InsertExplodingKitten, PositionInDrawPile: 6}, MaxActions - 1},
+	}
+
+	for _, tc := range testCases {
+		if slot := ActionSlot(tc.action, 10); slot != tc.slot {
+			t.Errorf("expected %v to be in slot %d, got %d", tc.action, tc.slot, slot)
+		}
+	}
+}
+
+func TestPaddedPolicy(t *testing.T) {
+	rng := rand.New(rand.NewSource(123))
+	for k := 0; k < 10; k++ {
+		deal := NewRandomDeal(cards.CoreDeck.AsSlice(), 4)
+		var node cfr.GameTreeNode = NewGame(deal.DrawPile, deal.P0Deal, deal.P1Deal)
+		for node.Type() != cfr.TerminalNodeType {
+			if node.Type() == cfr.PlayerNodeType {
+				gn := node.(*GameNode)
+				slots := gn.ActionSlots()
+				seen := make(map[int]bool)
+				for _, slot := range slots {
+					if slot < 0 || slot >= MaxActions || seen[slot] {
+						t.Fatalf("invalid action slots %v: %v", slots, gn)
+					}
+					seen[slot] = true
+				}
+
+				p := make([]float32, node.NumChildren())
+				for i := range p {
+					p[i] = float32(i + 1)
+				}
+
+				padded := gn.PaddedPolicy(p)
+				if len(padded) != MaxActions {
+					t.Fatalf("expected padded policy of length %d, got %d", MaxActions, len(padded))
+				}
+
+				if unpadded := gn.UnpadPolicy(padded); !reflect.DeepEqual(unpadded, p) {
+					t.Errorf("expected %v, got %v", p, unpadded)
+				}
+
+				if !reflect.DeepEqual(gn.ActionSlots(), slots) {
+					t.Errorf("action slots are not stable: %v", gn)
+				}
+			}
+
+			node = node.GetChild(rng.Intn(node.NumChildren()))
+		}
+	}
+}
+
+func TestActionMask(t *testing.T) {
+	rng := rand.New(rand.NewSource(123))
+	for k := 0; k < 20; k++ {
+		deal := NewRandomDeal(cards.CoreDeck.AsSlice(), 4)
+		var node cfr.GameTreeNode = NewGame(deal.DrawPile, deal.P0Deal, deal.P1Deal)
+		for node.Type() != cfr.TerminalNodeType {
+			if node.Type() == cfr.PlayerNodeType {
+				gn := node.(*GameNode)
+				mask := gn.ActionMask()
+				if len(mask) != MaxActions {
+					t.Fatalf("expected mask of length %d, got %d", MaxActions, len(mask))
+				}
+
+				nLegal := 0
+				state := gn.GetState()
+				nDrawPileCards := state.GetDrawPile().Len()
+				for slot, legal := range mask {
+					i, ok := gn.ChildIndex(slot)
+					if ok != legal {
+						t.Fatalf("slot %d is legal: %v, but has child: %v", slot, legal, ok)
+					}
+					if !legal {
+						continue
+					}
+
+					nLegal++
+					child := gn.GetChild(i).(*GameNode)
+					if s := ActionSlot(child.LastAction(), nDrawPileCards); s != slot {
+						t.Errorf("slot %d maps to child %d with action %v in slot %d",
+							slot, i, child.LastAction(), s)
+					}
+				}
+
+				if nLegal != gn.NumChildren() {
+					t.Errorf("expected %d legal actions, got %d: %v", gn.NumChildren(), nLegal, mask)
+				}
+			}
+
+			node = node.GetChild(rng.Intn(node.NumChildren()))
+		}
+	}
+}
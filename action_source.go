@@ -0,0 +1,222 @@
+package alphacats
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	"github.com/timpalpant/go-cfr"
+
+	"github.com/timpalpant/alphacats/gamestate"
+)
+
+// ActionSource chooses the actions of one of the players in a game,
+// for example by prompting a human or by following a strategy.
+type ActionSource interface {
+	// SelectAction returns the index of the action to take at the given node
+	// among the available actions (which lead to each of its children).
+	// The available actions must not be modified.
+	SelectAction(game *GameNode, available []gamestate.Action) (int, error)
+}
+
+// policySource is implemented by ActionSources that sample their actions
+// from a policy, so that the policy can be included in the GameLog.
+type policySource interface {
+	lastPolicy() []float32
+}
+
+// PlayGame plays out the given game, asking the source for each player to
+// choose their actions. Chance outcomes are sampled with rng. Each step of the
+// game is written to log, which may be nil. Returns the terminal node, or the
+// node at which a source returned an error.
+func PlayGame(game *GameNode, sources [2]ActionSource, rng *rand.Rand, log *GameLog) (*GameNode, error) {
+	for game.Type() != cfr.TerminalNodeType {
+		prev := game
+		var probabilities []float32
+		if game.Type() == cfr.ChanceNodeType {
+			child, p := game.SampleChildWithRand(rng)
+			game = child.(*GameNode)
+			vlogf(1, "[chance] Sampled child node with probability %v", p)
+			probabilities = []float32{float32(p)}
+		} else {
+			source := sources[game.Player()]
+			n := game.NumChildren()
+			selected, err := source.SelectAction(game, game.actions)
+			if err != nil {
+				return game, err
+			}
+
+			if selected < 0 || selected >= n {
+				return game, fmt.Errorf("selected action %d is out of range, node has %d children: %v",
+					selected, n, game)
+			}
+
+			if ps, ok := source.(policySource); ok {
+				probabilities = ps.lastPolicy()
+			}
+
+			game = game.GetChild(selected).(*GameNode)
+			action := game.LastAction()
+			logger().Infof("[player %d] Chose to %v", prev.Player(),
+				hidePrivateInfo(gamestate.EncodeAction(action)).Decode())
+			vlogf(4, "[player %d] Action result was: %v", prev.Player(), action)
+		}
+
+		if err := log.Log(prev, game, probabilities); err != nil {
+			logger().Errorf("Error writing game log: %v", err)
+		}
+	}
+
+	return game, nil
+}
+
+// StrategyProfile chooses actions by sampling from a policy.
+type StrategyProfile struct {
+	Policy func(cfr.GameTreeNode) []float32
+	Rand   *rand.Rand
+
+	last []float32
+}
+
+func (s *StrategyProfile) SelectAction(game *GameNode, available []gamestate.Action) (int, error) {
+	p := s.Policy(game)
+	if len(p) != len(available) {
+		return 0, fmt.Errorf("policy has %d entries for %d available actions", len(p), len(available))
+	}
+
+	s.last = p
+	return SampleAction(p, s.Rand.Float32()), nil
+}
+
+func (s *StrategyProfile) lastPolicy() []float32 {
+	return s.last
+}
+
+// SampleAction returns the index of the action selected from the policy p by
+// the random draw x in [0, 1), so that games can be reproduced exactly from
+// the sequence of draws. Probabilities are summed in order in float64, and x
+// selects the first action whose cumulative probability reaches x times the
+// total. Ties are therefore broken toward the lower index, and actions with
+// zero probability are never selected (unless all of them are, in which case
+// the first action is returned).
+func SampleAction(p []float32, x float32) int {
+	var total float64
+	for _, v := range p {
+		if v > 0 {
+			total += float64(v)
+		}
+	}
+
+	target := float64(x) * total
+	var cumSum float64
+	selected := 0
+	for i, v := range p {
+		if v <= 0 {
+			continue
+		}
+
+		cumSum += float64(v)
+		selected = i
+		if target <= cumSum {
+			break
+		}
+	}
+
+	return selected
+}
+
+// MCTS chooses actions by searching from the player's beliefs about the
+// hidden state of the game, and then sampling from the policy of the search.
+type MCTS struct {
+	// StrategyProfile samples from the policy of the search.
+	StrategyProfile
+	Player  gamestate.Player
+	Beliefs *BeliefState
+	// Search runs the search at the given node, from the player's beliefs.
+	Search func(game *GameNode, beliefs *BeliefState)
+}
+
+func (m *MCTS) SelectAction(game *GameNode, available []gamestate.Action) (int, error) {
+	// NB: Update propagates the beliefs through all actions taken since
+	// the player's last turn.
+	m.Beliefs.Update(game.GetInfoSet(m.Player))
+	m.Search(game, m.Beliefs)
+	return m.StrategyProfile.SelectAction(game, available)
+}
+
+// HintCommand may be entered at a HumanPrompt to ask for a hint.
+const HintCommand = "?"
+
+// HumanPrompt chooses actions by showing the board to a human, and then
+// reading their choice of action, re-prompting until they enter a valid one.
+type HumanPrompt struct {
+	In  *bufio.Reader
+	Out io.Writer
+	// Hint, if set, is called when the human enters HintCommand.
+	Hint func(game *GameNode, available []gamestate.Action)
+}
+
+func (h *HumanPrompt) SelectAction(game *GameNode, available []gamestate.Action) (int, error) {
+	fmt.Fprintf(h.Out, "Your turn.\n%s\n", game.RenderBoard(game.Player()))
+	fmt.Fprintln(h.Out, "Choices (enter the number or the move):")
+	for i, action := range available {
+		fmt.Fprintf(h.Out, "%d: %v (%s)\n", i, action, gamestate.FormatAction(action))
+	}
+
+	msg := "Which action? "
+	if h.Hint != nil {
+		msg = fmt.Sprintf("Which action? (%s for a hint) ", HintCommand)
+	}
+
+	for {
+		fmt.Fprint(h.Out, msg)
+		input, err := h.In.ReadString('\n')
+		if err != nil {
+			return 0, err
+		}
+
+		selected, isHint, err := parseSelection(input, available)
+		if err != nil {
+			fmt.Fprintln(h.Out, err)
+			continue
+		}
+
+		if isHint {
+			if h.Hint == nil {
+				fmt.Fprintln(h.Out, "No hints are available")
+			} else {
+				h.Hint(game, available)
+			}
+			continue
+		}
+
+		return selected, nil
+	}
+}
+
+// parseSelection parses the user's input at the prompt, which is either the
+// hint command, the index of one of the actions, or its move notation
+// (see gamestate.FormatAction).
+func parseSelection(input string, actions []gamestate.Action) (selected int, isHint bool, err error) {
+	input = strings.TrimSpace(input)
+	if input == HintCommand {
+		return 0, true, nil
+	}
+
+	i, err := strconv.Atoi(input)
+	if err != nil {
+		i, err = gamestate.FindMove(input, actions)
+		if err != nil {
+			return 0, false, fmt.Errorf("Invalid selection: %v: %v", input, err)
+		}
+	}
+
+	if i < 0 || i >= len(actions) {
+		return 0, false, fmt.Errorf("Selection must be between 0 and %d: %v", len(actions)-1, i)
+	}
+
+	return i, false, nil
+}
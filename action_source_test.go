@@ -0,0 +1,209 @@
+package alphacats
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/timpalpant/go-cfr"
+
+	"github.com/timpalpant/alphacats/cards"
+	"github.com/timpalpant/alphacats/gamestate"
+)
+
+// scriptedSource chooses actions from a fixed list of moves (in move
+// notation), and fails once the script has run out.
+type scriptedSource struct {
+	moves []string
+}
+
+func (s *scriptedSource) SelectAction(game *GameNode, available []gamestate.Action) (int, error) {
+	if len(s.moves) == 0 {
+		return 0, fmt.Errorf("script ran out of moves at %v", game)
+	}
+
+	move := s.moves[0]
+	s.moves = s.moves[1:]
+	return gamestate.FindMove(move, available)
+}
+
+func TestPlayGameScripted(t *testing.T) {
+	sources := [2]ActionSource{
+		&scriptedSource{moves: []string{"D", "D", "I:1"}},
+		&scriptedSource{moves: []string{"D", "I:1", "D"}},
+	}
+
+	rng := rand.New(rand.NewSource(123))
+	game, err := PlayGame(newTestDeckGame(), sources, rng, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if game.Type() != cfr.TerminalNodeType {
+		t.Fatalf("game ended at non-terminal node: %v", game)
+	}
+
+	for i, source := range sources {
+		if moves := source.(*scriptedSource).moves; len(moves) != 0 {
+			t.Errorf("player %d did not play moves: %v", i, moves)
+		}
+	}
+
+	expected := []string{"D", "D", "I:1", "D", "I:1", "D"}
+	h := game.GetHistory()
+	var moves []string
+	for _, action := range h.AsSlice() {
+		moves = append(moves, gamestate.FormatAction(action))
+	}
+
+	if strings.Join(moves, " ") != strings.Join(expected, " ") {
+		t.Errorf("expected moves %v, got %v", expected, moves)
+	}
+}
+
+func TestPlayGameSourceError(t *testing.T) {
+	sources := [2]ActionSource{
+		&scriptedSource{moves: []string{"D"}},
+		&scriptedSource{},
+	}
+
+	rng := rand.New(rand.NewSource(123))
+	game, err := PlayGame(newTestDeckGame(), sources, rng, nil)
+	if err == nil {
+		t.Fatal("expected error when the script runs out")
+	}
+
+	if game.Player() != int(gamestate.Player1) {
+		t.Errorf("expected to stop at player 1's turn, got %v", game)
+	}
+}
+
+func TestStrategyProfile(t *testing.T) {
+	rng := rand.New(rand.NewSource(123))
+	strategy := &StrategyProfile{Policy: uniformPolicy, Rand: rng}
+	game, err := PlayGame(newTestDeckGame(), [2]ActionSource{strategy, strategy}, rng, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if game.Type() != cfr.TerminalNodeType {
+		t.Fatalf("game ended at non-terminal node: %v", game)
+	}
+
+	strategy.Policy = func(node cfr.GameTreeNode) []float32 {
+		return []float32{1.0}
+	}
+	game = newTestDeckGame()
+	game.buildChildren()
+	if _, err := strategy.SelectAction(game, game.actions); err == nil {
+		t.Error("expected error for policy of the wrong length")
+	}
+}
+
+func TestHumanPrompt(t *testing.T) {
+	game := newTestDeckGame()
+	game.buildChildren()
+	var out bytes.Buffer
+	hints := 0
+	human := &HumanPrompt{
+		In:  bufio.NewReader(strings.NewReader("bogus\n?\nD\n")),
+		Out: &out,
+		Hint: func(node *GameNode, available []gamestate.Action) {
+			hints++
+		},
+	}
+
+	selected, err := human.SelectAction(game, game.actions)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if action := game.actions[selected]; action.Type != gamestate.DrawCard {
+		t.Errorf("expected to select draw card, got %v", action)
+	}
+
+	if hints != 1 {
+		t.Errorf("expected 1 hint, got %d", hints)
+	}
+
+	if !strings.Contains(out.String(), "Invalid selection") {
+		t.Errorf("expected invalid selection to be reported, got: %s", out.String())
+	}
+
+	// The prompt fails once the input runs out.
+	if _, err := human.SelectAction(game, game.actions); err == nil {
+		t.Error("expected error at end of input")
+	}
+}
+
+func TestParseSelection(t *testing.T) {
+	testCases := []struct {
+		input    string
+		selected int
+		isHint   bool
+		isErr    bool
+	}{
+		{"?\n", 0, true, false},
+		{" ? \n", 0, true, false},
+		{"0\n", 0, false, false},
+		{"2\n", 2, false, false},
+		{"3\n", 0, false, true},
+		{"-1\n", 0, false, true},
+		{"??\n", 0, false, true},
+		{"draw\n", 0, false, true},
+		// Move notation.
+		{"P:Cat\n", 1, false, false},
+		{" D \n", 2, false, false},
+		{"P:Shuffle\n", 0, false, true},
+	}
+
+	actions := []gamestate.Action{
+		{Player: gamestate.Player0, Type: gamestate.PlayCard, Card: cards.Skip},
+		{Player: gamestate.Player0, Type: gamestate.PlayCard, Card: cards.Cat},
+		{Player: gamestate.Player0, Type: gamestate.DrawCard},
+	}
+
+	for _, tc := range testCases {
+		selected, isHint, err := parseSelection(tc.input, actions)
+		if (err != nil) != tc.isErr {
+			t.Errorf("%q: expected error = %v, got %v", tc.input, tc.isErr, err)
+			continue
+		}
+
+		if selected != tc.selected || isHint != tc.isHint {
+			t.Errorf("%q: expected (%d, %v), got (%d, %v)",
+				tc.input, tc.selected, tc.isHint, selected, isHint)
+		}
+	}
+}
+
+func TestSampleAction(t *testing.T) {
+	testCases := []struct {
+		p        []float32
+		x        float32
+		expected int
+	}{
+		{[]float32{1.0}, 0.0, 0},
+		{[]float32{0.5, 0.5}, 0.0, 0},
+		{[]float32{0.5, 0.5}, 0.5, 0},
+		{[]float32{0.5, 0.5}, 0.5001, 1},
+		{[]float32{0.25, 0.25, 0.25, 0.25}, 0.5, 1},
+		{[]float32{0.25, 0.25, 0.25, 0.25}, 0.75, 2},
+		{[]float32{0.125, 0.25, 0.125, 0.5}, 0.375, 1},
+		{[]float32{0, 0.5, 0, 0.5}, 0.0, 1},
+		{[]float32{0.5, 0.5, 0}, 0.9999999, 1},
+		{[]float32{1, 1, 2}, 0.5, 1},
+		{[]float32{0, 0}, 0.5, 0},
+	}
+
+	for _, tc := range testCases {
+		for k := 0; k < 3; k++ {
+			if selected := SampleAction(tc.p, tc.x); selected != tc.expected {
+				t.Errorf("SampleAction(%v, %v) = %d, expected %d", tc.p, tc.x, selected, tc.expected)
+			}
+		}
+	}
+}
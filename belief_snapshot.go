@@ -0,0 +1,172 @@
+package alphacats
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/timpalpant/go-cfr"
+
+	"github.com/timpalpant/alphacats/cards"
+)
+
+// BeliefSnapshot is a serializable copy of the game states in a BeliefState
+// and their reach probabilities, to inspect after the fact the beliefs that
+// drove a decision. It is JSON-encodable.
+type BeliefSnapshot struct {
+	// Turn labels the point in the game at which the snapshot was taken.
+	Turn       int       `json:"turn"`
+	ReachProbs []float32 `json:"reachProbs"`
+	// States are the GameNode.MarshalBinary encodings of each state.
+	States [][]byte `json:"states"`
+	// Descriptions are human-readable descriptions of each state.
+	Descriptions []string `json:"descriptions"`
+}
+
+// Snapshot returns a serializable copy of the belief state.
+func (bs *BeliefState) Snapshot(turn int) (*BeliefSnapshot, error) {
+	snapshot := &BeliefSnapshot{
+		Turn:         turn,
+		ReachProbs:   append([]float32(nil), bs.reachProbs...),
+		States:       make([][]byte, len(bs.states)),
+		Descriptions: make([]string, len(bs.states)),
+	}
+
+	for i, game := range bs.states {
+		buf, err := game.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+
+		snapshot.States[i] = buf
+		h := game.state.GetHistory()
+		snapshot.Descriptions[i] = fmt.Sprintf("%v. History: %v", game, h.AsSlice())
+	}
+
+	return snapshot, nil
+}
+
+// Load decodes the game states of the snapshot, and returns them along
+// with their reach probabilities.
+func (s *BeliefSnapshot) Load() ([]*GameNode, []float32, error) {
+	if len(s.States) != len(s.ReachProbs) {
+		return nil, nil, fmt.Errorf("snapshot has %d states but %d reach probabilities",
+			len(s.States), len(s.ReachProbs))
+	}
+
+	states := make([]*GameNode, len(s.States))
+	for i, buf := range s.States {
+		states[i] = &GameNode{}
+		if err := states[i].UnmarshalBinary(buf); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return states, append([]float32(nil), s.ReachProbs...), nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler. Unlike a Snapshot, which
+// is for inspecting beliefs after the fact, the encoding can be restored with
+// UnmarshalBeliefState to a belief state that can be updated and searched
+// as before, so that an analysis can be paused between turns and resumed.
+// The opponent policy is not encoded.
+//
+// The encoding is a flags byte (1 if validation is enabled), the deck
+// (uint64), the length of the info set (uint32) and its encoding, and the
+// number of states N (uint32). It is followed by N records of the reach
+// probability (float32), the length of the state (uint16) and the
+// GameNode.MarshalBinary encoding of the state. All integers are little endian.
+func (bs *BeliefState) MarshalBinary() ([]byte, error) {
+	infoSet, err := bs.infoSet.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	var flags uint8
+	if bs.validate {
+		flags = 1
+	}
+
+	buf := make([]byte, 13, 13+len(infoSet)+4)
+	buf[0] = flags
+	binary.LittleEndian.PutUint64(buf[1:], uint64(bs.deck))
+	binary.LittleEndian.PutUint32(buf[9:], uint32(len(infoSet)))
+	buf = append(buf, infoSet...)
+	var tmp [6]byte
+	binary.LittleEndian.PutUint32(tmp[:], uint32(len(bs.states)))
+	buf = append(buf, tmp[:4]...)
+	for i, game := range bs.states {
+		state, err := game.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+
+		if len(state) > math.MaxUint16 {
+			return nil, fmt.Errorf("state %d is too large to encode (%d bytes)", i, len(state))
+		}
+
+		binary.LittleEndian.PutUint32(tmp[:], math.Float32bits(bs.reachProbs[i]))
+		binary.LittleEndian.PutUint16(tmp[4:], uint16(len(state)))
+		buf = append(buf, tmp[:]...)
+		buf = append(buf, state...)
+	}
+
+	return buf, nil
+}
+
+// UnmarshalBeliefState restores a belief state encoded with MarshalBinary.
+// The given opponent policy is used to weight the opponent's actions in
+// future updates.
+func UnmarshalBeliefState(opponentPolicy func(cfr.GameTreeNode) []float32, buf []byte) (*BeliefState, error) {
+	errTruncated := fmt.Errorf("invalid belief state encoding: truncated (%d bytes)", len(buf))
+	if len(buf) < 13 {
+		return nil, errTruncated
+	}
+
+	bs := &BeliefState{
+		opponentPolicy: opponentPolicy,
+		validate:       buf[0]&1 != 0,
+		deck:           cards.Set(binary.LittleEndian.Uint64(buf[1:])),
+	}
+
+	n := int(binary.LittleEndian.Uint32(buf[9:]))
+	buf = buf[13:]
+	// NB: The encoding of an info set always includes the player and hand.
+	if n < 9 || len(buf) < n+4 {
+		return nil, errTruncated
+	}
+
+	if err := bs.infoSet.UnmarshalBinary(buf[:n]); err != nil {
+		return nil, err
+	}
+
+	nStates := int(binary.LittleEndian.Uint32(buf[n:]))
+	buf = buf[n+4:]
+	for i := 0; i < nStates; i++ {
+		if len(buf) < 6 {
+			return nil, errTruncated
+		}
+
+		reachProb := math.Float32frombits(binary.LittleEndian.Uint32(buf))
+		n := int(binary.LittleEndian.Uint16(buf[4:]))
+		buf = buf[6:]
+		if len(buf) < n {
+			return nil, errTruncated
+		}
+
+		game := &GameNode{}
+		if err := game.UnmarshalBinary(buf[:n]); err != nil {
+			return nil, err
+		}
+
+		bs.states = append(bs.states, game)
+		bs.reachProbs = append(bs.reachProbs, reachProb)
+		buf = buf[n:]
+	}
+
+	if len(buf) != 0 {
+		return nil, fmt.Errorf("invalid belief state encoding: %d trailing bytes", len(buf))
+	}
+
+	return bs, nil
+}
@@ -0,0 +1,205 @@
+package alphacats
+
+import (
+	"encoding/json"
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/timpalpant/go-cfr"
+
+	"github.com/timpalpant/alphacats/cards"
+	"github.com/timpalpant/alphacats/gamestate"
+)
+
+func TestGameNodeMarshalBinary(t *testing.T) {
+	rng := rand.New(rand.NewSource(123))
+	for i := 0; i < 20; i++ {
+		deal := NewRandomDeal(cards.CoreDeck.AsSlice(), 4)
+		game := NewGameWithOptions(deal.DrawPile, deal.P0Deal, deal.P1Deal, GameOptions{
+			RecallDepth:        rng.Intn(10),
+			MaxShuffleChildren: rng.Intn(1000),
+			SlapBackRule:       SlapBackRule(rng.Intn(2)),
+		})
+		game = playRandomActions(rng, game, rng.Intn(20))
+
+		buf, err := game.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		loaded := &GameNode{}
+		if err := loaded.UnmarshalBinary(buf); err != nil {
+			t.Fatal(err)
+		}
+
+		expected := game.Clone()
+		expected.parent = nil
+		expected.gnPool, expected.aPool = loaded.gnPool, loaded.aPool
+		if !sameNode(loaded, expected) {
+			t.Errorf("expected %v, got %v", game, loaded)
+		}
+
+		if loaded.recallDepth != game.recallDepth || loaded.maxShuffleChildren != game.maxShuffleChildren {
+			t.Errorf("expected recall depth %d and max shuffle children %d, got %d and %d",
+				game.recallDepth, game.maxShuffleChildren, loaded.recallDepth, loaded.maxShuffleChildren)
+		}
+
+		if loaded.slapBackRule != game.slapBackRule {
+			t.Errorf("expected slap back rule %v, got %v", game.slapBackRule, loaded.slapBackRule)
+		}
+
+		if game.Type() != cfr.TerminalNodeType && loaded.NumChildren() != game.NumChildren() {
+			t.Errorf("expected %d children, got %d", game.NumChildren(), loaded.NumChildren())
+		}
+	}
+}
+
+func TestBeliefSnapshot(t *testing.T) {
+	rng := rand.New(rand.NewSource(123))
+	deal := NewRandomDeal(cards.CoreDeck.AsSlice(), 4)
+	game := NewGame(deal.DrawPile, deal.P0Deal, deal.P1Deal)
+	game = playRandomActions(rng, game, 4)
+	beliefs := NewBeliefStateFromInfoSet(uniformPolicy, game.GetInfoSet(gamestate.Player1))
+
+	snapshot, err := beliefs.Snapshot(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf, err := json.Marshal(snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded BeliefSnapshot
+	if err := json.Unmarshal(buf, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	if decoded.Turn != 4 {
+		t.Errorf("expected turn 4, got %d", decoded.Turn)
+	}
+
+	states, reachProbs, err := decoded.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(reachProbs, beliefs.reachProbs) {
+		t.Errorf("expected reach probabilities %v, got %v", beliefs.reachProbs, reachProbs)
+	}
+
+	if len(states) != len(beliefs.states) {
+		t.Fatalf("expected %d states, got %d", len(beliefs.states), len(states))
+	}
+
+	for i, state := range states {
+		expected := beliefs.states[i].Clone()
+		expected.parent = nil
+		expected.gnPool, expected.aPool = state.gnPool, state.aPool
+		if !sameNode(state, expected) {
+			t.Errorf("state %d: expected %v, got %v", i, beliefs.states[i], state)
+		}
+	}
+}
+
+func TestBeliefStateMarshalBinary(t *testing.T) {
+	rng := rand.New(rand.NewSource(123))
+	deal := NewRandomDeal(cards.CoreDeck.AsSlice(), 4)
+	game := NewGame(deal.DrawPile, deal.P0Deal, deal.P1Deal)
+	game = playRandomActions(rng, game, 4)
+	beliefs := NewBeliefStateFromInfoSet(uniformPolicy, game.GetInfoSet(gamestate.Player1))
+
+	buf, err := beliefs.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restored, err := UnmarshalBeliefState(uniformPolicy, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkSameBeliefs(t, restored, beliefs)
+
+	// The restored beliefs sample the same determinizations (for search)
+	// given the same seed.
+	for i := 0; i < 10; i++ {
+		rand.Seed(int64(i))
+		expected, err := beliefs.SampleDeterminization()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rand.Seed(int64(i))
+		sampled, err := restored.SampleDeterminization()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if sampled.GetState() != expected.GetState() {
+			t.Errorf("expected to sample %v, got %v", expected, sampled)
+		}
+	}
+
+	// And can be updated as before, after the game continues.
+	// NB: Updates do not preserve the order of the states.
+	game = playRandomActions(rng, game, 4)
+	beliefs.Update(game.GetInfoSet(gamestate.Player1))
+	restored.Update(game.GetInfoSet(gamestate.Player1))
+	expected, actual := beliefReachProbs(beliefs), beliefReachProbs(restored)
+	if len(actual) != len(expected) {
+		t.Errorf("expected %d states after update, got %d", len(expected), len(actual))
+	}
+
+	for key, p := range expected {
+		if q, ok := actual[key]; !ok || math.Abs(float64(q-p)) > 1e-6*float64(p) {
+			t.Errorf("%v: expected reach probability %v, got %v", key.drawPile, p, q)
+		}
+	}
+
+	for _, n := range []int{0, 12, 20, len(buf) - 1} {
+		if _, err := UnmarshalBeliefState(uniformPolicy, buf[:n]); err == nil {
+			t.Errorf("expected error decoding %d of %d bytes", n, len(buf))
+		}
+	}
+}
+
+func checkSameBeliefs(t *testing.T, actual, expected *BeliefState) {
+	t.Helper()
+	if actual.infoSet.Key() != expected.infoSet.Key() || actual.deck != expected.deck {
+		t.Errorf("expected info set %v and deck %v, got %v and %v",
+			expected.infoSet, expected.deck, actual.infoSet, actual.deck)
+	}
+
+	if !reflect.DeepEqual(actual.reachProbs, expected.reachProbs) {
+		t.Errorf("expected reach probabilities %v, got %v", expected.reachProbs, actual.reachProbs)
+	}
+
+	if len(actual.states) != len(expected.states) {
+		t.Fatalf("expected %d states, got %d", len(expected.states), len(actual.states))
+	}
+
+	for i, state := range actual.states {
+		if state.GetState() != expected.states[i].GetState() {
+			t.Errorf("state %d: expected %v, got %v", i, expected.states[i], state)
+		}
+	}
+}
+
+// Returns the reach probability of each state in the belief state, keyed
+// as in dedupStates since equivalent states may be collapsed in any order.
+func beliefReachProbs(bs *BeliefState) map[dedupKey]float32 {
+	result := make(map[dedupKey]float32, len(bs.states))
+	for i, game := range bs.states {
+		key := dedupKey{
+			is:       string(game.InfoSetKey(int(1 - bs.infoSet.Player))),
+			drawPile: game.GetDrawPile(),
+		}
+		result[key] = bs.reachProbs[i]
+	}
+
+	return result
+}
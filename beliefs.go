@@ -1,19 +1,21 @@
 package alphacats
 
 import (
+	"expvar"
 	"fmt"
 	"math/rand"
+	"sort"
 	"sync"
 
-	"github.com/golang/glog"
 	"github.com/timpalpant/go-cfr"
 
 	"github.com/timpalpant/alphacats/cards"
 	"github.com/timpalpant/alphacats/gamestate"
 )
 
-// Core deck - 2x player hands + Defuse + Exploding Kitten
-var initialNumCardsInDrawPile = cards.CoreDeck.Len() - 2*4 + 2
+var (
+	invalidBeliefsDropped = expvar.NewInt("invalid_beliefs_dropped")
+)
 
 // BeliefState holds the distribution of probabilities over underlying
 // game states as perceived from the point of view of one player.
@@ -22,19 +24,43 @@ type BeliefState struct {
 	infoSet        gamestate.InfoSet
 	states         []*GameNode
 	reachProbs     []float32
+	// All cards in play, including the Defuses and the Exploding Kitten.
+	deck cards.Set
+	// If validate is set, each state is re-validated after every update
+	// and inconsistent states are dropped. See EnableValidation.
+	validate bool
 }
 
 // Return all game states consistent with the given initial hand.
 // Note that the passed hand should include the Defuse card.
 func NewBeliefState(opponentPolicy func(cfr.GameTreeNode) []float32, infoSet gamestate.InfoSet) *BeliefState {
+	return NewBeliefStateWithConfig(opponentPolicy, infoSet, DealConfig{})
+}
+
+// NewBeliefStateWithConfig returns all game states consistent with the given
+// initial hand, for a game dealt from config.Deck with the given config. The
+// passed hand should include the config.DefusesPerPlayer Defuse cards.
+func NewBeliefStateWithConfig(opponentPolicy func(cfr.GameTreeNode) []float32, infoSet gamestate.InfoSet, config DealConfig) *BeliefState {
+	nDefuses := config.defusesPerPlayer()
+	if n := int(infoSet.Hand.CountOf(cards.Defuse)); n < nDefuses {
+		panic(fmt.Errorf("initial hand %v has %d Defuses, expected at least %d",
+			infoSet.Hand, n, nDefuses))
+	}
+
+	remaining := config.dealtDeck()
+	privateDeal := infoSet.Hand
+	privateDeal.RemoveN(cards.Defuse, nDefuses)
+
+	// Both players are dealt the same number of cards. The rest of the deck,
+	// the remaining Defuse and the Exploding Kitten make up the draw pile.
+	nDrawPile := remaining.Len() - 2*privateDeal.Len() + 2
 	tbdDrawPile := cards.NewStack()
-	for i := 0; i < initialNumCardsInDrawPile; i++ {
+	for i := 0; i < nDrawPile; i++ {
 		tbdDrawPile.SetNthCard(i, cards.TBD)
 	}
 
-	remaining := cards.CoreDeck
-	privateDeal := infoSet.Hand
-	privateDeal.Remove(cards.Defuse)
+	// The Imploding Kitten is never dealt (see NewRandomDealWithConfig).
+	remaining.RemoveN(cards.ImplodingKitten, int(remaining.CountOf(cards.ImplodingKitten)))
 	remaining.RemoveAll(privateDeal)
 
 	var states []*GameNode
@@ -54,8 +80,8 @@ func NewBeliefState(opponentPolicy func(cfr.GameTreeNode) []float32, infoSet gam
 			p1Deal = privateDeal
 		}
 
-		p0Deal.Add(cards.Defuse)
-		p1Deal.Add(cards.Defuse)
+		p0Deal.AddN(cards.Defuse, nDefuses)
+		p1Deal.AddN(cards.Defuse, nDefuses)
 		game := NewGame(tbdDrawPile, p0Deal, p1Deal)
 		states = append(states, game)
 	})
@@ -65,7 +91,78 @@ func NewBeliefState(opponentPolicy func(cfr.GameTreeNode) []float32, infoSet gam
 		infoSet:        infoSet,
 		states:         states,
 		reachProbs:     uniformDistribution(len(states)),
+		deck:           config.deck(),
+	}
+}
+
+// NewBeliefStateFromInfoSet returns all game states consistent with the given
+// info set, which may be from any point in the game (not just the initial deal).
+//
+// The player's initial hand is reconstructed from their current hand and
+// the history of cards they have drawn, received, played or given away.
+// Beliefs are then enumerated from the initial deal and propagated forward
+// through the observed history, weighting opponent actions by opponentPolicy.
+func NewBeliefStateFromInfoSet(opponentPolicy func(cfr.GameTreeNode) []float32, infoSet gamestate.InfoSet) *BeliefState {
+	return NewBeliefStateFromInfoSetWithConfig(opponentPolicy, infoSet, DealConfig{})
+}
+
+// NewBeliefStateFromInfoSetWithConfig is like NewBeliefStateFromInfoSet,
+// for a game dealt with the given config (see NewBeliefStateWithConfig).
+func NewBeliefStateFromInfoSetWithConfig(opponentPolicy func(cfr.GameTreeNode) []float32, infoSet gamestate.InfoSet, config DealConfig) *BeliefState {
+	initialInfoSet := gamestate.InfoSet{
+		Player: infoSet.Player,
+		Hand:   initialHand(infoSet),
 	}
+
+	bs := NewBeliefStateWithConfig(opponentPolicy, initialInfoSet, config)
+	bs.Update(infoSet)
+	return bs
+}
+
+// initialHand returns the hand the player was dealt at the start of the game,
+// given their info set at some later point in the game.
+func initialHand(infoSet gamestate.InfoSet) cards.Set {
+	// Cards that have left or entered the player's hand since the deal.
+	var left, entered cards.Set
+	for i := 0; i < infoSet.History.Len(); i++ {
+		action := infoSet.History.Get(i)
+		if action.Player != infoSet.Player {
+			if action.Type == gamestate.GiveCard {
+				entered.Add(action.Card)
+			}
+			continue
+		}
+
+		switch action.Type {
+		case gamestate.PlayCard:
+			left.Add(action.Card)
+			if action.Card == cards.DrawFromTheBottom {
+				entered.Add(action.CardsSeen[0])
+			}
+		case gamestate.GiveCard:
+			left.Add(action.Card)
+		case gamestate.DrawCard:
+			entered.Add(action.CardsSeen[0])
+		case gamestate.InsertExplodingKitten:
+			if action.Card == cards.ImplodingKitten {
+				left.Add(cards.ImplodingKitten)
+				break
+			}
+
+			left.Add(cards.Defuse)
+			// If the kitten is being inserted randomly, it does not leave
+			// the player's hand until the chance node is resolved.
+			isPending := (action.PositionInDrawPile == 0 && i == infoSet.History.Len()-1)
+			if !isPending || !infoSet.Hand.Contains(cards.ExplodingKitten) {
+				left.Add(cards.ExplodingKitten)
+			}
+		}
+	}
+
+	result := infoSet.Hand
+	result.AddAll(left)
+	result.RemoveAll(entered)
+	return result
 }
 
 func (bs *BeliefState) Clone() *BeliefState {
@@ -97,19 +194,94 @@ func (bs *BeliefState) Swap(i, j int) {
 // the given new info set.
 func (bs *BeliefState) Update(infoSet gamestate.InfoSet) {
 	nUpdates := infoSet.History.Len() - bs.infoSet.History.Len()
-	glog.V(2).Infof("Performing %d belief updates", nUpdates)
+	vlogf(2, "Performing %d belief updates", nUpdates)
 	for nUpdates > 0 {
 		action := infoSet.History.Get(bs.infoSet.History.Len())
-		glog.V(2).Infof("Processing action: %s", action)
+		vlogf(2, "Processing action: %s", action)
 		bs.determinizeForAction(action)
 		bs.advanceAction(action)
-		glog.V(2).Infof("Belief state now has %d states", len(bs.states))
+		vlogf(2, "Belief state now has %d states", len(bs.states))
 		nBefore := len(bs.states)
 		bs.dedupStates()
-		glog.V(2).Infof("Belief state now has %d states after deduping (deduped %d)", len(bs.states), nBefore-len(bs.states))
-		bs.infoSet = infoSet
+		vlogf(2, "Belief state now has %d states after deduping (deduped %d)", len(bs.states), nBefore-len(bs.states))
+		bs.infoSet.History.Append(action)
 		nUpdates = infoSet.History.Len() - bs.infoSet.History.Len()
 	}
+
+	bs.infoSet = infoSet
+	if bs.validate {
+		bs.dropInvalidStates()
+	}
+}
+
+// EnableValidation is a debugging aid that re-validates every state in
+// the belief state after each update. States that are not consistent with
+// the player's info set, or whose cards do not add up to the deck, are
+// dropped with a warning. This is expensive, and should never happen.
+func (bs *BeliefState) EnableValidation() {
+	bs.validate = true
+}
+
+func (bs *BeliefState) dropInvalidStates() {
+	n := 0
+	for i, game := range bs.states {
+		if err := validateBelief(game, bs.infoSet, bs.deck); err != nil {
+			logger().Warningf("Dropping invalid belief state %v: %v", game, err)
+			invalidBeliefsDropped.Add(1)
+			continue
+		}
+
+		bs.states[n] = game
+		bs.reachProbs[n] = bs.reachProbs[i]
+		n++
+	}
+
+	if n == 0 && len(bs.states) > 0 {
+		panic(fmt.Errorf("Belief state is empty after dropping %d invalid states!", len(bs.states)))
+	}
+
+	bs.states = bs.states[:n]
+	bs.reachProbs = bs.reachProbs[:n]
+}
+
+// validateBelief returns an error if the given game is not consistent with
+// the given info set, or if its cards do not add up to the given deck.
+func validateBelief(game *GameNode, infoSet gamestate.InfoSet, deck cards.Set) (err error) {
+	// NB: Compare decoded actions, since the censored history retains
+	// the bit indicating whether the opponent's action had private info.
+	is := game.GetInfoSet(infoSet.Player)
+	if is.Hand != infoSet.Hand {
+		return fmt.Errorf("expected hand %v, got %v", infoSet.Hand, is.Hand)
+	}
+
+	if is.History.Len() != infoSet.History.Len() {
+		return fmt.Errorf("expected %d actions in history, got %d",
+			infoSet.History.Len(), is.History.Len())
+	}
+
+	for i := 0; i < is.History.Len(); i++ {
+		if is.History.Get(i) != infoSet.History.Get(i) {
+			return fmt.Errorf("expected action %d to be %v, got %v",
+				i, infoSet.History.Get(i), is.History.Get(i))
+		}
+	}
+
+	// getFreeCards panics if more cards are accounted for than are in the deck.
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("cards do not add up to the deck: %v", r)
+		}
+	}()
+
+	state := game.GetState()
+	freeCards := getFreeCards(state, deck)
+	drawPile := state.GetDrawPile()
+	if nTBD := drawPile.CountOf(cards.TBD); freeCards.Len() != nTBD {
+		return fmt.Errorf("%d free cards for %d undetermined positions in draw pile %v",
+			freeCards.Len(), nTBD, drawPile)
+	}
+
+	return nil
 }
 
 type weightedBelief struct {
@@ -132,8 +304,12 @@ type dedupKey struct {
 // was played, the specific set of 3 cards that they had seen is no longer relevant.
 func (bs *BeliefState) dedupStates() {
 	states := make(map[dedupKey]weightedBelief)
+	// NB: States are kept in the order they were first seen, so that sampling
+	// from the belief state is reproducible (see SampleDeterminizationWithRand).
+	var order []dedupKey
+	var is []byte
 	for i, game := range bs.states {
-		is := game.InfoSetKey(int(1 - bs.infoSet.Player))
+		is = game.AppendInfoSetKey(is[:0], int(1-bs.infoSet.Player))
 		drawPile := game.GetDrawPile()
 		key := dedupKey{
 			is:       string(is),
@@ -145,27 +321,31 @@ func (bs *BeliefState) dedupStates() {
 			prev := b.node.GetInfoSet(bs.infoSet.Player)
 			now := game.GetInfoSet(bs.infoSet.Player)
 			if prev != now {
-				glog.Errorf("Previous: Hand: %s, History: %s", prev.Hand, prev.History)
-				glog.Errorf("Now: Hand: %s, History: %s", now.Hand, now.History)
+				logger().Errorf("Previous: Hand: %s, History: %s", prev.Hand, prev.History)
+				logger().Errorf("Now: Hand: %s, History: %s", now.Hand, now.History)
 				panic(fmt.Errorf("Collapsing infosets that are not equivalent from player %d POV", bs.infoSet.Player))
 			}
 
-			glog.V(3).Infof("Deduping game: %s", game)
+			vlogf(3, "Deduping game: %s", game)
 			state := game.GetState()
-			glog.V(3).Infof("=> Draw pile: %s", state.GetDrawPile())
-			glog.V(3).Infof("=> P0 hand: %s", state.GetPlayerHand(gamestate.Player0))
-			glog.V(3).Infof("=> P1 hand: %s", state.GetPlayerHand(gamestate.Player1))
-			glog.V(3).Infof("=> History: %s", state.GetHistory())
+			vlogf(3, "=> Draw pile: %s", state.GetDrawPile())
+			vlogf(3, "=> P0 hand: %s", state.GetPlayerHand(gamestate.Player0))
+			vlogf(3, "=> P1 hand: %s", state.GetPlayerHand(gamestate.Player1))
+			vlogf(3, "=> History: %s", state.GetHistory())
 			p1IS := game.InfoSet(int(1 - bs.infoSet.Player)).(*AbstractedInfoSet)
-			glog.V(3).Infof("=> P1 draw pile: %s", p1IS.DrawPile)
-			glog.V(3).Infof("Into game: %s", b.node)
+			vlogf(3, "=> P1 draw pile: %s", p1IS.DrawPile)
+			vlogf(3, "Into game: %s", b.node)
 			state = b.node.GetState()
-			glog.V(3).Infof("=> Draw pile: %s", state.GetDrawPile())
-			glog.V(3).Infof("=> P0 hand: %s", state.GetPlayerHand(gamestate.Player0))
-			glog.V(3).Infof("=> P1 hand: %s", state.GetPlayerHand(gamestate.Player1))
-			glog.V(3).Infof("=> History: %s", state.GetHistory())
+			vlogf(3, "=> Draw pile: %s", state.GetDrawPile())
+			vlogf(3, "=> P0 hand: %s", state.GetPlayerHand(gamestate.Player0))
+			vlogf(3, "=> P1 hand: %s", state.GetPlayerHand(gamestate.Player1))
+			vlogf(3, "=> History: %s", state.GetHistory())
 			p1IS = b.node.InfoSet(int(1 - bs.infoSet.Player)).(*AbstractedInfoSet)
-			glog.V(3).Infof("=> P1 draw pile: %s", p1IS.DrawPile)
+			vlogf(3, "=> P1 draw pile: %s", p1IS.DrawPile)
+		}
+
+		if !ok {
+			order = append(order, key)
 		}
 
 		b.node = game
@@ -175,7 +355,8 @@ func (bs *BeliefState) dedupStates() {
 
 	bs.states = bs.states[:0]
 	bs.reachProbs = bs.reachProbs[:0]
-	for _, b := range states {
+	for _, key := range order {
+		b := states[key]
 		bs.states = append(bs.states, b.node)
 		bs.reachProbs = append(bs.reachProbs, b.p)
 	}
@@ -220,8 +401,8 @@ func (bs *BeliefState) advanceAction(action gamestate.Action) {
 					(action.Type == gamestate.PlayCard && action.Card == cards.Shuffle) {
 					rndGame := child.GetChild(0).(*GameNode)
 					if newIS := rndGame.GetInfoSet(bs.infoSet.Player); is.History != newIS.History {
-						glog.Errorf("Old info set: hand: %s, history: %s", is.Hand, is.History)
-						glog.Errorf("New info set: hand: %s, history: %s", newIS.Hand, newIS.History)
+						logger().Errorf("Old info set: hand: %s, history: %s", is.Hand, is.History)
+						logger().Errorf("New info set: hand: %s, history: %s", newIS.Hand, newIS.History)
 						panic(fmt.Errorf("Advancing through chance node changed infoset"))
 					}
 					state := rndGame.GetState()
@@ -247,12 +428,12 @@ func (bs *BeliefState) advanceAction(action gamestate.Action) {
 }
 
 func (bs *BeliefState) determinizeForAction(action gamestate.Action) {
-	glog.V(2).Infof("Determinizing for action: %v", action)
+	vlogf(2, "Determinizing for action: %v", action)
 	// Determinize just enough info so that all actions are fully specified.
 	switch action.Type {
 	case gamestate.PlayCard:
 		if action.Card == cards.SeeTheFuture {
-			if action.CardsSeen[0] != cards.Unknown {
+			if !action.CardsSeen[0].IsUnknown() {
 				// We know the specific cards that were seen, so we can avoid
 				// fully expanding the set of possibiliies.
 				bs.determinizeSeenCards(action.CardsSeen)
@@ -261,7 +442,7 @@ func (bs *BeliefState) determinizeForAction(action gamestate.Action) {
 			}
 		} else if action.Card == cards.DrawFromTheBottom {
 			drawnCard := action.CardsSeen[0]
-			if drawnCard != cards.Unknown {
+			if !drawnCard.IsUnknown() {
 				// We know the specific card that was drawn, so we can avoid
 				// fully expanding the set of possibiliies.
 				bs.determinizeDrawnCardFromBottom(drawnCard)
@@ -271,7 +452,7 @@ func (bs *BeliefState) determinizeForAction(action gamestate.Action) {
 		}
 	case gamestate.DrawCard:
 		drawnCard := action.CardsSeen[0]
-		if drawnCard != cards.Unknown {
+		if !drawnCard.IsUnknown() {
 			// We know the specific card that was drawn, so we can avoid
 			// fully expanding the set of possibiliies.
 			bs.determinizeDrawnCard(drawnCard)
@@ -289,10 +470,15 @@ func (bs *BeliefState) determinizeSeenCards(seenCards [3]cards.Card) {
 		drawPile := state.GetDrawPile()
 		incompatibleState := false
 		for i, card := range seenCards {
+			if card.IsUnknown() {
+				// Fewer than 3 cards were seen because the draw pile is short.
+				break
+			}
+
 			drawPileCard := drawPile.NthCard(i)
-			if drawPileCard == cards.TBD {
+			if drawPileCard.IsTBD() {
 				tmpState := gamestate.NewShuffled(state, drawPile)
-				freeCards := getFreeCards(tmpState)
+				freeCards := getFreeCards(tmpState, bs.deck)
 				if !freeCards.Contains(card) {
 					// This state could not possibly be valid because we saw a card
 					// that was known not to be among the set of undetermined cards.
@@ -329,8 +515,8 @@ func (bs *BeliefState) determinizeDrawnCard(drawnCard cards.Card) {
 		state := game.GetState()
 		drawPile := state.GetDrawPile()
 		topCard := drawPile.NthCard(0)
-		if topCard == cards.TBD {
-			freeCards := getFreeCards(state)
+		if topCard.IsTBD() {
+			freeCards := getFreeCards(state, bs.deck)
 			if !freeCards.Contains(drawnCard) {
 				// This state could not possibly be valid because we drew a card
 				// that was known not to be among the set of undetermined cards.
@@ -360,8 +546,8 @@ func (bs *BeliefState) determinizeDrawnCardFromBottom(drawnCard cards.Card) {
 		state := game.GetState()
 		drawPile := state.GetDrawPile()
 		bottomCard := drawPile.NthCard(drawPile.Len() - 1)
-		if bottomCard == cards.TBD {
-			freeCards := getFreeCards(state)
+		if bottomCard.IsTBD() {
+			freeCards := getFreeCards(state, bs.deck)
 			if !freeCards.Contains(drawnCard) {
 				// This state could not possibly be valid because we drew a card
 				// that was known not to be among the set of undetermined cards.
@@ -385,13 +571,26 @@ func (bs *BeliefState) determinizeDrawnCardFromBottom(drawnCard cards.Card) {
 }
 
 func (bs *BeliefState) determinizeTopKCards(k int) {
+	topK := make([]int, k)
+	for i := range topK {
+		topK[i] = i
+	}
+
 	var newStates []*GameNode
 	var newReachProbs []float32
 	for i, game := range bs.states {
 		state := game.GetState()
-		determinizedDrawPiles := enumerateDrawPileDeterminizations(state, k)
+		determinizedDrawPiles := enumerateDrawPileDeterminizations(state, bs.deck, topK)
 		total := sumValues(determinizedDrawPiles)
-		for determinizedDrawPile, freq := range determinizedDrawPiles {
+		// NB: Draw piles are added in a fixed order, so that sampling from
+		// the belief state is reproducible (see SampleDeterminizationWithRand).
+		drawPiles := make([]cards.Stack, 0, len(determinizedDrawPiles))
+		for drawPile := range determinizedDrawPiles {
+			drawPiles = append(drawPiles, drawPile)
+		}
+		sort.Slice(drawPiles, func(i, j int) bool { return drawPiles[i] < drawPiles[j] })
+		for _, determinizedDrawPile := range drawPiles {
+			freq := determinizedDrawPiles[determinizedDrawPile]
 			determinizedState := gamestate.NewShuffled(state, determinizedDrawPile)
 			determinizedGame := game.CloneWithState(determinizedState)
 			newStates = append(newStates, determinizedGame)
@@ -413,8 +612,8 @@ func (bs *BeliefState) determinizeForDrawFromTheBottom() {
 		determinizedState := game.GetState()
 		drawPile := determinizedState.GetDrawPile()
 		bottomCard := drawPile.NthCard(drawPile.Len() - 1)
-		if bottomCard == cards.TBD {
-			freeCards := getFreeCards(determinizedState)
+		if bottomCard.IsTBD() {
+			freeCards := getFreeCards(determinizedState, bs.deck)
 			nFreeCards := freeCards.Len()
 			freeCards.Iter(func(card cards.Card, count uint8) {
 				drawPile.SetNthCard(drawPile.Len()-1, card)
@@ -451,31 +650,31 @@ func (bs *BeliefState) checkNewStates(newStates []*GameNode, action gamestate.Ac
 	if len(newStates) == 0 {
 		nChildren := 0
 		for i, determinization := range bs.states {
-			glog.Errorf("Candidate previous state %d: %s", i, determinization)
+			logger().Errorf("Candidate previous state %d: %s", i, determinization)
 			state := determinization.GetState()
-			glog.Errorf("=> Draw pile: %s", state.GetDrawPile())
-			glog.Errorf("=> P0 hand: %s", state.GetPlayerHand(gamestate.Player0))
-			glog.Errorf("=> P1 hand: %s", state.GetPlayerHand(gamestate.Player1))
-			glog.Errorf("=> History: %s", state.GetHistory())
+			logger().Errorf("=> Draw pile: %s", state.GetDrawPile())
+			logger().Errorf("=> P0 hand: %s", state.GetPlayerHand(gamestate.Player0))
+			logger().Errorf("=> P1 hand: %s", state.GetPlayerHand(gamestate.Player1))
+			logger().Errorf("=> History: %s", state.GetHistory())
 			for j := 0; j < determinization.NumChildren(); j++ {
 				nChildren++
 				child := determinization.GetChild(j).(*GameNode)
-				glog.Errorf("Candidate child %d-%d: %s", i, j, child)
+				logger().Errorf("Candidate child %d-%d: %s", i, j, child)
 				state := child.GetState()
-				glog.Errorf("=> Draw pile: %s", state.GetDrawPile())
-				glog.Errorf("=> P0 hand: %s", state.GetPlayerHand(gamestate.Player0))
-				glog.Errorf("=> P1 hand: %s", state.GetPlayerHand(gamestate.Player1))
+				logger().Errorf("=> Draw pile: %s", state.GetDrawPile())
+				logger().Errorf("=> P0 hand: %s", state.GetPlayerHand(gamestate.Player0))
+				logger().Errorf("=> P1 hand: %s", state.GetPlayerHand(gamestate.Player1))
 				h := state.GetHistory()
-				glog.Errorf("=> History: %s", h)
-				glog.Errorf("=> Last action: %s", h.Get(h.Len()-1))
+				logger().Errorf("=> History: %s", h)
+				logger().Errorf("=> Last action: %s", h.Get(h.Len()-1))
 			}
 
 			determinization.Close()
 		}
-		glog.Errorf("Old info set: hand: %s, history: %s", bs.infoSet.Hand, bs.infoSet.History)
-		glog.Errorf("New action: %s", action)
-		glog.Infof("Children considered: %d", nChildren)
-		glog.Infof("States considered: %d", len(bs.states))
+		logger().Errorf("Old info set: hand: %s, history: %s", bs.infoSet.Hand, bs.infoSet.History)
+		logger().Errorf("New action: %s", action)
+		logger().Infof("Children considered: %d", nChildren)
+		logger().Infof("States considered: %d", len(bs.states))
 		panic(fmt.Errorf("Belief state is empty!"))
 	}
 
@@ -483,51 +682,157 @@ func (bs *BeliefState) checkNewStates(newStates []*GameNode, action gamestate.Ac
 		state := determinization.GetState()
 		p0Hand := state.GetPlayerHand(gamestate.Player0)
 		if p0Hand.Contains(cards.TBD) {
-			glog.Errorf("Game: %s", determinization)
-			glog.Errorf("=> Draw pile: %s", state.GetDrawPile())
-			glog.Errorf("=> P0 hand: %s", state.GetPlayerHand(gamestate.Player0))
-			glog.Errorf("=> P1 hand: %s", state.GetPlayerHand(gamestate.Player1))
+			logger().Errorf("Game: %s", determinization)
+			logger().Errorf("=> Draw pile: %s", state.GetDrawPile())
+			logger().Errorf("=> P0 hand: %s", state.GetPlayerHand(gamestate.Player0))
+			logger().Errorf("=> P1 hand: %s", state.GetPlayerHand(gamestate.Player1))
 			h := state.GetHistory()
-			glog.Errorf("=> History: %s", h)
-			glog.Errorf("=> Last action: %s", h.Get(h.Len()-1))
+			logger().Errorf("=> History: %s", h)
+			logger().Errorf("=> Last action: %s", h.Get(h.Len()-1))
 			panic(fmt.Errorf("Player 0 drew TBD card"))
 		}
 
 		p1Hand := state.GetPlayerHand(gamestate.Player1)
 		if p1Hand.Contains(cards.TBD) {
-			glog.Errorf("Game: %s", determinization)
-			glog.Errorf("=> Draw pile: %s", state.GetDrawPile())
-			glog.Errorf("=> P0 hand: %s", state.GetPlayerHand(gamestate.Player0))
-			glog.Errorf("=> P1 hand: %s", state.GetPlayerHand(gamestate.Player1))
+			logger().Errorf("Game: %s", determinization)
+			logger().Errorf("=> Draw pile: %s", state.GetDrawPile())
+			logger().Errorf("=> P0 hand: %s", state.GetPlayerHand(gamestate.Player0))
+			logger().Errorf("=> P1 hand: %s", state.GetPlayerHand(gamestate.Player1))
 			h := state.GetHistory()
-			glog.Errorf("=> History: %s", h)
-			glog.Errorf("=> Last action: %s", h.Get(h.Len()-1))
+			logger().Errorf("=> History: %s", h)
+			logger().Errorf("=> Last action: %s", h.Get(h.Len()-1))
 			panic(fmt.Errorf("Player 1 drew TBD card"))
 		}
 	}
 }
 
-func (bs *BeliefState) SampleDeterminization() *GameNode {
+// DeterminizationError is returned when the cards that are not otherwise
+// accounted for do not exactly fill the undetermined positions in the draw pile.
+type DeterminizationError struct {
+	// Leftover are the free cards that remained after filling every
+	// undetermined position in the draw pile.
+	Leftover []cards.Card
+	// NumUnfilled is the number of positions that could not be filled
+	// because there were not enough free cards.
+	NumUnfilled int
+	State       gamestate.GameState
+}
+
+func (e *DeterminizationError) Error() string {
+	return fmt.Sprintf("unable to determinize draw pile %v: %d free cards remaining (%v), %d positions unfilled",
+		e.State.GetDrawPile(), len(e.Leftover), e.Leftover, e.NumUnfilled)
+}
+
+// SampleDeterminization samples a fully determinized game from the belief state.
+// If the sampled state cannot be determinized, a *DeterminizationError is returned.
+func (bs *BeliefState) SampleDeterminization() (*GameNode, error) {
 	// First sample one of our belief states according to the reach probabilities.
 	selected := sampleOne(bs.reachProbs)
+	return bs.determinize(selected, rand.Shuffle)
+}
+
+// SampleDeterminizationWithRand is like SampleDeterminization, but draws from
+// the given source, so that the sample is reproducible given its seed.
+func (bs *BeliefState) SampleDeterminizationWithRand(rng *rand.Rand) (*GameNode, error) {
+	selected := SampleAction(bs.reachProbs, rng.Float32())
+	return bs.determinize(selected, rng.Shuffle)
+}
+
+// ForcedOutcome returns the winner of the game if it is forced in every
+// determinization of the belief state (see GameNode.ForcedOutcome). States
+// with undetermined positions in the draw pile have many determinizations,
+// so the outcome is only considered forced if every state is fully determined.
+func (bs *BeliefState) ForcedOutcome() (winner gamestate.Player, forced bool) {
+	if len(bs.states) == 0 {
+		return 0, false
+	}
+
+	for i, game := range bs.states {
+		if len(game.GetDrawPile().TBDPositions()) > 0 {
+			return 0, false
+		}
+
+		stateWinner, stateForced := game.ForcedOutcome()
+		if !stateForced || (i > 0 && stateWinner != winner) {
+			return 0, false
+		}
+
+		winner = stateWinner
+	}
+
+	return winner, true
+}
+
+func (bs *BeliefState) determinize(selected int, shuffle func(n int, swap func(i, j int))) (*GameNode, error) {
 	game := bs.states[selected]
 	// Now sample a full determinization of this state uniformly, since all
 	// unresolved determinizations are uniformly probable.
-	determinizedState := sampleDeterminizedState(game.GetState())
-	return game.CloneWithState(determinizedState)
+	determinizedState, err := sampleDeterminizedState(game.GetState(), bs.deck, shuffle)
+	if err != nil {
+		return nil, err
+	}
+
+	return game.CloneWithState(determinizedState), nil
+}
+
+// DeterminizationSampler samples a fully determinized game from a belief state.
+type DeterminizationSampler func(bs *BeliefState) (*GameNode, error)
+
+// UniformDeterminizationSampler fills the undetermined positions of the
+// draw pile uniformly at random.
+func UniformDeterminizationSampler(bs *BeliefState) (*GameNode, error) {
+	return bs.SampleDeterminization()
 }
 
-func sampleDeterminizedState(state gamestate.GameState) gamestate.GameState {
-	freeCards := getFreeCards(state)
+// DrawPilePrior returns the relative (unnormalized) probability of the given
+// fully determinized draw pile in the given state, for example according to
+// a model of how the opponent plays given what they know about the draw pile.
+type DrawPilePrior func(state gamestate.GameState, drawPile cards.Stack) float64
+
+// NewWeightedDeterminizationSampler returns a DeterminizationSampler that
+// biases determinizations according to the given prior. It draws numCandidates
+// uniform determinizations, and then resamples one of them in proportion to
+// its prior probability (sampling-importance-resampling). If the prior is zero
+// for every candidate, the first candidate is returned.
+//
+// Only the undetermined positions of the draw pile are ever filled, so every
+// determinization is still consistent with the belief state.
+func NewWeightedDeterminizationSampler(prior DrawPilePrior, numCandidates int) DeterminizationSampler {
+	return func(bs *BeliefState) (*GameNode, error) {
+		candidates := make([]*GameNode, numCandidates)
+		weights := make([]float32, numCandidates)
+		var total float32
+		for i := range candidates {
+			game, err := bs.SampleDeterminization()
+			if err != nil {
+				return nil, err
+			}
+
+			candidates[i] = game
+			weights[i] = float32(prior(game.GetState(), game.GetDrawPile()))
+			total += weights[i]
+		}
+
+		if total <= 0 {
+			return candidates[0], nil
+		}
+
+		return candidates[sampleOne(weights)], nil
+	}
+}
+
+func sampleDeterminizedState(state gamestate.GameState, deck cards.Set, shuffle func(n int, swap func(i, j int))) (gamestate.GameState, error) {
+	freeCards := getFreeCards(state, deck)
 	freeCardsSlice := freeCards.AsSlice()
-	rand.Shuffle(len(freeCardsSlice), func(i, j int) {
+	shuffle(len(freeCardsSlice), func(i, j int) {
 		freeCardsSlice[i], freeCardsSlice[j] = freeCardsSlice[j], freeCardsSlice[i]
 	})
 
 	drawPile := state.GetDrawPile()
-	for i := 0; i < drawPile.Len(); i++ {
-		nthCard := drawPile.NthCard(i)
-		if nthCard != cards.TBD {
+	numUnfilled := 0
+	for _, i := range drawPile.TBDPositions() {
+		if len(freeCardsSlice) == 0 {
+			numUnfilled++
 			continue
 		}
 
@@ -536,25 +841,26 @@ func sampleDeterminizedState(state gamestate.GameState) gamestate.GameState {
 		freeCardsSlice = freeCardsSlice[1:]
 	}
 
-	if len(freeCardsSlice) > 0 {
-		panic(fmt.Errorf("Still have %d free cards remaining after determinization: %v",
-			len(freeCardsSlice), freeCardsSlice))
+	if len(freeCardsSlice) > 0 || numUnfilled > 0 {
+		return state, &DeterminizationError{
+			Leftover:    freeCardsSlice,
+			NumUnfilled: numUnfilled,
+			State:       state,
+		}
 	}
 
-	return gamestate.NewShuffled(state, drawPile)
+	return gamestate.NewShuffled(state, drawPile), nil
 }
 
-func getFreeCards(state gamestate.GameState) cards.Set {
+// getFreeCards returns the cards in the given deck whose location is not
+// known in the given state.
+func getFreeCards(state gamestate.GameState, deck cards.Set) cards.Set {
 	drawPile := state.GetDrawPile()
 	p0Hand := state.GetPlayerHand(gamestate.Player0)
 	p1Hand := state.GetPlayerHand(gamestate.Player1)
 	h := state.GetHistory()
 
-	freeCards := cards.CoreDeck
-	freeCards.Add(cards.Defuse)
-	freeCards.Add(cards.Defuse)
-	freeCards.Add(cards.Defuse)
-	freeCards.Add(cards.ExplodingKitten)
+	freeCards := deck
 
 	// Remove all cards which are known to exist in either player's hand, a known position in the draw
 	// pile, or have already been played.
@@ -562,13 +868,13 @@ func getFreeCards(state gamestate.GameState) cards.Set {
 	freeCards.RemoveAll(p1Hand)
 	for i := 0; i < drawPile.Len(); i++ {
 		nthCard := drawPile.NthCard(i)
-		if nthCard != cards.Unknown && nthCard != cards.TBD {
+		if !nthCard.IsPlaceholder() {
 			freeCards.Remove(nthCard)
 		}
 	}
 	for i := 0; i < h.Len(); i++ {
 		action := h.Get(i)
-		if action.Type == gamestate.PlayCard || action.Type == gamestate.InsertExplodingKitten {
+		if action.Type == gamestate.PlayCard || isDefuse(action) {
 			freeCards.Remove(action.Card)
 		}
 	}
@@ -576,38 +882,45 @@ func getFreeCards(state gamestate.GameState) cards.Set {
 	return freeCards
 }
 
-func enumerateDrawPileDeterminizations(state gamestate.GameState, n int) map[cards.Stack]int {
+// enumerateDrawPileDeterminizations returns all determinizations of the given
+// positions in the draw pile, along with the number of ways each can occur.
+// Positions that are already determined in the state (e.g. because they were
+// seen with SeeTheFuture) are left as is, and only the undetermined positions
+// are filled in from the free cards.
+func enumerateDrawPileDeterminizations(state gamestate.GameState, deck cards.Set, positions []int) map[cards.Stack]int {
 	drawPile := state.GetDrawPile()
-	freeCards := getFreeCards(state)
+	freeCards := getFreeCards(state, deck)
 	result := make(map[cards.Stack]int)
-	enumerateDrawPilesHelper(freeCards, drawPile, n, 1, func(determinizedDrawPile cards.Stack, freq int) {
+	enumerateDrawPilesHelper(freeCards, drawPile, positions, 1, func(determinizedDrawPile cards.Stack, freq int) {
 		result[determinizedDrawPile] += freq
 	})
 
 	return result
 }
 
-func enumerateDrawPilesHelper(deck cards.Set, result cards.Stack, n int, freq int, cb func(shuffle cards.Stack, freq int)) {
-	if n == 0 { // All cards have been used, complete shuffle.
+func enumerateDrawPilesHelper(deck cards.Set, result cards.Stack, positions []int, freq int, cb func(shuffle cards.Stack, freq int)) {
+	if len(positions) == 0 { // All positions have been determinized.
 		cb(result, freq)
 		return
 	}
 
-	nthCard := result.NthCard(n - 1)
-	if nthCard == cards.TBD {
+	n := positions[len(positions)-1]
+	positions = positions[:len(positions)-1]
+	nthCard := result.NthCard(n)
+	if nthCard.IsTBD() {
 		deck.Iter(func(card cards.Card, count uint8) {
 			// Take one of card from deck and append to result.
 			remaining := deck
 			remaining.Remove(card)
 			newResult := result
-			newResult.SetNthCard(n-1, card)
+			newResult.SetNthCard(n, card)
 
 			// Recurse with remaining deck and new result.
-			enumerateDrawPilesHelper(remaining, newResult, n-1, int(count)*freq, cb)
+			enumerateDrawPilesHelper(remaining, newResult, positions, int(count)*freq, cb)
 		})
 	} else {
 		// Nth card in the draw pile is already determined.
-		enumerateDrawPilesHelper(deck, result, n-1, freq, cb)
+		enumerateDrawPilesHelper(deck, result, positions, freq, cb)
 	}
 }
 
@@ -0,0 +1,481 @@
+package alphacats
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/timpalpant/go-cfr"
+
+	"github.com/timpalpant/alphacats/cards"
+	"github.com/timpalpant/alphacats/gamestate"
+)
+
+// Plays up to n uniform random actions, stopping early if the game ends.
+func playRandomActions(rng *rand.Rand, node *GameNode, n int) *GameNode {
+	var result cfr.GameTreeNode = node
+	for i := 0; i < n && result.Type() != cfr.TerminalNodeType; i++ {
+		if result.Type() == cfr.ChanceNodeType {
+			result, _ = result.(*GameNode).SampleChildWithRand(rng)
+		} else {
+			result = result.GetChild(rng.Intn(result.NumChildren()))
+		}
+	}
+
+	return result.(*GameNode)
+}
+
+func TestNewBeliefStateFromInfoSet(t *testing.T) {
+	rng := rand.New(rand.NewSource(123))
+	for i := 0; i < 10; i++ {
+		deal := NewRandomDeal(cards.CoreDeck.AsSlice(), 4)
+		game := NewGame(deal.DrawPile, deal.P0Deal, deal.P1Deal)
+		game = playRandomActions(rng, game, 6)
+		if game.Type() != cfr.PlayerNodeType {
+			continue
+		}
+
+		is := game.GetInfoSet(gamestate.Player1)
+		if hand := initialHand(is); hand != deal.P1Deal {
+			t.Errorf("expected initial hand %v, got %v", deal.P1Deal, hand)
+		}
+
+		beliefs := NewBeliefStateFromInfoSet(uniformPolicy, is)
+		if beliefs.Len() == 0 {
+			t.Fatalf("no states consistent with %v", is)
+		}
+
+		foundOpponentHand := false
+		state := game.GetState()
+		opponentHand := state.GetPlayerHand(gamestate.Player0)
+		for _, node := range beliefs.states {
+			// NB: Compare decoded actions, since the censored history retains
+			// the bit indicating whether the opponent's action had private info.
+			nodeIS := node.GetInfoSet(gamestate.Player1)
+			if nodeIS.Hand != is.Hand || !reflect.DeepEqual(nodeIS.History.AsSlice(), is.History.AsSlice()) {
+				t.Errorf("expected info set %v, got %v", is, nodeIS)
+			}
+
+			state := node.GetState()
+			if state.GetPlayerHand(gamestate.Player0) == opponentHand {
+				foundOpponentHand = true
+			}
+		}
+
+		if !foundOpponentHand {
+			t.Errorf("true opponent hand %v is not in belief state", opponentHand)
+		}
+	}
+}
+
+func TestSampleDeterminizationError(t *testing.T) {
+	// Not all of the cards in the core deck are accounted for,
+	// but there are no undetermined positions in the draw pile.
+	game := newTestDeckGame()
+	bs := &BeliefState{
+		states:     []*GameNode{game},
+		reachProbs: []float32{1.0},
+		deck:       DealConfig{}.deck(),
+	}
+
+	result, err := bs.SampleDeterminization()
+	if err == nil {
+		t.Fatalf("expected determinization to fail, got %v", result)
+	}
+
+	detErr, ok := err.(*DeterminizationError)
+	if !ok {
+		t.Fatalf("expected a *DeterminizationError, got %T: %v", err, err)
+	}
+
+	if len(detErr.Leftover) == 0 || detErr.NumUnfilled != 0 {
+		t.Errorf("expected leftover cards and no unfilled positions: %v", detErr)
+	}
+
+	if detErr.State != game.GetState() {
+		t.Errorf("expected error to include the state %v, got %v", game.GetState(), detErr.State)
+	}
+}
+
+func TestSampleDeterminizationWithRand(t *testing.T) {
+	// Two belief states built from the same game sample the same
+	// determinizations from the same seed.
+	sample := func() []cards.Stack {
+		rng := rand.New(rand.NewSource(123))
+		deal := NewRandomDealWithConfig(cards.CoreDeck.AsSlice(), 4, DealConfig{Rand: rng})
+		game := NewGame(deal.DrawPile, deal.P0Deal, deal.P1Deal)
+		game = playRandomActions(rng, game, 6)
+		bs := NewBeliefStateFromInfoSet(uniformPolicy, game.GetInfoSet(gamestate.Player1))
+
+		var result []cards.Stack
+		for i := 0; i < 10; i++ {
+			determinization, err := bs.SampleDeterminizationWithRand(rng)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			result = append(result, determinization.GetDrawPile())
+		}
+
+		return result
+	}
+
+	if first, second := sample(), sample(); !reflect.DeepEqual(first, second) {
+		t.Errorf("expected the same determinizations from the same seed, got %v and %v", first, second)
+	}
+}
+
+func TestWeightedDeterminizationSampler(t *testing.T) {
+	// All cards are in the players' hands, except for a Cat and a Skip
+	// in undetermined positions of the draw pile.
+	remaining := cards.CoreDeck
+	remaining.AddN(cards.Defuse, 3)
+	remaining.Add(cards.ExplodingKitten)
+	remaining.Remove(cards.Cat)
+	remaining.Remove(cards.Skip)
+	hand := remaining.AsSlice()
+	p0Deal := cards.NewSetFromCards(hand[:len(hand)/2])
+	p1Deal := cards.NewSetFromCards(hand[len(hand)/2:])
+	drawPile := cards.NewStackFromCards([]cards.Card{cards.TBD, cards.TBD})
+	bs := &BeliefState{
+		states:     []*GameNode{NewGame(drawPile, p0Deal, p1Deal)},
+		reachProbs: []float32{1.0},
+		deck:       DealConfig{}.deck(),
+	}
+
+	skipOnTop := func(state gamestate.GameState, drawPile cards.Stack) float64 {
+		if drawPile.NthCard(0) == cards.Skip {
+			return 1.0
+		}
+		return 0.0
+	}
+
+	sampler := NewWeightedDeterminizationSampler(skipOnTop, 64)
+	for i := 0; i < 100; i++ {
+		game, err := sampler(bs)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := cards.NewStackFromCards([]cards.Card{cards.Skip, cards.Cat})
+		if game.GetDrawPile() != expected {
+			t.Fatalf("expected draw pile %v, got %v", expected, game.GetDrawPile())
+		}
+	}
+
+	// Without the prior, both arrangements are sampled.
+	seen := make(map[cards.Stack]bool)
+	for i := 0; i < 100; i++ {
+		game, err := UniformDeterminizationSampler(bs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen[game.GetDrawPile()] = true
+	}
+
+	if len(seen) != 2 {
+		t.Errorf("expected 2 distinct draw piles, got %v", seen)
+	}
+}
+
+func TestBeliefValidation(t *testing.T) {
+	rng := rand.New(rand.NewSource(123))
+	for i := 0; i < 10; i++ {
+		deal := NewRandomDeal(cards.CoreDeck.AsSlice(), 4)
+		game := NewGame(deal.DrawPile, deal.P0Deal, deal.P1Deal)
+		game = playRandomActions(rng, game, 6)
+		if game.Type() != cfr.PlayerNodeType {
+			continue
+		}
+
+		is := game.GetInfoSet(gamestate.Player1)
+		initialIS := gamestate.InfoSet{Player: is.Player, Hand: initialHand(is)}
+		beliefs := NewBeliefState(uniformPolicy, initialIS)
+		beliefs.EnableValidation()
+		nDropped := invalidBeliefsDropped.Value()
+		beliefs.Update(is)
+		if n := invalidBeliefsDropped.Value() - nDropped; n != 0 {
+			t.Errorf("%d valid states were dropped", n)
+		}
+	}
+}
+
+func TestBeliefStateTwoDefusesPerPlayer(t *testing.T) {
+	config := DealConfig{DefusesPerPlayer: 2}
+	rng := rand.New(rand.NewSource(123))
+	for i := 0; i < 10; i++ {
+		deal := NewRandomDealWithConfig(cards.CoreDeck.AsSlice(), 4, config)
+		for _, hand := range []cards.Set{deal.P0Deal, deal.P1Deal} {
+			if n := hand.CountOf(cards.Defuse); n != 2 {
+				t.Fatalf("expected 2 Defuses in hand %v, got %d", hand, n)
+			}
+		}
+
+		game := NewGame(deal.DrawPile, deal.P0Deal, deal.P1Deal)
+		is := game.GetInfoSet(gamestate.Player1)
+		if is.Hand != deal.P1Deal {
+			t.Errorf("expected initial hand %v, got %v", deal.P1Deal, is.Hand)
+		}
+
+		beliefs := NewBeliefStateWithConfig(uniformPolicy, is, config)
+		foundOpponentHand := false
+		for _, state := range beliefs.states {
+			if err := validateBelief(state, is, beliefs.deck); err != nil {
+				t.Errorf("invalid initial belief %v: %v", state, err)
+			}
+
+			if state.state.GetPlayerHand(gamestate.Player0) == deal.P0Deal {
+				foundOpponentHand = true
+			}
+		}
+
+		if !foundOpponentHand {
+			t.Errorf("true opponent hand %v is not in belief state", deal.P0Deal)
+		}
+
+		// The standard deck does not account for the extra Defuses.
+		if err := validateBelief(beliefs.states[0], is, DealConfig{}.deck()); err == nil {
+			t.Errorf("expected belief to be invalid for the standard deck")
+		}
+
+		game = playRandomActions(rng, game, 6)
+		if game.Type() != cfr.PlayerNodeType {
+			continue
+		}
+
+		beliefs.EnableValidation()
+		nDropped := invalidBeliefsDropped.Value()
+		beliefs.Update(game.GetInfoSet(gamestate.Player1))
+		if n := invalidBeliefsDropped.Value() - nDropped; n != 0 {
+			t.Errorf("%d valid states were dropped", n)
+		}
+	}
+}
+
+func TestBeliefStateImplodingDeck(t *testing.T) {
+	deckConfig, err := cards.GetDeckConfig("core_imploding")
+	if err != nil {
+		t.Fatal(err)
+	}
+	deck, err := deckConfig.Deck()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := DealConfig{Deck: deck}
+	rng := rand.New(rand.NewSource(123))
+	for i := 0; i < 10; i++ {
+		deal := NewRandomDealWithConfig(deck.AsSlice(), 4, config)
+		game := NewGame(deal.DrawPile, deal.P0Deal, deal.P1Deal)
+		is := game.GetInfoSet(gamestate.Player1)
+		beliefs := NewBeliefStateWithConfig(uniformPolicy, is, config)
+		foundOpponentHand := false
+		for _, state := range beliefs.states {
+			if n := state.state.GetDrawPile().Len(); n != deal.DrawPile.Len() {
+				t.Fatalf("expected draw pile of %d cards, got %d", deal.DrawPile.Len(), n)
+			}
+
+			if err := validateBelief(state, is, beliefs.deck); err != nil {
+				t.Errorf("invalid initial belief %v: %v", state, err)
+			}
+
+			if state.state.GetPlayerHand(gamestate.Player0) == deal.P0Deal {
+				foundOpponentHand = true
+			}
+		}
+
+		if !foundOpponentHand {
+			t.Errorf("true opponent hand %v is not in belief state", deal.P0Deal)
+		}
+
+		// The abstracted draw pile has the same number of cards as the
+		// true draw pile, throughout the game.
+		game = playRandomActions(rng, game, 6)
+		if game.Type() != cfr.PlayerNodeType {
+			continue
+		}
+
+		abstracted := game.InfoSet(game.Player()).(*AbstractedInfoSet)
+		if n := game.GetDrawPile().Len(); abstracted.DrawPile.Len() != n {
+			t.Errorf("expected abstracted draw pile of %d cards, got %v", n, abstracted.DrawPile)
+		}
+
+		beliefs.EnableValidation()
+		nDropped := invalidBeliefsDropped.Value()
+		beliefs.Update(game.GetInfoSet(gamestate.Player1))
+		if n := invalidBeliefsDropped.Value() - nDropped; n != 0 {
+			t.Errorf("%d valid states were dropped", n)
+		}
+	}
+}
+
+func TestBeliefValidationDropsInvalidStates(t *testing.T) {
+	deal := NewRandomDeal(cards.CoreDeck.AsSlice(), 4)
+	game := NewGame(deal.DrawPile, deal.P0Deal, deal.P1Deal)
+	is := game.GetInfoSet(gamestate.Player0)
+	for _, validate := range []bool{false, true} {
+		beliefs := NewBeliefState(uniformPolicy, is)
+		nValid := beliefs.Len()
+
+		// Inject a state in which the opponent holds an extra card,
+		// so that its cards no longer add up to the deck.
+		invalid := beliefs.states[0].GetState()
+		opponentHand := invalid.GetPlayerHand(gamestate.Player1)
+		opponentHand.Add(cards.Cat)
+		bad := beliefs.states[0].CloneWithState(gamestate.New(
+			invalid.GetDrawPile(), invalid.GetPlayerHand(gamestate.Player0), opponentHand))
+		beliefs.states = append(beliefs.states, bad)
+		beliefs.reachProbs = append(beliefs.reachProbs, beliefs.reachProbs[0])
+
+		if validate {
+			beliefs.EnableValidation()
+		}
+
+		nDropped := invalidBeliefsDropped.Value()
+		beliefs.Update(is)
+		expected := nValid + 1
+		if validate {
+			expected = nValid
+			if n := invalidBeliefsDropped.Value() - nDropped; n != 1 {
+				t.Errorf("expected 1 dropped state, got %d", n)
+			}
+		}
+
+		if beliefs.Len() != expected {
+			t.Errorf("validate=%v: expected %d states, got %d", validate, expected, beliefs.Len())
+		}
+	}
+}
+
+func TestEnumerateDrawPileDeterminizations(t *testing.T) {
+	// Positions 0 and 4 of the draw pile are known, and the free cards
+	// {Defuse, Defuse, ExplodingKitten, Slap1x} fill the other 4 positions.
+	free := []cards.Card{cards.Defuse, cards.Defuse, cards.ExplodingKitten, cards.Slap1x}
+	remaining := cards.CoreDeck
+	remaining.AddN(cards.Defuse, 3)
+	remaining.Add(cards.ExplodingKitten)
+	remaining.Remove(cards.Cat)
+	remaining.Remove(cards.Skip)
+	remaining.RemoveAll(cards.NewSetFromCards(free))
+	hand := remaining.AsSlice()
+	p0Deal := cards.NewSetFromCards(hand[:len(hand)/2])
+	p1Deal := cards.NewSetFromCards(hand[len(hand)/2:])
+	drawPile := cards.NewStackFromCards([]cards.Card{
+		cards.Cat, cards.TBD, cards.TBD, cards.TBD, cards.Skip, cards.TBD,
+	})
+	state := gamestate.New(drawPile, p0Deal, p1Deal)
+
+	determinizations := enumerateDrawPileDeterminizations(state, DealConfig{}.deck(), []int{0, 1, 2, 3, 4})
+	for determinized := range determinizations {
+		if determinized.NthCard(0) != cards.Cat || determinized.NthCard(4) != cards.Skip {
+			t.Errorf("known positions were changed: %v", determinized)
+		}
+
+		for i := 1; i < 4; i++ {
+			if determinized.NthCard(i).IsTBD() {
+				t.Errorf("position %d was not determinized: %v", i, determinized)
+			}
+		}
+
+		if !determinized.NthCard(5).IsTBD() {
+			t.Errorf("position 5 should not be determinized: %v", determinized)
+		}
+	}
+
+	// Each of the 4*3*2 ways of filling positions 1-3 is counted once.
+	if total := sumValues(determinizations); total != 24 {
+		t.Errorf("expected 24 total determinizations, got %d", total)
+	}
+
+	// Only the requested positions are determinized.
+	bottom := enumerateDrawPileDeterminizations(state, DealConfig{}.deck(), []int{5})
+	expected := map[cards.Card]int{cards.Defuse: 2, cards.ExplodingKitten: 1, cards.Slap1x: 1}
+	if len(bottom) != len(expected) {
+		t.Errorf("expected %d determinizations of the bottom card, got %v", len(expected), bottom)
+	}
+
+	for determinized, freq := range bottom {
+		bottomCard := determinized.NthCard(5)
+		if freq != expected[bottomCard] {
+			t.Errorf("expected frequency %d for %v, got %d", expected[bottomCard], bottomCard, freq)
+		}
+
+		for _, i := range []int{1, 2, 3} {
+			if !determinized.NthCard(i).IsTBD() {
+				t.Errorf("position %d should not be determinized: %v", i, determinized)
+			}
+		}
+	}
+}
+
+func TestDeterminizeSeenCardsShortDrawPile(t *testing.T) {
+	// All cards are in the players' hands, except for a Cat and a Skip
+	// in undetermined positions of the draw pile.
+	remaining := cards.CoreDeck
+	remaining.AddN(cards.Defuse, 3)
+	remaining.Add(cards.ExplodingKitten)
+	remaining.Remove(cards.Cat)
+	remaining.Remove(cards.Skip)
+	hand := remaining.AsSlice()
+	p0Deal := cards.NewSetFromCards(hand[:len(hand)/2])
+	p1Deal := cards.NewSetFromCards(hand[len(hand)/2:])
+	drawPile := cards.NewStackFromCards([]cards.Card{cards.TBD, cards.TBD})
+	newBeliefs := func() *BeliefState {
+		return &BeliefState{
+			states:     []*GameNode{NewGame(drawPile, p0Deal, p1Deal)},
+			reachProbs: []float32{1.0},
+			deck:       DealConfig{}.deck(),
+		}
+	}
+
+	// Only the 2 cards in the draw pile are seen.
+	bs := newBeliefs()
+	bs.determinizeSeenCards([3]cards.Card{cards.Skip, cards.Cat, cards.Unknown})
+	expected := cards.NewStackFromCards([]cards.Card{cards.Skip, cards.Cat})
+	if bs.Len() != 1 || bs.states[0].GetDrawPile() != expected {
+		t.Errorf("expected a single state with draw pile %v, got %v", expected, bs.states)
+	}
+
+	// The opponent did not see the cards, so either order is possible.
+	bs = newBeliefs()
+	bs.determinizeTopKCards(3)
+	if bs.Len() != 2 {
+		t.Errorf("expected 2 states, got %v", bs.states)
+	}
+
+	for _, game := range bs.states {
+		if drawPile := game.GetDrawPile(); drawPile.Len() != 2 || len(drawPile.TBDPositions()) != 0 {
+			t.Errorf("expected a fully determinized draw pile of 2 cards, got %v", drawPile)
+		}
+	}
+}
+
+func TestBeliefStateForcedOutcome(t *testing.T) {
+	drawPile := cards.NewStackFromCards([]cards.Card{cards.ExplodingKitten, cards.Cat})
+	p0Deal := cards.NewSetFromCards([]cards.Card{cards.Skip})
+	game := NewGame(drawPile, p0Deal, cards.NewSet())
+	forcedGame := playCard(t, game, cards.Skip)
+	newBeliefs := func(states ...*GameNode) *BeliefState {
+		return &BeliefState{states: states, reachProbs: uniformDistribution(len(states))}
+	}
+
+	// Player1 has no cards and must draw the kitten.
+	winner, forced := newBeliefs(forcedGame).ForcedOutcome()
+	if !forced || winner != gamestate.Player0 {
+		t.Errorf("expected forced win for %v, got %v (forced = %v)",
+			gamestate.Player0, winner, forced)
+	}
+
+	// The outcome is only forced in one of the states.
+	if winner, forced := newBeliefs(forcedGame, game).ForcedOutcome(); forced {
+		t.Errorf("expected outcome to not be forced, got %v wins", winner)
+	}
+
+	// The outcome would be forced if the kitten is on top of the draw pile,
+	// but it may be in any undetermined position.
+	tbdDrawPile := cards.NewStackFromCards([]cards.Card{cards.TBD, cards.TBD})
+	tbdGame := playCard(t, NewGame(tbdDrawPile, p0Deal, cards.NewSet()), cards.Skip)
+	if winner, forced := newBeliefs(tbdGame).ForcedOutcome(); forced {
+		t.Errorf("expected outcome to not be forced, got %v wins", winner)
+	}
+}
@@ -0,0 +1,101 @@
+package alphacats
+
+import (
+	"github.com/timpalpant/go-cfr"
+)
+
+// BestResponsePolicy plays a best response to a fixed opponent policy.
+//
+// The best response is computed within the determinized subgame rooted at
+// the node it is queried with: the expected utility of each action is found by
+// expanding the game tree to the end, weighting the opponent's decisions by their
+// policy and chance outcomes by their probability.
+//
+// NOTE: The best response is clairvoyant. When it plays a real game, the node
+// it is queried with holds the true hidden state, so it sees the opponent's hand
+// and the order of the draw pile. It is therefore not a legal strategy, and its
+// win rate is an upper bound on what an imperfect-information best response
+// could achieve. This is still useful to measure how exploitable a policy is.
+//
+// The full game tree is traversed on every query, which is only tractable
+// for small decks such as cards.TestDeck.
+type BestResponsePolicy struct {
+	player         int
+	opponentPolicy func(cfr.GameTreeNode) []float32
+}
+
+func NewBestResponsePolicy(player int, opponentPolicy func(cfr.GameTreeNode) []float32) *BestResponsePolicy {
+	return &BestResponsePolicy{
+		player:         player,
+		opponentPolicy: opponentPolicy,
+	}
+}
+
+// GetPolicy implements mcts.Policy. The returned policy places all weight
+// on the action with the highest expected utility.
+func (br *BestResponsePolicy) GetPolicy(node cfr.GameTreeNode) []float32 {
+	values := br.ActionValues(node)
+	policy := make([]float32, len(values))
+	policy[argmax(values)] = 1.0
+	return policy
+}
+
+// ActionValues returns the expected utility of taking each action at the
+// given node and then playing a best response for the remainder of the game.
+func (br *BestResponsePolicy) ActionValues(node cfr.GameTreeNode) []float64 {
+	values := make([]float64, node.NumChildren())
+	for i := range values {
+		child := node.GetChild(i)
+		values[i] = br.Value(child)
+		child.Close()
+	}
+
+	return values
+}
+
+// Value returns the expected utility of the best response from the given node.
+func (br *BestResponsePolicy) Value(node cfr.GameTreeNode) float64 {
+	switch node.Type() {
+	case cfr.TerminalNodeType:
+		return node.Utility(br.player)
+	case cfr.ChanceNodeType:
+		ev := 0.0
+		for i := 0; i < node.NumChildren(); i++ {
+			p := node.GetChildProbability(i)
+			child := node.GetChild(i)
+			ev += p * br.Value(child)
+			child.Close()
+		}
+		return ev
+	}
+
+	if node.Player() == br.player {
+		values := br.ActionValues(node)
+		return values[argmax(values)]
+	}
+
+	policy := br.opponentPolicy(node)
+	ev := 0.0
+	for i, p := range policy {
+		if p == 0 {
+			continue
+		}
+
+		child := node.GetChild(i)
+		ev += float64(p) * br.Value(child)
+		child.Close()
+	}
+
+	return ev
+}
+
+func argmax(vs []float64) int {
+	best := 0
+	for i, v := range vs {
+		if v > vs[best] {
+			best = i
+		}
+	}
+
+	return best
+}
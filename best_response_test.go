@@ -0,0 +1,59 @@
+package alphacats
+
+import (
+	"testing"
+
+	"github.com/timpalpant/go-cfr"
+
+	"github.com/timpalpant/alphacats/internal/testgames"
+)
+
+func newTestDeckGame() *GameNode {
+	return NewGame(testgames.TestDeck())
+}
+
+func uniformPolicy(node cfr.GameTreeNode) []float32 {
+	return uniformDistribution(node.NumChildren())
+}
+
+func TestBestResponse(t *testing.T) {
+	for player := 0; player < 2; player++ {
+		br := NewBestResponsePolicy(player, uniformPolicy)
+		brValue := br.Value(newTestDeckGame())
+		uniformValue := ExpectedUtility(uniformPolicy, newTestDeckGame(), player)
+		t.Logf("Player %d: best response value = %v, uniform value = %v",
+			player, brValue, uniformValue)
+		if brValue < uniformValue {
+			t.Errorf("player %d: best response (%v) does worse than uniform play (%v)",
+				player, brValue, uniformValue)
+		}
+
+		// Policies are float32, so allow for some rounding error.
+		if brValue < -1.0-1e-6 || brValue > 1.0+1e-6 {
+			t.Errorf("player %d: best response value %v is not a valid utility", player, brValue)
+		}
+	}
+}
+
+func TestBestResponsePolicy(t *testing.T) {
+	game := newTestDeckGame()
+	br := NewBestResponsePolicy(0, uniformPolicy)
+	values := br.ActionValues(game)
+	policy := br.GetPolicy(game)
+	if len(policy) != game.NumChildren() {
+		t.Fatalf("expected %d actions, got %d", game.NumChildren(), len(policy))
+	}
+
+	for i, p := range policy {
+		if p == 1.0 {
+			for j, v := range values {
+				if v > values[i] {
+					t.Errorf("selected action %d (value %v) but action %d has value %v",
+						i, values[i], j, v)
+				}
+			}
+		} else if p != 0.0 {
+			t.Errorf("best response should be pure, got %v", policy)
+		}
+	}
+}
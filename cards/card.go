@@ -1,8 +1,17 @@
 package cards
 
+import (
+	"fmt"
+)
+
 // Card represents one card from the Exploding Kittens game deck.
 type Card uint8
 
+// Unknown and TBD are both placeholders for the identity of a card, but with
+// different meanings. A card is Unknown if its identity is hidden (e.g. from
+// one of the players), whereas TBD marks a position (e.g. in the draw pile)
+// which has not been determinized yet, and must be filled in with one of
+// the cards not otherwise accounted for.
 const (
 	Unknown Card = iota
 	ExplodingKitten
@@ -14,6 +23,10 @@ const (
 	Shuffle
 	DrawFromTheBottom
 	Cat
+	// From the Imploding Kittens expansion. It cannot be defused: the first
+	// time it is drawn it is put back face up, and the second time it is
+	// drawn the player loses.
+	ImplodingKitten
 	// Placeholder used for cards that have not been determinized yet.
 	TBD
 )
@@ -29,6 +42,7 @@ var cardStr = [...]string{
 	"Shuffle",
 	"DrawFromTheBottom",
 	"Cat",
+	"ImplodingKitten",
 	"TBD",
 }
 
@@ -39,3 +53,83 @@ func (c Card) String() string {
 
 // The number of distinct types of Cards.
 const NumTypes = len(cardStr)
+
+// ParseCard returns the Card with the given name, as returned by String.
+func ParseCard(name string) (Card, error) {
+	for i, s := range cardStr {
+		if s == name {
+			return Card(i), nil
+		}
+	}
+
+	return Unknown, fmt.Errorf("unknown card: %q", name)
+}
+
+// CardCategory groups cards by their role in the game.
+type CardCategory uint8
+
+const (
+	// Unknown and TBD are placeholders, not real cards.
+	PlaceholderCard CardCategory = iota
+	// Action cards have an effect when played.
+	ActionCard
+	// Cat cards may be played to take a card from the other player.
+	CatCard
+	DefuseCard
+	KittenCard
+)
+
+var cardCategoryStr = [...]string{
+	"Placeholder",
+	"Action",
+	"Cat",
+	"Defuse",
+	"Kitten",
+}
+
+func (c CardCategory) String() string {
+	return cardCategoryStr[c]
+}
+
+var cardCategories = [...]CardCategory{
+	Unknown:           PlaceholderCard,
+	ExplodingKitten:   KittenCard,
+	Defuse:            DefuseCard,
+	Skip:              ActionCard,
+	Slap1x:            ActionCard,
+	Slap2x:            ActionCard,
+	SeeTheFuture:      ActionCard,
+	Shuffle:           ActionCard,
+	DrawFromTheBottom: ActionCard,
+	Cat:               CatCard,
+	ImplodingKitten:   KittenCard,
+	TBD:               PlaceholderCard,
+}
+
+// Category returns the category of the card.
+func (c Card) Category() CardCategory {
+	return cardCategories[c]
+}
+
+// IsPlaceholder reports whether c is Unknown or TBD, rather than a real card.
+func (c Card) IsPlaceholder() bool {
+	return c.Category() == PlaceholderCard
+}
+
+// IsTBD reports whether c marks a position that has not been determinized yet.
+func (c Card) IsTBD() bool {
+	return c == TBD
+}
+
+// IsUnknown reports whether the identity of c is hidden.
+func (c Card) IsUnknown() bool {
+	return c == Unknown
+}
+
+func (c Card) IsActionCard() bool {
+	return c.Category() == ActionCard
+}
+
+func (c Card) IsCatCard() bool {
+	return c.Category() == CatCard
+}
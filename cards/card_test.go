@@ -0,0 +1,56 @@
+package cards
+
+import (
+	"testing"
+)
+
+func TestCategory(t *testing.T) {
+	expected := map[Card]CardCategory{
+		Unknown:           PlaceholderCard,
+		ExplodingKitten:   KittenCard,
+		Defuse:            DefuseCard,
+		Skip:              ActionCard,
+		Slap1x:            ActionCard,
+		Slap2x:            ActionCard,
+		SeeTheFuture:      ActionCard,
+		Shuffle:           ActionCard,
+		DrawFromTheBottom: ActionCard,
+		Cat:               CatCard,
+		ImplodingKitten:   KittenCard,
+		TBD:               PlaceholderCard,
+	}
+
+	if len(expected) != NumTypes {
+		t.Fatalf("expected categories for all %d cards, got %d", NumTypes, len(expected))
+	}
+
+	for card, category := range expected {
+		if card.Category() != category {
+			t.Errorf("expected %v to be a %v card, got %v", card, category, card.Category())
+		}
+
+		if card.IsActionCard() != (category == ActionCard) {
+			t.Errorf("%v: IsActionCard() = %v", card, card.IsActionCard())
+		}
+
+		if card.IsCatCard() != (category == CatCard) {
+			t.Errorf("%v: IsCatCard() = %v", card, card.IsCatCard())
+		}
+	}
+}
+
+func TestPlaceholders(t *testing.T) {
+	for card := Card(0); int(card) < NumTypes; card++ {
+		if card.IsUnknown() != (card == Unknown) {
+			t.Errorf("%v: IsUnknown() = %v", card, card.IsUnknown())
+		}
+
+		if card.IsTBD() != (card == TBD) {
+			t.Errorf("%v: IsTBD() = %v", card, card.IsTBD())
+		}
+
+		if card.IsPlaceholder() != (card == Unknown || card == TBD) {
+			t.Errorf("%v: IsPlaceholder() = %v", card, card.IsPlaceholder())
+		}
+	}
+}
@@ -1,5 +1,10 @@
 package cards
 
+import (
+	"fmt"
+	"sort"
+)
+
 // Cards in the 2-player core deck in the iOS app.
 // Does not include the ExplodingKitten, or the Defuse
 var CoreDeck = NewSetFromCards([]Card{
@@ -17,3 +22,253 @@ var TestDeck = NewSetFromCards([]Card{
 	SeeTheFuture, Slap1x, Slap2x,
 	Skip, DrawFromTheBottom, Cat,
 })
+
+// The number of players in the game.
+const numPlayers = 2
+
+// DeckConfig is a named composition of the deck.
+//
+// The composition may include cards from the physical game and its
+// expansions which are not implemented (yet), so that presets can be
+// declared in full. They are an error when the deck is built.
+type DeckConfig struct {
+	Name string
+	// The number of each card in the deck, by name (see ParseCard).
+	// Includes the ExplodingKittens and Defuses.
+	Cards map[string]int
+}
+
+// DeckConfigs are the known presets, selectable by name with GetDeckConfig.
+var DeckConfigs = []DeckConfig{
+	{
+		Name: "core",
+		Cards: map[string]int{
+			"ExplodingKitten":   1,
+			"Defuse":            3,
+			"Skip":              5,
+			"Slap1x":            3,
+			"Slap2x":            1,
+			"SeeTheFuture":      3,
+			"Shuffle":           2,
+			"DrawFromTheBottom": 2,
+			"Cat":               3,
+		},
+	},
+	{
+		Name: "test",
+		Cards: map[string]int{
+			"ExplodingKitten":   1,
+			"Defuse":            3,
+			"Skip":              1,
+			"Slap1x":            1,
+			"Slap2x":            1,
+			"SeeTheFuture":      1,
+			"DrawFromTheBottom": 1,
+			"Cat":               1,
+		},
+	},
+	{
+		// The core deck with the Imploding Kitten added. It is shuffled into
+		// the draw pile along with the ExplodingKitten (see ImplodingKitten).
+		Name: "core_imploding",
+		Cards: map[string]int{
+			"ExplodingKitten":   1,
+			"ImplodingKitten":   1,
+			"Defuse":            3,
+			"Skip":              5,
+			"Slap1x":            3,
+			"Slap2x":            1,
+			"SeeTheFuture":      3,
+			"Shuffle":           2,
+			"DrawFromTheBottom": 2,
+			"Cat":               3,
+		},
+	},
+	{
+		// The NSFW deck has the same composition as the original deck.
+		// The five kinds of Cat cards differ only in art, so they are
+		// counted together.
+		Name: "nsfw",
+		Cards: map[string]int{
+			"ExplodingKitten": 4,
+			"Defuse":          6,
+			"Attack":          4,
+			"Skip":            4,
+			"Favor":           4,
+			"Shuffle":         4,
+			"SeeTheFuture":    5,
+			"Nope":            5,
+			"Cat":             20,
+		},
+	},
+	{
+		// The original deck with the Imploding Kittens expansion.
+		Name: "imploding",
+		Cards: map[string]int{
+			"ExplodingKitten":   4,
+			"ImplodingKitten":   1,
+			"Defuse":            6,
+			"Attack":            4,
+			"TargetedAttack":    3,
+			"Skip":              4,
+			"Favor":             4,
+			"Shuffle":           4,
+			"SeeTheFuture":      5,
+			"AlterTheFuture":    4,
+			"DrawFromTheBottom": 4,
+			"Reverse":           4,
+			"Nope":              5,
+			"Cat":               20,
+			"FeralCat":          4,
+		},
+	},
+}
+
+// GetDeckConfig returns a copy of the preset with the given name, which
+// may be modified without changing the preset.
+func GetDeckConfig(name string) (DeckConfig, error) {
+	for _, config := range DeckConfigs {
+		if config.Name == name {
+			result := DeckConfig{Name: config.Name, Cards: make(map[string]int, len(config.Cards))}
+			for card, count := range config.Cards {
+				result.Cards[card] = count
+			}
+
+			return result, nil
+		}
+	}
+
+	return DeckConfig{}, fmt.Errorf("unknown deck type: %q", name)
+}
+
+// Len returns the total number of cards in the deck.
+func (c DeckConfig) Len() int {
+	n := 0
+	for _, count := range c.Cards {
+		n += count
+	}
+
+	return n
+}
+
+// Deck returns the cards in the deck, not including the ExplodingKittens
+// and Defuses: the ExplodingKitten and Defuses needed for a game are added
+// when the cards are dealt (see CoreDeck), and any extras are removed from
+// the deck, as in the rules for a 2-player game.
+//
+// An error is returned if the deck includes cards that are not implemented,
+// or does not have enough ExplodingKittens and Defuses for the players:
+// one fewer ExplodingKitten than players, and a Defuse for each player
+// plus one more in the draw pile.
+func (c DeckConfig) Deck() (Set, error) {
+	names := make([]string, 0, len(c.Cards))
+	for name := range c.Cards {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var result Set
+	for _, name := range names {
+		card, err := ParseCard(name)
+		if err != nil || card.IsPlaceholder() {
+			return Set(0), fmt.Errorf("deck %q: %s is not implemented", c.Name, name)
+		}
+
+		count := c.Cards[name]
+		if count < 0 || count > MaxCountOf(card) {
+			return Set(0), fmt.Errorf("deck %q: invalid number of %v: %d", c.Name, card, count)
+		}
+
+		result.AddN(card, count)
+	}
+
+	if n := result.CountOf(ExplodingKitten); int(n) < numPlayers-1 {
+		return Set(0), fmt.Errorf("deck %q: need %d ExplodingKittens for %d players, got %d",
+			c.Name, numPlayers-1, numPlayers, n)
+	}
+	if n := result.CountOf(Defuse); int(n) < numPlayers+1 {
+		return Set(0), fmt.Errorf("deck %q: need %d Defuses for %d players, got %d",
+			c.Name, numPlayers+1, numPlayers, n)
+	}
+
+	result.RemoveN(ExplodingKitten, int(result.CountOf(ExplodingKitten)))
+	result.RemoveN(Defuse, int(result.CountOf(Defuse)))
+	return result, nil
+}
+
+// gameDeck returns all of the cards in play in a game dealt from the deck,
+// with defusesPerPlayer Defuses dealt to each player and one more in the
+// draw pile, including the ExplodingKitten (see Deck).
+func (c DeckConfig) gameDeck(defusesPerPlayer int) (Set, error) {
+	result, err := c.Deck()
+	if err != nil {
+		return Set(0), err
+	}
+
+	result.AddN(ExplodingKitten, numPlayers-1)
+	result.AddN(Defuse, numPlayers*defusesPerPlayer+1)
+	return result, nil
+}
+
+// IsValidDeck returns whether the set contains exactly the cards in play
+// in a game dealt from the given deck with defusesPerPlayer Defuses per
+// player, including the ExplodingKitten and Defuses.
+func (s Set) IsValidDeck(config DeckConfig, defusesPerPlayer int) bool {
+	deck, err := config.gameDeck(defusesPerPlayer)
+	return err == nil && s == deck
+}
+
+// ValidateGameSetup returns an error if the given draw pile and deals are
+// not a legal start to a game with the given deck: each player must be
+// dealt the same number of cards, including defusesPerPlayer Defuses
+// (see alphacats.DealConfig), and the draw pile must contain the
+// ExplodingKitten (and ImplodingKitten, if the deck has one) and the
+// remaining cards in the deck.
+//
+// It is intended to catch mistakes in setups that are built by hand.
+func ValidateGameSetup(drawPile Stack, p0Deal, p1Deal Set, config DeckConfig, defusesPerPlayer int) error {
+	if defusesPerPlayer <= 0 {
+		return fmt.Errorf("must deal at least 1 Defuse per player, got %d", defusesPerPlayer)
+	}
+
+	deck, err := config.gameDeck(defusesPerPlayer)
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < drawPile.Len(); i++ {
+		if card := drawPile.NthCard(i); card.IsPlaceholder() {
+			return fmt.Errorf("draw pile has %v card at position %d", card, i)
+		}
+	}
+
+	if p0Deal.Len() != p1Deal.Len() {
+		return fmt.Errorf("players dealt different numbers of cards: %d and %d",
+			p0Deal.Len(), p1Deal.Len())
+	}
+
+	for player, deal := range []Set{p0Deal, p1Deal} {
+		if n := int(deal.CountOf(Defuse)); n != defusesPerPlayer {
+			return fmt.Errorf("player %d dealt %d Defuses, expected %d", player, n, defusesPerPlayer)
+		}
+		if deal.Contains(ExplodingKitten) {
+			return fmt.Errorf("player %d dealt an ExplodingKitten", player)
+		}
+		if deal.Contains(ImplodingKitten) {
+			return fmt.Errorf("player %d dealt the ImplodingKitten", player)
+		}
+	}
+
+	if n := drawPile.CountOf(ExplodingKitten); n != numPlayers-1 {
+		return fmt.Errorf("draw pile has %d ExplodingKittens, expected %d", n, numPlayers-1)
+	}
+
+	all := drawPile.ToSet()
+	all.AddAll(p0Deal)
+	all.AddAll(p1Deal)
+	if all != deck {
+		return fmt.Errorf("cards in play %v do not match deck %q: %v", all, config.Name, deck)
+	}
+
+	return nil
+}
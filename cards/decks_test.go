@@ -0,0 +1,216 @@
+package cards
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDeckConfigs(t *testing.T) {
+	coreImploding := CoreDeck
+	coreImploding.Add(ImplodingKitten)
+	testCases := []struct {
+		name          string
+		total         int
+		expected      Set
+		unimplemented string
+	}{
+		{name: "core", total: 23, expected: CoreDeck},
+		{name: "core_imploding", total: 24, expected: coreImploding},
+		{name: "test", total: 10, expected: TestDeck},
+		{name: "nsfw", total: 56, unimplemented: "Attack"},
+		{name: "imploding", total: 76, unimplemented: "AlterTheFuture"},
+	}
+
+	for _, tc := range testCases {
+		config, err := GetDeckConfig(tc.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if config.Len() != tc.total {
+			t.Errorf("%s: expected %d cards, got %d", tc.name, tc.total, config.Len())
+		}
+
+		deck, err := config.Deck()
+		if tc.unimplemented != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.unimplemented) {
+				t.Errorf("%s: expected error for unimplemented %s, got %v",
+					tc.name, tc.unimplemented, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+		}
+		if deck != tc.expected {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.expected, deck)
+		}
+		// The ExplodingKitten and 3 Defuses are added when dealing.
+		if deck.Len()+4 != tc.total {
+			t.Errorf("%s: expected %d cards after removing kitten and defuses, got %d",
+				tc.name, tc.total-4, deck.Len())
+		}
+	}
+
+	if len(testCases) != len(DeckConfigs) {
+		t.Errorf("expected %d presets, got %d", len(testCases), len(DeckConfigs))
+	}
+}
+
+func TestDeckConfigRequiresDefuses(t *testing.T) {
+	config := DeckConfig{
+		Name:  "no-defuse",
+		Cards: map[string]int{"ExplodingKitten": 1, "Defuse": 2, "Cat": 10},
+	}
+
+	if _, err := config.Deck(); err == nil {
+		t.Error("expected error for deck with too few Defuses")
+	}
+}
+
+func TestGetDeckConfigUnknown(t *testing.T) {
+	if _, err := GetDeckConfig("party"); err == nil {
+		t.Error("expected error for unknown deck type")
+	}
+}
+
+func TestDecksNotAliased(t *testing.T) {
+	for _, deck := range []*Set{&CoreDeck, &TestDeck} {
+		original := *deck
+		// Many callers start from one of the decks and modify it.
+		cards := *deck
+		cards.Remove(Skip)
+		cards.Add(Defuse)
+		cards.AddAll(original)
+		if *deck != original {
+			t.Errorf("modifying a copy of %v changed it to %v", original, *deck)
+		}
+	}
+
+	config, err := GetDeckConfig("core")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Cards["Cat"] = 0
+	if config, _ := GetDeckConfig("core"); config.Cards["Cat"] != 3 {
+		t.Errorf("modifying a copy of the core preset changed it to %v", config)
+	}
+}
+
+func TestValidateGameSetup(t *testing.T) {
+	config, err := GetDeckConfig("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	validDrawPile := NewStackFromCards([]Card{Cat, ExplodingKitten, DrawFromTheBottom, Defuse})
+	validP0 := NewSetFromCards([]Card{SeeTheFuture, Slap1x, Defuse})
+	validP1 := NewSetFromCards([]Card{Slap2x, Skip, Defuse})
+
+	testCases := []struct {
+		name     string
+		drawPile Stack
+		p0Deal   Set
+		p1Deal   Set
+		valid    bool
+	}{
+		{"valid", validDrawPile, validP0, validP1, true},
+		{
+			"missing defuse",
+			NewStackFromCards([]Card{Cat, ExplodingKitten, DrawFromTheBottom, Defuse, Defuse}),
+			NewSetFromCards([]Card{SeeTheFuture, Slap1x}),
+			validP1,
+			false,
+		},
+		{
+			"extra kitten",
+			NewStackFromCards([]Card{Cat, ExplodingKitten, DrawFromTheBottom, ExplodingKitten, Defuse}),
+			validP0, validP1, false,
+		},
+		{
+			"kitten in hand",
+			NewStackFromCards([]Card{Cat, Slap1x, DrawFromTheBottom, Defuse}),
+			NewSetFromCards([]Card{SeeTheFuture, ExplodingKitten, Defuse}),
+			validP1,
+			false,
+		},
+		{
+			"wrong hand size",
+			NewStackFromCards([]Card{ExplodingKitten, DrawFromTheBottom, Defuse}),
+			NewSetFromCards([]Card{SeeTheFuture, Slap1x, Cat, Defuse}),
+			validP1,
+			false,
+		},
+		{
+			"missing card",
+			NewStackFromCards([]Card{ExplodingKitten, DrawFromTheBottom, Defuse}),
+			validP0, validP1, false,
+		},
+		{
+			"duplicate card",
+			NewStackFromCards([]Card{Cat, ExplodingKitten, Cat, Defuse}),
+			validP0, validP1, false,
+		},
+		{
+			"unknown card",
+			NewStackFromCards([]Card{Cat, ExplodingKitten, Unknown, Defuse}),
+			validP0, validP1, false,
+		},
+	}
+
+	for _, tc := range testCases {
+		err := ValidateGameSetup(tc.drawPile, tc.p0Deal, tc.p1Deal, config, 1)
+		if tc.valid && err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+		} else if !tc.valid && err == nil {
+			t.Errorf("%s: expected error", tc.name)
+		}
+	}
+
+	deck := validDrawPile.ToSet()
+	deck.AddAll(validP0)
+	deck.AddAll(validP1)
+	if !deck.IsValidDeck(config, 1) {
+		t.Errorf("%v should be a valid %q deck", deck, config.Name)
+	}
+	deck.Remove(Cat)
+	if deck.IsValidDeck(config, 1) {
+		t.Errorf("%v should not be a valid %q deck", deck, config.Name)
+	}
+
+	// The ImplodingKitten must be in the draw pile, not dealt.
+	config.Cards["ImplodingKitten"] = 1
+	drawPile := NewStackFromCards([]Card{Cat, ExplodingKitten, DrawFromTheBottom, ImplodingKitten, Defuse})
+	if err := ValidateGameSetup(drawPile, validP0, validP1, config, 1); err != nil {
+		t.Errorf("unexpected error with ImplodingKitten in draw pile: %v", err)
+	}
+	p0Deal := NewSetFromCards([]Card{SeeTheFuture, ImplodingKitten, Defuse})
+	drawPile = NewStackFromCards([]Card{Cat, ExplodingKitten, DrawFromTheBottom, Slap1x, Defuse})
+	if err := ValidateGameSetup(drawPile, p0Deal, validP1, config, 1); err == nil {
+		t.Error("expected error with ImplodingKitten dealt to player 0")
+	}
+	delete(config.Cards, "ImplodingKitten")
+
+	// With 2 Defuses per player, 5 Defuses are in play.
+	p0Deal = NewSetFromCards([]Card{SeeTheFuture, Slap1x, Defuse, Defuse})
+	p1Deal := NewSetFromCards([]Card{Slap2x, Skip, Defuse, Defuse})
+	if err := ValidateGameSetup(validDrawPile, p0Deal, p1Deal, config, 2); err != nil {
+		t.Errorf("unexpected error with 2 Defuses per player: %v", err)
+	}
+	if err := ValidateGameSetup(validDrawPile, p0Deal, p1Deal, config, 1); err == nil {
+		t.Error("expected error with 2 Defuses per player when 1 is expected")
+	}
+	if err := ValidateGameSetup(validDrawPile, validP0, validP1, config, 2); err == nil {
+		t.Error("expected error with 1 Defuse per player when 2 are expected")
+	}
+	deck = validDrawPile.ToSet()
+	deck.AddAll(p0Deal)
+	deck.AddAll(p1Deal)
+	if !deck.IsValidDeck(config, 2) {
+		t.Errorf("%v should be a valid %q deck with 2 Defuses per player", deck, config.Name)
+	}
+	if err := ValidateGameSetup(validDrawPile, validP0, validP1, config, 0); err == nil {
+		t.Error("expected error with no Defuses per player")
+	}
+}
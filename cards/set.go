@@ -2,21 +2,26 @@ package cards
 
 import (
 	"fmt"
+	"math/rand"
+	"strconv"
 	"strings"
 )
 
 const (
-	bitsPerCardCount uint = 6
-	maxCountPerType       = (1 << bitsPerCardCount) - 1
+	bitsPerCardCount uint = 5
 	mask                  = Set(1<<bitsPerCardCount) - 1
+
+	// MaxCountPerType is the maximum number of any one type of Card that
+	// may be held in a Set. See MaxCountOf.
+	MaxCountPerType = (1 << bitsPerCardCount) - 1
 )
 
 // Set represents an unordered set of cards.
 // Set[Card] is the number of that Card in the set.
 //
-// The maximum value for a single type of Card is 63.
+// The maximum value for a single type of Card is 31.
 // Therefore the counts for all Cards can fit in a single uint64:
-// 6 bits per Card x 10 types of Cards = 60 bits.
+// 5 bits per Card x 12 types of Cards = 60 bits.
 type Set uint64
 
 func NewSet() Set {
@@ -33,6 +38,17 @@ func NewSetFromCards(cards []Card) Set {
 	return result
 }
 
+// MaxCountOf returns the maximum number of the given type of Card that
+// may be held in a Set.
+func MaxCountOf(card Card) int {
+	shift := uint(card) * bitsPerCardCount
+	if remaining := 64 - shift; remaining < bitsPerCardCount {
+		return (1 << remaining) - 1
+	}
+
+	return MaxCountPerType
+}
+
 // IsEmpty returns whether this Set contains any Cards.
 func (s Set) IsEmpty() bool {
 	return s == 0
@@ -58,6 +74,36 @@ func (s Set) Counts() map[Card]uint8 {
 	return result
 }
 
+// ToCountVector returns the number of each type of Card in the Set,
+// indexed by Card. Unlike Counts, cards with a zero count are included,
+// so the result always has length NumTypes.
+func (s Set) ToCountVector() []uint8 {
+	result := make([]uint8, NumTypes)
+	for card := range result {
+		result[card] = s.CountOf(Card(card))
+	}
+
+	return result
+}
+
+// SetFromCountVector is the inverse of ToCountVector.
+// SetFromCountVector panics if counts has more than NumTypes entries,
+// or if any count exceeds the capacity of the Set (see MaxCountOf).
+func SetFromCountVector(counts []uint8) Set {
+	if len(counts) > NumTypes {
+		panic(fmt.Errorf("count vector has %d entries, expected at most %d", len(counts), NumTypes))
+	}
+
+	result := NewSet()
+	for card, count := range counts {
+		result.AddN(Card(card), int(count))
+	}
+
+	return result
+}
+
+// Iter calls cb for each distinct card in the set with its count.
+// Cards are always visited in ascending order.
 func (s Set) Iter(cb func(card Card, count uint8)) {
 	for card := Card(0); s > 0; card++ {
 		count := uint8(s & mask)
@@ -86,6 +132,41 @@ func (s Set) Distinct() []Card {
 	return result
 }
 
+// MostCommon returns the Card with the highest count in the Set, and its
+// count. Ties are broken in favor of the lowest Card. If the Set is empty,
+// MostCommon returns (Unknown, 0).
+func (s Set) MostCommon() (Card, uint8) {
+	best, bestCount := Unknown, uint8(0)
+	s.Iter(func(card Card, count uint8) {
+		if count > bestCount {
+			best, bestCount = card, count
+		}
+	})
+
+	return best, bestCount
+}
+
+// SampleWeighted returns a random Card from the Set, with each type of Card
+// chosen in proportion to its count. If the Set is empty, SampleWeighted
+// returns Unknown.
+func (s Set) SampleWeighted(r *rand.Rand) Card {
+	n := s.Len()
+	if n == 0 {
+		return Unknown
+	}
+
+	k := r.Intn(n)
+	result := Unknown
+	s.Iter(func(card Card, count uint8) {
+		if k >= 0 && k < int(count) {
+			result = card
+		}
+		k -= int(count)
+	})
+
+	return result
+}
+
 // AsSlice returns a slice of Cards with the given number of each
 // Card as found in this Set.
 func (s Set) AsSlice() []Card {
@@ -104,7 +185,14 @@ func (s *Set) Add(card Card) {
 	s.AddN(card, 1)
 }
 
+// AddN includes n of the given Card in the Set.
+// AddN panics if the Set would exceed its capacity for the Card.
 func (s *Set) AddN(card Card, n int) {
+	if int(s.CountOf(card))+n > MaxCountOf(card) {
+		panic(fmt.Errorf("cannot add %d %v cards to set with %d (max %d)",
+			n, card, s.CountOf(card), MaxCountOf(card)))
+	}
+
 	shift := uint(card) * bitsPerCardCount
 	*s += Set(n << shift)
 }
@@ -115,13 +203,26 @@ func (s *Set) Remove(card Card) {
 	s.RemoveN(card, 1)
 }
 
+// RemoveChecked is like Remove, but returns an error rather than panicking
+// if the card is not present in the Set. The Set is unchanged on error.
+func (s *Set) RemoveChecked(card Card) error {
+	return s.removeN(card, 1)
+}
+
 func (s *Set) RemoveN(card Card, n int) {
+	if err := s.removeN(card, n); err != nil {
+		panic(err)
+	}
+}
+
+func (s *Set) removeN(card Card, n int) error {
 	if int(s.CountOf(card)) < n {
-		panic(fmt.Errorf("card %v not in set", card))
+		return fmt.Errorf("card %v not in set", card)
 	}
 
 	shift := uint(card) * bitsPerCardCount
 	*s -= Set(n << shift)
+	return nil
 }
 
 // AddAll adds the given cards to the Set.
@@ -132,14 +233,25 @@ func (s *Set) AddAll(cards Set) {
 // RemoveAll removes the given cards from the set.
 // RemoveAll panics if the cards are not present to be removed.
 func (s *Set) RemoveAll(cards Set) {
+	if err := s.SubtractChecked(cards); err != nil {
+		panic(err)
+	}
+}
+
+// SubtractChecked is like RemoveAll, but returns an error rather than
+// panicking if the cards are not present to be removed. Since the counts
+// are packed together, subtracting a count that underflows would corrupt
+// the counts of the other cards, so the Set is left unchanged on error.
+func (s *Set) SubtractChecked(other Set) error {
 	for card := Card(0); card < Card(NumTypes); card++ {
-		if s.CountOf(card) < cards.CountOf(card) {
-			panic(fmt.Errorf("cannot remove %d %v cards from set with only %d",
-				cards.CountOf(card), card, s.CountOf(card)))
+		if s.CountOf(card) < other.CountOf(card) {
+			return fmt.Errorf("cannot remove %d %v cards from set with only %d",
+				other.CountOf(card), card, s.CountOf(card))
 		}
 	}
 
-	*s -= cards
+	*s -= other
+	return nil
 }
 
 // String implements Stringer.
@@ -152,3 +264,41 @@ func (s Set) String() string {
 
 	return "{" + strings.Join(result, ", ") + "}"
 }
+
+// ParseSet parses a Set from its String form, e.g. "{1 Defuse, 2 Cat}".
+func ParseSet(str string) (Set, error) {
+	if !strings.HasPrefix(str, "{") || !strings.HasSuffix(str, "}") {
+		return 0, fmt.Errorf("invalid set: %q", str)
+	}
+
+	result := NewSet()
+	inner := strings.TrimSuffix(strings.TrimPrefix(str, "{"), "}")
+	if inner == "" {
+		return result, nil
+	}
+
+	for _, cardCount := range strings.Split(inner, ", ") {
+		fields := strings.SplitN(cardCount, " ", 2)
+		if len(fields) != 2 {
+			return 0, fmt.Errorf("invalid set: %q", str)
+		}
+
+		count, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return 0, fmt.Errorf("invalid set: %q: %v", str, err)
+		}
+
+		card, err := ParseCard(fields[1])
+		if err != nil {
+			return 0, err
+		}
+
+		if count < 0 || int(result.CountOf(card))+count > MaxCountOf(card) {
+			return 0, fmt.Errorf("invalid set: %q: invalid count of %v", str, card)
+		}
+
+		result.AddN(card, count)
+	}
+
+	return result, nil
+}
@@ -1,6 +1,8 @@
 package cards
 
 import (
+	"math"
+	"math/rand"
 	"testing"
 )
 
@@ -71,6 +73,55 @@ func BenchmarkDistinct(b *testing.B) {
 	}
 }
 
+func TestMostCommon(t *testing.T) {
+	testCases := []struct {
+		cards         []Card
+		expected      Card
+		expectedCount uint8
+	}{
+		{nil, Unknown, 0},
+		{[]Card{Skip, Cat, Cat}, Cat, 2},
+		// Ties go to the lowest card.
+		{[]Card{Cat, Cat, Skip, Skip, Defuse}, Skip, 2},
+		{[]Card{TBD, Shuffle}, Shuffle, 1},
+	}
+
+	for _, tc := range testCases {
+		set := NewSetFromCards(tc.cards)
+		card, count := set.MostCommon()
+		if card != tc.expected || count != tc.expectedCount {
+			t.Errorf("%v: expected (%v, %d), got (%v, %d)",
+				set, tc.expected, tc.expectedCount, card, count)
+		}
+	}
+}
+
+func TestSampleWeighted(t *testing.T) {
+	rng := rand.New(rand.NewSource(123))
+	if card := NewSet().SampleWeighted(rng); card != Unknown {
+		t.Errorf("expected Unknown from empty set, got %v", card)
+	}
+
+	set := NewSetFromCards([]Card{Skip, Cat, Cat, Cat, Defuse, Defuse, Defuse, Defuse, Defuse, Defuse})
+	counts := make(map[Card]int)
+	n := 100000
+	for i := 0; i < n; i++ {
+		counts[set.SampleWeighted(rng)]++
+	}
+
+	if len(counts) != len(set.Distinct()) {
+		t.Errorf("sampled unexpected cards: %v", counts)
+	}
+
+	for card, count := range set.Counts() {
+		expected := float64(count) / float64(set.Len())
+		actual := float64(counts[card]) / float64(n)
+		if math.Abs(actual-expected) > 0.01 {
+			t.Errorf("%v: expected frequency %.3f, got %.3f", card, expected, actual)
+		}
+	}
+}
+
 func TestAsSlice(t *testing.T) {
 	testCards := []Card{Unknown, Unknown, Skip, Shuffle, SeeTheFuture, SeeTheFuture}
 	set := NewSetFromCards(testCards)
@@ -221,6 +272,54 @@ func TestRemoveAll_Panic(t *testing.T) {
 	set.RemoveAll(set2)
 }
 
+func TestRemoveChecked(t *testing.T) {
+	set := NewSetFromCards([]Card{Skip, Shuffle, Shuffle})
+	if err := set.RemoveChecked(Skip); err != nil {
+		t.Fatal(err)
+	}
+	if set.CountOf(Skip) != 0 {
+		t.Error("failed to remove Skip card")
+	}
+
+	// Removing the last card of each type is at the underflow boundary.
+	before := set
+	if err := set.RemoveChecked(Skip); err == nil {
+		t.Error("expected error when removing non-existent card")
+	}
+	if set != before {
+		t.Errorf("expected set to be unchanged on error, got %v", set)
+	}
+	if set.CountOf(Shuffle) != 2 {
+		t.Errorf("expected 2 Shuffle cards, got %v", set)
+	}
+}
+
+func TestSubtractChecked(t *testing.T) {
+	set := NewSetFromCards([]Card{Unknown, Unknown, Skip, Shuffle})
+	if err := set.SubtractChecked(NewSetFromCards([]Card{Unknown, Unknown, Skip})); err != nil {
+		t.Fatal(err)
+	}
+	expected := []Card{Shuffle}
+	if !setEqual(set.AsSlice(), expected) {
+		t.Errorf("got unexpected slice of cards: %v", set)
+	}
+
+	// Any card short by one must fail, without corrupting the other counts.
+	for card := Card(0); card < Card(NumTypes); card++ {
+		set := NewSetFromCards([]Card{Skip, Shuffle})
+		set.AddN(card, 2)
+		other := NewSet()
+		other.AddN(card, int(set.CountOf(card))+1)
+		before := set
+		if err := set.SubtractChecked(other); err == nil {
+			t.Errorf("expected error when subtracting %v from %v", other, before)
+		}
+		if set != before {
+			t.Errorf("expected set to be unchanged on error, got %v", set)
+		}
+	}
+}
+
 func setEqual(s1, s2 []Card) bool {
 	if len(s1) != len(s2) {
 		return false
@@ -243,3 +342,84 @@ func setEqual(s1, s2 []Card) bool {
 
 	return true
 }
+
+func TestAddOverflow(t *testing.T) {
+	for card := Card(0); card < Card(NumTypes); card++ {
+		set := NewSet()
+		set.AddN(card, MaxCountOf(card))
+		if int(set.CountOf(card)) != MaxCountOf(card) {
+			t.Errorf("expected %d %v cards, got %d", MaxCountOf(card), card, set.CountOf(card))
+		}
+
+		// Neighboring cards must not be affected when filled to capacity.
+		for other := Card(0); other < Card(NumTypes); other++ {
+			if other != card && set.Contains(other) {
+				t.Errorf("filling set with %v overflowed into %v: %v", card, other, set)
+			}
+		}
+
+		func() {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Errorf("expected panic adding %v to full set", card)
+				}
+			}()
+
+			set.Add(card)
+		}()
+	}
+
+	if MaxCountOf(Cat) != MaxCountPerType {
+		t.Errorf("expected max %d Cat cards, got %d", MaxCountPerType, MaxCountOf(Cat))
+	}
+}
+
+func TestParseSet(t *testing.T) {
+	testCases := []Set{
+		NewSet(),
+		NewSetFromCards([]Card{Defuse}),
+		NewSetFromCards([]Card{Cat, Cat, Skip, Defuse, TBD}),
+	}
+
+	for _, set := range testCases {
+		parsed, err := ParseSet(set.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if parsed != set {
+			t.Errorf("expected %v, got %v", set, parsed)
+		}
+	}
+
+	for _, invalid := range []string{"1 Cat", "{Cat}", "{1 Dog}", "{x Cat}", "{64 Cat}"} {
+		if _, err := ParseSet(invalid); err == nil {
+			t.Errorf("expected error parsing %q", invalid)
+		}
+	}
+}
+
+func TestCountVector(t *testing.T) {
+	testCases := []Set{
+		NewSet(),
+		NewSetFromCards([]Card{Unknown, Defuse}),
+		NewSetFromCards([]Card{Cat, Cat, Skip, Defuse, TBD}),
+	}
+
+	for _, set := range testCases {
+		counts := set.ToCountVector()
+		if len(counts) != NumTypes {
+			t.Errorf("expected %d counts, got %d", NumTypes, len(counts))
+		}
+
+		for card, count := range counts {
+			if count != set.CountOf(Card(card)) {
+				t.Errorf("expected %d %v cards, got %d", set.CountOf(Card(card)), Card(card), count)
+			}
+		}
+
+		if reloaded := SetFromCountVector(counts); reloaded != set {
+			t.Errorf("expected %v, got %v", set, reloaded)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package cards
 
 import (
+	"encoding/binary"
 	"fmt"
 	"math/bits"
 	"strings"
@@ -20,9 +21,10 @@ var (
 // The top card in the pile is always the lowest order digit.
 //
 // NOTE: This implementation relies on the fact that there are at most
-// 13 cards in the draw pile, and 10 distinct cards. Therefore, we can
-// represent the identity of each card using 4 bits, and the identities
-// of all 13 cards in the pile in 13 * 4 = 52 bits, or a single uint64.
+// 14 cards in the draw pile (with the ImplodingKitten), and 12 distinct
+// cards. Therefore, we can represent the identity of each card using 4 bits,
+// and the identities of all 14 cards in the pile in 14 * 4 = 56 bits,
+// or a single uint64.
 // Cards that are Unknown are set to zero.
 type Stack uint64
 
@@ -73,7 +75,12 @@ func (s Stack) NthCard(n int) Card {
 }
 
 // RemoveCard removes the Card in the Nth position.
+// RemoveCard panics if n is not in [0, Len()).
 func (s *Stack) RemoveCard(n int) {
+	if n < 0 || n >= s.Len() {
+		panic(fmt.Errorf("cannot remove card %d from stack with %d cards: %v", n, s.Len(), *s))
+	}
+
 	nBitsToKeep := uint(n) * bitsPerCard
 	keepMask := Stack(1<<nBitsToKeep) - 1
 	unchanged := (*s) & keepMask
@@ -83,7 +90,17 @@ func (s *Stack) RemoveCard(n int) {
 }
 
 // InsertCard places the given card inserted in the Nth position.
+// Inserting at position Len() places the card on the bottom of the stack.
+// InsertCard panics if n is not in [0, Len()], or if the stack is full.
 func (s *Stack) InsertCard(card Card, n int) {
+	if n < 0 || n > s.Len() {
+		panic(fmt.Errorf("cannot insert card in position %d of stack with %d cards: %v", n, s.Len(), *s))
+	}
+
+	if s.Len() >= maxCapacity {
+		panic(fmt.Errorf("cannot insert card into full stack: %v", *s))
+	}
+
 	nBitsToKeep := uint(n) * bitsPerCard
 	keepMask := Stack(1<<nBitsToKeep) - 1
 	unchanged := (*s) & keepMask
@@ -101,6 +118,46 @@ func (s Stack) Iter(cb func(card Card)) {
 	}
 }
 
+// TBDPositions returns the positions in the stack that have not
+// been determinized yet.
+func (s Stack) TBDPositions() []int {
+	var result []int
+	i := 0
+	s.Iter(func(card Card) {
+		if card.IsTBD() {
+			result = append(result, i)
+		}
+		i++
+	})
+
+	return result
+}
+
+// CountOf returns the number of the given Card in the Stack, without
+// converting it to a Set. As with Len, Unknown cards on the bottom of the
+// stack are not counted.
+func (s Stack) CountOf(card Card) int {
+	n := 0
+	for ; !s.IsEmpty(); s >>= bitsPerCard {
+		if Card(s&topCardMask) == card {
+			n++
+		}
+	}
+
+	return n
+}
+
+// Contains returns whether the given Card is in the Stack.
+func (s Stack) Contains(card Card) bool {
+	for ; !s.IsEmpty(); s >>= bitsPerCard {
+		if Card(s&topCardMask) == card {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (s Stack) ToSet() Set {
 	set := NewSet()
 	s.Iter(func(card Card) { set.Add(card) })
@@ -116,3 +173,64 @@ func (s Stack) String() string {
 
 	return "[" + strings.Join(cards, ", ") + "]"
 }
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+// The binary form is the packed representation of the Stack (8 bytes,
+// little endian), so it round-trips exactly, including Unknown and TBD cards.
+func (s Stack) MarshalBinary() ([]byte, error) {
+	buf := make([]byte, 8)
+	binary.LittleEndian.PutUint64(buf, uint64(s))
+	return buf, nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (s *Stack) UnmarshalBinary(buf []byte) error {
+	if len(buf) != 8 {
+		return fmt.Errorf("invalid stack: expected 8 bytes, got %d", len(buf))
+	}
+
+	*s = Stack(binary.LittleEndian.Uint64(buf))
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler.
+// The text form is the list of cards from the top of the stack, as in String.
+// Note that, as with Len, Unknown cards on the bottom of the stack are not included.
+func (s Stack) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// ParseStack parses a Stack from its String form, e.g. "[Cat, Skip]".
+func ParseStack(str string) (Stack, error) {
+	var result Stack
+	err := result.UnmarshalText([]byte(str))
+	return result, err
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (s *Stack) UnmarshalText(text []byte) error {
+	str := string(text)
+	if !strings.HasPrefix(str, "[") || !strings.HasSuffix(str, "]") {
+		return fmt.Errorf("invalid stack: %q", str)
+	}
+
+	str = strings.TrimSuffix(strings.TrimPrefix(str, "["), "]")
+	result := NewStack()
+	if str != "" {
+		for i, name := range strings.Split(str, ", ") {
+			if i >= maxCapacity {
+				return fmt.Errorf("invalid stack: more than %d cards: %q", maxCapacity, text)
+			}
+
+			card, err := ParseCard(name)
+			if err != nil {
+				return err
+			}
+
+			result.SetNthCard(i, card)
+		}
+	}
+
+	*s = result
+	return nil
+}
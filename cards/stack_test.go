@@ -1,6 +1,7 @@
 package cards
 
 import (
+	"reflect"
 	"testing"
 )
 
@@ -49,6 +50,47 @@ func TestStackLen(t *testing.T) {
 	}
 }
 
+func TestStackCountOf(t *testing.T) {
+	testCases := []struct {
+		cards []Card
+	}{
+		{[]Card{}},
+		{[]Card{Skip, Shuffle, Skip}},
+		{[]Card{Unknown, Skip, TBD, TBD, Unknown, ExplodingKitten}},
+		// Unknowns on the end of the stack are not counted.
+		{[]Card{Cat, Unknown, Cat, Unknown, Unknown}},
+	}
+
+	for _, tc := range testCases {
+		stack := NewStackFromCards(tc.cards)
+		set := stack.ToSet()
+		for card := Card(0); int(card) < NumTypes; card++ {
+			if n := stack.CountOf(card); n != int(set.CountOf(card)) {
+				t.Errorf("%v: expected %d %v, got %d", stack, set.CountOf(card), card, n)
+			}
+
+			if stack.Contains(card) != set.Contains(card) {
+				t.Errorf("%v: expected Contains(%v) = %v", stack, card, set.Contains(card))
+			}
+		}
+	}
+}
+
+func BenchmarkStackCountOf(b *testing.B) {
+	stack := NewStackFromCards([]Card{Unknown, Skip, TBD, TBD, Shuffle, SeeTheFuture, TBD})
+	b.Run("CountOf", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			stack.CountOf(TBD)
+		}
+	})
+
+	b.Run("ToSet", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			stack.ToSet().CountOf(TBD)
+		}
+	})
+}
+
 func TestSetNthCard(t *testing.T) {
 	testCards := []Card{Unknown, Unknown, Skip, Shuffle, SeeTheFuture, SeeTheFuture}
 	stack := NewStackFromCards(testCards)
@@ -158,6 +200,61 @@ func TestInsertCard(t *testing.T) {
 	}
 }
 
+func TestInsertCardBounds(t *testing.T) {
+	testCards := []Card{Skip, Shuffle, Cat}
+	stack := NewStackFromCards(testCards)
+	// Insert on the bottom.
+	stack.InsertCard(Slap1x, stack.Len())
+	// Insert on the top.
+	stack.InsertCard(Slap2x, 0)
+	expected := NewStackFromCards([]Card{Slap2x, Skip, Shuffle, Cat, Slap1x})
+	if stack != expected {
+		t.Errorf("expected %v, got %v", expected, stack)
+	}
+
+	for _, n := range []int{-1, stack.Len() + 1} {
+		func() {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Errorf("expected panic inserting in position %d of %v", n, stack)
+				}
+			}()
+
+			stack.InsertCard(Defuse, n)
+		}()
+	}
+}
+
+func TestRemoveCardBounds(t *testing.T) {
+	stack := NewStackFromCards([]Card{Skip, Shuffle, Cat})
+	for _, n := range []int{-1, stack.Len()} {
+		func() {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Errorf("expected panic removing card %d of %v", n, stack)
+				}
+			}()
+
+			stack.RemoveCard(n)
+		}()
+	}
+}
+
+func TestInsertCardFull(t *testing.T) {
+	var stack Stack
+	for i := 0; i < maxCapacity; i++ {
+		stack.InsertCard(Cat, 0)
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf("expected panic inserting into full stack: %v", stack)
+		}
+	}()
+
+	stack.InsertCard(Cat, 0)
+}
+
 func TestStackToSet(t *testing.T) {
 	testCases := [][]Card{
 		{Skip, Shuffle, SeeTheFuture, Cat},
@@ -174,3 +271,93 @@ func TestStackToSet(t *testing.T) {
 		}
 	}
 }
+
+func TestStackMarshalBinary(t *testing.T) {
+	testCases := []Stack{
+		NewStack(),
+		NewStackFromCards([]Card{Cat, Skip, ExplodingKitten}),
+		NewStackFromCards([]Card{TBD, TBD, Defuse, TBD}),
+		NewStackFromCards([]Card{Unknown, Unknown, Skip, TBD}),
+		NewStackFromCards([]Card{Cat, Unknown, TBD, Unknown, Shuffle}),
+	}
+
+	for _, s := range testCases {
+		buf, err := s.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var reloaded Stack
+		if err := reloaded.UnmarshalBinary(buf); err != nil {
+			t.Fatal(err)
+		}
+
+		if reloaded != s {
+			t.Errorf("expected %v, got %v", s, reloaded)
+		}
+	}
+
+	var s Stack
+	if err := s.UnmarshalBinary([]byte{1, 2, 3}); err == nil {
+		t.Error("expected error unmarshaling truncated stack")
+	}
+}
+
+func TestStackMarshalText(t *testing.T) {
+	testCases := []struct {
+		stack Stack
+		text  string
+	}{
+		{NewStack(), "[]"},
+		{NewStackFromCards([]Card{Cat, Skip, ExplodingKitten}), "[Cat, Skip, ExplodingKitten]"},
+		{NewStackFromCards([]Card{TBD, TBD, Defuse, TBD}), "[TBD, TBD, Defuse, TBD]"},
+		{NewStackFromCards([]Card{Unknown, Unknown, Skip, TBD}), "[Unknown, Unknown, Skip, TBD]"},
+		// Trailing Unknown cards are not part of the stack.
+		{NewStackFromCards([]Card{Cat, Unknown, TBD, Unknown, Unknown}), "[Cat, Unknown, TBD]"},
+	}
+
+	for _, tc := range testCases {
+		text, err := tc.stack.MarshalText()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(text) != tc.text {
+			t.Errorf("expected %q, got %q", tc.text, text)
+		}
+
+		var reloaded Stack
+		if err := reloaded.UnmarshalText(text); err != nil {
+			t.Fatal(err)
+		}
+
+		if reloaded != tc.stack {
+			t.Errorf("expected %v, got %v", tc.stack, reloaded)
+		}
+	}
+
+	for _, invalid := range []string{"Cat, Skip", "[Cat, Dog]"} {
+		var s Stack
+		if err := s.UnmarshalText([]byte(invalid)); err == nil {
+			t.Errorf("expected error unmarshaling %q", invalid)
+		}
+	}
+}
+
+func TestTBDPositions(t *testing.T) {
+	testCases := []struct {
+		stack    Stack
+		expected []int
+	}{
+		{NewStack(), nil},
+		{NewStackFromCards([]Card{Cat, Skip}), nil},
+		{NewStackFromCards([]Card{TBD, Cat, Unknown, TBD, Defuse, TBD}), []int{0, 3, 5}},
+		{NewStackFromCards([]Card{Unknown, Unknown, TBD}), []int{2}},
+	}
+
+	for _, tc := range testCases {
+		if positions := tc.stack.TBDPositions(); !reflect.DeepEqual(positions, tc.expected) {
+			t.Errorf("%v: expected TBD positions %v, got %v", tc.stack, tc.expected, positions)
+		}
+	}
+}
@@ -4,6 +4,7 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"encoding/gob"
 	"expvar"
 	"flag"
@@ -12,18 +13,20 @@ import (
 	"net/http"
 	_ "net/http/pprof"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/golang/glog"
 	"github.com/timpalpant/go-cfr"
 	"github.com/timpalpant/go-cfr/mcts"
-	"github.com/timpalpant/go-cfr/sampling"
 
 	"github.com/timpalpant/alphacats"
 	"github.com/timpalpant/alphacats/cards"
+	"github.com/timpalpant/alphacats/cmd/internal/glogger"
 	"github.com/timpalpant/alphacats/gamestate"
 	"github.com/timpalpant/alphacats/model"
 )
@@ -64,6 +67,10 @@ type RunParams struct {
 
 	ModelParams         model.Params
 	PredictionCacheSize int
+
+	// Tablebase, if not nil, is used to evaluate endgame positions in
+	// search instead of the policy's network (see alphacats.TablebaseEvaluator).
+	Tablebase *alphacats.Tablebase
 }
 
 type SamplingParams struct {
@@ -109,8 +116,36 @@ func main() {
 		"Maximum batch size for prediction")
 	flag.IntVar(&params.PredictionCacheSize, "prediction_cache_size", 100000,
 		"Size of LRU prediction cache per model")
-
+	seedLogFile := flag.String("seed_log", "",
+		"If set, append the seed of each game to this file")
+	replayGame := flag.String("replay_game", "",
+		"Print the deal of the given game (epoch:player:game) in -seed_log and exit")
+
+	tablebaseMaxCards := flag.Int("tablebase_max_cards", 0,
+		"If positive, evaluate positions with at most this many cards in the draw pile "+
+			"with their clairvoyant value (see alphacats.Minimax) in search")
 	flag.Parse()
+	alphacats.SetLogger(glogger.Logger{})
+	if *tablebaseMaxCards > 0 {
+		params.Tablebase = alphacats.NewTablebase(*tablebaseMaxCards)
+	}
+
+	if *replayGame != "" {
+		if err := replayDeal(params, *seedLogFile, *replayGame); err != nil {
+			glog.Fatal(err)
+		}
+		return
+	}
+
+	var seeds *seedLog
+	if *seedLogFile != "" {
+		f, err := os.OpenFile(*seedLogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			glog.Fatal(err)
+		}
+		defer f.Close()
+		seeds = newSeedLog(f)
+	}
 
 	rand.Seed(params.SamplingParams.Seed)
 	go http.ListenAndServe("localhost:4123", nil)
@@ -134,24 +169,50 @@ func main() {
 	}
 	wg.Wait()
 
+	// Stop gracefully on Ctrl-C, saving the policies trained so far.
+	// A second Ctrl-C exits immediately.
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	ctx := notifyShutdown(context.Background(), sigs)
+	go func() {
+		<-ctx.Done()
+		signal.Stop(sigs)
+	}()
+
 	// Run PSRO: Each epoch, train an approximate best response to the opponent's
 	// current policy distribution. Then add the new policies to the meta-model.
 	start = time.Now()
-	for epoch := 1; ; epoch++ {
+	playEpoch := func(ctx context.Context, epoch int) {
 		glog.Infof("Starting epoch %d: Playing %d games to train approximate best responses",
 			epoch, params.NumGamesPerEpoch)
 		wg.Add(2)
 		go func() {
-			runEpoch(policies, 0, params, epoch)
+			runEpoch(ctx, policies, 0, params, epoch, seeds)
 			wg.Done()
 		}()
 		// NB: Work around some CUDA initialization race that leads to segfault.
 		time.Sleep(5 * time.Second)
 		go func() {
-			runEpoch(policies, 1, params, epoch)
+			runEpoch(ctx, policies, 1, params, epoch, seeds)
 			wg.Done()
 		}()
 		wg.Wait()
+	}
+
+	endEpoch := func(epoch int, interrupted bool) error {
+		if interrupted {
+			// NB: The best responses of an interrupted epoch are only
+			// partially trained, so they are saved (with their samples) to
+			// be resumed, but not added to the meta-model.
+			glog.Infof("Epoch %d was interrupted, saving policies", epoch)
+			for player := range policies {
+				if err := savePolicy(params, player, policies[player], epoch, -1); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		}
 
 		// Update meta-model with new best response policies.
 		// TODO: Implement Nash solver for the meta-game rather than using uniform
@@ -159,9 +220,15 @@ func main() {
 		for player := 0; player < 1; player++ {
 			policies[player].AddCurrentExploiterToModel()
 			if err := savePolicy(params, player, policies[player], epoch, -1); err != nil {
-				glog.Fatal(err)
+				return err
 			}
 		}
+
+		return nil
+	}
+
+	if err := train(ctx, playEpoch, endEpoch); err != nil {
+		glog.Fatal(err)
 	}
 }
 
@@ -217,7 +284,10 @@ func loadTrainingSamples(filename string) ([]model.Sample, error) {
 	return samples, err
 }
 
-func runEpoch(policies [2]*model.MCTSPSRO, player int, params RunParams, epoch int) {
+// runEpoch plays games to train the given player's best response, until
+// NumGamesPerEpoch games have been played or ctx is canceled. If seeds is
+// non-nil, the seed used to deal each game is recorded in it.
+func runEpoch(ctx context.Context, policies [2]*model.MCTSPSRO, player int, params RunParams, epoch int, seeds *seedLog) {
 	gamesRemaining.Add(int64(params.NumGamesPerEpoch))
 
 	var mx sync.Mutex
@@ -236,27 +306,39 @@ func runEpoch(policies [2]*model.MCTSPSRO, player int, params RunParams, epoch i
 	var prevWins, prevLosses int64
 	numSamplesSinceLastTrain := 0
 	modelIter := 0
-	for i := 0; i < params.NumGamesPerEpoch; i++ {
+	progress := alphacats.NewProgressLogger(
+		fmt.Sprintf("epoch %d, player %d", epoch, player), params.NumGamesPerEpoch)
+	numGames := 0
+	for ; numGames < params.NumGamesPerEpoch && ctx.Err() == nil; numGames++ {
 		wg.Add(1)
 		sem <- struct{}{}
+		id := gameID{Epoch: epoch, Player: player, Game: numGames}
+		seed := rand.Int63()
 		go func() {
 			defer func() {
 				gamesRemaining.Add(-1)
 				wg.Done()
 				<-sem
 			}()
-			// NB: NewRandomDeal shuffles the passed deck.
-			deck := make([]cards.Card, len(params.Deck))
-			copy(deck, params.Deck)
-			deal := alphacats.NewRandomDeal(deck, params.CardsPerPlayer)
+			if seeds != nil {
+				if err := seeds.Record(id, seed); err != nil {
+					glog.Errorf("Error recording seed of game %v: %v", id, err)
+				}
+			}
+			rng := newGameRand(seed)
+			deal := seededDeal(params, rng)
 			game := alphacats.NewGame(deal.DrawPile, deal.P0Deal, deal.P1Deal)
 			opponentPolicy := opponent.SamplePolicy()
-			ismcts := mcts.NewOneSidedISMCTS(player, policy,
+			var evaluator mcts.Evaluator = policy
+			if params.Tablebase != nil {
+				evaluator = &alphacats.TablebaseEvaluator{Tablebase: params.Tablebase, Fallback: policy}
+			}
+			ismcts := mcts.NewOneSidedISMCTS(player, evaluator,
 				float32(params.SamplingParams.C), float32(params.Temperature))
 			glog.Infof("Playing game with %d/%d search iterations",
 				params.NumMCTSIterationsExpensive,
 				params.NumMCTSIterationsCheap)
-			samples := playGame(game, ismcts, opponentPolicy, player, params)
+			samples := playGame(game, ismcts, opponentPolicy, player, params, rng)
 			glog.Infof("Collected %d samples", len(samples))
 			gamesPlayed.Add(1)
 			numSamples.Add(int64(len(samples)))
@@ -270,8 +352,10 @@ func runEpoch(policies [2]*model.MCTSPSRO, player int, params RunParams, epoch i
 			// turn before we had any chance to play.
 			if len(samples) == 0 || samples[len(samples)-1].Value != 1.0 {
 				losses.Add(1)
+				progress.Add(1, -1.0)
 			} else {
 				wins.Add(1)
+				progress.Add(1, 1.0)
 			}
 
 			mx.Lock()
@@ -300,9 +384,41 @@ func runEpoch(policies [2]*model.MCTSPSRO, player int, params RunParams, epoch i
 		mx.Unlock()
 	}
 
+	if numGames < params.NumGamesPerEpoch {
+		glog.Infof("Epoch %d, player %d: Interrupted after %d games", epoch, player, numGames)
+		gamesRemaining.Add(-int64(params.NumGamesPerEpoch - numGames))
+	}
+
 	wg.Wait()
 }
 
+// replayDeal prints the deal of the given game, from the seed recorded
+// for it in the seed log.
+func replayDeal(params RunParams, seedLogFile, game string) error {
+	id, err := parseGameID(game)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(seedLogFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	seed, err := findSeed(bufio.NewReader(f), id)
+	if err != nil {
+		return err
+	}
+
+	deal := seededDeal(params, newGameRand(seed))
+	fmt.Printf("Game %v (seed %d):\n", id, seed)
+	fmt.Printf("Draw pile: %v\n", deal.DrawPile)
+	fmt.Printf("Player 0: %v\n", deal.P0Deal)
+	fmt.Printf("Player 1: %v\n", deal.P1Deal)
+	return nil
+}
+
 func loadPolicy(params RunParams) [2]*model.MCTSPSRO {
 	p0Params := params.ModelParams
 	p0Params.OutputDir = filepath.Join(p0Params.OutputDir, "player0")
@@ -367,7 +483,9 @@ func savePolicyToFile(policy *model.MCTSPSRO, filename string) error {
 	return f.Close()
 }
 
-func playGame(game cfr.GameTreeNode, search *mcts.OneSidedISMCTS, opponentPolicy mcts.Policy, player int, params RunParams) []model.Sample {
+// playGame plays one game, searching for the moves of the given player,
+// and returns the samples collected. All randomness is drawn from rng.
+func playGame(game cfr.GameTreeNode, search *mcts.OneSidedISMCTS, opponentPolicy mcts.Policy, player int, params RunParams, rng *rand.Rand) []model.Sample {
 	gamesInFlight.Add(1)
 	defer gamesInFlight.Add(-1)
 	infoSet := game.(*alphacats.GameNode).GetInfoSet(gamestate.Player(player))
@@ -376,21 +494,21 @@ func playGame(game cfr.GameTreeNode, search *mcts.OneSidedISMCTS, opponentPolicy
 	var samples []model.Sample
 	for game.Type() != cfr.TerminalNodeType {
 		if game.Type() == cfr.ChanceNodeType {
-			game, _ = game.SampleChild()
+			game, _ = game.(*alphacats.GameNode).SampleChildWithRand(rng)
 		} else if game.Player() != player { // Opponent.
 			p := opponentPolicy.GetPolicy(game)
-			selected := sampling.SampleOne(p, rand.Float32())
+			selected := alphacats.SampleAction(p, rng.Float32())
 			game = game.GetChild(selected)
 		} else {
 			numMCTSIterations := params.NumMCTSIterationsCheap
-			expensiveSearch := (rand.Float64() < params.ExpensiveMoveFraction)
+			expensiveSearch := (rng.Float64() < params.ExpensiveMoveFraction)
 			if expensiveSearch {
 				numMCTSIterations = params.NumMCTSIterationsExpensive
 			}
-			simulate(search, opponentPolicy, beliefs, numMCTSIterations, params.MaxParallelSearches)
+			simulate(search, opponentPolicy, beliefs, numMCTSIterations, params.MaxParallelSearches, rng)
 			is := game.InfoSet(game.Player()).(*alphacats.AbstractedInfoSet)
 			p := search.GetPolicy(game)
-			selected := sampling.SampleOne(p, rand.Float32())
+			selected := alphacats.SampleAction(p, rng.Float32())
 			game = game.GetChild(selected)
 			if expensiveSearch {
 				samples = append(samples, model.Sample{
@@ -414,19 +532,27 @@ func playGame(game cfr.GameTreeNode, search *mcts.OneSidedISMCTS, opponentPolicy
 	return samples
 }
 
-func simulate(search *mcts.OneSidedISMCTS, opponentPolicy mcts.Policy, beliefs *alphacats.BeliefState, n, nParallel int) {
+// simulate runs n simulations of the search in nParallel workers. Each worker
+// has its own source of randomness, seeded from rng.
+func simulate(search *mcts.OneSidedISMCTS, opponentPolicy mcts.Policy, beliefs *alphacats.BeliefState, n, nParallel int, rng *rand.Rand) {
 	var wg sync.WaitGroup
 	nWorkers := min(n, nParallel)
 	nPerWorker := n / nWorkers
 	for worker := 0; worker < nWorkers; worker++ {
 		wg.Add(1)
+		workerRng := rand.New(rand.NewSource(rng.Int63()))
 		go func() {
 			defer wg.Done()
-			rng := rand.New(rand.NewSource(rand.Int63()))
 			for k := 0; k < nPerWorker; k++ {
-				game := beliefs.SampleDeterminization()
+				game, err := beliefs.SampleDeterminizationWithRand(workerRng)
+				if err != nil {
+					// Drop this sample and continue searching with the next one.
+					glog.Warningf("Skipping invalid determinization: %v", err)
+					continue
+				}
+
 				searchesInFlight.Add(1)
-				search.Run(rng, game, opponentPolicy)
+				search.Run(workerRng, game, opponentPolicy)
 				searchesInFlight.Add(-1)
 				searchesPerformed.Add(1)
 				searchesPerSecond.Set(float64(searchesPerformed.Value()) / time.Since(start).Seconds())
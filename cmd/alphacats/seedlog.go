@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/rand"
+	"sync"
+
+	"github.com/timpalpant/alphacats"
+	"github.com/timpalpant/alphacats/cards"
+)
+
+// gameID identifies a game played during training.
+type gameID struct {
+	Epoch  int
+	Player int
+	Game   int
+}
+
+func (id gameID) String() string {
+	return fmt.Sprintf("%d:%d:%d", id.Epoch, id.Player, id.Game)
+}
+
+// parseGameID parses a gameID in the format of gameID.String, epoch:player:game.
+func parseGameID(s string) (gameID, error) {
+	var id gameID
+	if _, err := fmt.Sscanf(s, "%d:%d:%d", &id.Epoch, &id.Player, &id.Game); err != nil {
+		return id, fmt.Errorf("invalid game %q, expected epoch:player:game: %v", s, err)
+	}
+
+	return id, nil
+}
+
+// seedLog records the seed of each game played during training, so that
+// the game can be reproduced later (see newGameRand).
+// Each game is logged as one line: "<epoch> <player> <game> <seed>".
+type seedLog struct {
+	mx sync.Mutex
+	w  io.Writer
+}
+
+func newSeedLog(w io.Writer) *seedLog {
+	return &seedLog{w: w}
+}
+
+// Record logs the seed of the given game. It is safe to call
+// from concurrent games.
+func (l *seedLog) Record(id gameID, seed int64) error {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	_, err := fmt.Fprintf(l.w, "%d %d %d %d\n", id.Epoch, id.Player, id.Game, seed)
+	return err
+}
+
+// findSeed returns the seed of the given game from a seed log. If the game
+// was logged more than once (for example, because training was resumed),
+// the last seed logged is returned.
+func findSeed(r io.Reader, id gameID) (int64, error) {
+	var seed int64
+	found := false
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		var logged gameID
+		var loggedSeed int64
+		if _, err := fmt.Sscanf(scanner.Text(), "%d %d %d %d",
+			&logged.Epoch, &logged.Player, &logged.Game, &loggedSeed); err != nil {
+			return 0, fmt.Errorf("invalid seed log entry %q: %v", scanner.Text(), err)
+		}
+
+		if logged == id {
+			seed, found = loggedSeed, true
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+
+	if !found {
+		return 0, fmt.Errorf("game %v not found in seed log", id)
+	}
+
+	return seed, nil
+}
+
+// newGameRand returns the source of randomness for the game with the given
+// seed. It is first used to deal the game (see seededDeal), and then to sample
+// its chance outcomes, the actions of both players, and the search.
+//
+// NOTE: The search is only reproducible with -max_parallel_searches=1, since
+// concurrent simulations share the search tree, and if the opponent policy
+// sampled and the network evaluating the search are the same as when the game
+// was played. Only the deal is reproduced exactly in any case.
+func newGameRand(seed int64) *rand.Rand {
+	return rand.New(rand.NewSource(seed))
+}
+
+// seededDeal deals a game from the deck in params, shuffled by rng.
+func seededDeal(params RunParams, rng *rand.Rand) alphacats.Deal {
+	// NB: NewRandomDealWithConfig shuffles the passed deck.
+	deck := make([]cards.Card, len(params.Deck))
+	copy(deck, params.Deck)
+	return alphacats.NewRandomDealWithConfig(deck, params.CardsPerPlayer,
+		alphacats.DealConfig{Rand: rng})
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/timpalpant/alphacats/cards"
+)
+
+func TestSeedLogReplay(t *testing.T) {
+	params := RunParams{
+		Deck:           cards.CoreDeck.AsSlice(),
+		CardsPerPlayer: 4,
+	}
+
+	var buf bytes.Buffer
+	seeds := newSeedLog(&buf)
+	rng := rand.New(rand.NewSource(123))
+	var ids []gameID
+	var drawPiles []cards.Stack
+	for player := 0; player < 2; player++ {
+		for game := 0; game < 10; game++ {
+			id := gameID{Epoch: 1, Player: player, Game: game}
+			seed := rng.Int63()
+			if err := seeds.Record(id, seed); err != nil {
+				t.Fatal(err)
+			}
+
+			ids = append(ids, id)
+			drawPiles = append(drawPiles, seededDeal(params, newGameRand(seed)).DrawPile)
+		}
+	}
+
+	for i, id := range ids {
+		seed, err := findSeed(bytes.NewReader(buf.Bytes()), id)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if drawPile := seededDeal(params, newGameRand(seed)).DrawPile; drawPile != drawPiles[i] {
+			t.Errorf("game %v: replayed draw pile %v != logged %v", id, drawPile, drawPiles[i])
+		}
+	}
+
+	if _, err := findSeed(bytes.NewReader(buf.Bytes()), gameID{Epoch: 2}); err == nil {
+		t.Error("expected error for game not in the seed log")
+	}
+}
+
+func TestParseGameID(t *testing.T) {
+	id := gameID{Epoch: 3, Player: 1, Game: 4201}
+	parsed, err := parseGameID(id.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed != id {
+		t.Errorf("expected %v, got %v", id, parsed)
+	}
+
+	if _, err := parseGameID("4201"); err == nil {
+		t.Error("expected error for invalid game")
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"os"
+
+	"github.com/golang/glog"
+)
+
+// notifyShutdown returns a context that is canceled when a signal is
+// received on sigs (see signal.Notify), so that training can be stopped
+// gracefully rather than losing the progress made since the last save.
+func notifyShutdown(ctx context.Context, sigs <-chan os.Signal) context.Context {
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		select {
+		case sig := <-sigs:
+			glog.Warningf("Received %v: finishing games in progress before saving", sig)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx
+}
+
+// train runs epochs of training until ctx is canceled. Each epoch is passed
+// the context, and should stop starting new work once it is canceled. After
+// each epoch, endEpoch is called to save the policies, with whether the epoch
+// was interrupted before it was completed. Training stops after the first
+// interrupted epoch, or if endEpoch returns an error.
+func train(ctx context.Context, runEpoch func(ctx context.Context, epoch int),
+	endEpoch func(epoch int, interrupted bool) error) error {
+	for epoch := 1; ; epoch++ {
+		runEpoch(ctx, epoch)
+		interrupted := ctx.Err() != nil
+		if err := endEpoch(epoch, interrupted); err != nil {
+			return err
+		}
+
+		if interrupted {
+			return nil
+		}
+	}
+}
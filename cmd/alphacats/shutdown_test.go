@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestNotifyShutdown(t *testing.T) {
+	sigs := make(chan os.Signal, 1)
+	ctx := notifyShutdown(context.Background(), sigs)
+	if ctx.Err() != nil {
+		t.Fatal("context canceled before any signal was received")
+	}
+
+	sigs <- syscall.SIGINT
+	select {
+	case <-ctx.Done():
+	case <-time.After(10 * time.Second):
+		t.Fatal("context was not canceled after SIGINT")
+	}
+}
+
+func TestTrainSavesWhenInterrupted(t *testing.T) {
+	sigs := make(chan os.Signal, 1)
+	ctx := notifyShutdown(context.Background(), sigs)
+
+	var epochsRun, gamesPlayed int
+	runEpoch := func(ctx context.Context, epoch int) {
+		epochsRun++
+		for i := 0; i < 10 && ctx.Err() == nil; i++ {
+			gamesPlayed++
+			if epoch == 3 && i == 4 {
+				// Interrupted in the middle of the third epoch.
+				sigs <- syscall.SIGTERM
+				<-ctx.Done()
+			}
+		}
+	}
+
+	var saved []bool
+	endEpoch := func(epoch int, interrupted bool) error {
+		saved = append(saved, interrupted)
+		return nil
+	}
+
+	if err := train(ctx, runEpoch, endEpoch); err != nil {
+		t.Fatal(err)
+	}
+
+	if epochsRun != 3 {
+		t.Errorf("expected 3 epochs to run, got %d", epochsRun)
+	}
+
+	if gamesPlayed != 25 {
+		t.Errorf("expected 25 games to be played, got %d", gamesPlayed)
+	}
+
+	expected := []bool{false, false, true}
+	if len(saved) != len(expected) {
+		t.Fatalf("expected policies to be saved %d times, got %v", len(expected), saved)
+	}
+
+	for i, interrupted := range expected {
+		if saved[i] != interrupted {
+			t.Errorf("epoch %d: expected interrupted = %v, got %v", i+1, interrupted, saved[i])
+		}
+	}
+}
+
+func TestTrainSaveError(t *testing.T) {
+	errSave := errors.New("disk full")
+	runEpoch := func(ctx context.Context, epoch int) {}
+	endEpoch := func(epoch int, interrupted bool) error {
+		return errSave
+	}
+
+	if err := train(context.Background(), runEpoch, endEpoch); err != errSave {
+		t.Errorf("expected error %v, got %v", errSave, err)
+	}
+}
@@ -20,10 +20,10 @@ import (
 	"github.com/golang/glog"
 	"github.com/timpalpant/go-cfr"
 	"github.com/timpalpant/go-cfr/mcts"
-	"github.com/timpalpant/go-cfr/sampling"
 
 	"github.com/timpalpant/alphacats"
 	"github.com/timpalpant/alphacats/cards"
+	"github.com/timpalpant/alphacats/cmd/internal/glogger"
 	"github.com/timpalpant/alphacats/gamestate"
 	"github.com/timpalpant/alphacats/model"
 )
@@ -91,6 +91,7 @@ func main() {
 		"Mixing factor d used in Smooth UCT search")
 
 	flag.Parse()
+	alphacats.SetLogger(glogger.Logger{})
 
 	rand.Seed(params.SamplingParams.Seed)
 	go http.ListenAndServe("localhost:4123", nil)
@@ -178,7 +179,7 @@ func playGame(game cfr.GameTreeNode, params RunParams) []model.Sample {
 			simulate(search, beliefs[0], params.NumMCTSIterations, params.MaxParallelSearches)
 			simulate(search, beliefs[1], params.NumMCTSIterations, params.MaxParallelSearches)
 			p := search.GetPolicy(game)
-			selected := sampling.SampleOne(p, rand.Float32())
+			selected := alphacats.SampleAction(p, rand.Float32())
 			game = game.GetChild(selected)
 			samples = append(samples, model.Sample{
 				InfoSet: *is,
@@ -250,7 +251,13 @@ func simulate(search *mcts.SmoothUCT, beliefs *alphacats.BeliefState, n, nParall
 			defer wg.Done()
 			rng := rand.New(rand.NewSource(rand.Int63()))
 			for k := 0; k < nPerWorker; k++ {
-				game := beliefs.SampleDeterminization()
+				game, err := beliefs.SampleDeterminization()
+				if err != nil {
+					// Drop this sample and continue searching with the next one.
+					glog.Warningf("Skipping invalid determinization: %v", err)
+					continue
+				}
+
 				search.Run(rng, game)
 				searchesPerformed.Add(1)
 			}
@@ -3,52 +3,96 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"encoding/json"
 	"flag"
-	"fmt"
 	"math/rand"
 	"net/http"
 	_ "net/http/pprof"
 	"os"
 	"runtime"
-	"strconv"
-	"strings"
 	"sync"
+	"time"
 
 	"github.com/golang/glog"
-	"github.com/timpalpant/go-cfr"
-	"github.com/timpalpant/go-cfr/mcts"
-	"github.com/timpalpant/go-cfr/sampling"
 
 	"github.com/timpalpant/alphacats"
 	"github.com/timpalpant/alphacats/cards"
+	"github.com/timpalpant/alphacats/cmd/internal/glogger"
 	"github.com/timpalpant/alphacats/gamestate"
 )
 
 var stdin = bufio.NewReader(os.Stdin)
 
+// gameLog receives a JSON event for each step of the game, if enabled.
+var gameLog *alphacats.GameLog
+
+// beliefDump receives a JSON snapshot of the belief state at each of the
+// strategy's turns, if enabled.
+var beliefDump *json.Encoder
+
 type RunParams struct {
 	DeckType          string
+	CardsPerPlayer    int
+	KittenPlacement   string
 	NumMCTSIterations int
-	SamplingParams    SamplingParams
-	Temperature       float64
+	// ThinkTime, if non-zero, limits each search by wall-clock time
+	// instead of NumMCTSIterations.
+	ThinkTime      time.Duration
+	SamplingParams SamplingParams
+	Temperature    float64
+	// DeterminizationSampler is used to sample games from the belief state
+	// for each MCTS simulation. Defaults to alphacats.UniformDeterminizationSampler.
+	DeterminizationSampler alphacats.DeterminizationSampler
+	// ValidateBeliefs re-validates the belief state after each update (slow).
+	ValidateBeliefs bool
 }
 
 type SamplingParams struct {
-	Seed  int64
-	C     float64
-	Gamma float64
-	Eta   float64
-	D     float64
+	Seed   int64
+	C      float64
+	CDecay float64
+	CMin   float64
+	Gamma  float64
+	Eta    float64
+	D      float64
+}
+
+// ExplorationC returns the exploration factor C to use for a simulation
+// from an info set that has been visited the given number of times.
+// C decays hyperbolically with the number of visits, down to CMin.
+// With no decay, C is constant.
+func (p SamplingParams) ExplorationC(visits int) float64 {
+	c := p.C / (1.0 + p.CDecay*float64(visits))
+	if c < p.CMin {
+		return p.CMin
+	}
+
+	return c
 }
 
 func main() {
 	var params RunParams
+	flag.StringVar(&params.DeckType, "decktype", "core",
+		"Composition of the deck (see cards.DeckConfigs)")
+	flag.IntVar(&params.CardsPerPlayer, "cards_per_player", 4,
+		"Number of cards dealt to each player, in addition to their Defuse")
+	flag.StringVar(&params.KittenPlacement, "kitten_placement", "uniform",
+		"Placement of the exploding kitten in the initial draw pile (uniform, never_top, bottom)")
 	flag.IntVar(&params.NumMCTSIterations, "iter", 100000, "Number of MCTS iterations to perform")
+	flag.DurationVar(&params.ThinkTime, "think_time", 0,
+		"If set, search for this long each turn instead of a fixed number of iterations (-iter)")
+	flag.BoolVar(&params.ValidateBeliefs, "validate_beliefs", false,
+		"Debug: re-validate belief states after each update, dropping invalid ones")
 	flag.Float64Var(&params.Temperature, "temperature", 0.1,
 		"Temperature used when selecting actions during play")
 	flag.Int64Var(&params.SamplingParams.Seed, "sampling.seed", 1234, "Random seed")
 	flag.Float64Var(&params.SamplingParams.C, "sampling.c", 1.75,
 		"Exploration factor C used in MCTS search")
+	flag.Float64Var(&params.SamplingParams.CDecay, "sampling.c_decay", 0.0,
+		"Hyperbolic decay rate of C with each visit to the root of the search (0 = no decay)")
+	flag.Float64Var(&params.SamplingParams.CMin, "sampling.c_min", 0.0,
+		"Minimum exploration factor C when decaying")
 	flag.Float64Var(&params.SamplingParams.Gamma, "sampling.gamma", 0.1,
 		"Mixing factor Gamma used in Smooth UCT search")
 	flag.Float64Var(&params.SamplingParams.Eta, "sampling.eta", 0.9,
@@ -56,36 +100,142 @@ func main() {
 	flag.Float64Var(&params.SamplingParams.D, "sampling.d", 0.001,
 		"Mixing factor d used in Smooth UCT search")
 
+	gameLogFile := flag.String("game_log", "",
+		"Write newline-delimited JSON events for each step of each game to this file")
+	dumpBeliefsFile := flag.String("dump_beliefs", "",
+		"Write a newline-delimited JSON snapshot of the belief state at each of the "+
+			"strategy's turns to this file")
+	flag.BoolVar(&profilePhases, "profile_phases", false,
+		"Publish the number of calls to and time spent in each phase of the search "+
+			"(e.g. phases/simulate/total_secs) at localhost:4123/debug/vars")
 	flag.Parse()
+	alphacats.SetLogger(glogger.Logger{})
+
+	if params.Temperature <= 0 {
+		glog.Fatalf("-temperature must be positive, got %v", params.Temperature)
+	}
 
 	rand.Seed(params.SamplingParams.Seed)
 	go http.ListenAndServe("localhost:4123", nil)
 
-	deck := cards.CoreDeck.AsSlice()
-	cardsPerPlayer := 4
-	optimizer := mcts.NewSmoothUCT(
-		float32(params.SamplingParams.C), float32(params.SamplingParams.Gamma),
-		float32(params.SamplingParams.Eta), float32(params.SamplingParams.D),
-		float32(params.Temperature))
-	for i := 0; ; i++ {
-		deal := alphacats.NewRandomDeal(deck, cardsPerPlayer)
-		playGame(optimizer, params, deal)
+	if *gameLogFile != "" {
+		f, err := os.Create(*gameLogFile)
+		if err != nil {
+			glog.Fatalf("Unable to create game log: %v", err)
+		}
+		defer f.Close()
+		gameLog = alphacats.NewGameLog(f, gamestate.Player0)
+	}
+
+	if *dumpBeliefsFile != "" {
+		f, err := os.Create(*dumpBeliefsFile)
+		if err != nil {
+			glog.Fatalf("Unable to create belief dump: %v", err)
+		}
+		defer f.Close()
+		beliefDump = json.NewEncoder(f)
+	}
+
+	deck := getDeck(params.DeckType)
+	if n := alphacats.MaxCardsPerPlayer(deck); params.CardsPerPlayer > n {
+		glog.Fatalf("Deck %q has enough cards for at most %d cards per player, got -cards_per_player=%d",
+			params.DeckType, n, params.CardsPerPlayer)
+	}
+	dealConfig := alphacats.DealConfig{
+		KittenPlacement: kittenPlacement(params.KittenPlacement),
+		Deck:            cards.NewSetFromCards(deck),
+	}
+	optimizer := newSmoothUCT(params)
+	for {
+		deal := alphacats.NewRandomDealWithConfig(deck, params.CardsPerPlayer, dealConfig)
+		playGame(optimizer, params, dealConfig, deal)
 	}
 }
 
-func simulate(optimizer *mcts.SmoothUCT, beliefs *alphacats.BeliefState, n int) {
-	var wg sync.WaitGroup
+func getDeck(deckType string) []cards.Card {
+	config, err := cards.GetDeckConfig(deckType)
+	if err != nil {
+		glog.Fatal(err)
+	}
+
+	deck, err := config.Deck()
+	if err != nil {
+		glog.Fatal(err)
+	}
+
+	return deck.AsSlice()
+}
+
+func kittenPlacement(name string) alphacats.KittenPlacement {
+	switch name {
+	case "uniform":
+		return alphacats.UniformKittenPlacement
+	case "never_top":
+		return alphacats.NeverTopKittenPlacement
+	case "bottom":
+		return alphacats.BottomKittenPlacement
+	default:
+		glog.Fatalf("Unknown kitten placement: %v", name)
+		return nil
+	}
+}
+
+func simulate(optimizer *smoothUCT, beliefs *alphacats.BeliefState, params RunParams) {
+	defer simulateTimer.start()()
+	sampleDeterminization := params.DeterminizationSampler
+	if sampleDeterminization == nil {
+		sampleDeterminization = alphacats.UniformDeterminizationSampler
+	}
+
+	ctx := context.Background()
 	nWorkers := runtime.NumCPU()
-	nPerWorker := n / nWorkers
-	glog.Infof("Simulating %d games in %d workers", nWorkers*nPerWorker, nWorkers)
+	limited := params.ThinkTime <= 0
+	if limited {
+		glog.Infof("Simulating %d games in %d workers", params.NumMCTSIterations, nWorkers)
+	} else {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, params.ThinkTime)
+		defer cancel()
+		glog.Infof("Simulating games for %v in %d workers", params.ThinkTime, nWorkers)
+	}
+
+	runWorkers(ctx, nWorkers, params.NumMCTSIterations, limited, func(rng *rand.Rand) {
+		done := sampleDeterminizationTimer.start()
+		game, err := sampleDeterminization(beliefs)
+		done()
+		if err != nil {
+			// Drop this sample and continue searching with the next one.
+			glog.Warningf("Skipping invalid determinization: %v", err)
+			return
+		}
+
+		optimizer.Run(rng, game)
+	})
+}
+
+// runWorkers calls run in each of nWorkers goroutines until ctx is done.
+// If limited, the workers also stop once they have called run n times in
+// total: the calls are spread as evenly as possible, with each worker
+// running at least once. Each worker has its own source of randomness.
+func runWorkers(ctx context.Context, nWorkers, n int, limited bool, run func(rng *rand.Rand)) {
+	var wg sync.WaitGroup
 	for worker := 0; worker < nWorkers; worker++ {
+		nPerWorker := n / nWorkers
+		if worker < n%nWorkers {
+			nPerWorker++
+		}
+		nPerWorker = max(1, nPerWorker)
+
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
 			rng := rand.New(rand.NewSource(rand.Int63()))
-			for k := 0; k < nPerWorker; k++ {
-				game := beliefs.SampleDeterminization()
-				optimizer.Run(rng, game)
+			for k := 0; !limited || k < nPerWorker; k++ {
+				if ctx.Err() != nil {
+					return
+				}
+
+				run(rng)
 			}
 		}()
 	}
@@ -93,46 +243,71 @@ func simulate(optimizer *mcts.SmoothUCT, beliefs *alphacats.BeliefState, n int)
 	wg.Wait()
 }
 
-func playGame(policy *mcts.SmoothUCT, params RunParams, deal alphacats.Deal) {
-	var game cfr.GameTreeNode = alphacats.NewGame(deal.DrawPile, deal.P0Deal, deal.P1Deal)
+func playGame(policy *smoothUCT, params RunParams, dealConfig alphacats.DealConfig, deal alphacats.Deal) {
+	game := alphacats.NewGame(deal.DrawPile, deal.P0Deal, deal.P1Deal)
 
 	glog.Infof("Building initial info set")
-	infoSet := game.(*alphacats.GameNode).GetInfoSet(gamestate.Player1)
-	beliefs := alphacats.NewBeliefState(policy.GetPolicy, infoSet)
+	infoSet := game.GetInfoSet(gamestate.Player1)
+	done := newBeliefsTimer.start()
+	beliefs := alphacats.NewBeliefStateWithConfig(policy.GetPolicy, infoSet, dealConfig)
+	done()
+	if params.ValidateBeliefs {
+		beliefs.EnableValidation()
+	}
 	glog.Infof("Initial info set has %d game states", beliefs.Len())
-	simulate(policy, beliefs, params.NumMCTSIterations)
-
-	for game.Type() != cfr.TerminalNodeType {
-		if game.Type() == cfr.ChanceNodeType {
-			var p float64
-			game, p = game.SampleChild()
-			glog.Infof("[chance] Sampled child node with probability %v", p)
-		} else if game.Player() == 0 {
-			is := game.InfoSet(game.Player()).(*alphacats.AbstractedInfoSet)
-			glog.Infof("[player] Your turn. %d cards remaining in draw pile.",
-				game.(*alphacats.GameNode).GetDrawPile().Len())
-			glog.Infof("[player] Hand: %v, Choices:", is.Hand)
-			for i, action := range is.AvailableActions {
-				glog.Infof("%d: %v", i, action)
+	simulate(policy, beliefs, params)
+
+	rng := rand.New(rand.NewSource(rand.Int63()))
+	strategy := &alphacats.MCTS{
+		StrategyProfile: alphacats.StrategyProfile{Policy: timedPolicy(policy.GetPolicy), Rand: rng},
+		Player:          gamestate.Player1,
+		Beliefs:         beliefs,
+		Search: func(game *alphacats.GameNode, beliefs *alphacats.BeliefState) {
+			// No need to search if the outcome no longer depends on our choice.
+			// NB: This must be decided from our beliefs, since the game includes
+			// private information (e.g. the draw pile) that we do not know.
+			if winner, forced := beliefs.ForcedOutcome(); forced {
+				glog.V(1).Infof("[strategy] Outcome is forced (%v wins), skipping search", winner)
+			} else {
+				simulate(policy, beliefs, params)
 			}
 
-			selected := prompt("Which action? ")
-			game = game.GetChild(selected)
-			lastAction := game.(*alphacats.GameNode).LastAction()
-			glog.Infof("[player] Chose to %v", lastAction)
-		} else {
-			simulate(policy, beliefs, params.NumMCTSIterations)
-			p := policy.GetPolicy(game)
-			selected := sampling.SampleOne(p, rand.Float32())
-			game = game.GetChild(selected)
-			lastAction := game.(*alphacats.GameNode).LastAction()
-			glog.Infof("[strategy] Chose to %v with probability %v: %v",
-				hidePrivateInfo(lastAction), p[selected], p)
-			glog.V(4).Infof("[strategy] Action result was: %v", lastAction)
-		}
+			h := game.GetHistory()
+			if err := dumpBeliefs(beliefs, h.Len()); err != nil {
+				glog.Errorf("Error writing belief dump: %v", err)
+			}
+		},
+	}
+
+	// NB: The hint is searched with its own optimizer, sampling from beliefs
+	// built from your info set, so that it is limited to information
+	// available to you. The computer's policy is used to model its actions.
+	hintOptimizer := newSmoothUCT(params)
+	human := &alphacats.HumanPrompt{
+		In:  stdin,
+		Out: os.Stdout,
+		Hint: func(game *alphacats.GameNode, available []gamestate.Action) {
+			// NB: A shorter search is used, since the hint is only a guide.
+			hintParams := params
+			hintParams.NumMCTSIterations = params.NumMCTSIterations / 10
+			hintParams.ThinkTime = params.ThinkTime / 10
+			done := newBeliefsTimer.start()
+			hintBeliefs := alphacats.NewBeliefStateFromInfoSetWithConfig(
+				policy.GetPolicy, game.GetInfoSet(gamestate.Player0), dealConfig)
+			done()
+			simulate(hintOptimizer, hintBeliefs, hintParams)
+			p := hintOptimizer.GetPolicy(game)
+			glog.Info("[hint] Current policy:")
+			for i, action := range available {
+				glog.Infof("%d: %v (%.3f)", i, action, p[i])
+			}
+		},
+	}
 
-		glog.Info("Propagating beliefs")
-		beliefs.Update(game.(*alphacats.GameNode).GetInfoSet(gamestate.Player1))
+	sources := [2]alphacats.ActionSource{human, timedMCTS{strategy}}
+	game, err := alphacats.PlayGame(game, sources, rng, gameLog)
+	if err != nil {
+		glog.Fatal(err)
 	}
 
 	glog.Info("GAME OVER")
@@ -143,33 +318,30 @@ func playGame(policy *mcts.SmoothUCT, params RunParams, deal alphacats.Deal) {
 	}
 
 	glog.Info("Game history:")
-	h := game.(*alphacats.GameNode).GetHistory()
+	h := game.GetHistory()
 	for i, action := range h.AsSlice() {
 		glog.Infof("%d: %v", i, action)
 	}
 }
 
-func prompt(msg string) int {
-	for {
-		fmt.Print(msg)
-		result, err := stdin.ReadString('\n')
-		if err != nil {
-			panic(err)
-		}
-
-		result = strings.TrimRight(result, "\n")
-		i, err := strconv.Atoi(result)
-		if err != nil {
-			glog.Errorf("Invalid selection: %v", result)
-			continue
-		}
+// dumpBeliefs writes a snapshot of the belief state, labeled with the given
+// turn, to the belief dump (if enabled).
+func dumpBeliefs(beliefs *alphacats.BeliefState, turn int) error {
+	if beliefDump == nil {
+		return nil
+	}
 
-		return i
+	snapshot, err := beliefs.Snapshot(turn)
+	if err != nil {
+		return err
 	}
+
+	return beliefDump.Encode(snapshot)
 }
 
-func hidePrivateInfo(a gamestate.Action) gamestate.Action {
-	a.PositionInDrawPile = 0
-	a.CardsSeen = [3]cards.Card{}
-	return a
+func max(i, j int) int {
+	if i > j {
+		return i
+	}
+	return j
 }
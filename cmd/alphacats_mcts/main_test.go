@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestExplorationC(t *testing.T) {
+	testCases := []struct {
+		params   SamplingParams
+		visits   int
+		expected float64
+	}{
+		// No decay is constant.
+		{SamplingParams{C: 1.75}, 0, 1.75},
+		{SamplingParams{C: 1.75}, 1000, 1.75},
+		// Hyperbolic decay.
+		{SamplingParams{C: 2.0, CDecay: 0.5}, 0, 2.0},
+		{SamplingParams{C: 2.0, CDecay: 0.5}, 2, 1.0},
+		{SamplingParams{C: 2.0, CDecay: 0.5}, 6, 0.5},
+		// Bounded below by CMin.
+		{SamplingParams{C: 2.0, CDecay: 0.5, CMin: 0.8}, 2, 1.0},
+		{SamplingParams{C: 2.0, CDecay: 0.5, CMin: 0.8}, 6, 0.8},
+	}
+
+	for _, tc := range testCases {
+		c := tc.params.ExplorationC(tc.visits)
+		if math.Abs(c-tc.expected) > 1e-9 {
+			t.Errorf("%+v: expected C = %v after %d visits, got %v",
+				tc.params, tc.expected, tc.visits, c)
+		}
+	}
+}
+
+func TestRunWorkersDeadline(t *testing.T) {
+	thinkTime := 50 * time.Millisecond
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), thinkTime)
+	defer cancel()
+
+	var n int64
+	runWorkers(ctx, 4, 0, false, func(rng *rand.Rand) {
+		atomic.AddInt64(&n, 1)
+		time.Sleep(time.Millisecond)
+	})
+	elapsed := time.Since(start)
+
+	// Each worker may finish at most one iteration after the deadline.
+	// NB: The upper bound is generous so that the test does not flake
+	// on a loaded machine.
+	if tolerance := time.Second; elapsed < thinkTime || elapsed > thinkTime+tolerance {
+		t.Errorf("expected search to stop after %v (+ at most %v), took %v", thinkTime, tolerance, elapsed)
+	}
+	if n == 0 {
+		t.Error("expected search to run before the deadline")
+	}
+}
+
+func TestRunWorkersIterations(t *testing.T) {
+	testCases := []struct {
+		nWorkers, n int
+		expected    int64
+	}{
+		{4, 40, 40},
+		// The remainder is spread across the workers.
+		{4, 42, 42},
+		// Each worker runs at least once, rather than forever.
+		{4, 2, 4},
+		{4, 0, 4},
+	}
+
+	for _, tc := range testCases {
+		var n int64
+		runWorkers(context.Background(), tc.nWorkers, tc.n, true, func(rng *rand.Rand) {
+			atomic.AddInt64(&n, 1)
+		})
+
+		if n != tc.expected {
+			t.Errorf("%d iterations in %d workers: expected %d calls, got %d",
+				tc.n, tc.nWorkers, tc.expected, n)
+		}
+	}
+}
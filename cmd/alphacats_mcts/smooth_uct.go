@@ -0,0 +1,275 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+
+	"github.com/timpalpant/go-cfr"
+
+	"github.com/timpalpant/alphacats"
+)
+
+// smoothUCT is Smooth UCT search (Heinrich & Silver, 2015), like
+// mcts.SmoothUCT, except that the exploration factor C follows a schedule
+// over the number of visits to the root of the search. (The C of an
+// mcts.SmoothUCT cannot be changed once it has been created.)
+//
+// It is safe to call Run and GetPolicy concurrently.
+type smoothUCT struct {
+	// explorationC returns the exploration factor to use for a simulation,
+	// given the number of times the root of the simulation has been visited.
+	explorationC func(rootVisits int) float64
+	gamma        float32
+	eta          float32
+	d            float32
+	temperature  float32
+
+	mx   sync.Mutex
+	tree map[string]*uctNode
+}
+
+func newSmoothUCT(params RunParams) *smoothUCT {
+	return &smoothUCT{
+		explorationC: params.SamplingParams.ExplorationC,
+		gamma:        float32(params.SamplingParams.Gamma),
+		eta:          float32(params.SamplingParams.Eta),
+		d:            float32(params.SamplingParams.D),
+		temperature:  float32(params.Temperature),
+		tree:         make(map[string]*uctNode),
+	}
+}
+
+// Run performs one simulation of the search from node, and returns the
+// sampled value of node to the player to act.
+func (s *smoothUCT) Run(rng *rand.Rand, node cfr.GameTreeNode) float32 {
+	c := float32(s.explorationC(s.visits(node)))
+	return s.run(rng, node, c, node.Player())
+}
+
+// GetPolicy returns the average strategy of the search at the info set of
+// the player to act in node, sharpened by the temperature. It is uniform if
+// the info set has not been searched.
+func (s *smoothUCT) GetPolicy(node cfr.GameTreeNode) []float32 {
+	n := s.lookup(node)
+	if n == nil {
+		return uniform(node.NumChildren())
+	}
+
+	n.mx.Lock()
+	defer n.mx.Unlock()
+	return sharpen(n.avgPolicy, s.temperature)
+}
+
+// sharpen returns counts^(1/temperature), normalized. It is computed in
+// log space relative to the largest count, since the power of large visit
+// counts at a low temperature overflows (even as a float64).
+func sharpen(counts []float32, temperature float32) []float32 {
+	maxLogCount := math.Inf(-1)
+	for _, count := range counts {
+		if count > 0 {
+			maxLogCount = math.Max(maxLogCount, math.Log(float64(count)))
+		}
+	}
+
+	if math.IsInf(maxLogCount, -1) {
+		return uniform(len(counts))
+	}
+
+	result := make([]float32, len(counts))
+	var total float32
+	for i, count := range counts {
+		if count > 0 {
+			logP := (math.Log(float64(count)) - maxLogCount) / float64(temperature)
+			result[i] = float32(math.Exp(logP))
+			total += result[i]
+		}
+	}
+
+	for i := range result {
+		result[i] /= total
+	}
+
+	return result
+}
+
+// Returns the sampled value of node to player.
+func (s *smoothUCT) run(rng *rand.Rand, node cfr.GameTreeNode, c float32, player int) float32 {
+	switch node.Type() {
+	case cfr.TerminalNodeType:
+		return float32(node.Utility(player))
+	case cfr.ChanceNodeType:
+		child, _ := node.(*alphacats.GameNode).SampleChildWithRand(rng)
+		value := s.run(rng, child, c, player)
+		child.Close()
+		return value
+	}
+
+	n, created := s.getOrCreate(node)
+	if created {
+		return rollout(rng, node, player)
+	}
+
+	i, useUCB := n.selectAction(rng, c, s.gamma, s.eta, s.d)
+	child := node.GetChild(i)
+	value := s.run(rng, child, c, node.Player())
+	child.Close()
+	n.update(i, value, useUCB)
+
+	if node.Player() != player {
+		return -value
+	}
+
+	return value
+}
+
+// Returns the number of times the info set of the player to act in node
+// has been visited by the search.
+func (s *smoothUCT) visits(node cfr.GameTreeNode) int {
+	n := s.lookup(node)
+	if n == nil {
+		return 0
+	}
+
+	n.mx.Lock()
+	defer n.mx.Unlock()
+	return n.n
+}
+
+func (s *smoothUCT) lookup(node cfr.GameTreeNode) *uctNode {
+	if node.Type() != cfr.PlayerNodeType {
+		return nil
+	}
+
+	key := string(node.InfoSet(node.Player()).Key())
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return s.tree[key]
+}
+
+// Returns the search node for the info set of the player to act in node,
+// and whether it was newly added to the tree.
+func (s *smoothUCT) getOrCreate(node cfr.GameTreeNode) (*uctNode, bool) {
+	key := string(node.InfoSet(node.Player()).Key())
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if n, ok := s.tree[key]; ok {
+		return n, false
+	}
+
+	n := newUCTNode(node.NumChildren())
+	s.tree[key] = n
+	return n, true
+}
+
+// rollout returns the value to player of a uniformly random playout from node.
+func rollout(rng *rand.Rand, node cfr.GameTreeNode, player int) float32 {
+	var child cfr.GameTreeNode
+	switch node.Type() {
+	case cfr.TerminalNodeType:
+		return float32(node.Utility(player))
+	case cfr.ChanceNodeType:
+		child, _ = node.(*alphacats.GameNode).SampleChildWithRand(rng)
+	default:
+		child = node.GetChild(rng.Intn(node.NumChildren()))
+	}
+
+	value := rollout(rng, child, player)
+	child.Close()
+	return value
+}
+
+// uctNode holds the search statistics of one info set.
+type uctNode struct {
+	mx sync.Mutex
+	// Total number of visits, and the number of visits, total value and
+	// number of UCB selections of each action.
+	n          int
+	visits     []int
+	totalValue []float32
+	avgPolicy  []float32
+}
+
+func newUCTNode(numChildren int) *uctNode {
+	return &uctNode{
+		visits:     make([]int, numChildren),
+		totalValue: make([]float32, numChildren),
+		avgPolicy:  make([]float32, numChildren),
+	}
+}
+
+// Selects the action to simulate: the UCB action with probability
+// max(gamma, eta / (1 + d*sqrt(n))), and otherwise an action sampled from
+// the average strategy. Returns the action and whether it was UCB.
+func (n *uctNode) selectAction(rng *rand.Rand, c, gamma, eta, d float32) (int, bool) {
+	n.mx.Lock()
+	defer n.mx.Unlock()
+	etaK := eta / (1.0 + d*float32(math.Sqrt(float64(n.n))))
+	if etaK < gamma {
+		etaK = gamma
+	}
+
+	if rng.Float32() < etaK {
+		return n.ucb(c), true
+	}
+
+	return alphacats.SampleAction(n.averagePolicy(), rng.Float32()), false
+}
+
+// Returns the action with the highest upper confidence bound,
+// or the first action that has not yet been visited.
+func (n *uctNode) ucb(c float32) int {
+	logN := math.Log(float64(n.n))
+	best, bestScore := 0, math.Inf(-1)
+	for i, visits := range n.visits {
+		if visits == 0 {
+			return i
+		}
+
+		q := float64(n.totalValue[i]) / float64(visits)
+		score := q + float64(c)*math.Sqrt(logN/float64(visits))
+		if score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+
+	return best
+}
+
+func (n *uctNode) averagePolicy() []float32 {
+	var total float32
+	for _, count := range n.avgPolicy {
+		total += count
+	}
+
+	if total <= 0 {
+		return uniform(len(n.avgPolicy))
+	}
+
+	result := make([]float32, len(n.avgPolicy))
+	for i, count := range n.avgPolicy {
+		result[i] = count / total
+	}
+
+	return result
+}
+
+func (n *uctNode) update(action int, value float32, useUCB bool) {
+	n.mx.Lock()
+	defer n.mx.Unlock()
+	n.n++
+	n.visits[action]++
+	n.totalValue[action] += value
+	if useUCB {
+		n.avgPolicy[action]++
+	}
+}
+
+func uniform(n int) []float32 {
+	result := make([]float32, n)
+	for i := range result {
+		result[i] = 1.0 / float32(n)
+	}
+
+	return result
+}
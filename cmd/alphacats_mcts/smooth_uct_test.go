@@ -0,0 +1,99 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/timpalpant/alphacats"
+)
+
+func TestSmoothUCTExplorationSchedule(t *testing.T) {
+	game, err := alphacats.NewGameFromSpec(
+		"[Cat, Skip, ExplodingKitten, Cat]; {1 Defuse, 1 Skip}; {1 Defuse, 1 Cat}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	params := RunParams{
+		SamplingParams: SamplingParams{C: 2.0, CDecay: 0.5, Gamma: 0.1, Eta: 0.9, D: 0.001},
+		Temperature:    1.0,
+	}
+	optimizer := newSmoothUCT(params)
+	var rootVisits []int
+	var cs []float64
+	optimizer.explorationC = func(visits int) float64 {
+		rootVisits = append(rootVisits, visits)
+		c := params.SamplingParams.ExplorationC(visits)
+		cs = append(cs, c)
+		return c
+	}
+
+	rng := rand.New(rand.NewSource(123))
+	const n = 10
+	for i := 0; i < n; i++ {
+		optimizer.Run(rng, game)
+	}
+
+	// The first simulation adds the root to the tree, and each one after
+	// that visits it once.
+	for i, visits := range rootVisits {
+		expected := i - 1
+		if i == 0 {
+			expected = 0
+		}
+
+		if visits != expected {
+			t.Errorf("simulation %d: expected %d root visits, got %d", i, expected, visits)
+		}
+
+		if c := 2.0 / (1.0 + 0.5*float64(expected)); math.Abs(cs[i]-c) > 1e-9 {
+			t.Errorf("simulation %d: expected C = %v, got %v", i, c, cs[i])
+		}
+	}
+
+	if visits := optimizer.visits(game); visits != n-1 {
+		t.Errorf("expected %d root visits, got %d", n-1, visits)
+	}
+
+	p := optimizer.GetPolicy(game)
+	if len(p) != game.NumChildren() {
+		t.Fatalf("expected policy over %d actions, got %v", game.NumChildren(), p)
+	}
+
+	var total float32
+	for _, x := range p {
+		total += x
+	}
+	if math.Abs(float64(total-1.0)) > 1e-5 {
+		t.Errorf("expected normalized policy, got %v", p)
+	}
+}
+
+func TestSharpenLargeCounts(t *testing.T) {
+	// 100000^(1/0.1) overflows a float32 (and 100000^(1/0.001) a float64).
+	counts := []float32{100000, 50000, 0}
+	for _, temperature := range []float32{1.0, 0.1, 0.001} {
+		p := sharpen(counts, temperature)
+		expected := math.Pow(0.5, 1/float64(temperature))
+		if math.Abs(float64(p[1]/p[0])-expected) > 1e-5 {
+			t.Errorf("temperature %v: expected ratio %v, got %v", temperature, expected, p)
+		}
+
+		var total float32
+		for _, x := range p {
+			if math.IsNaN(float64(x)) || math.IsInf(float64(x), 0) {
+				t.Fatalf("temperature %v: expected finite policy, got %v", temperature, p)
+			}
+			total += x
+		}
+
+		if math.Abs(float64(total-1.0)) > 1e-5 || p[2] != 0 {
+			t.Errorf("temperature %v: expected normalized policy, got %v", temperature, p)
+		}
+	}
+
+	if p := sharpen([]float32{0, 0}, 0.1); p[0] != 0.5 || p[1] != 0.5 {
+		t.Errorf("expected uniform policy with no counts, got %v", p)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"expvar"
+	"time"
+
+	"github.com/timpalpant/go-cfr"
+
+	"github.com/timpalpant/alphacats"
+	"github.com/timpalpant/alphacats/gamestate"
+)
+
+// profilePhases enables the phase timers. It must be set before any
+// games are started.
+var profilePhases = false
+
+// phaseTimer counts the calls to, and total time spent in, one phase of the
+// search loop. It is published with expvar as "phases/<name>/calls" and
+// "phases/<name>/total_secs".
+type phaseTimer struct {
+	calls     *expvar.Int
+	totalSecs *expvar.Float
+}
+
+func newPhaseTimer(name string) phaseTimer {
+	return phaseTimer{
+		calls:     expvar.NewInt("phases/" + name + "/calls"),
+		totalSecs: expvar.NewFloat("phases/" + name + "/total_secs"),
+	}
+}
+
+var (
+	newBeliefsTimer            = newPhaseTimer("new_beliefs")
+	updateBeliefsTimer         = newPhaseTimer("update_beliefs")
+	sampleDeterminizationTimer = newPhaseTimer("sample_determinization")
+	simulateTimer              = newPhaseTimer("simulate")
+	getPolicyTimer             = newPhaseTimer("get_policy")
+)
+
+func noop() {}
+
+// start begins timing one call of the phase, and returns a func that ends
+// it. If profiling is disabled, nothing is timed, for example:
+//
+//	defer simulateTimer.start()()
+func (t phaseTimer) start() func() {
+	if !profilePhases {
+		return noop
+	}
+
+	start := time.Now()
+	return func() {
+		t.calls.Add(1)
+		t.totalSecs.Add(time.Since(start).Seconds())
+	}
+}
+
+// timedPolicy wraps policy to time each call with getPolicyTimer.
+func timedPolicy(policy func(cfr.GameTreeNode) []float32) func(cfr.GameTreeNode) []float32 {
+	return func(node cfr.GameTreeNode) []float32 {
+		defer getPolicyTimer.start()()
+		return policy(node)
+	}
+}
+
+// timedMCTS times the update of the strategy's beliefs with
+// updateBeliefsTimer. The beliefs are updated before selecting each action,
+// so the update made by alphacats.MCTS itself has nothing left to do.
+type timedMCTS struct {
+	*alphacats.MCTS
+}
+
+func (m timedMCTS) SelectAction(game *alphacats.GameNode, available []gamestate.Action) (int, error) {
+	done := updateBeliefsTimer.start()
+	m.Beliefs.Update(game.GetInfoSet(m.Player))
+	done()
+	return m.MCTS.SelectAction(game, available)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"math/rand"
+	"runtime"
+	"testing"
+
+	"github.com/timpalpant/go-cfr"
+
+	"github.com/timpalpant/alphacats"
+	"github.com/timpalpant/alphacats/gamestate"
+)
+
+func uniformPolicy(node cfr.GameTreeNode) []float32 {
+	p := make([]float32, node.NumChildren())
+	for i := range p {
+		p[i] = 1.0 / float32(len(p))
+	}
+	return p
+}
+
+func TestPhaseTimers(t *testing.T) {
+	timers := map[string]phaseTimer{
+		"new_beliefs":            newBeliefsTimer,
+		"update_beliefs":         updateBeliefsTimer,
+		"sample_determinization": sampleDeterminizationTimer,
+		"simulate":               simulateTimer,
+		"get_policy":             getPolicyTimer,
+	}
+	calls := func() map[string]int64 {
+		result := make(map[string]int64)
+		for name, timer := range timers {
+			result[name] = timer.calls.Value()
+		}
+		return result
+	}
+
+	// Play a short game, in which the strategy (player 1) searches each turn.
+	playShortGame := func() {
+		game, err := alphacats.NewGameFromSpec(
+			"[Cat, Skip, ExplodingKitten, Cat]; {1 Defuse, 1 Skip}; {1 Defuse, 1 Cat}")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		done := newBeliefsTimer.start()
+		beliefs := alphacats.NewBeliefState(uniformPolicy, game.GetInfoSet(gamestate.Player1))
+		done()
+
+		params := RunParams{NumMCTSIterations: runtime.NumCPU()}
+		params.SamplingParams = SamplingParams{C: 1.0, Gamma: 0.1, Eta: 0.9, D: 0.001}
+		params.Temperature = 1.0
+		optimizer := newSmoothUCT(params)
+		rng := rand.New(rand.NewSource(123))
+		strategy := &alphacats.MCTS{
+			StrategyProfile: alphacats.StrategyProfile{Policy: timedPolicy(uniformPolicy), Rand: rng},
+			Player:          gamestate.Player1,
+			Beliefs:         beliefs,
+			Search: func(game *alphacats.GameNode, beliefs *alphacats.BeliefState) {
+				simulate(optimizer, beliefs, params)
+			},
+		}
+
+		opponent := &alphacats.StrategyProfile{Policy: uniformPolicy, Rand: rng}
+		sources := [2]alphacats.ActionSource{opponent, timedMCTS{strategy}}
+		if _, err := alphacats.PlayGame(game, sources, rng, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Nothing is timed when profiling is disabled.
+	before := calls()
+	playShortGame()
+	for name, n := range calls() {
+		if n != before[name] {
+			t.Errorf("%s: expected no calls while disabled, got %d", name, n-before[name])
+		}
+	}
+
+	profilePhases = true
+	defer func() { profilePhases = false }()
+	before = calls()
+	playShortGame()
+	after := calls()
+	for name, timer := range timers {
+		if after[name] <= before[name] {
+			t.Errorf("%s: expected calls to be counted, got %d", name, after[name]-before[name])
+		}
+
+		if timer.totalSecs.Value() < 0 {
+			t.Errorf("%s: invalid total time %v", name, timer.totalSecs.Value())
+		}
+	}
+
+	// Each search samples a determinization per iteration.
+	nSearches := after["simulate"] - before["simulate"]
+	nSamples := after["sample_determinization"] - before["sample_determinization"]
+	if nSamples != nSearches*int64(runtime.NumCPU()) {
+		t.Errorf("expected %d determinizations for %d searches, got %d",
+			nSearches*int64(runtime.NumCPU()), nSearches, nSamples)
+	}
+}
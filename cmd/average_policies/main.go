@@ -0,0 +1,70 @@
+// Ensemble tabular policies (e.g. from training runs with different seeds)
+// by averaging the policy of each info set over the tables that contain it.
+// If every table was saved with visit counts, each policy is weighted by
+// how often its info set was visited (see model.AverageWeightedPolicyTables).
+//
+// Usage: average_policies -output ensemble.policy a.policy b.policy ...
+package main
+
+import (
+	"flag"
+
+	"github.com/golang/glog"
+
+	"github.com/timpalpant/alphacats"
+	"github.com/timpalpant/alphacats/cmd/internal/glogger"
+	"github.com/timpalpant/alphacats/model"
+)
+
+func main() {
+	output := flag.String("output", "", "File to save the averaged tabular policy to")
+	flag.Parse()
+	alphacats.SetLogger(glogger.Logger{})
+
+	if *output == "" || flag.NArg() == 0 {
+		glog.Fatal("Must specify -output and at least one tabular policy to average")
+	}
+
+	if err := averagePolicyFiles(flag.Args(), *output); err != nil {
+		glog.Fatal(err)
+	}
+}
+
+// averagePolicyFiles averages the tabular policies in the given files, and
+// saves the result to output. The result has visit counts if all of the
+// inputs do.
+func averagePolicyFiles(filenames []string, output string) error {
+	tables := make([]model.PolicyTable, len(filenames))
+	visits := make([]*model.VisitCounts, len(filenames))
+	weighted := true
+	for i, filename := range filenames {
+		dp, err := model.OpenDiskPolicy(filename)
+		if err != nil {
+			return err
+		}
+
+		weighted = weighted && dp.HasVisits()
+		tables[i], visits[i] = dp.Load()
+		dp.Close()
+		glog.Infof("Loaded policy for %d info sets from %v", len(tables[i]), filename)
+	}
+
+	if !weighted {
+		glog.Warning("Not all tables have visit counts, weighting them equally")
+		result, err := model.AveragePolicyTables(tables...)
+		if err != nil {
+			return err
+		}
+
+		glog.Infof("Saving policy for %d info sets to %v", len(result), output)
+		return model.WritePolicyTable(result, output)
+	}
+
+	result, totalVisits, err := model.AverageWeightedPolicyTables(tables, visits)
+	if err != nil {
+		return err
+	}
+
+	glog.Infof("Saving policy for %d info sets to %v", len(result), output)
+	return model.WritePolicyTableWithVisits(result, totalVisits, output)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/timpalpant/alphacats/model"
+)
+
+func TestAveragePolicyFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "average_policies")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tables := []model.PolicyTable{
+		{"a": {1.0, 0.0}},
+		{"a": {0.0, 1.0}},
+	}
+	var filenames []string
+	for i, table := range tables {
+		visits := model.NewVisitCounts()
+		visits.Add([]byte("a"), uint32(3-2*i))
+		filename := filepath.Join(dir, []string{"a.policy", "b.policy"}[i])
+		if err := model.WritePolicyTableWithVisits(table, visits, filename); err != nil {
+			t.Fatal(err)
+		}
+		filenames = append(filenames, filename)
+	}
+
+	// Weighted 3:1 by visits.
+	output := filepath.Join(dir, "avg.policy")
+	if err := averagePolicyFiles(filenames, output); err != nil {
+		t.Fatal(err)
+	}
+	checkPolicy(t, output, []float32{0.75, 0.25}, true)
+
+	// Weighted equally if any table has no visit counts.
+	if err := model.WritePolicyTable(tables[1], filenames[1]); err != nil {
+		t.Fatal(err)
+	}
+	if err := averagePolicyFiles(filenames, output); err != nil {
+		t.Fatal(err)
+	}
+	checkPolicy(t, output, []float32{0.5, 0.5}, false)
+}
+
+func checkPolicy(t *testing.T, filename string, expected []float32, hasVisits bool) {
+	dp, err := model.OpenDiskPolicy(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dp.Close()
+
+	if dp.HasVisits() != hasVisits {
+		t.Errorf("expected averaged table to have visits: %v", hasVisits)
+	}
+
+	p, ok := dp.Lookup([]byte("a"))
+	if !ok || len(p) != len(expected) {
+		t.Fatalf("expected policy %v, got %v", expected, p)
+	}
+	for i := range p {
+		if math.Abs(float64(p[i]-expected[i])) > 1e-6 {
+			t.Errorf("expected policy %v, got %v", expected, p)
+			break
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/timpalpant/alphacats"
 	"github.com/timpalpant/alphacats/cards"
+	"github.com/timpalpant/alphacats/cmd/internal/glogger"
 
 	"github.com/golang/glog"
 )
@@ -26,6 +27,7 @@ func enumerateDeals(deck cards.Set, result cards.Set, n int, cb func(deal cards.
 
 func main() {
 	flag.Parse()
+	alphacats.SetLogger(glogger.Logger{})
 
 	p0Deals := make(map[cards.Set]struct{})
 	enumerateDeals(cards.CoreDeck, cards.NewSet(), 4, func(deal cards.Set) {
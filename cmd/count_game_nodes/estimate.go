@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+
+	"github.com/timpalpant/go-cfr"
+)
+
+// Estimate is an estimate of the number of nodes in a game tree.
+// As in chanceSampling, a single outcome is sampled at each chance node.
+type Estimate struct {
+	// Counted is the number of nodes that were counted exactly.
+	Counted int
+	// Total is the estimated total number of nodes, including those counted.
+	Total float64
+	// StdErr is the standard error of Total.
+	StdErr float64
+}
+
+func (e Estimate) String() string {
+	return fmt.Sprintf("%d nodes counted, estimated total %.4g +/- %.2g nodes",
+		e.Counted, e.Total, e.StdErr)
+}
+
+// estimator counts the game tree exactly down to maxDepth, or until budget
+// nodes have been counted. Beyond that, the size of each remaining subtree is
+// extrapolated from its branching factors along random probes (Knuth, 1975).
+type estimator struct {
+	maxDepth  int
+	budget    int
+	numProbes int
+	rng       *rand.Rand
+	// fullTree, if set, makes probes count every outcome of chance nodes,
+	// to extrapolate the size of the full game tree rather than the tree
+	// with a single outcome sampled at each chance node.
+	fullTree bool
+}
+
+func (e *estimator) estimate(node cfr.GameTreeNode, depth int) Estimate {
+	defer node.Close()
+	switch node.Type() {
+	case cfr.ChanceNodeType:
+		child, _ := node.SampleChild()
+		result := e.estimate(child, depth+1)
+		result.Counted++
+		result.Total++
+		return result
+	case cfr.TerminalNodeType:
+		return Estimate{Counted: 1, Total: 1}
+	}
+
+	if depth >= e.maxDepth || e.budget <= 0 {
+		return e.extrapolate(node)
+	}
+
+	e.budget--
+	result := Estimate{Counted: 1, Total: 1}
+	variance := 0.0
+	for i := 0; i < node.NumChildren(); i++ {
+		child := e.estimate(node.GetChild(i), depth+1)
+		result.Counted += child.Counted
+		result.Total += child.Total
+		variance += child.StdErr * child.StdErr
+	}
+
+	result.StdErr = math.Sqrt(variance)
+	return result
+}
+
+// Estimates the size of the subtree rooted at node from the mean of
+// numProbes random probes.
+func (e *estimator) extrapolate(node cfr.GameTreeNode) Estimate {
+	var sum, sumSq float64
+	for k := 0; k < e.numProbes; k++ {
+		x := e.probe(node)
+		sum += x
+		sumSq += x * x
+	}
+
+	n := float64(e.numProbes)
+	mean := sum / n
+	variance := 0.0
+	if e.numProbes > 1 {
+		variance = (sumSq - n*mean*mean) / (n - 1)
+	}
+
+	return Estimate{
+		Total:  mean,
+		StdErr: math.Sqrt(math.Max(variance, 0) / n),
+	}
+}
+
+// Walks a random path from node to a terminal, returning the unbiased
+// estimate of the subtree size: 1 + b0 + b0*b1 + ..., where bi is the
+// branching factor at depth i along the path.
+func (e *estimator) probe(node cfr.GameTreeNode) float64 {
+	var path []cfr.GameTreeNode
+	total, weight := 1.0, 1.0
+	for node.Type() != cfr.TerminalNodeType {
+		if node.Type() == cfr.ChanceNodeType && !e.fullTree {
+			node, _ = node.SampleChild()
+		} else {
+			n := node.NumChildren()
+			weight *= float64(n)
+			node = node.GetChild(e.rng.Intn(n))
+		}
+
+		path = append(path, node)
+		total += weight
+	}
+
+	// Children must be closed before their parents.
+	for i := len(path) - 1; i >= 0; i-- {
+		path[i].Close()
+	}
+
+	return total
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/timpalpant/alphacats"
+	"github.com/timpalpant/alphacats/internal/testgames"
+)
+
+func newTestDeckGame() *alphacats.GameNode {
+	return alphacats.NewGame(testgames.TestDeck())
+}
+
+func TestEstimateFullDepth(t *testing.T) {
+	rand.Seed(123)
+	expected := chanceSampling(newTestDeckGame())
+
+	rand.Seed(123)
+	e := &estimator{
+		maxDepth:  math.MaxInt32,
+		budget:    math.MaxInt32,
+		numProbes: 10,
+		rng:       rand.New(rand.NewSource(1)),
+	}
+	result := e.estimate(newTestDeckGame(), 0)
+	if result.Counted != expected || result.Total != float64(expected) || result.StdErr != 0 {
+		t.Errorf("expected exactly %d nodes, got %v", expected, result)
+	}
+}
+
+func TestEstimateCutoff(t *testing.T) {
+	rand.Seed(123)
+	exact := chanceSampling(newTestDeckGame())
+
+	e := &estimator{
+		maxDepth:  3,
+		budget:    math.MaxInt32,
+		numProbes: 10,
+		rng:       rand.New(rand.NewSource(1)),
+	}
+	result := e.estimate(newTestDeckGame(), 0)
+	if result.Counted <= 0 || result.Counted >= exact {
+		t.Errorf("expected to count fewer than %d nodes exactly, got %v", exact, result)
+	}
+
+	if result.Total < float64(result.Counted) || result.StdErr <= 0 {
+		t.Errorf("invalid estimate: %v", result)
+	}
+}
@@ -4,6 +4,8 @@ package main
 import (
 	"expvar"
 	"flag"
+	"math"
+	"math/rand"
 	"net/http"
 	_ "net/http/pprof"
 	"runtime"
@@ -14,12 +16,31 @@ import (
 
 	"github.com/timpalpant/alphacats"
 	"github.com/timpalpant/alphacats/cards"
+	"github.com/timpalpant/alphacats/cmd/internal/glogger"
 )
 
 var workInProgress = expvar.NewInt("work_in_progress")
 
 func main() {
+	maxDepth := flag.Int("max_depth", 0,
+		"Count nodes exactly up to this depth, and extrapolate beyond it (0 = unlimited)")
+	budget := flag.Int("budget", 0,
+		"Maximum number of nodes to count exactly before extrapolating (0 = unlimited)")
+	numProbes := flag.Int("num_probes", 100,
+		"Number of random probes used to extrapolate the size of each subtree")
+	deckType := flag.String("decktype", "core", "Composition of the deck (see cards.DeckConfigs)")
+	cardsPerPlayer := flag.Int("cards_per_player", 4,
+		"Number of cards dealt to each player, in addition to their Defuse")
+	estimateMemory := flag.Bool("estimate_memory", false,
+		"Estimate the memory needed for the strategy table of a CFR run on a single random deal, "+
+			"rather than counting nodes. Info sets are enumerated up to -budget nodes, and extrapolated "+
+			"beyond it. NB: The estimate is per deal, a run over many deals needs more memory")
+	bytesPerInfoSet := flag.Int("bytes_per_info_set", 128,
+		"With -estimate_memory, the memory used by each info set in addition to its key and actions")
+	bytesPerAction := flag.Int("bytes_per_action", 16,
+		"With -estimate_memory, the memory used for each available action of an info set")
 	flag.Parse()
+	alphacats.SetLogger(glogger.Logger{})
 
 	go http.ListenAndServe("localhost:4124", nil)
 
@@ -33,9 +54,55 @@ func main() {
 	}
 	defer close(workCh)
 
-	deck := cards.CoreDeck.AsSlice()
-	deal := alphacats.NewRandomDeal(deck, 4)
+	deckConfig, err := cards.GetDeckConfig(*deckType)
+	if err != nil {
+		glog.Fatal(err)
+	}
+	deckSet, err := deckConfig.Deck()
+	if err != nil {
+		glog.Fatal(err)
+	}
+
+	deck := deckSet.AsSlice()
+	if n := alphacats.MaxCardsPerPlayer(deck); *cardsPerPlayer > n {
+		glog.Fatalf("Deck %q has enough cards for at most %d cards per player, got -cards_per_player=%d",
+			*deckType, n, *cardsPerPlayer)
+	}
+
+	deal := alphacats.NewRandomDeal(deck, *cardsPerPlayer)
+	if *estimateMemory {
+		m := &memoryEstimator{
+			budget:          *budget,
+			numProbes:       *numProbes,
+			rng:             rand.New(rand.NewSource(rand.Int63())),
+			bytesPerInfoSet: *bytesPerInfoSet,
+			bytesPerAction:  *bytesPerAction,
+		}
+
+		glog.Infof("Estimating memory for deal: %+v", deal)
+		glog.Info(m.estimate(deal))
+		return
+	}
+
 	game := alphacats.NewGame(deal.DrawPile, deal.P0Deal, deal.P1Deal)
+	if *maxDepth > 0 || *budget > 0 {
+		e := &estimator{
+			maxDepth:  *maxDepth,
+			budget:    *budget,
+			numProbes: *numProbes,
+			rng:       rand.New(rand.NewSource(rand.Int63())),
+		}
+		if e.maxDepth <= 0 {
+			e.maxDepth = math.MaxInt32
+		}
+		if e.budget <= 0 {
+			e.budget = math.MaxInt32
+		}
+
+		glog.Info(e.estimate(game, 0))
+		return
+	}
+
 	result := countParallel(game, workCh)
 	glog.Info(result)
 }
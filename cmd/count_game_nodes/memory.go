@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/timpalpant/alphacats"
+)
+
+// MemoryEstimate is an estimate of the memory needed for the strategy table
+// of a CFR run on a single deal, which has an entry for each distinct info set.
+//
+// NOTE: It is not extrapolated over deals. A run over random deals will visit
+// the info sets of many deals, which share some info sets with each other,
+// so it needs more memory than this estimate.
+type MemoryEstimate struct {
+	// InfoSetsCounted is the number of distinct info sets enumerated.
+	InfoSetsCounted int
+	// InfoSets is the estimated total number of distinct info sets,
+	// including those counted.
+	InfoSets float64
+	// Bytes is the estimated memory needed to store the info sets.
+	Bytes float64
+}
+
+func (m MemoryEstimate) String() string {
+	return fmt.Sprintf("Per deal: %d info sets counted, estimated total %.4g info sets using %.4g MB",
+		m.InfoSetsCounted, m.InfoSets, m.Bytes/(1<<20))
+}
+
+// memoryEstimator enumerates the info sets of both players in the game tree
+// of a deal (see alphacats.EnumerateInfoSetsWithBudget), until budget nodes
+// have been visited. If the tree is larger than the budget, the number of
+// info sets is extrapolated in proportion to the size of the full tree,
+// which is estimated from numProbes random probes (see estimator).
+//
+// NOTE: Later parts of the tree share info sets with those already
+// visited, so the extrapolation is an overestimate. It is intended to
+// give the order of magnitude needed for a run.
+type memoryEstimator struct {
+	budget    int
+	numProbes int
+	rng       *rand.Rand
+	// Each info set is assumed to take bytesPerInfoSet, plus the length
+	// of its key, plus bytesPerAction for each of its available actions.
+	bytesPerInfoSet int
+	bytesPerAction  int
+}
+
+func (m *memoryEstimator) estimate(deal alphacats.Deal) MemoryEstimate {
+	var result MemoryEstimate
+	var bytes float64
+	var nodes int
+	complete := true
+	for player := 0; player < 2; player++ {
+		n, ok := alphacats.EnumerateInfoSetsWithBudget(deal, player, m.budget,
+			func(is *alphacats.InfoSetWithAvailableActions) {
+				abstracted := is.Abstract()
+				result.InfoSetsCounted++
+				bytes += float64(m.bytesPerInfoSet + len(abstracted.Key()) +
+					m.bytesPerAction*len(is.AvailableActions))
+			})
+		nodes = n
+		complete = complete && ok
+	}
+
+	scale := 1.0
+	if !complete {
+		e := &estimator{numProbes: m.numProbes, rng: m.rng, fullTree: true}
+		game := alphacats.NewGame(deal.DrawPile, deal.P0Deal, deal.P1Deal)
+		scale = e.extrapolate(game).Total / float64(nodes)
+		game.Close()
+	}
+
+	result.InfoSets = scale * float64(result.InfoSetsCounted)
+	result.Bytes = scale * bytes
+	return result
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/timpalpant/alphacats"
+	"github.com/timpalpant/alphacats/internal/testgames"
+)
+
+func newTestDeckDeal() alphacats.Deal {
+	drawPile, p0Deal, p1Deal := testgames.TestDeck()
+	return alphacats.Deal{DrawPile: drawPile, P0Deal: p0Deal, P1Deal: p1Deal}
+}
+
+func TestEstimateMemoryFullTraversal(t *testing.T) {
+	deal := newTestDeckDeal()
+	exact := 0
+	for player := 0; player < 2; player++ {
+		alphacats.EnumerateInfoSets(deal, player, func(is *alphacats.InfoSetWithAvailableActions) {
+			exact++
+		})
+	}
+
+	m := &memoryEstimator{
+		numProbes:       10,
+		rng:             rand.New(rand.NewSource(1)),
+		bytesPerInfoSet: 100,
+		bytesPerAction:  10,
+	}
+	result := m.estimate(deal)
+	if result.InfoSetsCounted != exact || result.InfoSets != float64(exact) {
+		t.Errorf("expected exactly %d info sets, got %v", exact, result)
+	}
+
+	// Each info set has at least one action, and a non-empty key.
+	if result.Bytes <= float64(110*exact) {
+		t.Errorf("expected more than %d bytes, got %v", 110*exact, result)
+	}
+}
+
+func TestEstimateMemoryBudget(t *testing.T) {
+	m := &memoryEstimator{
+		budget:          100,
+		numProbes:       100,
+		rng:             rand.New(rand.NewSource(1)),
+		bytesPerInfoSet: 100,
+		bytesPerAction:  10,
+	}
+	result := m.estimate(newTestDeckDeal())
+	if result.InfoSetsCounted == 0 || result.InfoSets <= float64(result.InfoSetsCounted) {
+		t.Errorf("expected to extrapolate beyond the info sets counted, got %v", result)
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/timpalpant/go-cfr"
+	"github.com/timpalpant/go-cfr/mcts"
+
+	"github.com/timpalpant/alphacats"
+	"github.com/timpalpant/alphacats/cards"
+)
+
+type LengthParams struct {
+	Deck           []cards.Card
+	CardsPerPlayer int
+	NumGames       int
+	Seed           int64
+	MaxParallel    int
+}
+
+// sampleGameLengths plays NumGames games in which both players follow the
+// given policy, and returns the length of each game in plies. Results are
+// deterministic given params.Seed, provided that the policy is too.
+func sampleGameLengths(policy mcts.Policy, params LengthParams) []int {
+	// All randomness is drawn up front, so that the results do not depend
+	// on the order in which games are scheduled.
+	rand.Seed(params.Seed)
+	// NB: NewRandomDeal shuffles the deck in place.
+	deck := append([]cards.Card(nil), params.Deck...)
+	deals := make([]alphacats.Deal, params.NumGames)
+	seeds := make([]int64, params.NumGames)
+	for i := range deals {
+		deals[i] = alphacats.NewRandomDeal(deck, params.CardsPerPlayer)
+		seeds[i] = rand.Int63()
+	}
+
+	lengths := make([]int, params.NumGames)
+	var wg sync.WaitGroup
+	gameCh := make(chan int)
+	for worker := 0; worker < params.MaxParallel; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range gameCh {
+				game := alphacats.NewGame(deals[i].DrawPile, deals[i].P0Deal, deals[i].P1Deal)
+				lengths[i] = gameLength(policy, game, seeds[i])
+			}
+		}()
+	}
+
+	for i := range deals {
+		gameCh <- i
+	}
+	close(gameCh)
+	wg.Wait()
+
+	return lengths
+}
+
+// gameLength plays out the given game with both players following policy,
+// and returns the number of plies: the number of actions in the terminal
+// history. Chance outcomes that are not actions (shuffles) are not counted.
+func gameLength(policy mcts.Policy, game *alphacats.GameNode, seed int64) int {
+	rng := rand.New(rand.NewSource(seed))
+	var node cfr.GameTreeNode = game
+	for node.Type() != cfr.TerminalNodeType {
+		var selected int
+		if node.Type() == cfr.ChanceNodeType {
+			// All chance nodes are uniform random over their children.
+			selected = rng.Intn(node.NumChildren())
+		} else {
+			p := policy.GetPolicy(node)
+			selected = alphacats.SampleAction(p, rng.Float32())
+		}
+
+		node = node.GetChild(selected)
+	}
+
+	h := node.(*alphacats.GameNode).GetHistory()
+	return h.Len()
+}
+
+// LengthStats summarizes the distribution of game lengths.
+type LengthStats struct {
+	// Lengths of each game in plies, in increasing order.
+	Lengths []int
+}
+
+func newLengthStats(lengths []int) *LengthStats {
+	sorted := append([]int(nil), lengths...)
+	sort.Ints(sorted)
+	return &LengthStats{Lengths: sorted}
+}
+
+// Percentile returns the smallest length such that at least p percent of
+// games are no longer than it (the nearest-rank percentile).
+func (s *LengthStats) Percentile(p float64) int {
+	if len(s.Lengths) == 0 {
+		return 0
+	}
+
+	rank := int(math.Ceil(p / 100 * float64(len(s.Lengths))))
+	if rank < 1 {
+		rank = 1
+	}
+
+	return s.Lengths[rank-1]
+}
+
+// Mean returns the average game length.
+func (s *LengthStats) Mean() float64 {
+	if len(s.Lengths) == 0 {
+		return 0
+	}
+
+	total := 0
+	for _, n := range s.Lengths {
+		total += n
+	}
+
+	return float64(total) / float64(len(s.Lengths))
+}
+
+// WriteSummary writes a human-readable summary of the distribution.
+func (s *LengthStats) WriteSummary(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "Games:\t%d\n", len(s.Lengths))
+	fmt.Fprintf(tw, "Mean length (plies):\t%.2f\n", s.Mean())
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "Percentile\tLength\t")
+	for _, p := range []float64{0, 10, 25, 50, 75, 90, 99, 100} {
+		fmt.Fprintf(tw, "%g\t%d\t\n", p, s.Percentile(p))
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/timpalpant/go-cfr"
+
+	"github.com/timpalpant/alphacats"
+	"github.com/timpalpant/alphacats/cards"
+)
+
+// Always plays the i'th available action (or the last, if there are fewer).
+type fixedPolicy int
+
+func (f fixedPolicy) GetPolicy(node cfr.GameTreeNode) []float32 {
+	p := make([]float32, node.NumChildren())
+	if int(f) < len(p) {
+		p[f] = 1.0
+	} else {
+		p[len(p)-1] = 1.0
+	}
+
+	return p
+}
+
+func TestGameLength(t *testing.T) {
+	testCases := []struct {
+		drawPile []cards.Card
+		p0Deal   []cards.Card
+		expected int
+	}{
+		// Player 0 draws the Cat, then player 1 draws the kitten.
+		{[]cards.Card{cards.Cat, cards.ExplodingKitten}, nil, 2},
+		// Player 0 skips, player 1 draws the Cat, then player 0 draws the kitten.
+		{[]cards.Card{cards.Cat, cards.ExplodingKitten}, []cards.Card{cards.Skip}, 3},
+		// Player 0 draws the kitten.
+		{[]cards.Card{cards.ExplodingKitten, cards.Cat}, nil, 1},
+	}
+
+	for _, tc := range testCases {
+		drawPile := cards.NewStackFromCards(tc.drawPile)
+		game := alphacats.NewGame(drawPile, cards.NewSetFromCards(tc.p0Deal), cards.NewSet())
+		if n := gameLength(fixedPolicy(0), game, 123); n != tc.expected {
+			t.Errorf("%v: expected %d plies, got %d", tc.drawPile, tc.expected, n)
+		}
+	}
+}
+
+func TestSampleGameLengths(t *testing.T) {
+	params := LengthParams{
+		Deck:           cards.CoreDeck.AsSlice(),
+		CardsPerPlayer: 4,
+		NumGames:       20,
+		Seed:           123,
+		MaxParallel:    4,
+	}
+
+	lengths := sampleGameLengths(fixedPolicy(0), params)
+	if len(lengths) != params.NumGames {
+		t.Fatalf("expected %d games, got %d", params.NumGames, len(lengths))
+	}
+
+	for _, n := range lengths {
+		if n <= 0 {
+			t.Errorf("expected positive game length, got %d", n)
+		}
+	}
+
+	// Results do not depend on the number of workers.
+	params.MaxParallel = 1
+	if serial := sampleGameLengths(fixedPolicy(0), params); !reflect.DeepEqual(lengths, serial) {
+		t.Errorf("expected %v, got %v", lengths, serial)
+	}
+}
+
+func TestLengthStats(t *testing.T) {
+	stats := newLengthStats([]int{5, 1, 4, 2, 3, 6, 8, 7, 10, 9})
+	testCases := []struct {
+		p        float64
+		expected int
+	}{
+		{0, 1},
+		{10, 1},
+		{50, 5},
+		{55, 6},
+		{90, 9},
+		{100, 10},
+	}
+
+	for _, tc := range testCases {
+		if n := stats.Percentile(tc.p); n != tc.expected {
+			t.Errorf("expected %v percentile %d, got %d", tc.p, tc.expected, n)
+		}
+	}
+
+	if stats.Mean() != 5.5 {
+		t.Errorf("expected mean 5.5, got %v", stats.Mean())
+	}
+
+	var buf bytes.Buffer
+	if err := stats.WriteSummary(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte("5.50")) {
+		t.Errorf("expected mean in summary, got:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,73 @@
+// Estimate the distribution of game lengths under a saved strategy, by
+// sampling games in which both players follow it. Longer games need a
+// larger MCTS search budget.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/golang/glog"
+	"github.com/timpalpant/go-cfr/mcts"
+
+	"github.com/timpalpant/alphacats"
+	"github.com/timpalpant/alphacats/cards"
+	"github.com/timpalpant/alphacats/cmd/internal/glogger"
+	"github.com/timpalpant/alphacats/model"
+)
+
+func main() {
+	params := LengthParams{
+		Deck:           cards.CoreDeck.AsSlice(),
+		CardsPerPlayer: 4,
+	}
+	strategy := flag.String("strategy", "",
+		"Strategy to play: an MCTSPSRO model (*.model) "+
+			"or a tabular policy saved with model.WritePolicyTable (*.policy)")
+	flag.IntVar(&params.NumGames, "num_games", 1000, "Number of games to sample")
+	flag.Int64Var(&params.Seed, "seed", 123, "Random seed")
+	flag.IntVar(&params.MaxParallel, "max_parallel_games", runtime.NumCPU(),
+		"Number of games to play in parallel")
+	flag.Parse()
+	alphacats.SetLogger(glogger.Logger{})
+
+	if *strategy == "" {
+		glog.Fatal("Must specify -strategy")
+	}
+
+	policy, err := loadPolicy(*strategy)
+	if err != nil {
+		glog.Fatalf("Unable to load strategy: %v", err)
+	}
+
+	glog.Infof("Sampling %d games", params.NumGames)
+	lengths := sampleGameLengths(policy, params)
+	if err := newLengthStats(lengths).WriteSummary(os.Stdout); err != nil {
+		glog.Fatal(err)
+	}
+}
+
+// loadPolicy loads the strategy saved in the given file. A mixed strategy
+// (MCTSPSRO) is sampled once, and that policy is played in every game.
+func loadPolicy(filename string) (mcts.Policy, error) {
+	switch filepath.Ext(filename) {
+	case ".model":
+		f, err := os.Open(filename)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		psro, err := model.LoadMCTSPSRO(bufio.NewReader(f))
+		if err != nil {
+			return nil, err
+		}
+
+		return psro.SamplePolicy(), nil
+	default:
+		return model.OpenDiskPolicy(filename)
+	}
+}
@@ -0,0 +1,27 @@
+// Package glogger adapts glog to the alphacats.Logger interface.
+package glogger
+
+import (
+	"fmt"
+
+	"github.com/golang/glog"
+)
+
+// Logger is an alphacats.Logger that writes to glog.
+type Logger struct{}
+
+func (Logger) V(level int) bool {
+	return bool(glog.V(glog.Level(level)))
+}
+
+func (Logger) Infof(format string, args ...interface{}) {
+	glog.InfoDepth(1, fmt.Sprintf(format, args...))
+}
+
+func (Logger) Warningf(format string, args ...interface{}) {
+	glog.WarningDepth(1, fmt.Sprintf(format, args...))
+}
+
+func (Logger) Errorf(format string, args ...interface{}) {
+	glog.ErrorDepth(1, fmt.Sprintf(format, args...))
+}
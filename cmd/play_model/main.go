@@ -3,42 +3,85 @@ package main
 import (
 	"bufio"
 	"flag"
-	"fmt"
 	"math/rand"
 	"net/http"
 	_ "net/http/pprof"
 	"os"
-	"strconv"
-	"strings"
 
 	"github.com/golang/glog"
-	"github.com/timpalpant/go-cfr"
 	"github.com/timpalpant/go-cfr/mcts"
-	"github.com/timpalpant/go-cfr/sampling"
 
 	"github.com/timpalpant/alphacats"
 	"github.com/timpalpant/alphacats/cards"
+	"github.com/timpalpant/alphacats/cmd/internal/glogger"
 	"github.com/timpalpant/alphacats/gamestate"
 	"github.com/timpalpant/alphacats/model"
 )
 
 var stdin = bufio.NewReader(os.Stdin)
 
+// gameLog receives a JSON event for each step of the game, if enabled.
+var gameLog *alphacats.GameLog
+
 func main() {
-	model := flag.String("model", "models/player_0.model", "Model to play against")
+	modelPath := flag.String("model", "models/player_0.model", "Model to play against")
 	seed := flag.Int64("sampling.seed", 123, "Random seed")
+	annotate := flag.Bool("annotate", false,
+		"At the end of each game, show the model's action probabilities at each of its turns")
+	cacheSize := flag.Int("policy_cache_size", 100000,
+		"Number of info sets to cache the model's policy for (0 = no caching)")
+	policyTable := flag.String("policy_table", "",
+		"Play against a tabular policy saved with model.WritePolicyTable instead of -model")
+	gameLogFile := flag.String("game_log", "",
+		"Write newline-delimited JSON events for each step of each game to this file")
 	flag.Parse()
+	alphacats.SetLogger(glogger.Logger{})
+
+	if *cacheSize < 0 {
+		glog.Fatalf("-policy_cache_size must be non-negative, got %d", *cacheSize)
+	}
 
 	rand.Seed(*seed)
 	go http.ListenAndServe("localhost:4123", nil)
 
+	if *gameLogFile != "" {
+		f, err := os.Create(*gameLogFile)
+		if err != nil {
+			glog.Fatalf("Unable to create game log: %v", err)
+		}
+		defer f.Close()
+		gameLog = alphacats.NewGameLog(f, gamestate.Player1)
+	}
+
 	deck := cards.CoreDeck.AsSlice()
 	cardsPerPlayer := 4
-	opponent := loadPolicy(*model)
+	if *policyTable != "" {
+		policy, err := model.OpenDiskPolicy(*policyTable)
+		if err != nil {
+			glog.Fatalf("Unable to load policy table: %v", err)
+		}
+
+		for {
+			deal := alphacats.NewRandomDeal(deck, cardsPerPlayer)
+			playGame(policy, deal, *annotate)
+		}
+	}
+
+	opponent := loadPolicy(*modelPath)
+	cachedPolicies := make(map[mcts.Policy]*model.CachedPolicy)
 	for i := 0; ; i++ {
-		opponentPolicy := opponent.SamplePolicy()
+		var opponentPolicy mcts.Policy = opponent.SamplePolicy()
+		if *cacheSize > 0 {
+			cached, ok := cachedPolicies[opponentPolicy]
+			if !ok {
+				cached = model.NewCachedPolicy(opponentPolicy, *cacheSize)
+				cachedPolicies[opponentPolicy] = cached
+			}
+			opponentPolicy = cached
+		}
+
 		deal := alphacats.NewRandomDeal(deck, cardsPerPlayer)
-		playGame(opponentPolicy, deal)
+		playGame(opponentPolicy, deal, *annotate)
 	}
 }
 
@@ -58,35 +101,17 @@ func loadPolicy(modelPath string) *model.MCTSPSRO {
 	return policy
 }
 
-func playGame(opponent mcts.Policy, deal alphacats.Deal) {
-	var game cfr.GameTreeNode = alphacats.NewGame(deal.DrawPile, deal.P0Deal, deal.P1Deal)
-	for game.Type() != cfr.TerminalNodeType {
-		if game.Type() == cfr.ChanceNodeType {
-			var p float64
-			game, p = game.SampleChild()
-			glog.Infof("[chance] Sampled child node with probability %v", p)
-		} else if game.Player() == 1 {
-			is := game.InfoSet(game.Player()).(*alphacats.AbstractedInfoSet)
-			glog.Infof("[player] Your turn. %d cards remaining in draw pile.",
-				game.(*alphacats.GameNode).GetDrawPile().Len())
-			glog.Infof("[player] Hand: %v, Choices:", is.Hand)
-			for i, action := range is.AvailableActions {
-				glog.Infof("%d: %v", i, action)
-			}
+func playGame(opponent mcts.Policy, deal alphacats.Deal, annotate bool) {
+	game := alphacats.NewGame(deal.DrawPile, deal.P0Deal, deal.P1Deal)
+	rng := rand.New(rand.NewSource(rand.Int63()))
+	sources := [2]alphacats.ActionSource{
+		&alphacats.StrategyProfile{Policy: opponent.GetPolicy, Rand: rng},
+		&alphacats.HumanPrompt{In: stdin, Out: os.Stdout},
+	}
 
-			selected := prompt("Which action? ")
-			game = game.GetChild(selected)
-			lastAction := game.(*alphacats.GameNode).LastAction()
-			glog.Infof("[player] Chose to %v", lastAction)
-		} else {
-			p := opponent.GetPolicy(game)
-			selected := sampling.SampleOne(p, rand.Float32())
-			game = game.GetChild(selected)
-			lastAction := game.(*alphacats.GameNode).LastAction()
-			glog.Infof("[strategy] Chose to %v with probability %v: %v",
-				hidePrivateInfo(lastAction), p[selected], p)
-			glog.V(4).Infof("[strategy] Action result was: %v", lastAction)
-		}
+	game, err := alphacats.PlayGame(game, sources, rng, gameLog)
+	if err != nil {
+		glog.Fatal(err)
 	}
 
 	glog.Info("GAME OVER")
@@ -97,33 +122,21 @@ func playGame(opponent mcts.Policy, deal alphacats.Deal) {
 	}
 
 	glog.Info("Game history:")
-	h := game.(*alphacats.GameNode).GetHistory()
+	h := game.GetHistory()
 	for i, action := range h.AsSlice() {
 		glog.Infof("%d: %v", i, action)
 	}
-}
 
-func prompt(msg string) int {
-	for {
-		fmt.Print(msg)
-		result, err := stdin.ReadString('\n')
+	if annotate {
+		glog.Info("Annotated game:")
+		annotations, err := alphacats.Annotate(deal, h.AsSlice(), 0, opponent.GetPolicy)
 		if err != nil {
-			panic(err)
+			glog.Errorf("Unable to annotate game: %v", err)
+			return
 		}
 
-		result = strings.TrimRight(result, "\n")
-		i, err := strconv.Atoi(result)
-		if err != nil {
-			glog.Errorf("Invalid selection: %v", result)
-			continue
+		for i, line := range annotations {
+			glog.Infof("%d: %v", i, line)
 		}
-
-		return i
 	}
 }
-
-func hidePrivateInfo(a gamestate.Action) gamestate.Action {
-	a.PositionInDrawPile = 0
-	a.CardsSeen = [3]cards.Card{}
-	return a
-}
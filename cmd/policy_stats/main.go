@@ -0,0 +1,91 @@
+// Summarize how converged a tabular policy is: a histogram of how peaked its
+// strategy is in each info set, and the fraction of info sets that are pure.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+
+	"github.com/golang/glog"
+
+	"github.com/timpalpant/alphacats"
+	"github.com/timpalpant/alphacats/cards"
+	"github.com/timpalpant/alphacats/cmd/internal/glogger"
+	"github.com/timpalpant/alphacats/model"
+)
+
+func main() {
+	policyTable := flag.String("policy_table", "",
+		"Tabular policy saved with model.WritePolicyTable to analyze")
+	numDeals := flag.Int("num_deals", 1,
+		"Number of random deals to sample. All info sets reachable from "+
+			"each deal are enumerated, so the full game tree is walked for each one.")
+	seed := flag.Int64("seed", 123, "Random seed for sampling deals")
+	numBins := flag.Int("num_bins", 20, "Number of histogram bins")
+	pureThreshold := flag.Float64("pure_threshold", 0.95,
+		"Info sets whose most likely action exceeds this probability are considered pure")
+	outputCSV := flag.String("output_csv", "",
+		"Also write the histogram as CSV to this file")
+	leastVisited := flag.Int("least_visited", 0,
+		"Also list this many of the info sets that were visited the fewest times during "+
+			"training (requires a table saved with model.WritePolicyTableWithVisits)")
+	flag.Parse()
+	alphacats.SetLogger(glogger.Logger{})
+
+	if *policyTable == "" {
+		glog.Fatal("Must specify -policy_table")
+	}
+
+	policy, err := model.OpenDiskPolicy(*policyTable)
+	if err != nil {
+		glog.Fatalf("Unable to load policy table: %v", err)
+	}
+	defer policy.Close()
+	glog.Infof("Loaded policy for %d info sets from %v", policy.Len(), *policyTable)
+
+	rand.Seed(*seed)
+	deck := cards.CoreDeck.AsSlice()
+	deals := make([]alphacats.Deal, *numDeals)
+	for i := range deals {
+		deals[i] = alphacats.NewRandomDeal(deck, 4)
+	}
+
+	stats := newPolicyStats(*numBins, *pureThreshold)
+	collectPolicyStats(deals, policy.Lookup, stats)
+	if err := stats.WriteSummary(os.Stdout); err != nil {
+		glog.Fatal(err)
+	}
+
+	if *outputCSV != "" {
+		if err := saveCSV(*outputCSV, stats); err != nil {
+			glog.Fatalf("Unable to save histogram: %v", err)
+		}
+	}
+
+	if *leastVisited > 0 {
+		if !policy.HasVisits() {
+			glog.Fatalf("%v was saved without visit counts", *policyTable)
+		}
+
+		fmt.Println("Least visited info sets (visits, key):")
+		for _, isv := range policy.LeastVisited(*leastVisited) {
+			fmt.Printf("%d\t%x\n", isv.Visits, isv.Key)
+		}
+	}
+}
+
+func saveCSV(filename string, stats *PolicyStats) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	if err := stats.WriteCSV(f); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
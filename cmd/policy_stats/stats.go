@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/timpalpant/alphacats"
+)
+
+// PolicyStats summarizes how peaked the strategy is in each info set,
+// as a histogram of the probability of the most likely action.
+// Info sets with only one available action are not counted.
+type PolicyStats struct {
+	// PureThreshold is the probability of the most likely action
+	// above which the strategy is considered to be (effectively) pure.
+	PureThreshold float64
+	// Histogram[i] is the number of info sets whose maximum action
+	// probability is in [i/len(Histogram), (i+1)/len(Histogram)).
+	Histogram []int
+	// NumInfoSets is the number of info sets with a recorded policy.
+	NumInfoSets int
+	// NumPure is the number of info sets whose maximum action
+	// probability exceeds PureThreshold.
+	NumPure int
+	// NumMissing is the number of info sets without a recorded policy.
+	NumMissing int
+}
+
+func newPolicyStats(numBins int, pureThreshold float64) *PolicyStats {
+	return &PolicyStats{
+		PureThreshold: pureThreshold,
+		Histogram:     make([]int, numBins),
+	}
+}
+
+// Add records the policy for one info set.
+func (s *PolicyStats) Add(p []float32) {
+	maxP := 0.0
+	for _, x := range p {
+		if float64(x) > maxP {
+			maxP = float64(x)
+		}
+	}
+
+	bin := int(maxP * float64(len(s.Histogram)))
+	if bin >= len(s.Histogram) {
+		bin = len(s.Histogram) - 1
+	}
+
+	s.Histogram[bin]++
+	s.NumInfoSets++
+	if maxP > s.PureThreshold {
+		s.NumPure++
+	}
+}
+
+// PureFraction returns the fraction of info sets in which the strategy
+// is effectively pure.
+func (s *PolicyStats) PureFraction() float64 {
+	if s.NumInfoSets == 0 {
+		return 0
+	}
+
+	return float64(s.NumPure) / float64(s.NumInfoSets)
+}
+
+// WriteSummary writes a human-readable summary of the statistics.
+func (s *PolicyStats) WriteSummary(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "Info sets with a policy:\t%d\n", s.NumInfoSets)
+	fmt.Fprintf(tw, "Info sets without a policy:\t%d\n", s.NumMissing)
+	fmt.Fprintf(tw, "Pure (max prob > %.2f):\t%d (%.1f%%)\n",
+		s.PureThreshold, s.NumPure, 100*s.PureFraction())
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "Max prob\tInfo sets\t")
+	for i, count := range s.Histogram {
+		lo, hi := s.binRange(i)
+		bar := ""
+		if s.NumInfoSets > 0 {
+			bar = strings.Repeat("#", 50*count/s.NumInfoSets)
+		}
+		fmt.Fprintf(tw, "[%.2f, %.2f)\t%d\t%s\n", lo, hi, count, bar)
+	}
+
+	return tw.Flush()
+}
+
+// WriteCSV writes the histogram as CSV, with one row per bin.
+func (s *PolicyStats) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"bin_min", "bin_max", "count"})
+	for i, count := range s.Histogram {
+		lo, hi := s.binRange(i)
+		cw.Write([]string{
+			strconv.FormatFloat(lo, 'f', -1, 64),
+			strconv.FormatFloat(hi, 'f', -1, 64),
+			strconv.Itoa(count),
+		})
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func (s *PolicyStats) binRange(i int) (float64, float64) {
+	n := float64(len(s.Histogram))
+	return float64(i) / n, float64(i+1) / n
+}
+
+// collectPolicyStats records the policy in every distinct info set,
+// for both players, that is reachable from any of the given deals.
+// lookup returns the policy for the info set with the given key,
+// and whether it was found.
+func collectPolicyStats(deals []alphacats.Deal, lookup func(key []byte) ([]float32, bool), stats *PolicyStats) {
+	seen := make(map[string]struct{})
+	for _, deal := range deals {
+		for player := 0; player < 2; player++ {
+			alphacats.EnumerateInfoSets(deal, player, func(is *alphacats.InfoSetWithAvailableActions) {
+				if len(is.AvailableActions) <= 1 {
+					return // No decision to make.
+				}
+
+				abstracted := is.Abstract()
+				key := abstracted.Key()
+				if _, ok := seen[string(key)]; ok {
+					return
+				}
+				seen[string(key)] = struct{}{}
+
+				p, ok := lookup(key)
+				if !ok {
+					stats.NumMissing++
+					return
+				}
+
+				stats.Add(p)
+			})
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/timpalpant/alphacats"
+	"github.com/timpalpant/alphacats/cards"
+	"github.com/timpalpant/alphacats/gamestate"
+	"github.com/timpalpant/alphacats/model"
+)
+
+func TestCollectPolicyStats(t *testing.T) {
+	// Player 0 has decisions with 2, 3 and 2 available actions,
+	// and player 1 has two decisions with 2 available actions.
+	deal := alphacats.Deal{
+		DrawPile: cards.NewStackFromCards([]cards.Card{cards.Cat, cards.ExplodingKitten}),
+		P0Deal:   cards.NewSetFromCards([]cards.Card{cards.Skip}),
+		P1Deal:   cards.NewSetFromCards([]cards.Card{cards.Skip}),
+	}
+
+	// Play the first action with probability 0.97, except when there are
+	// 3 actions (uniform), and after player 0 plays Skip (missing).
+	table := make(model.PolicyTable)
+	for player := 0; player < 2; player++ {
+		alphacats.EnumerateInfoSets(deal, player, func(is *alphacats.InfoSetWithAvailableActions) {
+			abstracted := is.Abstract()
+			key := string(abstracted.Key())
+			switch {
+			case is.Player == gamestate.Player1 && is.History.Len() == 1 &&
+				is.History.Get(0).Type == gamestate.PlayCard:
+			case len(is.AvailableActions) == 3:
+				table[key] = []float32{1.0 / 3, 1.0 / 3, 1.0 / 3}
+			case len(is.AvailableActions) == 2:
+				table[key] = []float32{0.97, 0.03}
+			default:
+				table[key] = []float32{1.0}
+			}
+		})
+	}
+
+	lookup := func(key []byte) ([]float32, bool) {
+		p, ok := table[string(key)]
+		return p, ok
+	}
+
+	stats := newPolicyStats(10, 0.95)
+	collectPolicyStats([]alphacats.Deal{deal, deal}, lookup, stats)
+	if stats.NumInfoSets != 4 || stats.NumMissing != 1 {
+		t.Errorf("expected 4 info sets and 1 missing, got %d and %d",
+			stats.NumInfoSets, stats.NumMissing)
+	}
+
+	if stats.NumPure != 3 || stats.PureFraction() != 0.75 {
+		t.Errorf("expected 3 pure info sets (0.75), got %d (%v)",
+			stats.NumPure, stats.PureFraction())
+	}
+
+	expected := []int{0, 0, 0, 1, 0, 0, 0, 0, 0, 3}
+	for i, count := range stats.Histogram {
+		if count != expected[i] {
+			t.Errorf("expected histogram %v, got %v", expected, stats.Histogram)
+			break
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := stats.WriteCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 11 || lines[0] != "bin_min,bin_max,count" || lines[10] != "0.9,1,3" {
+		t.Errorf("unexpected CSV output:\n%s", buf.String())
+	}
+}
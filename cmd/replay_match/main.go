@@ -0,0 +1,48 @@
+// Regenerate a game saved as an alphacats.MatchRecord (e.g. by tournament
+// -match_dir), check that it reaches the same outcome, and print its history.
+package main
+
+import (
+	"flag"
+	"os"
+
+	"github.com/golang/glog"
+
+	"github.com/timpalpant/alphacats"
+	"github.com/timpalpant/alphacats/cmd/internal/glogger"
+)
+
+func main() {
+	matchFile := flag.String("match", "", "Match record to replay")
+	flag.Parse()
+	alphacats.SetLogger(glogger.Logger{})
+
+	if *matchFile == "" {
+		glog.Fatal("Must specify -match")
+	}
+
+	f, err := os.Open(*matchFile)
+	if err != nil {
+		glog.Fatal(err)
+	}
+
+	record, err := alphacats.LoadMatchRecord(f)
+	f.Close()
+	if err != nil {
+		glog.Fatalf("Unable to load match record: %v", err)
+	}
+
+	glog.Infof("Replaying %v (seed %d) between %v and %v",
+		record.Game, record.Seed, record.Strategies[0], record.Strategies[1])
+	game, err := record.Replay()
+	if err != nil {
+		glog.Fatalf("Replay does not match the record: %v", err)
+	}
+
+	glog.Info("Game history:")
+	h := game.GetHistory()
+	for i, action := range h.AsSlice() {
+		glog.Infof("%d: %v", i, action)
+	}
+	glog.Infof("Player %d won, as recorded", record.Winner)
+}
@@ -0,0 +1,107 @@
+// Generate training data for the model by playing games of self-play
+// with Smooth UCT search. Each turn of each game is written to the output
+// as a JSON example of the acting player's info set features, the search
+// policy, and the outcome of the game for that player.
+//
+// With -policy_table, the search policy at each info set reached is also
+// saved as a tabular policy, along with the number of times each info set
+// was reached (see model.WritePolicyTableWithVisits).
+package main
+
+import (
+	"bufio"
+	"flag"
+	"os"
+	"runtime"
+
+	"github.com/golang/glog"
+	"github.com/timpalpant/go-cfr/mcts"
+
+	"github.com/timpalpant/alphacats"
+	"github.com/timpalpant/alphacats/cards"
+	"github.com/timpalpant/alphacats/cmd/internal/glogger"
+)
+
+type SamplingParams struct {
+	C           float64
+	Gamma       float64
+	Eta         float64
+	D           float64
+	Temperature float64
+}
+
+func main() {
+	params := SelfPlayParams{
+		Deck:           cards.CoreDeck.AsSlice(),
+		CardsPerPlayer: 4,
+	}
+	var sampling SamplingParams
+	output := flag.String("output", "selfplay.jsonl",
+		"File to write newline-delimited JSON training examples to")
+	policyTable := flag.String("policy_table", "",
+		"If set, also save the search policy of each info set reached to this tabular policy file")
+	initPolicyTable := flag.String("init_policy_table", "",
+		"With -policy_table, continue from the policies and visit counts in this tabular policy file")
+	flag.IntVar(&params.NumGames, "num_games", 1000, "Number of games of self-play")
+	flag.Int64Var(&params.Seed, "seed", 123, "Random seed")
+	flag.IntVar(&params.NumMCTSIterations, "search_iter", 10000,
+		"Number of MCTS iterations to perform per move")
+	flag.IntVar(&params.MaxParallelGames, "max_parallel_games", runtime.NumCPU(),
+		"Number of games to play in parallel")
+	flag.Float64Var(&sampling.C, "sampling.c", 1.75,
+		"Exploration factor C used in MCTS search")
+	flag.Float64Var(&sampling.Gamma, "sampling.gamma", 0.1,
+		"Mixing factor Gamma used in Smooth UCT search")
+	flag.Float64Var(&sampling.Eta, "sampling.eta", 0.9,
+		"Mixing factor eta used in Smooth UCT search")
+	flag.Float64Var(&sampling.D, "sampling.d", 0.001,
+		"Mixing factor d used in Smooth UCT search")
+	flag.Float64Var(&sampling.Temperature, "temperature", 1.0,
+		"Temperature used when selecting actions during play")
+	flag.Parse()
+	alphacats.SetLogger(glogger.Logger{})
+
+	f, err := os.Create(*output)
+	if err != nil {
+		glog.Fatalf("Unable to create output file: %v", err)
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+
+	newSearch := func() Search {
+		return mcts.NewSmoothUCT(
+			float32(sampling.C), float32(sampling.Gamma),
+			float32(sampling.Eta), float32(sampling.D),
+			float32(sampling.Temperature))
+	}
+
+	glog.Infof("Playing %d games of self-play with %d search iterations per move",
+		params.NumGames, params.NumMCTSIterations)
+	var tp *tabularPolicy
+	if *policyTable != "" && *initPolicyTable != "" {
+		tp, err = loadTabularPolicy(*initPolicyTable)
+		if err != nil {
+			glog.Fatalf("Unable to load policy table: %v", err)
+		}
+	} else if *policyTable != "" {
+		tp = newTabularPolicy()
+	}
+
+	n, err := generateExamples(newSearch, params, w, tp)
+	if err != nil {
+		glog.Fatalf("Error writing examples: %v", err)
+	}
+
+	if err := w.Flush(); err != nil {
+		glog.Fatal(err)
+	}
+
+	glog.Infof("Wrote %d examples to %v", n, *output)
+	if tp != nil {
+		if err := tp.Save(*policyTable); err != nil {
+			glog.Fatalf("Error saving policy table: %v", err)
+		}
+
+		glog.Infof("Saved policies of %d info sets to %v", len(tp.table), *policyTable)
+	}
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"math/rand"
+	"sync"
+
+	"github.com/golang/glog"
+	"github.com/timpalpant/go-cfr"
+	"github.com/timpalpant/go-cfr/mcts"
+
+	"github.com/timpalpant/alphacats"
+	"github.com/timpalpant/alphacats/cards"
+	"github.com/timpalpant/alphacats/gamestate"
+	"github.com/timpalpant/alphacats/model"
+)
+
+// Hand, and the cards played by each player.
+const numFeatures = 3 * cards.NumTypes
+
+type SelfPlayParams struct {
+	Deck              []cards.Card
+	CardsPerPlayer    int
+	NumGames          int
+	Seed              int64
+	NumMCTSIterations int
+	MaxParallelGames  int
+}
+
+// Search is the subset of mcts.SmoothUCT used to choose moves in self-play.
+type Search interface {
+	mcts.Policy
+	Run(rng *rand.Rand, node cfr.GameTreeNode) float32
+}
+
+// Example is a single training example, taken from one of the turns
+// of a self-play game.
+type Example struct {
+	Player int `json:"player"`
+	// Features are the count vectors (see cards.Set.ToCountVector) of the
+	// player's hand, and of the cards played by player 0 and player 1.
+	Features []int `json:"features"`
+	// Policy is the search policy over the available actions.
+	Policy []float32 `json:"policy"`
+	// Outcome is 1 if the player went on to win the game, and -1 otherwise.
+	Outcome float32 `json:"outcome"`
+
+	// The node of the turn, to record the policy in a tabularPolicy.
+	node cfr.GameTreeNode
+}
+
+// tabularPolicy accumulates the search policy at each info set reached
+// in self-play, along with the number of times it was reached, so that
+// they can be saved with model.WritePolicyTableWithVisits. The policy of
+// an info set reached in more than one turn is the policy of the last of
+// them, in the order the games were dealt.
+type tabularPolicy struct {
+	table  model.PolicyTable
+	visits *model.VisitCounts
+}
+
+func newTabularPolicy() *tabularPolicy {
+	return &tabularPolicy{
+		table:  make(model.PolicyTable),
+		visits: model.NewVisitCounts(),
+	}
+}
+
+// loadTabularPolicy continues recording into a table saved by Save,
+// keeping its policies and visit counts.
+func loadTabularPolicy(filename string) (*tabularPolicy, error) {
+	dp, err := model.OpenDiskPolicy(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer dp.Close()
+
+	table, visits := dp.Load()
+	return &tabularPolicy{table: table, visits: visits}, nil
+}
+
+func (tp *tabularPolicy) add(examples []Example) {
+	for _, example := range examples {
+		tp.table.Record(example.node, example.Policy)
+		tp.visits.Visit(example.node)
+	}
+}
+
+// Save writes the accumulated policy table to the given file.
+func (tp *tabularPolicy) Save(filename string) error {
+	return model.WritePolicyTableWithVisits(tp.table, tp.visits, filename)
+}
+
+// generateExamples plays NumGames games of self-play, with MaxParallelGames
+// games in progress at once, and writes the examples from each game to w as
+// newline-delimited JSON. A new search is created for each game with
+// newSearch. If tp is non-nil, the policy of each example is also recorded
+// in it. Returns the number of examples written.
+//
+// Examples are written in the order the games were dealt, as soon as each
+// game is complete, so the output does not depend on the order in which
+// games are scheduled. The deals are drawn from params.Seed, and the chance
+// outcomes, determinizations searched and moves of each game from a per-game
+// source seeded from it, so the output is deterministic given the seed.
+func generateExamples(newSearch func() Search, params SelfPlayParams, w io.Writer, tp *tabularPolicy) (int, error) {
+	// All randomness is drawn up front, so that the results do not depend
+	// on the order in which games are scheduled.
+	rng := rand.New(rand.NewSource(params.Seed))
+	dealConfig := alphacats.DealConfig{Rand: rng}
+	// NB: NewRandomDealWithConfig shuffles the deck in place.
+	deck := append([]cards.Card(nil), params.Deck...)
+	deals := make([]alphacats.Deal, params.NumGames)
+	seeds := make([]int64, params.NumGames)
+	results := make([]chan []Example, params.NumGames)
+	for i := range deals {
+		deals[i] = alphacats.NewRandomDealWithConfig(deck, params.CardsPerPlayer, dealConfig)
+		seeds[i] = rng.Int63()
+		results[i] = make(chan []Example, 1)
+	}
+
+	var wg sync.WaitGroup
+	gameCh := make(chan int)
+	for worker := 0; worker < params.MaxParallelGames; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range gameCh {
+				game := alphacats.NewGame(deals[i].DrawPile, deals[i].P0Deal, deals[i].P1Deal)
+				results[i] <- playGame(newSearch(), game, seeds[i], params.NumMCTSIterations)
+			}
+		}()
+	}
+
+	go func() {
+		for i := range deals {
+			gameCh <- i
+		}
+		close(gameCh)
+	}()
+
+	enc := json.NewEncoder(w)
+	n := 0
+	var err error
+	for _, result := range results {
+		examples := <-result
+		if err != nil {
+			continue // Wait for the remaining games to finish.
+		}
+
+		if tp != nil {
+			tp.add(examples)
+		}
+
+		for _, example := range examples {
+			if err = enc.Encode(example); err != nil {
+				break
+			}
+			n++
+		}
+	}
+
+	wg.Wait()
+	return n, err
+}
+
+// playGame plays out the given game with both players choosing moves with
+// the given search, and returns an example for each of their turns.
+func playGame(search Search, game *alphacats.GameNode, seed int64, numIterations int) []Example {
+	rng := rand.New(rand.NewSource(seed))
+	beliefs := [2]*alphacats.BeliefState{
+		alphacats.NewBeliefState(search.GetPolicy, game.GetInfoSet(gamestate.Player0)),
+		alphacats.NewBeliefState(search.GetPolicy, game.GetInfoSet(gamestate.Player1)),
+	}
+
+	var examples []Example
+	var node cfr.GameTreeNode = game
+	for node.Type() != cfr.TerminalNodeType {
+		if node.Type() == cfr.ChanceNodeType {
+			node, _ = node.(*alphacats.GameNode).SampleChildWithRand(rng)
+		} else {
+			is := node.InfoSet(node.Player()).(*alphacats.AbstractedInfoSet)
+			// Both players' beliefs are simulated so that the opponent's
+			// policy is well approximated in each player's belief updates.
+			simulate(search, beliefs[0], rng, numIterations)
+			simulate(search, beliefs[1], rng, numIterations)
+			p := append([]float32(nil), search.GetPolicy(node)...)
+			examples = append(examples, Example{
+				Player:   node.Player(),
+				Features: features(is),
+				Policy:   p,
+				node:     node,
+			})
+
+			node = node.GetChild(alphacats.SampleAction(p, rng.Float32()))
+		}
+
+		gn := node.(*alphacats.GameNode)
+		beliefs[0].Update(gn.GetInfoSet(gamestate.Player0))
+		beliefs[1].Update(gn.GetInfoSet(gamestate.Player1))
+	}
+
+	winner, _ := node.(*alphacats.GameNode).GameOverReason()
+	for i := range examples {
+		if examples[i].Player == int(winner) {
+			examples[i].Outcome = 1.0
+		} else {
+			examples[i].Outcome = -1.0
+		}
+	}
+
+	return examples
+}
+
+// simulate runs n iterations of search, each from a determinization sampled
+// from the given beliefs. Iterations are run serially, since many games are
+// played in parallel.
+func simulate(search Search, beliefs *alphacats.BeliefState, rng *rand.Rand, n int) {
+	for k := 0; k < n; k++ {
+		game, err := beliefs.SampleDeterminizationWithRand(rng)
+		if err != nil {
+			// Drop this sample and continue searching with the next one.
+			glog.Warningf("Skipping invalid determinization: %v", err)
+			continue
+		}
+
+		search.Run(rng, game)
+	}
+}
+
+func features(is *alphacats.AbstractedInfoSet) []int {
+	result := make([]int, 0, numFeatures)
+	for _, s := range []cards.Set{is.Hand, is.P0PlayedCards, is.P1PlayedCards} {
+		for _, count := range s.ToCountVector() {
+			result = append(result, int(count))
+		}
+	}
+
+	return result
+}
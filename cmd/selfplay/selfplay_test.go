@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/timpalpant/go-cfr"
+
+	"github.com/timpalpant/alphacats"
+	"github.com/timpalpant/alphacats/cards"
+	"github.com/timpalpant/alphacats/model"
+)
+
+// Plays uniformly at random, and counts the number of searches run.
+type uniformSearch struct {
+	numRuns int
+}
+
+func (s *uniformSearch) Run(rng *rand.Rand, node cfr.GameTreeNode) float32 {
+	s.numRuns++
+	return 0
+}
+
+func (s *uniformSearch) GetPolicy(node cfr.GameTreeNode) []float32 {
+	p := make([]float32, node.NumChildren())
+	for i := range p {
+		p[i] = 1.0 / float32(len(p))
+	}
+
+	return p
+}
+
+// Plays a policy that depends on every determinization it has searched.
+type determinizedSearch struct {
+	h uint64
+}
+
+func (s *determinizedSearch) Run(rng *rand.Rand, node cfr.GameTreeNode) float32 {
+	s.h = 31*s.h + uint64(node.(*alphacats.GameNode).GetDrawPile())
+	return 0
+}
+
+func (s *determinizedSearch) GetPolicy(node cfr.GameTreeNode) []float32 {
+	p := make([]float32, node.NumChildren())
+	var total float32
+	for i := range p {
+		p[i] = float32(1 + (s.h+uint64(i))%3)
+		total += p[i]
+	}
+
+	for i := range p {
+		p[i] /= total
+	}
+
+	return p
+}
+
+func checkExample(t *testing.T, example Example) {
+	if example.Player != 0 && example.Player != 1 {
+		t.Errorf("invalid player: %v", example)
+	}
+
+	if len(example.Features) != numFeatures {
+		t.Errorf("expected %d features, got %d: %v", numFeatures, len(example.Features), example)
+	}
+
+	var total float64
+	for _, p := range example.Policy {
+		total += float64(p)
+	}
+	if len(example.Policy) == 0 || math.Abs(total-1.0) > 1e-5 {
+		t.Errorf("invalid policy target: %v", example)
+	}
+
+	if example.Outcome != 1.0 && example.Outcome != -1.0 {
+		t.Errorf("invalid outcome: %v", example)
+	}
+}
+
+func TestPlayGame(t *testing.T) {
+	deal := alphacats.NewRandomDeal(cards.CoreDeck.AsSlice(), 4)
+	game := alphacats.NewGame(deal.DrawPile, deal.P0Deal, deal.P1Deal)
+	search := &uniformSearch{}
+	examples := playGame(search, game, 123, 3)
+	if len(examples) == 0 {
+		t.Fatal("expected examples from each turn")
+	}
+
+	// Both players' beliefs are searched on every turn.
+	if expected := 2 * 3 * len(examples); search.numRuns != expected {
+		t.Errorf("expected %d searches, got %d", expected, search.numRuns)
+	}
+
+	// Player 0's first hand is their deal.
+	first := cards.NewSet()
+	for card, count := range examples[0].Features[:cards.NumTypes] {
+		first.AddN(cards.Card(card), count)
+	}
+	if first != deal.P0Deal {
+		t.Errorf("expected hand features %v, got %v", deal.P0Deal, first)
+	}
+
+	// The winner's examples are all labeled as wins, and the loser's as losses.
+	outcomes := make(map[int]float32)
+	for _, example := range examples {
+		checkExample(t, example)
+		if prev, ok := outcomes[example.Player]; ok && prev != example.Outcome {
+			t.Errorf("player %d has outcomes %v and %v", example.Player, prev, example.Outcome)
+		}
+		outcomes[example.Player] = example.Outcome
+	}
+
+	if len(outcomes) == 2 && outcomes[0] == outcomes[1] {
+		t.Errorf("expected one player to win and one to lose: %v", outcomes)
+	}
+}
+
+func TestGenerateExamples(t *testing.T) {
+	params := SelfPlayParams{
+		Deck:              cards.CoreDeck.AsSlice(),
+		CardsPerPlayer:    4,
+		NumGames:          4,
+		Seed:              123,
+		NumMCTSIterations: 2,
+		MaxParallelGames:  2,
+	}
+
+	newSearch := func() Search { return &determinizedSearch{} }
+	var buf bytes.Buffer
+	tp := newTabularPolicy()
+	n, err := generateExamples(newSearch, params, &buf, tp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(buf.Bytes()))
+	var examples []Example
+	for dec.More() {
+		var example Example
+		if err := dec.Decode(&example); err != nil {
+			t.Fatal(err)
+		}
+
+		checkExample(t, example)
+		examples = append(examples, example)
+	}
+
+	if len(examples) != n || n < params.NumGames {
+		t.Errorf("wrote %d examples, read %d", n, len(examples))
+	}
+
+	// The same examples are generated with the same seed, even though
+	// the policy depends on the determinizations searched.
+	params.MaxParallelGames = 3
+	var buf2 bytes.Buffer
+	if _, err := generateExamples(newSearch, params, &buf2, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(buf.Bytes(), buf2.Bytes()) {
+		t.Error("examples are not deterministic given the seed")
+	}
+
+	// Every example's info set is recorded in the policy table.
+	if len(tp.table) == 0 || tp.visits.Len() != len(tp.table) {
+		t.Fatalf("expected visits for each of %d info sets, got %d", len(tp.table), tp.visits.Len())
+	}
+
+	dir, err := ioutil.TempDir("", "selfplay")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "selfplay.policy")
+	if err := tp.Save(filename); err != nil {
+		t.Fatal(err)
+	}
+
+	dp, err := model.OpenDiskPolicy(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dp.Close()
+
+	var total uint32
+	for key := range tp.table {
+		v, ok := dp.Visits([]byte(key))
+		if !ok || v == 0 {
+			t.Errorf("expected info set %q to be visited, got %d (found: %v)", key, v, ok)
+		}
+		total += v
+	}
+
+	if int(total) != n {
+		t.Errorf("expected %d visits in total, one for each example, got %d", n, total)
+	}
+
+	// Recording can be continued from the saved table.
+	loaded, err := loadTabularPolicy(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded.table, tp.table) {
+		t.Error("expected loaded policy table to match the saved table")
+	}
+	for key := range tp.table {
+		if v, expected := loaded.visits.Get([]byte(key)), tp.visits.Get([]byte(key)); v != expected {
+			t.Errorf("expected %d visits of info set %q, got %d", expected, key, v)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+// Play a round-robin tournament between saved strategies, and report
+// the win rate of each pair along with an overall ranking.
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/golang/glog"
+	"github.com/timpalpant/go-cfr"
+	"github.com/timpalpant/go-cfr/mcts"
+
+	"github.com/timpalpant/alphacats"
+	"github.com/timpalpant/alphacats/cards"
+	"github.com/timpalpant/alphacats/cmd/internal/glogger"
+	"github.com/timpalpant/alphacats/model"
+)
+
+func main() {
+	var params TournamentParams
+	deckType := flag.String("decktype", "core",
+		"Composition of the deck (see cards.DeckConfigs)")
+	flag.IntVar(&params.CardsPerPlayer, "cards_per_player", 4,
+		"Number of cards dealt to each player, in addition to their Defuse")
+	strategiesDir := flag.String("strategies_dir", "models/tournament",
+		"Directory of strategies to play: MCTSPSRO models (*.model) "+
+			"and tabular policies saved with model.WritePolicyTable (*.policy)")
+	flag.IntVar(&params.NumGames, "num_games", 100,
+		"Number of games each pair of strategies plays in each seating")
+	flag.Int64Var(&params.Seed, "seed", 123, "Random seed")
+	flag.IntVar(&params.MaxParallel, "max_parallel_games", runtime.NumCPU(),
+		"Number of games to play in parallel")
+	flag.StringVar(&params.MatchDir, "match_dir", "",
+		"Save a record of each game to this directory, which can be replayed with replay_match")
+	outputJSON := flag.String("output_json", "",
+		"Also write the results as JSON to this file")
+	includeHeuristic := flag.Bool("include_heuristic", false,
+		"Also play the built-in heuristic strategy (alphacats.Heuristic) as a baseline")
+	includeBestResponse := flag.Bool("include_best_response", false,
+		"Also play a best response to each strategy (alphacats.BestResponsePolicy). "+
+			"NB: The best response is clairvoyant, and is only tractable for small decks "+
+			"such as -decktype=test")
+	flag.Parse()
+	alphacats.SetLogger(glogger.Logger{})
+
+	config, err := cards.GetDeckConfig(*deckType)
+	if err != nil {
+		glog.Fatal(err)
+	}
+	deck, err := config.Deck()
+	if err != nil {
+		glog.Fatal(err)
+	}
+	params.Deck = deck.AsSlice()
+	if n := alphacats.MaxCardsPerPlayer(params.Deck); params.CardsPerPlayer > n {
+		glog.Fatalf("Deck %q has enough cards for at most %d cards per player, got -cards_per_player=%d",
+			*deckType, n, params.CardsPerPlayer)
+	}
+
+	strategies, err := loadStrategies(*strategiesDir)
+	if err != nil {
+		glog.Fatalf("Unable to load strategies: %v", err)
+	}
+	if *includeBestResponse {
+		for _, s := range strategies {
+			strategies = append(strategies, bestResponseStrategy(s))
+		}
+	}
+	if *includeHeuristic {
+		strategies = append(strategies, heuristicStrategy())
+	}
+	if len(strategies) < 2 {
+		glog.Fatalf("Need at least 2 strategies to play a tournament, found %d in %v",
+			len(strategies), *strategiesDir)
+	}
+
+	glog.Infof("Playing round-robin tournament between %d strategies", len(strategies))
+	results := runTournament(strategies, params)
+	if err := results.WriteTable(os.Stdout); err != nil {
+		glog.Fatal(err)
+	}
+
+	if *outputJSON != "" {
+		if err := saveResults(*outputJSON, results); err != nil {
+			glog.Fatalf("Unable to save results: %v", err)
+		}
+	}
+}
+
+// loadStrategies loads all of the strategies in the given directory,
+// in lexical order of their filenames.
+func loadStrategies(dir string) ([]Strategy, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var strategies []Strategy
+	for _, entry := range entries {
+		filename := filepath.Join(dir, entry.Name())
+		name := strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+		switch filepath.Ext(entry.Name()) {
+		case ".model":
+			psro, err := loadPSRO(filename)
+			if err != nil {
+				return nil, err
+			}
+
+			id, err := hashFile(filename)
+			if err != nil {
+				return nil, err
+			}
+
+			strategies = append(strategies, Strategy{name, id, psro.SamplePolicy})
+		case ".policy":
+			policy, err := model.OpenDiskPolicy(filename)
+			if err != nil {
+				return nil, err
+			}
+
+			id, err := hashFile(filename)
+			if err != nil {
+				return nil, err
+			}
+
+			strategies = append(strategies, Strategy{
+				Name:         name,
+				ID:           id,
+				SamplePolicy: func() mcts.Policy { return policy },
+			})
+		default:
+			glog.Warningf("Skipping unrecognized strategy file: %v", filename)
+		}
+	}
+
+	return strategies, nil
+}
+
+// heuristicStrategy returns the built-in heuristic baseline, which does
+// not need to be trained or loaded from a file.
+func heuristicStrategy() Strategy {
+	heuristic := alphacats.NewHeuristic()
+	return Strategy{
+		Name:         "heuristic",
+		ID:           "builtin:heuristic",
+		SamplePolicy: func() mcts.Policy { return heuristic },
+	}
+}
+
+// bestResponseStrategy returns a best response to a policy sampled from s,
+// which can play from either seat. Its win rate against s measures how
+// exploitable s is (see alphacats.BestResponsePolicy).
+func bestResponseStrategy(s Strategy) Strategy {
+	opponent := s.SamplePolicy()
+	br := bestResponse{
+		alphacats.NewBestResponsePolicy(0, opponent.GetPolicy),
+		alphacats.NewBestResponsePolicy(1, opponent.GetPolicy),
+	}
+
+	return Strategy{
+		Name:         "br:" + s.Name,
+		ID:           "br:" + s.ID,
+		SamplePolicy: func() mcts.Policy { return br },
+	}
+}
+
+// bestResponse plays the best response for the player to act.
+type bestResponse [2]*alphacats.BestResponsePolicy
+
+func (br bestResponse) GetPolicy(node cfr.GameTreeNode) []float32 {
+	return br[node.Player()].GetPolicy(node)
+}
+
+// hashFile returns the hex-encoded SHA-256 hash of the given file.
+func hashFile(filename string) (string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func loadPSRO(filename string) (*model.MCTSPSRO, error) {
+	glog.Infof("Loading strategy from: %v", filename)
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return model.LoadMCTSPSRO(bufio.NewReader(f))
+}
+
+func saveResults(filename string, results *Results) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(results); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/golang/glog"
+	"github.com/timpalpant/go-cfr"
+	"github.com/timpalpant/go-cfr/mcts"
+
+	"github.com/timpalpant/alphacats"
+	"github.com/timpalpant/alphacats/cards"
+)
+
+// Strategy is a named player in the tournament.
+type Strategy struct {
+	Name string
+	// ID identifies the saved strategy, e.g. by a hash of its file.
+	ID string
+	// SamplePolicy returns the policy to play for one game. Mixed strategies,
+	// such as a PSRO population, may return a different policy for each game.
+	SamplePolicy func() mcts.Policy
+}
+
+type TournamentParams struct {
+	Deck           []cards.Card
+	CardsPerPlayer int
+	// NumGames is the number of games each pair of strategies plays in each
+	// seating, so each pair plays 2*NumGames games in total.
+	NumGames    int
+	Seed        int64
+	MaxParallel int
+	// If set, a MatchRecord of each game is saved in this directory,
+	// so that any game can be regenerated exactly.
+	MatchDir string
+}
+
+// Results are the outcome of a round-robin tournament.
+type Results struct {
+	Strategies []string `json:"strategies"`
+	// Wins[i][j] is the number of games strategy i won against strategy j.
+	Wins [][]int `json:"wins"`
+	// Games[i][j] is the number of games played between strategies i and j.
+	// Strategies do not play themselves, so Games[i][i] is always zero.
+	Games   [][]int `json:"games"`
+	Ranking []Rank  `json:"ranking"`
+}
+
+// Rank is the overall standing of one strategy in the tournament.
+type Rank struct {
+	Strategy string `json:"strategy"`
+	// WinRate is the average of the strategy's win rates against each opponent.
+	WinRate float64 `json:"winRate"`
+}
+
+// WinRate returns the fraction of games that strategy i won against
+// strategy j, and false if they did not play.
+func (r *Results) WinRate(i, j int) (float64, bool) {
+	if r.Games[i][j] == 0 {
+		return 0, false
+	}
+
+	return float64(r.Wins[i][j]) / float64(r.Games[i][j]), true
+}
+
+// WriteTable writes the win-rate matrix and ranking as a human-readable table.
+func (r *Results) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprint(tw, "Win rate (row vs. column)")
+	for _, name := range r.Strategies {
+		fmt.Fprintf(tw, "\t%s", name)
+	}
+	fmt.Fprintln(tw)
+
+	for i, name := range r.Strategies {
+		fmt.Fprint(tw, name)
+		for j := range r.Strategies {
+			if winRate, ok := r.WinRate(i, j); ok {
+				fmt.Fprintf(tw, "\t%.3f", winRate)
+			} else {
+				fmt.Fprint(tw, "\t-")
+			}
+		}
+		fmt.Fprintln(tw)
+	}
+
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "Rank\tStrategy\tWin rate")
+	for i, rank := range r.Ranking {
+		fmt.Fprintf(tw, "%d\t%s\t%.3f\n", i+1, rank.Strategy, rank.WinRate)
+	}
+
+	return tw.Flush()
+}
+
+type match struct {
+	// Index of the strategy playing as each player.
+	players  [2]int
+	ids      [2]string
+	policies [2]mcts.Policy
+	deal     alphacats.Deal
+	seed     int64
+}
+
+// runTournament plays a round-robin between all pairs of the given strategies.
+// Each pair plays the same NumGames deals in both seatings. Results are
+// deterministic given params.Seed, provided that each strategy is too.
+func runTournament(strategies []Strategy, params TournamentParams) *Results {
+	// All randomness is drawn up front, so that the results do not depend
+	// on the order in which games are scheduled.
+	rand.Seed(params.Seed)
+	// NB: NewRandomDeal shuffles the deck in place.
+	deck := append([]cards.Card(nil), params.Deck...)
+	deals := make([]alphacats.Deal, params.NumGames)
+	for i := range deals {
+		deals[i] = alphacats.NewRandomDeal(deck, params.CardsPerPlayer)
+	}
+
+	var matches []match
+	for i := range strategies {
+		for j := range strategies {
+			if i == j {
+				continue // No self-play.
+			}
+
+			for _, deal := range deals {
+				matches = append(matches, match{
+					players: [2]int{i, j},
+					ids:     [2]string{strategies[i].ID, strategies[j].ID},
+					policies: [2]mcts.Policy{
+						strategies[i].SamplePolicy(),
+						strategies[j].SamplePolicy(),
+					},
+					deal: deal,
+					seed: rand.Int63(),
+				})
+			}
+		}
+	}
+
+	results := newResults(strategies)
+	var mx sync.Mutex
+	var wg sync.WaitGroup
+	matchCh := make(chan match)
+	for worker := 0; worker < params.MaxParallel; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for m := range matchCh {
+				record := playGame(m)
+				if params.MatchDir != "" {
+					if err := saveMatch(params.MatchDir, record); err != nil {
+						glog.Errorf("Unable to save match record: %v", err)
+					}
+				}
+
+				winner := m.players[record.Winner]
+				loser := m.players[0] + m.players[1] - winner
+				mx.Lock()
+				results.Wins[winner][loser]++
+				results.Games[winner][loser]++
+				results.Games[loser][winner]++
+				mx.Unlock()
+			}
+		}()
+	}
+
+	for _, m := range matches {
+		matchCh <- m
+	}
+	close(matchCh)
+	wg.Wait()
+
+	results.Ranking = rankStrategies(results)
+	return results
+}
+
+func newResults(strategies []Strategy) *Results {
+	results := &Results{
+		Strategies: make([]string, len(strategies)),
+		Wins:       make([][]int, len(strategies)),
+		Games:      make([][]int, len(strategies)),
+	}
+
+	for i, s := range strategies {
+		results.Strategies[i] = s.Name
+		results.Wins[i] = make([]int, len(strategies))
+		results.Games[i] = make([]int, len(strategies))
+	}
+
+	return results
+}
+
+// Ranks strategies by their average win rate against each opponent.
+func rankStrategies(results *Results) []Rank {
+	ranking := make([]Rank, len(results.Strategies))
+	for i, name := range results.Strategies {
+		total, n := 0.0, 0
+		for j := range results.Strategies {
+			if winRate, ok := results.WinRate(i, j); ok {
+				total += winRate
+				n++
+			}
+		}
+
+		ranking[i] = Rank{Strategy: name}
+		if n > 0 {
+			ranking[i].WinRate = total / float64(n)
+		}
+	}
+
+	sort.SliceStable(ranking, func(i, j int) bool {
+		return ranking[i].WinRate > ranking[j].WinRate
+	})
+
+	return ranking
+}
+
+// playGame plays out the given match, returning its record.
+func playGame(m match) *alphacats.MatchRecord {
+	policies := [2]func(cfr.GameTreeNode) []float32{
+		m.policies[0].GetPolicy, m.policies[1].GetPolicy,
+	}
+	record, _ := alphacats.PlayMatch(m.deal, m.seed, m.ids, policies)
+	return record
+}
+
+// saveMatch saves the given record in dir, named by its seed
+// (which is unique to each game in a tournament).
+func saveMatch(dir string, record *alphacats.MatchRecord) error {
+	filename := filepath.Join(dir, fmt.Sprintf("match-%d.json", record.Seed))
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	if err := record.Save(f); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/timpalpant/go-cfr"
+	"github.com/timpalpant/go-cfr/mcts"
+
+	"github.com/timpalpant/alphacats/cards"
+)
+
+// Always plays the i'th available action (or the last, if there are fewer).
+type fixedPolicy int
+
+func (f fixedPolicy) GetPolicy(node cfr.GameTreeNode) []float32 {
+	p := make([]float32, node.NumChildren())
+	if int(f) < len(p) {
+		p[f] = 1.0
+	} else {
+		p[len(p)-1] = 1.0
+	}
+
+	return p
+}
+
+type uniformPolicy struct{}
+
+func (uniformPolicy) GetPolicy(node cfr.GameTreeNode) []float32 {
+	p := make([]float32, node.NumChildren())
+	for i := range p {
+		p[i] = 1.0 / float32(len(p))
+	}
+
+	return p
+}
+
+func newStubStrategy(name string, policy mcts.Policy) Strategy {
+	return Strategy{
+		Name:         name,
+		SamplePolicy: func() mcts.Policy { return policy },
+	}
+}
+
+func TestRunTournament(t *testing.T) {
+	strategies := []Strategy{
+		newStubStrategy("first", fixedPolicy(0)),
+		newStubStrategy("second", fixedPolicy(1)),
+		newStubStrategy("uniform", uniformPolicy{}),
+		heuristicStrategy(),
+	}
+	params := TournamentParams{
+		Deck:           cards.CoreDeck.AsSlice(),
+		CardsPerPlayer: 4,
+		NumGames:       5,
+		Seed:           123,
+		MaxParallel:    4,
+	}
+
+	results := runTournament(strategies, params)
+	if len(results.Wins) != len(strategies) || len(results.Games) != len(strategies) {
+		t.Fatalf("expected %d rows, got %d wins and %d games",
+			len(strategies), len(results.Wins), len(results.Games))
+	}
+
+	for i := range strategies {
+		if len(results.Wins[i]) != len(strategies) || len(results.Games[i]) != len(strategies) {
+			t.Fatalf("row %d: expected %d columns, got %d wins and %d games",
+				i, len(strategies), len(results.Wins[i]), len(results.Games[i]))
+		}
+
+		for j := range strategies {
+			if i == j {
+				if results.Games[i][j] != 0 {
+					t.Errorf("%v played itself %d times", strategies[i].Name, results.Games[i][j])
+				}
+				if _, ok := results.WinRate(i, j); ok {
+					t.Errorf("%v has a win rate against itself", strategies[i].Name)
+				}
+				continue
+			}
+
+			if results.Games[i][j] != 2*params.NumGames {
+				t.Errorf("expected %d games between %d and %d, got %d",
+					2*params.NumGames, i, j, results.Games[i][j])
+			}
+
+			if results.Wins[i][j]+results.Wins[j][i] != results.Games[i][j] {
+				t.Errorf("wins between %d and %d do not add up: %d + %d != %d",
+					i, j, results.Wins[i][j], results.Wins[j][i], results.Games[i][j])
+			}
+		}
+	}
+
+	if len(results.Ranking) != len(strategies) {
+		t.Errorf("expected %d ranked strategies, got %d", len(strategies), len(results.Ranking))
+	}
+
+	for i := 1; i < len(results.Ranking); i++ {
+		if results.Ranking[i].WinRate > results.Ranking[i-1].WinRate {
+			t.Errorf("ranking is not sorted: %v", results.Ranking)
+		}
+	}
+
+	// Results are deterministic given the seed.
+	params.MaxParallel = 1
+	if again := runTournament(strategies, params); !reflect.DeepEqual(again, results) {
+		t.Errorf("expected identical results with the same seed: %v, got %v", results, again)
+	}
+
+	var buf bytes.Buffer
+	if err := results.WriteTable(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range strategies {
+		if !strings.Contains(buf.String(), s.Name) {
+			t.Errorf("table does not contain %v:\n%s", s.Name, buf.String())
+		}
+	}
+}
+
+func TestBestResponseStrategy(t *testing.T) {
+	uniform := newStubStrategy("uniform", uniformPolicy{})
+	strategies := []Strategy{uniform, bestResponseStrategy(uniform)}
+	params := TournamentParams{
+		Deck:           cards.TestDeck.AsSlice(),
+		CardsPerPlayer: 2,
+		NumGames:       5,
+		Seed:           123,
+		MaxParallel:    4,
+	}
+
+	if name := strategies[1].Name; name != "br:uniform" {
+		t.Errorf("expected best response to be named br:uniform, got %v", name)
+	}
+
+	results := runTournament(strategies, params)
+	t.Logf("Best response won %d of %d games against uniform",
+		results.Wins[1][0], results.Games[1][0])
+	if results.Wins[1][0] <= results.Wins[0][1] {
+		t.Errorf("expected best response to beat uniform, won %d of %d games",
+			results.Wins[1][0], results.Games[1][0])
+	}
+}
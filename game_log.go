@@ -0,0 +1,71 @@
+package alphacats
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/timpalpant/go-cfr"
+
+	"github.com/timpalpant/alphacats/gamestate"
+)
+
+// GameEvent is one step of a game, as seen by a spectator.
+type GameEvent struct {
+	// Type is "chance", "player" (the viewer's own actions),
+	// or "strategy" (the opponent's actions).
+	Type string `json:"type"`
+	// InfoSet is the viewer's info set before the action was taken.
+	InfoSet     string `json:"infoSet"`
+	ActionTaken string `json:"actionTaken"`
+	// Probabilities is the opponent's policy for strategy events,
+	// or the probability of the outcome for chance events.
+	Probabilities []float32 `json:"probabilities,omitempty"`
+}
+
+// GameLog writes the events of a game as newline-delimited JSON, so that an
+// external spectator can follow along. Events are written from the point of
+// view of one player: private information in the opponent's actions
+// (and in chance outcomes) is hidden.
+//
+// A nil *GameLog discards all events.
+type GameLog struct {
+	enc    *json.Encoder
+	viewer gamestate.Player
+}
+
+func NewGameLog(w io.Writer, viewer gamestate.Player) *GameLog {
+	return &GameLog{
+		enc:    json.NewEncoder(w),
+		viewer: viewer,
+	}
+}
+
+// Log writes the event of moving from node to child. For chance nodes,
+// probabilities should contain the probability of the sampled outcome.
+func (l *GameLog) Log(node, child *GameNode, probabilities []float32) error {
+	if l == nil {
+		return nil
+	}
+
+	event := GameEvent{
+		Type:          "strategy",
+		Probabilities: probabilities,
+	}
+	if node.Type() == cfr.ChanceNodeType {
+		event.Type = "chance"
+	} else if node.player == l.viewer {
+		event.Type = "player"
+	}
+
+	is := node.GetInfoSet(l.viewer)
+	abstracted := newAbstractedInfoSet(&is, nil, node.initialDrawPileLen())
+	event.InfoSet = abstracted.String()
+
+	action := child.LastAction()
+	if event.Type != "player" {
+		action = hidePrivateInfo(gamestate.EncodeAction(action)).Decode()
+	}
+	event.ActionTaken = action.String()
+
+	return l.enc.Encode(event)
+}
@@ -0,0 +1,90 @@
+package alphacats
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/timpalpant/alphacats/cards"
+	"github.com/timpalpant/alphacats/gamestate"
+)
+
+func TestGameLog(t *testing.T) {
+	drawPile := cards.NewStackFromCards([]cards.Card{
+		cards.Cat, cards.Skip, cards.ExplodingKitten, cards.Cat,
+	})
+	p0Deal := cards.NewSetFromCards([]cards.Card{cards.SeeTheFuture})
+	p1Deal := cards.NewSetFromCards([]cards.Card{cards.SeeTheFuture, cards.Shuffle})
+	game := NewGame(drawPile, p0Deal, p1Deal)
+
+	var buf bytes.Buffer
+	gameLog := NewGameLog(&buf, gamestate.Player0)
+	step := func(node, child *GameNode, p []float32) *GameNode {
+		if err := gameLog.Log(node, child, p); err != nil {
+			t.Fatal(err)
+		}
+		return child
+	}
+
+	game = step(game, drawCard(t, game), nil)
+	game = step(game, playCard(t, game, cards.SeeTheFuture), []float32{0.25, 0.5, 0.25})
+	game = step(game, playCard(t, game, cards.Shuffle), []float32{0.5, 0.5})
+	game = step(game, game.GetChild(0).(*GameNode), []float32{0.5})
+
+	expected := []GameEvent{
+		{Type: "player", ActionTaken: "Player0:DrawCard:Cat"},
+		{Type: "strategy", ActionTaken: "Player1:PlayCard:SeeTheFuture",
+			Probabilities: []float32{0.25, 0.5, 0.25}},
+		{Type: "strategy", ActionTaken: "Player1:PlayCard:Shuffle",
+			Probabilities: []float32{0.5, 0.5}},
+		{Type: "chance", ActionTaken: "Player1:PlayCard:Shuffle",
+			Probabilities: []float32{0.5}},
+	}
+
+	scanner := bufio.NewScanner(&buf)
+	i := 0
+	for ; scanner.Scan(); i++ {
+		var event GameEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("event %d is not valid JSON: %v: %s", i, err, scanner.Text())
+		}
+
+		if i >= len(expected) {
+			continue
+		}
+
+		want := expected[i]
+		if event.Type != want.Type || event.ActionTaken != want.ActionTaken {
+			t.Errorf("event %d: expected %v, got %v", i, want, event)
+		}
+
+		if len(event.Probabilities) != len(want.Probabilities) {
+			t.Errorf("event %d: expected probabilities %v, got %v",
+				i, want.Probabilities, event.Probabilities)
+		}
+
+		if !strings.HasPrefix(event.InfoSet, "Player0.") {
+			t.Errorf("event %d: expected info set for %v, got %v",
+				i, gamestate.Player0, event.InfoSet)
+		}
+
+		// Player0 never sees the cards that Player1 saw.
+		if event.Type == "strategy" && strings.Contains(event.ActionTaken, "[") {
+			t.Errorf("event %d: private information leaked: %v", i, event.ActionTaken)
+		}
+	}
+
+	if i != len(expected) {
+		t.Errorf("expected %d events, got %d", len(expected), i)
+	}
+}
+
+func TestNilGameLog(t *testing.T) {
+	var gameLog *GameLog
+	game := newTestDeckGame()
+	if err := gameLog.Log(game, drawCard(t, game), nil); err != nil {
+		t.Error(err)
+	}
+}
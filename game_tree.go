@@ -1,9 +1,12 @@
 package alphacats
 
 import (
+	"encoding/binary"
 	"expvar"
 	"fmt"
+	"math"
 	"math/rand"
+	"strings"
 
 	"github.com/timpalpant/go-cfr"
 
@@ -13,6 +16,9 @@ import (
 
 var (
 	nodesVisited = expvar.NewInt("nodes_visited")
+	// sampledShuffleNodes counts the shuffles with too many outcomes to
+	// enumerate, which were sampled instead (see GameOptions.MaxShuffleChildren).
+	sampledShuffleNodes = expvar.NewInt("sampled_shuffle_nodes")
 )
 
 // turnType represents the kind of turn at a given point in the game.
@@ -26,6 +32,9 @@ const (
 	MustDefuse
 	InsertKittenRandom
 	GameOver
+	// The player drew the ImplodingKitten for the first time, and must put
+	// it back face up.
+	MustReinsert
 )
 
 var turnTypeStr = [...]string{
@@ -36,12 +45,38 @@ var turnTypeStr = [...]string{
 	"MustDefuse",
 	"InsertKittenRandom",
 	"GameOver",
+	"MustReinsert",
 }
 
 func (tt turnType) String() string {
 	return turnTypeStr[tt]
 }
 
+// GameOverReason represents why the game ended, from the winner's point of view.
+type GameOverReason uint8
+
+const (
+	_ GameOverReason = iota
+	// The opponent drew the exploding kitten and did not have a Defuse.
+	OpponentExploded
+	// The opponent played DrawFromTheBottom, drew the exploding kitten
+	// and did not have a Defuse.
+	OpponentExplodedFromBottom
+	// The opponent drew the ImplodingKitten after it was put back face up.
+	OpponentImploded
+)
+
+var gameOverReasonStr = [...]string{
+	"Invalid",
+	"OpponentExploded",
+	"OpponentExplodedFromBottom",
+	"OpponentImploded",
+}
+
+func (r GameOverReason) String() string {
+	return gameOverReasonStr[r]
+}
+
 // GameNode implements cfr.GameTreeNode for Exploding Kittens.
 // GameNode represents a state of play in the extensive-form game tree.
 type GameNode struct {
@@ -50,10 +85,28 @@ type GameNode struct {
 	turnType turnType
 	// pendingTurns is the number of turns the player has outstanding
 	// to play. In general this will be 1, except when Slap cards are played.
+	// It need not be part of the info set, since it is determined by the
+	// public history of actions.
 	pendingTurns int
 	// nDrawPileCards is used lazily be ShuffleDrawPile nodes to cache
 	// the number of cards in the draw pile.
 	nDrawPileCards int
+	// nInitialDrawPileCards is the number of cards that were in the draw
+	// pile at the start of the game, which depends on the deck it was
+	// dealt from.
+	nInitialDrawPileCards int
+	// gameOverReason is set on GameOver nodes to the reason the game ended.
+	gameOverReason GameOverReason
+	// maxHandSize is the maximum number of cards a player may hold (0 = unlimited).
+	maxHandSize int
+	// recallDepth is the number of most recent actions retained in
+	// info sets (0 = perfect recall).
+	recallDepth int
+	// maxShuffleChildren is the maximum number of children of a
+	// ShuffleDrawPile node (0 = unlimited).
+	maxShuffleChildren int
+	// slapBackRule determines when slaps pass on pending turns.
+	slapBackRule SlapBackRule
 
 	// children are the possible next states in the game.
 	// Which child is realized will depend on chance or a player's action.
@@ -62,6 +115,9 @@ type GameNode struct {
 	// len(actions) must always equal len(children).
 	actions []gamestate.Action
 	parent  *GameNode
+	// singleAction is the storage for actions if the node has only one
+	// child, so that building forced nodes needs no pooled action slice.
+	singleAction [1]gamestate.Action
 
 	gnPool *gameNodeSlicePool
 	aPool  *actionSlicePool
@@ -73,15 +129,113 @@ var _ cfr.GameTreeNode = &GameNode{}
 // NewGame creates a root node for a new game with the given draw pile
 // and hands dealt to each player.
 func NewGame(drawPile cards.Stack, p0Deal, p1Deal cards.Set) *GameNode {
+	return NewGameWithOptions(drawPile, p0Deal, p1Deal, GameOptions{})
+}
+
+// GameOptions configure variants of the standard game.
+type GameOptions struct {
+	// MaxHandSize is the maximum number of cards a player may hold.
+	// A player with a full hand may not draw a card to end their turn,
+	// and must play a card instead. If they have no card they can play,
+	// they may still draw. Zero means unlimited, as in the standard game.
+	//
+	// NOTE: Playing a Cat never causes a hand to grow, since the
+	// Cat is discarded in exchange for the card that is given.
+	MaxHandSize int
+	// FirstPlayer is the player who takes the first turn. The zero value
+	// is Player0, as in the standard game.
+	//
+	// NOTE: BeliefState and Replay rebuild games with NewGame, and so
+	// assume that Player0 goes first.
+	FirstPlayer gamestate.Player
+	// DebugPool is a debugging aid that catches nodes being used after
+	// their parent was closed, which would otherwise silently see the
+	// state of whichever node reuses the pooled slice. Closed children are
+	// cleared and never reused, so that any later use of them panics.
+	// This defeats the pool, so it should only be enabled in tests.
+	DebugPool bool
+	// RecallDepth, if positive, abstracts the game with imperfect recall:
+	// info sets (see InfoSet and InfoSetKey) retain only the last
+	// RecallDepth actions of the public history. The player's hand and
+	// their knowledge of the cards played and the draw pile are still
+	// derived from the full history. This makes far fewer info sets for
+	// large games, at the cost of optimality. Zero means perfect recall.
+	//
+	// NOTE: The available actions remain part of the info set, so a
+	// policy for a truncated info set is always legal in every state
+	// that shares it.
+	RecallDepth int
+	// MaxShuffleChildren, if positive, limits the number of children of
+	// the chance node after a Shuffle is played. A draw pile of n cards
+	// has n! orderings, so traversals that enumerate every child (e.g.
+	// ExpectedUtility) are intractable for large draw piles. If there are
+	// more orderings than the limit, the node instead has
+	// MaxShuffleChildren children, each a pseudo-random ordering of the draw
+	// pile (see sampledShuffleIndex). Traversals that enumerate the children
+	// therefore average over a fixed sample of the orderings, which
+	// approximates the average over all of them. SampleChild still samples
+	// from all of the orderings. Zero means every ordering is enumerated.
+	MaxShuffleChildren int
+	// SlapBackRule determines when a Slap played in response to another
+	// Slap passes on the player's pending turns. The zero value is
+	// AnySlapBack, as in the standard game.
+	SlapBackRule SlapBackRule
+}
+
+// NewGameWithOptions creates a root node for a new game with the given draw
+// pile and hands dealt to each player, and the given rule variants.
+func NewGameWithOptions(drawPile cards.Stack, p0Deal, p1Deal cards.Set, opts GameOptions) *GameNode {
 	return &GameNode{
-		state: gamestate.New(drawPile, p0Deal, p1Deal),
-		// Player0 always goes first.
-		player:       gamestate.Player0,
-		turnType:     PlayTurn,
-		pendingTurns: 1,
-		gnPool:       &gameNodeSlicePool{},
-		aPool:        &actionSlicePool{},
+		state:                 gamestate.New(drawPile, p0Deal, p1Deal),
+		player:                opts.FirstPlayer,
+		turnType:              PlayTurn,
+		pendingTurns:          1,
+		nInitialDrawPileCards: drawPile.Len(),
+		maxHandSize:           opts.MaxHandSize,
+		recallDepth:           opts.RecallDepth,
+		maxShuffleChildren:    opts.MaxShuffleChildren,
+		slapBackRule:          opts.SlapBackRule,
+		gnPool:                &gameNodeSlicePool{debug: opts.DebugPool},
+		aPool:                 &actionSlicePool{},
+	}
+}
+
+// NewGameWithFirstPlayer creates a root node for a new game in which the
+// given player takes the first turn. Alternating the first player between
+// games controls for any first-mover advantage when evaluating strategies.
+func NewGameWithFirstPlayer(drawPile cards.Stack, p0Deal, p1Deal cards.Set, firstPlayer gamestate.Player) *GameNode {
+	return NewGameWithOptions(drawPile, p0Deal, p1Deal, GameOptions{FirstPlayer: firstPlayer})
+}
+
+// NewGameFromSpec creates a root node for a new game from a compact textual
+// description of the draw pile and the hands dealt to each player, separated
+// by semicolons. For example:
+//
+//	[Cat, ExplodingKitten, Skip]; {1 Defuse, 1 Skip}; {1 Defuse, 1 Cat}
+//
+// This is useful to pin an exact opening position in tests and benchmarks.
+func NewGameFromSpec(spec string) (*GameNode, error) {
+	parts := strings.Split(spec, ";")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("invalid game spec: expected 3 parts, got %d: %q", len(parts), spec)
 	}
+
+	drawPile, err := cards.ParseStack(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return nil, err
+	}
+
+	p0Deal, err := cards.ParseSet(strings.TrimSpace(parts[1]))
+	if err != nil {
+		return nil, err
+	}
+
+	p1Deal, err := cards.ParseSet(strings.TrimSpace(parts[2]))
+	if err != nil {
+		return nil, err
+	}
+
+	return NewGame(drawPile, p0Deal, p1Deal), nil
 }
 
 func (gn *GameNode) Clone() *GameNode {
@@ -99,8 +253,68 @@ func (gn *GameNode) CloneWithState(state gamestate.GameState) *GameNode {
 	return &result
 }
 
+// MarshalBinary implements encoding.BinaryMarshaler. Only the node itself
+// is encoded: its children are rebuilt as needed once it is unmarshaled.
+func (gn *GameNode) MarshalBinary() ([]byte, error) {
+	fields := []int{int(gn.player), int(gn.turnType), gn.pendingTurns,
+		gn.nDrawPileCards, int(gn.gameOverReason), gn.maxHandSize, gn.recallDepth,
+		int(gn.slapBackRule)}
+	buf := make([]byte, len(fields), len(fields)+4)
+	for i, x := range fields {
+		if x < 0 || x > 0xff {
+			return nil, fmt.Errorf("cannot encode %v: field %d (%d) is out of range", gn, i, x)
+		}
+
+		buf[i] = uint8(x)
+	}
+
+	if gn.maxShuffleChildren < 0 || uint64(gn.maxShuffleChildren) > math.MaxUint32 {
+		return nil, fmt.Errorf("cannot encode %v: max shuffle children (%d) is out of range",
+			gn, gn.maxShuffleChildren)
+	}
+	buf = buf[:len(fields)+4]
+	binary.LittleEndian.PutUint32(buf[len(fields):], uint32(gn.maxShuffleChildren))
+
+	state, err := gn.state.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	return append(buf, state...), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+// The unmarshaled node has no parent.
+func (gn *GameNode) UnmarshalBinary(buf []byte) error {
+	if len(buf) < 12 {
+		return fmt.Errorf("invalid game node encoding (%d bytes)", len(buf))
+	}
+
+	*gn = GameNode{
+		player:             gamestate.Player(buf[0]),
+		turnType:           turnType(buf[1]),
+		pendingTurns:       int(buf[2]),
+		nDrawPileCards:     int(buf[3]),
+		gameOverReason:     GameOverReason(buf[4]),
+		maxHandSize:        int(buf[5]),
+		recallDepth:        int(buf[6]),
+		slapBackRule:       SlapBackRule(buf[7]),
+		maxShuffleChildren: int(binary.LittleEndian.Uint32(buf[8:])),
+		gnPool:             &gameNodeSlicePool{},
+		aPool:              &actionSlicePool{},
+	}
+
+	if err := gn.state.UnmarshalBinary(buf[12:]); err != nil {
+		return err
+	}
+
+	gn.nInitialDrawPileCards = gn.countInitialDrawPileCards()
+	return nil
+}
+
 // Type implements cfr.GameTreeNode.
 func (gn *GameNode) Type() cfr.NodeType {
+	gn.checkLive()
 	switch gn.turnType {
 	case ShuffleDrawPile, InsertKittenRandom:
 		return cfr.ChanceNodeType
@@ -113,9 +327,21 @@ func (gn *GameNode) Type() cfr.NodeType {
 
 // Player implements cfr.GameTreeNode.
 func (gn *GameNode) Player() int {
+	gn.checkLive()
 	return int(gn.player)
 }
 
+// checkLive panics if this node has been freed by closing its parent.
+// Freed nodes are only detected with GameOptions.DebugPool, since otherwise
+// the freed slice is reused for other nodes.
+func (gn *GameNode) checkLive() {
+	// NB: Every node that is built has a valid turn type, and freed
+	// nodes are cleared to the zero value (except for their pool).
+	if gn.gnPool.debug && gn.turnType == 0 {
+		panic(fmt.Errorf("use of freed game node: its parent was closed while it was still in use"))
+	}
+}
+
 func (gn *GameNode) GetState() gamestate.GameState {
 	return gn.state
 }
@@ -135,7 +361,8 @@ func (gn *GameNode) InfoSet(player int) cfr.InfoSet {
 	}
 
 	is := gn.GetInfoSet(gamestate.Player(player))
-	abstractedIS := newAbstractedInfoSet(&is, gn.actions)
+	abstractedIS := newAbstractedInfoSet(&is, gn.actions, gn.initialDrawPileLen())
+	abstractedIS.truncateHistory(gn.recallDepth)
 	return &abstractedIS
 }
 
@@ -145,14 +372,60 @@ func (gn *GameNode) InfoSetKey(player int) []byte {
 	}
 
 	is := gn.GetInfoSet(gamestate.Player(player))
-	ais := newAbstractedInfoSet(&is, gn.actions)
+	ais := newAbstractedInfoSet(&is, gn.actions, gn.initialDrawPileLen())
+	ais.truncateHistory(gn.recallDepth)
 	return ais.Key()
 }
 
+// AppendInfoSetKey appends the key of the given player's info set to buf,
+// and returns the extended buffer. The key is identical to InfoSetKey,
+// but no allocations are necessary if buf has sufficient capacity.
+func (gn *GameNode) AppendInfoSetKey(buf []byte, player int) []byte {
+	if len(gn.children) == 0 {
+		gn.buildChildren()
+	}
+
+	is := gn.GetInfoSet(gamestate.Player(player))
+	ais := newAbstractedInfoSet(&is, gn.actions, gn.initialDrawPileLen())
+	ais.truncateHistory(gn.recallDepth)
+	return ais.AppendKey(buf)
+}
+
 func (gn *GameNode) GetInfoSet(player gamestate.Player) gamestate.InfoSet {
 	return gn.state.GetInfoSet(player)
 }
 
+// initialDrawPileLen returns the number of cards that were in the draw pile
+// at the start of the game, which depends on the deck it was dealt from.
+func (gn *GameNode) initialDrawPileLen() int {
+	return gn.nInitialDrawPileCards
+}
+
+// countInitialDrawPileCards recovers the number of cards that were in the
+// draw pile at the start of the game from the history of the game so far.
+func (gn *GameNode) countInitialDrawPileCards() int {
+	n := gn.state.GetDrawPile().Len()
+	h := gn.state.GetHistory()
+	for i := 0; i < h.Len(); i++ {
+		action := h.Get(i)
+		switch {
+		case action.Type == gamestate.DrawCard,
+			action.Type == gamestate.PlayCard && action.Card == cards.DrawFromTheBottom:
+			n++
+		case action.Type == gamestate.InsertExplodingKitten:
+			n--
+		}
+	}
+
+	// The kitten being inserted at a random position is not in the
+	// draw pile until the chance node is resolved.
+	if gn.turnType == InsertKittenRandom {
+		n++
+	}
+
+	return n
+}
+
 // Utility implements cfr.GameTreeNode.
 func (gn *GameNode) Utility(player int) float64 {
 	if gn.Type() != cfr.TerminalNodeType {
@@ -166,6 +439,70 @@ func (gn *GameNode) Utility(player int) float64 {
 	return -1.0
 }
 
+// The number of players in the game.
+const numPlayers = 2
+
+// Utilities returns the payoff of each player at a terminal node.
+// The game is zero-sum, so the payoffs always sum to zero.
+func (gn *GameNode) Utilities() []float64 {
+	result := make([]float64, numPlayers)
+	for player := range result {
+		result[player] = gn.Utility(player)
+	}
+
+	return result
+}
+
+// GameOverReason returns the winner of the game, and why they won.
+// GameOverReason panics if the game is not over.
+func (gn *GameNode) GameOverReason() (winner gamestate.Player, reason GameOverReason) {
+	if gn.Type() != cfr.TerminalNodeType {
+		panic("cannot get the game over reason of a non-terminal node")
+	}
+
+	return gn.player, gn.gameOverReason
+}
+
+// The maximum number of moves ForcedOutcome looks ahead.
+const forcedOutcomeDepth = 4
+
+// ForcedOutcome returns the winner of the game if it is already determined,
+// regardless of the actions that either player or chance take from here.
+// Only a shallow look-ahead is performed, so forced is false both when the
+// outcome is not determined and when it could not be determined cheaply.
+//
+// Note that the look-ahead uses the full game state, including information
+// that may not be known to either player.
+func (gn *GameNode) ForcedOutcome() (winner gamestate.Player, forced bool) {
+	return gn.forcedOutcome(forcedOutcomeDepth)
+}
+
+func (gn *GameNode) forcedOutcome(depth int) (gamestate.Player, bool) {
+	if gn.turnType == GameOver {
+		return gn.player, true
+	}
+
+	// Shuffle nodes have too many children to enumerate.
+	if depth == 0 || gn.turnType == ShuffleDrawPile {
+		return 0, false
+	}
+
+	// Expand a copy, so that the look-ahead does not build out this node.
+	node := gn.Clone()
+	node.buildChildren()
+	var winner gamestate.Player
+	for i := range node.children {
+		childWinner, forced := node.children[i].forcedOutcome(depth - 1)
+		if !forced || (i > 0 && childWinner != winner) {
+			return 0, false
+		}
+
+		winner = childWinner
+	}
+
+	return winner, true
+}
+
 // String implements fmt.Stringer.
 func (gn *GameNode) String() string {
 	return fmt.Sprintf("%v's turn to %v (%d remaining). Hand: %s. %d cards in draw pile: %s",
@@ -179,19 +516,30 @@ func (gn *GameNode) GetDrawPile() cards.Stack {
 
 func (gn *GameNode) allocChildren(n int) {
 	gn.children = gn.gnPool.alloc(n)
-	gn.actions = gn.aPool.alloc(n)
-	// Children are initialized as a copy of the current game node,
-	// but without any children (the new node's children must be built).
-	childPrototype := *gn
-	childPrototype.children = nil
-	childPrototype.actions = nil
-	childPrototype.parent = gn
+	if n == 1 {
+		// Fast path: forced nodes (and shuffles) are common, and have
+		// a single child, so its action is stored inline.
+		gn.actions = gn.singleAction[:0]
+	} else {
+		gn.actions = gn.aPool.alloc(n)
+	}
+	childPrototype := gn.childPrototype()
 	for i := 0; i < n; i++ {
 		gn.children = append(gn.children, childPrototype)
 		gn.actions = append(gn.actions, gamestate.Action{})
 	}
 }
 
+// Children are initialized as a copy of the current game node,
+// but without any children (the new node's children must be built).
+func (gn *GameNode) childPrototype() GameNode {
+	result := *gn
+	result.children = nil
+	result.actions = nil
+	result.parent = gn
+	return result
+}
+
 func (gn *GameNode) buildChildren() {
 	if len(gn.children) > 0 {
 		return // Already built.
@@ -211,6 +559,8 @@ func (gn *GameNode) buildChildren() {
 		gn.buildInsertKittenRandomChildren()
 	case MustDefuse:
 		gn.buildMustDefuseChildren()
+	case MustReinsert:
+		gn.buildMustReinsertChildren()
 	case GameOver:
 	default:
 		panic("unimplemented turn type!")
@@ -218,9 +568,14 @@ func (gn *GameNode) buildChildren() {
 }
 
 func (gn *GameNode) NumChildren() int {
+	gn.checkLive()
 	// Chance children are lazily generated because we always sample them
 	// but we can easily compute how many there will be.
 	if gn.turnType == ShuffleDrawPile {
+		if gn.isSampledShuffle() {
+			return gn.maxShuffleChildren
+		}
+
 		return factorial[gn.nDrawPileCards]
 	}
 
@@ -237,19 +592,70 @@ func (gn *GameNode) NumChildren() int {
 }
 
 // GetChild implements cfr.GameTreeNode.
+// GetChild panics if i is not in [0, NumChildren()).
 func (gn *GameNode) GetChild(i int) cfr.GameTreeNode {
+	gn.checkLive()
 	if len(gn.children) == 0 {
 		gn.buildChildren()
 	}
 
+	if n := gn.NumChildren(); i < 0 || i >= n {
+		panic(fmt.Errorf("child %d is out of range, node has %d children: %v", i, n, gn))
+	}
+
 	if gn.turnType == ShuffleDrawPile {
-		shuffle := nthShuffle(gn.state.GetDrawPile(), i)
+		k := i
+		if gn.isSampledShuffle() {
+			k = sampledShuffleIndex(i, factorial[gn.nDrawPileCards], uint64(gn.state.GetDrawPile()))
+		}
+
+		shuffle := nthShuffle(gn.state.GetDrawPile(), k)
 		return gn.buildShuffleChild(shuffle)
 	}
 
 	return &gn.children[i]
 }
 
+// ChildMatching returns the first child reached by an action that satisfies
+// pred, and whether there is one. Children are considered in the same order
+// as GetChild and the available actions of the InfoSet, so this can be used
+// to follow a chosen line of play (e.g. the opponent playing a Skip) without
+// scanning the children by index. Shuffles of the draw pile are not reached
+// by an action, so no child of a ShuffleDrawPile node matches.
+func (gn *GameNode) ChildMatching(pred func(gamestate.Action) bool) (*GameNode, bool) {
+	if gn.turnType == ShuffleDrawPile {
+		return nil, false
+	}
+
+	n := gn.NumChildren()
+	for i := 0; i < n; i++ {
+		if pred(gn.actions[i]) {
+			return &gn.children[i], true
+		}
+	}
+
+	return nil, false
+}
+
+// GiveCardOptions returns the distinct cards the player may give at a
+// GiveCard node, in the same order as its children (see GetChild), so that
+// an interactive tool can present them. The options depend only on the
+// giver's own hand, so presenting them does not reveal anything to the
+// giver that they do not already know.
+func (gn *GameNode) GiveCardOptions() []cards.Card {
+	if gn.turnType != GiveCard {
+		panic(fmt.Errorf("give card options are only defined for GiveCard nodes: %v", gn))
+	}
+
+	n := gn.NumChildren()
+	result := make([]cards.Card, n)
+	for i := range result {
+		result[i] = gn.actions[i].Card
+	}
+
+	return result
+}
+
 func (gn *GameNode) Parent() cfr.GameTreeNode {
 	// NOTE: Make sure to return explicit nil, so we don't fall into
 	// the non-nil interface gotcha.
@@ -268,6 +674,81 @@ func (gn *GameNode) Depth() int {
 	return count
 }
 
+// Step returns the child reached by taking the given action, without
+// building the other children of this node. The result is identical to
+// the corresponding child returned by GetChild.
+//
+// The action must be given as chosen by the player, without any private
+// information that is revealed by taking it (such as the card drawn).
+// Step returns an error if the action is not legal at this node. Shuffles
+// cannot be stepped through, since their outcome is not an Action.
+func (gn *GameNode) Step(action gamestate.Action) (*GameNode, error) {
+	if err := gn.checkLegal(action); err != nil {
+		return nil, err
+	}
+
+	child := gn.childPrototype()
+	gn.applyChildAction(&child, action)
+	return &child, nil
+}
+
+// checkLegal returns an error if the given action is not one of the
+// actions that buildChildren would generate at this node.
+func (gn *GameNode) checkLegal(action gamestate.Action) error {
+	if action.Player != gn.player {
+		return fmt.Errorf("illegal action %v: it is %v's turn", action, gn.player)
+	}
+
+	if action.HasPrivateInfo() && action.Type != gamestate.InsertExplodingKitten {
+		return fmt.Errorf("illegal action %v: must not include private information", action)
+	}
+
+	if action.Canceled {
+		return fmt.Errorf("illegal action %v: cannot take a canceled action", action)
+	}
+
+	hand := gn.state.GetPlayerHand(gn.player)
+	nCardsInDrawPile := gn.state.GetDrawPile().Len()
+	legal := false
+	switch gn.turnType {
+	case PlayTurn:
+		switch action.Type {
+		case gamestate.PlayCard:
+			legal = hand.Contains(action.Card) && isPlayable(action.Card)
+		case gamestate.DrawCard:
+			legal = action.Card == cards.Unknown && !gn.mustPlay()
+		}
+	case GiveCard:
+		legal = action.Type == gamestate.GiveCard && hand.Contains(action.Card)
+	case MustDefuse:
+		pos := int(action.PositionInDrawPile)
+		nOptions := min(nCardsInDrawPile+1, maxInsertKittenPositions)
+		isBottom := nCardsInDrawPile >= maxInsertKittenPositions && pos == nCardsInDrawPile+1
+		legal = action.Type == gamestate.InsertExplodingKitten &&
+			action.Card == cards.Defuse && (pos <= nOptions || isBottom)
+	case MustReinsert:
+		pos := int(action.PositionInDrawPile)
+		nOptions := min(nCardsInDrawPile+1, maxInsertKittenPositions)
+		isBottom := nCardsInDrawPile >= maxInsertKittenPositions && pos == nCardsInDrawPile+1
+		legal = action.Type == gamestate.InsertExplodingKitten &&
+			action.Card == cards.ImplodingKitten && pos >= 1 && (pos <= nOptions || isBottom)
+	case InsertKittenRandom:
+		pos := int(action.PositionInDrawPile)
+		legal = action.Type == gamestate.InsertExplodingKitten &&
+			action.Card == cards.Unknown && pos >= 1 && pos <= nCardsInDrawPile+1
+	case ShuffleDrawPile:
+		return fmt.Errorf("cannot step through a shuffle of the draw pile")
+	case GameOver:
+		return fmt.Errorf("illegal action %v: the game is over", action)
+	}
+
+	if !legal {
+		return fmt.Errorf("illegal action %v in %v", action, gn)
+	}
+
+	return nil
+}
+
 // GetChildProbability implements cfr.GameTreeNode.
 func (gn *GameNode) GetChildProbability(i int) float64 {
 	if gn.Type() != cfr.ChanceNodeType {
@@ -280,8 +761,33 @@ func (gn *GameNode) GetChildProbability(i int) float64 {
 
 // SampleChild implements cfr.GameTreeNode.
 func (gn *GameNode) SampleChild() (cfr.GameTreeNode, float64) {
-	// All chance nodes are uniform random over their children.
-	selected := rand.Intn(gn.NumChildren())
+	return gn.sampleChild(rand.Intn)
+}
+
+// SampleChildWithRand is like SampleChild, but draws from the given source,
+// so that chance outcomes are reproducible given its seed.
+func (gn *GameNode) SampleChildWithRand(rng *rand.Rand) (cfr.GameTreeNode, float64) {
+	return gn.sampleChild(rng.Intn)
+}
+
+// Samples a chance outcome uniformly at random, returning the child and its
+// probability. If the node is a sampled shuffle (see isSampledShuffle), the
+// ordering of the draw pile is drawn from all of its orderings, rather than
+// only the MaxShuffleChildren children enumerated by GetChild.
+func (gn *GameNode) sampleChild(intn func(n int) int) (cfr.GameTreeNode, float64) {
+	if gn.turnType == ShuffleDrawPile && gn.isSampledShuffle() {
+		gn.checkLive()
+		if len(gn.children) == 0 {
+			gn.buildChildren()
+		}
+
+		n := factorial[gn.nDrawPileCards]
+		shuffle := nthShuffle(gn.state.GetDrawPile(), intn(n))
+		return gn.buildShuffleChild(shuffle), 1.0 / float64(n)
+	}
+
+	// All other chance nodes are uniform random over their children.
+	selected := intn(gn.NumChildren())
 	return gn.GetChild(selected), gn.GetChildProbability(selected)
 }
 
@@ -296,7 +802,11 @@ func (gn *GameNode) Close() {
 		}
 
 		gn.gnPool.free(gn.children)
-		gn.aPool.free(gn.actions)
+		// NB: Pooled action slices always have capacity > 1,
+		// otherwise the actions are stored inline.
+		if cap(gn.actions) > 1 {
+			gn.aPool.free(gn.actions)
+		}
 	}
 
 	gn.children = nil
@@ -304,7 +814,19 @@ func (gn *GameNode) Close() {
 }
 
 func makePlayTurnNode(node *GameNode, player gamestate.Player, pendingTurns int) {
-	if node.state.GetPlayerHand(player).Contains(cards.ExplodingKitten) {
+	if node.state.GetPlayerHand(player).Contains(cards.ImplodingKitten) {
+		if implodingKittenIsFaceUp(node.state.GetHistory()) {
+			// Player drew the imploding kitten a second time, and loses
+			// even if they have a defuse card.
+			makeTerminalGameNode(node, nextPlayer(player), OpponentImploded)
+		} else {
+			// Player drew the imploding kitten for the first time,
+			// and must put it back face up.
+			node.player = player
+			node.turnType = MustReinsert
+			node.pendingTurns = pendingTurns
+		}
+	} else if node.state.GetPlayerHand(player).Contains(cards.ExplodingKitten) {
 		// Player drew an exploding kitten, must defuse it before continuing.
 		if node.state.GetPlayerHand(player).Contains(cards.Defuse) {
 			// Player has a defuse card, must play it.
@@ -312,7 +834,11 @@ func makePlayTurnNode(node *GameNode, player gamestate.Player, pendingTurns int)
 		} else {
 			// Player does not have a defuse card, end game with loss for them.
 			winner := nextPlayer(player)
-			makeTerminalGameNode(node, winner)
+			reason := OpponentExploded
+			if lastAction := node.state.LastAction(); lastAction.Card == cards.DrawFromTheBottom {
+				reason = OpponentExplodedFromBottom
+			}
+			makeTerminalGameNode(node, winner, reason)
 		}
 	} else {
 		// Just a normal card, add it to player's hand and continue.
@@ -328,6 +854,19 @@ func makePlayTurnNode(node *GameNode, player gamestate.Player, pendingTurns int)
 	}
 }
 
+// implodingKittenIsFaceUp returns whether the imploding kitten has already
+// been drawn and put back face up in the given history.
+func implodingKittenIsFaceUp(h gamestate.History) bool {
+	for i := 0; i < h.Len(); i++ {
+		action := h.Get(i)
+		if action.Type == gamestate.InsertExplodingKitten && action.Card == cards.ImplodingKitten {
+			return true
+		}
+	}
+
+	return false
+}
+
 func makeGiveCardNode(node *GameNode, player gamestate.Player) {
 	node.player = player
 	node.turnType = GiveCard
@@ -339,76 +878,87 @@ func makeMustDefuseNode(node *GameNode, player gamestate.Player, pendingTurns in
 	node.pendingTurns = pendingTurns
 }
 
-func makeTerminalGameNode(node *GameNode, winner gamestate.Player) {
+func makeTerminalGameNode(node *GameNode, winner gamestate.Player, reason GameOverReason) {
 	node.player = winner
 	node.turnType = GameOver
+	node.gameOverReason = reason
 }
 
 func (gn *GameNode) buildPlayTurnChildren() {
+	if gn.state.GetDrawPile().Len() == 0 {
+		// The exploding kitten is always in the draw pile until the game
+		// ends, so a valid game can never reach this state.
+		panic(fmt.Errorf("draw pile is empty, deal must include an exploding kitten: %v", gn))
+	}
+
 	hand := gn.state.GetPlayerHand(gn.player)
-	gn.allocChildren(hand.Len() + 1)
+	nPlayable := 0
+	hand.Iter(func(card cards.Card, count uint8) {
+		if isPlayable(card) {
+			nPlayable++
+		}
+	})
+
+	// NB: Children are allocated exactly, so that forced draws (with no
+	// playable cards) take the single child fast path in allocChildren.
+	mustPlay := gn.mustPlay()
+	if mustPlay {
+		gn.allocChildren(nPlayable)
+	} else {
+		gn.allocChildren(nPlayable + 1)
+	}
+
 	i := 0
 	// Play one of the cards in our hand.
 	hand.Iter(func(card cards.Card, count uint8) {
-		child := &gn.children[i]
+		if !isPlayable(card) {
+			// Defuse may only be played to defuse a drawn exploding kitten.
+			return
+		}
+
 		action := gamestate.Action{
 			Player: gn.player,
 			Type:   gamestate.PlayCard,
 			Card:   card,
 		}
 		gn.actions[i] = action
-		child.state.Apply(action, true)
-
-		switch card {
-		case cards.Defuse, cards.SeeTheFuture:
-			makePlayTurnNode(child, gn.player, gn.pendingTurns)
-		case cards.Skip, cards.DrawFromTheBottom:
-			// Ends our current turn (with/without drawing a card).
-			makePlayTurnNode(child, gn.player, gn.pendingTurns-1)
-		case cards.Shuffle:
-			child.turnType = ShuffleDrawPile
-			child.nDrawPileCards = gn.state.GetDrawPile().Len()
-		case cards.Slap1x, cards.Slap2x:
-			// Ends our turn (and all pending turns). Goes to next player with
-			// any pending turns + slap.
-			pendingTurns := 1
-			if card == cards.Slap2x {
-				pendingTurns = 2
-			}
-
-			lastAction := gn.state.LastAction()
-			slapBack := lastAction.Type == gamestate.PlayCard && (lastAction.Card == cards.Slap1x || lastAction.Card == cards.Slap2x)
-			if slapBack {
-				pendingTurns += gn.pendingTurns
-			}
-
-			makePlayTurnNode(child, nextPlayer(gn.player), pendingTurns)
-		case cards.Cat:
-			if child.state.GetPlayerHand(nextPlayer(gn.player)).Len() == 0 {
-				// Other player has no cards in their hand, this was a no-op.
-				makePlayTurnNode(child, gn.player, gn.pendingTurns)
-			} else {
-				// Other player must give us a card.
-				makeGiveCardNode(child, nextPlayer(gn.player))
-			}
-		default:
-			panic(fmt.Errorf("Player playing unsupported %v card", card))
-		}
-
+		gn.applyChildAction(&gn.children[i], action)
 		i++
 	})
 
-	gn.children = gn.children[:i+1]
-	gn.actions = gn.actions[:i+1]
+	if mustPlay {
+		// Our hand is full, so we must play a card rather than draw.
+		return
+	}
+
 	// End our turn by drawing a card.
-	lastChild := &gn.children[i]
 	action := gamestate.Action{
 		Player: gn.player,
 		Type:   gamestate.DrawCard,
 	}
-	lastChild.state.Apply(action, true)
 	gn.actions[i] = action
-	makePlayTurnNode(lastChild, gn.player, gn.pendingTurns-1)
+	gn.applyChildAction(&gn.children[i], action)
+}
+
+// Whether the current player has a full hand, and so must play a card
+// rather than draw to end their turn.
+func (gn *GameNode) mustPlay() bool {
+	if gn.maxHandSize <= 0 {
+		return false
+	}
+
+	hand := gn.state.GetPlayerHand(gn.player)
+	if hand.Len() < gn.maxHandSize {
+		return false
+	}
+
+	for _, card := range hand.Distinct() {
+		if isPlayable(card) {
+			return true
+		}
+	}
+
+	return false
 }
 
 func (gn *GameNode) buildGiveCardChildren() {
@@ -416,22 +966,13 @@ func (gn *GameNode) buildGiveCardChildren() {
 	gn.allocChildren(hand.Len())
 	i := 0
 	hand.Iter(func(card cards.Card, count uint8) {
-		// Form child node by:
-		//   1) Removing card from our hand,
-		//   2) Adding card to opponent's hand,
-		//   3) Returning to opponent's turn.
-		child := &gn.children[i]
 		action := gamestate.Action{
 			Player: gn.player,
 			Type:   gamestate.GiveCard,
 			Card:   card,
 		}
-		child.state.Apply(action, true)
 		gn.actions[i] = action
-
-		// Game play returns to other player (with the given card in their hand).
-		makePlayTurnNode(child, nextPlayer(gn.player), gn.pendingTurns)
-
+		gn.applyChildAction(&gn.children[i], action)
 		i++
 	})
 
@@ -440,70 +981,164 @@ func (gn *GameNode) buildGiveCardChildren() {
 }
 
 func (gn *GameNode) buildMustDefuseChildren() {
+	// 0 cards in draw pile -> nOptions = 1 -> 2 children -> i in 0 + prune extra child
 	// 1 card in draw pile -> nOptions = 2 -> 3 children -> i in 0, 1 + prune extra child
 	// 5 card in draw pile -> nOptions = 6 -> 7 children -> i in 0..5 + prune extra child
 	// 6 card in draw pile -> nOptions = 6 -> 7 children -> i in 0..5 + use extra child for bottom
 	nCardsInDrawPile := gn.state.GetDrawPile().Len()
-	nOptions := min(nCardsInDrawPile+1, 6)
+	nOptions := min(nCardsInDrawPile+1, maxInsertKittenPositions)
 	gn.allocChildren(nOptions + 2)
 	// Place in the i'th position.
 	for i := 0; i < nOptions; i++ {
-		child := &gn.children[i]
 		action := gamestate.Action{
 			Player:             gn.player,
 			Type:               gamestate.InsertExplodingKitten,
 			Card:               cards.Defuse,
 			PositionInDrawPile: uint8(i + 1),
 		}
-		child.state.Apply(action, true)
 		gn.actions[i] = action
-
-		makePlayTurnNode(child, gn.player, gn.pendingTurns)
+		gn.applyChildAction(&gn.children[i], action)
 	}
 
 	// Place randomly.
-	child := &gn.children[nOptions]
-	child.turnType = InsertKittenRandom
 	action := gamestate.Action{
 		Player: gn.player,
 		Type:   gamestate.InsertExplodingKitten,
 		Card:   cards.Defuse,
 	}
-	child.state.Apply(action, true)
 	gn.actions[nOptions] = action
+	gn.applyChildAction(&gn.children[nOptions], action)
 
 	// Place exploding cat on the bottom of the draw pile.
-	if nCardsInDrawPile > 5 {
-		child := &gn.children[len(gn.children)-1]
+	if nCardsInDrawPile >= maxInsertKittenPositions {
 		action := gamestate.Action{
 			Player:             gn.player,
 			Type:               gamestate.InsertExplodingKitten,
 			Card:               cards.Defuse,
 			PositionInDrawPile: uint8(nCardsInDrawPile + 1), // bottom
 		}
-		child.state.Apply(action, true)
 		gn.actions[len(gn.children)-1] = action
-		makePlayTurnNode(child, gn.player, gn.pendingTurns)
+		gn.applyChildAction(&gn.children[len(gn.children)-1], action)
 	} else {
 		gn.children = gn.children[:len(gn.children)-1]
 		gn.actions = gn.actions[:len(gn.actions)-1]
 	}
 }
 
+func (gn *GameNode) buildMustReinsertChildren() {
+	// As for MustDefuse, but the imploding kitten is face up,
+	// so it cannot be placed randomly.
+	nCardsInDrawPile := gn.state.GetDrawPile().Len()
+	nOptions := min(nCardsInDrawPile+1, maxInsertKittenPositions)
+	nChildren := nOptions
+	if nCardsInDrawPile >= maxInsertKittenPositions {
+		nChildren++
+	}
+
+	gn.allocChildren(nChildren)
+	for i := 0; i < nChildren; i++ {
+		pos := i + 1
+		if i == nOptions {
+			pos = nCardsInDrawPile + 1 // bottom
+		}
+
+		action := gamestate.Action{
+			Player:             gn.player,
+			Type:               gamestate.InsertExplodingKitten,
+			Card:               cards.ImplodingKitten,
+			PositionInDrawPile: uint8(pos),
+		}
+		gn.actions[i] = action
+		gn.applyChildAction(&gn.children[i], action)
+	}
+}
+
 func (gn *GameNode) buildInsertKittenRandomChildren() {
 	nPositions := gn.state.GetDrawPile().Len() + 1
 	gn.allocChildren(nPositions)
 	for i := 0; i < nPositions; i++ {
-		child := &gn.children[i]
 		action := gamestate.Action{
 			Player:             gn.player,
 			Type:               gamestate.InsertExplodingKitten,
 			PositionInDrawPile: uint8(i + 1),
 		}
-		child.state.Apply(action, false)
 		gn.actions[i] = action
+		gn.applyChildAction(&gn.children[i], action)
+	}
+}
+
+// applyChildAction forms the child reached by taking the given action,
+// which must be legal at this node. The child must be initialized as a
+// copy of this node (see allocChildren).
+func (gn *GameNode) applyChildAction(child *GameNode, action gamestate.Action) {
+	switch gn.turnType {
+	case PlayTurn:
+		child.state.Apply(action, true)
+		if action.Type == gamestate.DrawCard {
+			makePlayTurnNode(child, gn.player, gn.pendingTurns-1)
+			return
+		}
+
+		effect := cardEffects[action.Card]
+		switch {
+		case !effect.playable:
+			panic(fmt.Errorf("Player playing unsupported %v card", action.Card))
+		case effect.shuffle:
+			child.turnType = ShuffleDrawPile
+			child.nDrawPileCards = gn.state.GetDrawPile().Len()
+			if child.isSampledShuffle() {
+				sampledShuffleNodes.Add(1)
+				vlogf(2, "Sampling %d of %d orderings of draw pile with %d cards",
+					child.maxShuffleChildren, factorial[child.nDrawPileCards], child.nDrawPileCards)
+			}
+		case effect.opponentTurns > 0:
+			// Ends our turn (and all pending turns). Goes to next player with
+			// any pending turns + slap.
+			pendingTurns := effect.opponentTurns
+			if gn.slapBackRule.slapsBack(action.Card, gn.state.LastAction()) {
+				pendingTurns += gn.pendingTurns
+			}
 
+			makePlayTurnNode(child, nextPlayer(gn.player), pendingTurns)
+		case effect.targetsOpponent:
+			if child.state.GetPlayerHand(nextPlayer(gn.player)).Len() == 0 {
+				// Other player has no cards in their hand, this was a no-op.
+				makePlayTurnNode(child, gn.player, gn.pendingTurns)
+			} else {
+				// Other player must give us a card.
+				makeGiveCardNode(child, nextPlayer(gn.player))
+			}
+		case effect.endsTurn:
+			// Ends our current turn (with/without drawing a card).
+			makePlayTurnNode(child, gn.player, gn.pendingTurns-1)
+		default:
+			makePlayTurnNode(child, gn.player, gn.pendingTurns)
+		}
+	case GiveCard:
+		// Form child node by:
+		//   1) Removing card from our hand,
+		//   2) Adding card to opponent's hand,
+		//   3) Returning to opponent's turn.
+		child.state.Apply(action, true)
+		// Game play returns to other player (with the given card in their hand).
+		makePlayTurnNode(child, nextPlayer(gn.player), gn.pendingTurns)
+	case MustDefuse:
+		child.state.Apply(action, true)
+		if action.PositionInDrawPile == 0 {
+			// The position is chosen by a chance node.
+			child.turnType = InsertKittenRandom
+		} else {
+			makePlayTurnNode(child, gn.player, gn.pendingTurns)
+		}
+	case MustReinsert:
+		child.state.Apply(action, true)
 		makePlayTurnNode(child, gn.player, gn.pendingTurns)
+	case InsertKittenRandom:
+		// The player does not know where the kitten ended up.
+		child.state.Apply(action, false)
+		makePlayTurnNode(child, gn.player, gn.pendingTurns)
+	default:
+		panic(fmt.Errorf("cannot apply %v at %v node", action, gn.turnType))
 	}
 }
 
@@ -515,6 +1150,26 @@ func (gn *GameNode) buildShuffleChild(newDrawPile cards.Stack) *GameNode {
 	return result
 }
 
+// Whether this ShuffleDrawPile node has too many orderings of the draw pile
+// to enumerate, and so samples them (see GameOptions.MaxShuffleChildren).
+func (gn *GameNode) isSampledShuffle() bool {
+	return gn.maxShuffleChildren > 0 && factorial[gn.nDrawPileCards] > gn.maxShuffleChildren
+}
+
+// sampledShuffleIndex maps the i'th child of a sampled shuffle node to a
+// pseudo-random ordering in [0, n), by the splitmix64 hash of i and the seed
+// of the node (its draw pile). The mapping is fixed, so that each child is the
+// same every time it is built, but differs between draw piles, so that the
+// sample is not biased toward the same orderings at every shuffle.
+// Different children may map to the same ordering.
+func sampledShuffleIndex(i, n int, seed uint64) int {
+	z := seed + uint64(i+1)*0x9e3779b97f4a7c15
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	z ^= z >> 31
+	return int(z % uint64(n))
+}
+
 func nextPlayer(p gamestate.Player) gamestate.Player {
 	if p != gamestate.Player0 && p != gamestate.Player1 {
 		panic(fmt.Sprintf("cannot call nextPlayer with player %v", p))
@@ -0,0 +1,87 @@
+package alphacats
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/timpalpant/go-cfr"
+)
+
+// GameTreesEqual returns whether the game trees rooted at a and b have the
+// same structure down to maxDepth: each pair of nodes must have the same
+// turn type, player and pending turns, the same children in the same order,
+// the same chance probabilities, and the same terminal utilities.
+//
+// If the trees differ, it also returns a description of the first
+// divergence, including the path of actions from the root leading to it.
+// It is intended for regression testing changes to the rules (see
+// cardEffects), and expands the full tree below a and b, so it should only
+// be used with small games or depths.
+func GameTreesEqual(a, b *GameNode, maxDepth int) (bool, string) {
+	return gameTreesEqual(a, b, maxDepth, nil)
+}
+
+func gameTreesEqual(a, b *GameNode, depth int, path []string) (bool, string) {
+	diverged := func(format string, args ...interface{}) (bool, string) {
+		return false, fmt.Sprintf("[%s]: ", strings.Join(path, " > ")) + fmt.Sprintf(format, args...)
+	}
+
+	if a.turnType != b.turnType {
+		return diverged("turn type %v != %v", a.turnType, b.turnType)
+	}
+	if a.player != b.player {
+		return diverged("player %v != %v", a.player, b.player)
+	}
+	if a.pendingTurns != b.pendingTurns {
+		return diverged("pending turns %d != %d", a.pendingTurns, b.pendingTurns)
+	}
+
+	nodeType := a.Type()
+	if nodeType == cfr.TerminalNodeType {
+		for player := 0; player < 2; player++ {
+			if ua, ub := a.Utility(player), b.Utility(player); ua != ub {
+				return diverged("utility for player %d %v != %v", player, ua, ub)
+			}
+		}
+
+		return true, ""
+	}
+
+	if depth == 0 {
+		return true, ""
+	}
+
+	n := a.NumChildren()
+	if nb := b.NumChildren(); n != nb {
+		return diverged("%d children != %d children", n, nb)
+	}
+
+	for i := 0; i < n; i++ {
+		// NB: The children of shuffle nodes are built lazily, without actions.
+		label := fmt.Sprintf("%v %d", ShuffleDrawPile, i)
+		if a.turnType != ShuffleDrawPile {
+			if a.actions[i] != b.actions[i] {
+				return diverged("child %d action %v != %v", i, a.actions[i], b.actions[i])
+			}
+
+			label = a.actions[i].String()
+		}
+
+		if nodeType == cfr.ChanceNodeType {
+			if pa, pb := a.GetChildProbability(i), b.GetChildProbability(i); pa != pb {
+				return diverged("child %d probability %v != %v", i, pa, pb)
+			}
+		}
+
+		childA := a.GetChild(i).(*GameNode)
+		childB := b.GetChild(i).(*GameNode)
+		equal, diff := gameTreesEqual(childA, childB, depth-1, append(path, label))
+		childA.Close()
+		childB.Close()
+		if !equal {
+			return false, diff
+		}
+	}
+
+	return true, ""
+}
@@ -0,0 +1,48 @@
+package alphacats
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/timpalpant/alphacats/gamestate"
+)
+
+func TestGameTreesEqual(t *testing.T) {
+	if equal, diff := GameTreesEqual(newTestDeckGame(), newTestDeckGame(), 8); !equal {
+		t.Errorf("expected tree to equal itself, got divergence at %s", diff)
+	}
+
+	// Mutate the node reached by drawing a card.
+	a, b := newTestDeckGame(), newTestDeckGame()
+	for i := 0; i < b.NumChildren(); i++ {
+		child := b.GetChild(i).(*GameNode)
+		if child.LastAction().Type == gamestate.DrawCard {
+			child.pendingTurns++
+		}
+	}
+
+	equal, diff := GameTreesEqual(a, b, 8)
+	if equal {
+		t.Fatal("expected mutated tree to differ")
+	}
+
+	expected := "[Player0:DrawCard]: pending turns 1 != 2"
+	if diff != expected {
+		t.Errorf("expected divergence %q, got %q", expected, diff)
+	}
+
+	// Divergences below the maximum depth are not detected.
+	if equal, diff := GameTreesEqual(newTestDeckGame(), b, 0); !equal {
+		t.Errorf("expected trees to be equal at depth 0, got divergence at %s", diff)
+	}
+
+	// A tree with a different deal diverges below the root.
+	c := newTestDeckGame()
+	c.state = gamestate.New(c.state.GetDrawPile(), c.state.GetPlayerHand(gamestate.Player1),
+		c.state.GetPlayerHand(gamestate.Player0))
+	if equal, diff := GameTreesEqual(newTestDeckGame(), c, 8); equal {
+		t.Error("expected trees with different deals to differ")
+	} else if !strings.HasPrefix(diff, "[]: ") {
+		t.Errorf("expected divergence at the root, got %s", diff)
+	}
+}
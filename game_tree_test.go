@@ -0,0 +1,1203 @@
+package alphacats
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/timpalpant/go-cfr"
+
+	"github.com/timpalpant/alphacats/cards"
+	"github.com/timpalpant/alphacats/gamestate"
+)
+
+// Returns the child of node reached by drawing a card.
+func drawCard(t *testing.T, node *GameNode) *GameNode {
+	for i := 0; i < node.NumChildren(); i++ {
+		child := node.GetChild(i).(*GameNode)
+		if child.LastAction().Type == gamestate.DrawCard {
+			return child
+		}
+	}
+
+	t.Fatalf("%v cannot draw a card", node)
+	return nil
+}
+
+func TestGameOverReason(t *testing.T) {
+	testCases := []struct {
+		drawPile []cards.Card
+		p0Deal   []cards.Card
+		play     func(t *testing.T, node *GameNode) *GameNode
+		reason   GameOverReason
+	}{
+		{
+			drawPile: []cards.Card{cards.ExplodingKitten, cards.Cat},
+			p0Deal:   []cards.Card{cards.Skip},
+			play:     drawCard,
+			reason:   OpponentExploded,
+		},
+		{
+			drawPile: []cards.Card{cards.Cat, cards.ExplodingKitten},
+			p0Deal:   []cards.Card{cards.DrawFromTheBottom},
+			play: func(t *testing.T, node *GameNode) *GameNode {
+				return playCard(t, node, cards.DrawFromTheBottom)
+			},
+			reason: OpponentExplodedFromBottom,
+		},
+	}
+
+	for _, tc := range testCases {
+		game := NewGame(cards.NewStackFromCards(tc.drawPile),
+			cards.NewSetFromCards(tc.p0Deal), cards.NewSetFromCards([]cards.Card{cards.Defuse}))
+		child := tc.play(t, game)
+		if child.Type() != cfr.TerminalNodeType {
+			t.Errorf("expected game to be over: %v", child)
+			continue
+		}
+
+		winner, reason := child.GameOverReason()
+		if winner != gamestate.Player1 {
+			t.Errorf("expected %v to win, got %v", gamestate.Player1, winner)
+		}
+
+		if reason != tc.reason {
+			t.Errorf("expected game over reason %v, got %v", tc.reason, reason)
+		}
+	}
+}
+
+func TestEmptyDrawPile(t *testing.T) {
+	// Without an exploding kitten, the draw pile can run out.
+	game := NewGame(cards.NewStackFromCards([]cards.Card{cards.Cat}),
+		cards.NewSetFromCards([]cards.Card{cards.Skip}),
+		cards.NewSetFromCards([]cards.Card{cards.Skip}))
+	child := drawCard(t, game)
+	if n := child.GetDrawPile().Len(); n != 0 {
+		t.Fatalf("expected draw pile to be empty, got %d cards", n)
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf("expected panic building children with an empty draw pile")
+		}
+	}()
+
+	child.NumChildren()
+}
+
+func TestGetChildOutOfRange(t *testing.T) {
+	game := newTestDeckGame()
+	for _, i := range []int{-1, game.NumChildren()} {
+		func() {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Errorf("expected panic getting child %d of %d", i, game.NumChildren())
+				}
+			}()
+
+			game.GetChild(i)
+		}()
+	}
+}
+
+func TestCannotPlayDefuse(t *testing.T) {
+	game := newTestDeckGame()
+	state := game.GetState()
+	if !state.GetPlayerHand(gamestate.Player0).Contains(cards.Defuse) {
+		t.Fatalf("expected player 0 to have a Defuse: %v", game)
+	}
+
+	for i := 0; i < game.NumChildren(); i++ {
+		action := game.GetChild(i).(*GameNode).LastAction()
+		if action.Type == gamestate.PlayCard && action.Card == cards.Defuse {
+			t.Errorf("expected Defuse to not be playable, got child %d: %v", i, action)
+		}
+	}
+}
+
+func TestForcedOutcome(t *testing.T) {
+	drawPile := cards.NewStackFromCards([]cards.Card{cards.ExplodingKitten, cards.Cat})
+	p0Deal := cards.NewSetFromCards([]cards.Card{cards.Skip})
+	game := NewGame(drawPile, p0Deal, cards.NewSet())
+
+	// Player0 may draw the kitten (and lose) or skip (and win).
+	if winner, forced := game.ForcedOutcome(); forced {
+		t.Errorf("expected outcome to not be forced, got %v wins", winner)
+	}
+
+	// Player1 has no cards and must draw the kitten.
+	child := playCard(t, game, cards.Skip)
+	winner, forced := child.ForcedOutcome()
+	if !forced {
+		t.Fatal("expected outcome to be forced")
+	}
+
+	if winner != gamestate.Player0 {
+		t.Errorf("expected %v to win, got %v", gamestate.Player0, winner)
+	}
+
+	if len(child.children) != 0 {
+		t.Error("expected look-ahead to not build children of the node")
+	}
+}
+
+func TestForcedOutcomeTerminal(t *testing.T) {
+	drawPile := cards.NewStackFromCards([]cards.Card{cards.ExplodingKitten, cards.Cat})
+	game := NewGame(drawPile, cards.NewSet(), cards.NewSet())
+	if winner, forced := game.ForcedOutcome(); !forced || winner != gamestate.Player1 {
+		t.Errorf("expected forced win for %v, got %v (forced = %v)",
+			gamestate.Player1, winner, forced)
+	}
+
+	child := drawCard(t, game)
+	if winner, forced := child.ForcedOutcome(); !forced || winner != gamestate.Player1 {
+		t.Errorf("expected forced win for %v, got %v (forced = %v)",
+			gamestate.Player1, winner, forced)
+	}
+}
+
+// Computes the expected utility for player 0 if both players play uniformly
+// at random, enumerating chance outcomes exactly.
+func exactExpectedValue(t *testing.T, node cfr.GameTreeNode) float64 {
+	switch node.Type() {
+	case cfr.TerminalNodeType:
+		return node.Utility(0)
+	case cfr.ChanceNodeType:
+		total, ev := 0.0, 0.0
+		for i := 0; i < node.NumChildren(); i++ {
+			p := node.GetChildProbability(i)
+			total += p
+			child := node.GetChild(i)
+			ev += p * exactExpectedValue(t, child)
+			child.Close()
+		}
+
+		if math.Abs(total-1.0) > 1e-9 {
+			t.Errorf("chance probabilities sum to %v: %v", total, node)
+		}
+
+		return ev
+	}
+
+	ev := 0.0
+	p := 1.0 / float64(node.NumChildren())
+	for i := 0; i < node.NumChildren(); i++ {
+		child := node.GetChild(i)
+		ev += p * exactExpectedValue(t, child)
+		child.Close()
+	}
+
+	return ev
+}
+
+// Returns the utility for player 0 of one game of uniform random play,
+// sampling chance outcomes.
+func sampledValue(rng *rand.Rand, node cfr.GameTreeNode) float64 {
+	for node.Type() != cfr.TerminalNodeType {
+		if node.Type() == cfr.ChanceNodeType {
+			node, _ = node.SampleChild()
+		} else {
+			node = node.GetChild(rng.Intn(node.NumChildren()))
+		}
+	}
+
+	return node.Utility(0)
+}
+
+func TestExactChanceEnumeration(t *testing.T) {
+	newGame := func() *GameNode {
+		drawPile := cards.NewStackFromCards([]cards.Card{
+			cards.Cat, cards.Skip, cards.ExplodingKitten, cards.Cat,
+		})
+		p0Deal := cards.NewSetFromCards([]cards.Card{cards.Shuffle, cards.Defuse})
+		p1Deal := cards.NewSetFromCards([]cards.Card{cards.Shuffle, cards.Defuse})
+		return NewGame(drawPile, p0Deal, p1Deal)
+	}
+
+	exact := exactExpectedValue(t, newGame())
+
+	rand.Seed(123)
+	rng := rand.New(rand.NewSource(123))
+	n := 20000
+	total := 0.0
+	for i := 0; i < n; i++ {
+		total += sampledValue(rng, newGame())
+	}
+	sampled := total / float64(n)
+
+	// Utilities are +/- 1, so the standard error is at most 1/sqrt(n).
+	if tol := 4 / math.Sqrt(float64(n)); math.Abs(exact-sampled) > tol {
+		t.Errorf("exact expected value %v differs from sampled %v (tolerance %v)",
+			exact, sampled, tol)
+	}
+}
+
+func TestShuffleSampling(t *testing.T) {
+	drawPile := cards.NewStackFromCards([]cards.Card{
+		cards.Cat, cards.ExplodingKitten, cards.Cat, cards.Skip, cards.Cat,
+	})
+	p0Deal := cards.NewSetFromCards([]cards.Card{cards.Shuffle, cards.Defuse})
+	p1Deal := cards.NewSetFromCards([]cards.Card{cards.Defuse})
+	node := playCard(t, NewGame(drawPile, p0Deal, p1Deal), cards.Shuffle)
+	if node.turnType != ShuffleDrawPile {
+		t.Fatalf("expected shuffle node, got %v", node)
+	}
+
+	// Children are all permutations of the draw pile, so each distinct
+	// ordering should have total probability 1 / (# distinct shuffles).
+	exact := make(map[cards.Stack]float64)
+	total := 0.0
+	for i := 0; i < node.NumChildren(); i++ {
+		child := node.GetChild(i).(*GameNode)
+		exact[child.state.GetDrawPile()] += node.GetChildProbability(i)
+		total += node.GetChildProbability(i)
+	}
+
+	if math.Abs(total-1.0) > 1e-9 {
+		t.Errorf("child probabilities sum to %v", total)
+	}
+
+	nDistinct := CountDistinctShuffles(drawPile.ToSet())
+	if len(exact) != nDistinct {
+		t.Errorf("expected %d distinct shuffles, got %d", nDistinct, len(exact))
+	}
+
+	for shuffle, p := range exact {
+		if math.Abs(p-1.0/float64(nDistinct)) > 1e-9 {
+			t.Errorf("expected probability %v for %v, got %v", 1.0/float64(nDistinct), shuffle, p)
+		}
+	}
+
+	// Sampled frequencies of each distinct ordering should match.
+	rand.Seed(123)
+	n := 100000
+	counts := make(map[cards.Stack]int)
+	for i := 0; i < n; i++ {
+		child, _ := node.SampleChild()
+		counts[child.(*GameNode).state.GetDrawPile()]++
+	}
+
+	for shuffle, count := range counts {
+		p, ok := exact[shuffle]
+		if !ok {
+			t.Errorf("sampled impossible shuffle %v", shuffle)
+			continue
+		}
+
+		sampled := float64(count) / float64(n)
+		if tol := 4 * math.Sqrt(p*(1-p)/float64(n)); math.Abs(sampled-p) > tol {
+			t.Errorf("%v: expected probability %v, sampled %v (tolerance %v)",
+				shuffle, p, sampled, tol)
+		}
+	}
+}
+
+func TestMaxShuffleChildren(t *testing.T) {
+	drawPile := cards.NewStackFromCards([]cards.Card{
+		cards.Cat, cards.ExplodingKitten, cards.Cat, cards.Skip, cards.Cat,
+		cards.Slap1x, cards.SeeTheFuture, cards.Shuffle, cards.DrawFromTheBottom, cards.Slap2x,
+	})
+	p0Deal := cards.NewSetFromCards([]cards.Card{cards.Shuffle, cards.Defuse})
+	p1Deal := cards.NewSetFromCards([]cards.Card{cards.Defuse})
+	const maxChildren = 5000
+	opts := GameOptions{MaxShuffleChildren: maxChildren}
+	node := playCard(t, NewGameWithOptions(drawPile, p0Deal, p1Deal, opts), cards.Shuffle)
+	if node.turnType != ShuffleDrawPile {
+		t.Fatalf("expected shuffle node, got %v", node)
+	}
+
+	// 10! orderings is more than the limit, so they are sampled.
+	if n := node.NumChildren(); n != maxChildren {
+		t.Fatalf("expected %d children, got %d", maxChildren, n)
+	}
+
+	total := 0.0
+	topCounts := make(map[cards.Card]int)
+	for i := 0; i < node.NumChildren(); i++ {
+		total += node.GetChildProbability(i)
+		shuffle := node.GetChild(i).(*GameNode).state.GetDrawPile()
+		if shuffle.ToSet() != drawPile.ToSet() {
+			t.Fatalf("child %d is not an ordering of the draw pile: %v", i, shuffle)
+		}
+
+		if again := node.GetChild(i).(*GameNode).state.GetDrawPile(); again != shuffle {
+			t.Errorf("child %d is not stable: %v, then %v", i, shuffle, again)
+		}
+
+		topCounts[shuffle.NthCard(0)]++
+	}
+
+	if math.Abs(total-1.0) > 1e-9 {
+		t.Errorf("child probabilities sum to %v", total)
+	}
+
+	// Each card is equally likely to be on top after the shuffle.
+	drawPile.ToSet().Iter(func(card cards.Card, count uint8) {
+		p := float64(count) / float64(drawPile.Len())
+		sampled := float64(topCounts[card]) / maxChildren
+		if tol := 4 * math.Sqrt(p*(1-p)/maxChildren); math.Abs(sampled-p) > tol {
+			t.Errorf("%v: expected probability %v on top, sampled %v (tolerance %v)",
+				card, p, sampled, tol)
+		}
+	})
+
+	// Sampling a child draws from all orderings, not only the enumerated ones.
+	enumerated := make(map[cards.Stack]bool)
+	for i := 0; i < node.NumChildren(); i++ {
+		enumerated[node.GetChild(i).(*GameNode).state.GetDrawPile()] = true
+	}
+	rng := rand.New(rand.NewSource(123))
+	nOther := 0
+	for i := 0; i < 100; i++ {
+		child, p := node.SampleChildWithRand(rng)
+		if expected := 1.0 / float64(factorial[drawPile.Len()]); p != expected {
+			t.Errorf("expected sampled child probability %v, got %v", expected, p)
+		}
+		if !enumerated[child.(*GameNode).state.GetDrawPile()] {
+			nOther++
+		}
+	}
+	if nOther == 0 {
+		t.Error("sampled children are all among the enumerated orderings")
+	}
+
+	// Each draw pile has its own sample of orderings.
+	n := factorial[drawPile.Len()]
+	same := 0
+	for i := 0; i < maxChildren; i++ {
+		if sampledShuffleIndex(i, n, 1) == sampledShuffleIndex(i, n, 2) {
+			same++
+		}
+	}
+	if same > maxChildren/100 {
+		t.Errorf("%d of %d children are the same for different draw piles", same, maxChildren)
+	}
+
+	// Small shuffles are still enumerated in full.
+	smallDrawPile := cards.NewStackFromCards([]cards.Card{
+		cards.Cat, cards.ExplodingKitten, cards.Skip,
+	})
+	node = playCard(t, NewGameWithOptions(smallDrawPile, p0Deal, p1Deal, opts), cards.Shuffle)
+	if n := node.NumChildren(); n != 6 {
+		t.Errorf("expected all 6 orderings, got %d children", n)
+	}
+}
+
+func TestInsertKittenRandomSampling(t *testing.T) {
+	drawPile := cards.NewStackFromCards([]cards.Card{
+		cards.ExplodingKitten, cards.Cat, cards.Skip, cards.Cat,
+	})
+	p0Deal := cards.NewSetFromCards([]cards.Card{cards.Defuse})
+	p1Deal := cards.NewSetFromCards([]cards.Card{cards.Defuse})
+	node := drawCard(t, NewGame(drawPile, p0Deal, p1Deal))
+	node, err := node.Step(gamestate.Action{
+		Player: gamestate.Player0,
+		Type:   gamestate.InsertExplodingKitten,
+		Card:   cards.Defuse,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if node.turnType != InsertKittenRandom || node.Type() != cfr.ChanceNodeType {
+		t.Fatalf("expected random insertion chance node, got %v", node)
+	}
+
+	// The kitten may be inserted at any position, including the bottom.
+	nPositions := drawPile.Len()
+	if node.NumChildren() != nPositions {
+		t.Fatalf("expected %d children, got %d", nPositions, node.NumChildren())
+	}
+
+	positions := make(map[cards.Stack]int)
+	total := 0.0
+	for i := 0; i < node.NumChildren(); i++ {
+		child := node.GetChild(i).(*GameNode)
+		if child.state.GetDrawPile().NthCard(i) != cards.ExplodingKitten {
+			t.Errorf("expected child %d to have kitten at position %d, got %v",
+				i, i, child.state.GetDrawPile())
+		}
+		positions[child.state.GetDrawPile()] = i
+		total += node.GetChildProbability(i)
+	}
+
+	if math.Abs(total-1.0) > 1e-9 {
+		t.Errorf("child probabilities sum to %v", total)
+	}
+
+	rand.Seed(123)
+	n := 100000
+	counts := make([]int, nPositions)
+	for i := 0; i < n; i++ {
+		child, p := node.SampleChild()
+		if math.Abs(p-1.0/float64(nPositions)) > 1e-9 {
+			t.Errorf("expected sampled probability %v, got %v", 1.0/float64(nPositions), p)
+		}
+		counts[positions[child.(*GameNode).state.GetDrawPile()]]++
+	}
+
+	p := 1.0 / float64(nPositions)
+	for i, count := range counts {
+		sampled := float64(count) / float64(n)
+		if tol := 4 * math.Sqrt(p*(1-p)/float64(n)); math.Abs(sampled-p) > tol {
+			t.Errorf("position %d: expected probability %v, sampled %v (tolerance %v)",
+				i, p, sampled, tol)
+		}
+	}
+}
+
+func TestNewGameWithFirstPlayer(t *testing.T) {
+	drawPile := cards.NewStackFromCards([]cards.Card{cards.Cat, cards.ExplodingKitten})
+	p0Deal := cards.NewSetFromCards([]cards.Card{cards.Skip})
+	p1Deal := cards.NewSetFromCards([]cards.Card{cards.Slap2x})
+	game := NewGameWithFirstPlayer(drawPile, p0Deal, p1Deal, gamestate.Player1)
+	if game.Player() != int(gamestate.Player1) || game.turnType != PlayTurn {
+		t.Fatalf("expected %v to play first, got %v", gamestate.Player1, game)
+	}
+
+	for i := 0; i < game.NumChildren(); i++ {
+		if action := game.GetChild(i).(*GameNode).LastAction(); action.Player != gamestate.Player1 {
+			t.Errorf("expected %v to act, got %v", gamestate.Player1, action)
+		}
+	}
+
+	// Player 1 draws the Cat, then it is player 0's turn.
+	child := drawCard(t, game)
+	if child.Player() != int(gamestate.Player0) {
+		t.Errorf("expected %v to play second, got %v", gamestate.Player0, child)
+	}
+
+	// Player 1 slaps, so player 0 has to take 2 turns.
+	child = playCard(t, game, cards.Slap2x)
+	if child.Player() != int(gamestate.Player0) || child.pendingTurns != 2 {
+		t.Errorf("expected %v to have 2 turns, got %v", gamestate.Player0, child)
+	}
+
+	// By default, player 0 goes first.
+	if game := NewGame(drawPile, p0Deal, p1Deal); game.Player() != int(gamestate.Player0) {
+		t.Errorf("expected %v to play first, got %v", gamestate.Player0, game)
+	}
+}
+
+func TestSeeTheFutureAndDefuseProgress(t *testing.T) {
+	drawPile := cards.NewStackFromCards([]cards.Card{cards.ExplodingKitten, cards.Cat})
+	p0Deal := cards.NewSetFromCards([]cards.Card{
+		cards.SeeTheFuture, cards.SeeTheFuture, cards.SeeTheFuture, cards.Defuse})
+	p1Deal := cards.NewSetFromCards([]cards.Card{cards.Skip})
+	game := NewGame(drawPile, p0Deal, p1Deal)
+
+	// SeeTheFuture continues the player's turn, but consumes the card.
+	node := game
+	for n := 3; n > 0; n-- {
+		hand := node.state.GetPlayerHand(gamestate.Player0)
+		child := playCard(t, node, cards.SeeTheFuture)
+		if child.Player() != node.Player() || child.pendingTurns != node.pendingTurns {
+			t.Errorf("expected %v to continue turn, got %v", node, child)
+		}
+
+		if newHand := child.state.GetPlayerHand(gamestate.Player0); newHand.Len() != hand.Len()-1 {
+			t.Errorf("expected hand to shrink from %v, got %v", hand, newHand)
+		}
+
+		node = child
+	}
+
+	for i := 0; i < node.NumChildren(); i++ {
+		if action := node.GetChild(i).(*GameNode).LastAction(); action.Type == gamestate.PlayCard {
+			t.Errorf("expected no more cards to play, got %v", action)
+		}
+	}
+
+	// Defusing the kitten consumes the Defuse, so it cannot be reused.
+	node = drawCard(t, node)
+	if node.turnType != MustDefuse {
+		t.Fatalf("expected to defuse, got %v", node)
+	}
+	// NB: The Defuse is played along with re-inserting the kitten.
+	node = node.GetChild(0).(*GameNode)
+	if node.state.GetPlayerHand(gamestate.Player0).Contains(cards.Defuse) {
+		t.Errorf("expected Defuse to be consumed, got %v", node)
+	}
+	if node.state.GetPlayerHand(gamestate.Player0).Len() != 0 {
+		t.Errorf("expected empty hand, got %v", node.state.GetPlayerHand(gamestate.Player0))
+	}
+}
+
+// A player may play any number of cards before drawing to end their turn.
+func TestComboTurn(t *testing.T) {
+	drawPile := cards.NewStackFromCards([]cards.Card{
+		cards.Skip, cards.Cat, cards.ExplodingKitten, cards.Slap1x,
+	})
+	p0Deal := cards.NewSetFromCards([]cards.Card{
+		cards.SeeTheFuture, cards.SeeTheFuture, cards.Shuffle, cards.Cat, cards.Defuse})
+	p1Deal := cards.NewSetFromCards([]cards.Card{cards.Slap2x, cards.Defuse})
+	game := NewGame(drawPile, p0Deal, p1Deal)
+
+	checkContinues := func(node *GameNode) {
+		if node.turnType != PlayTurn || node.Player() != 0 || node.pendingTurns != 1 {
+			t.Fatalf("expected player 0's turn to continue, got %v", node)
+		}
+	}
+
+	node := playCard(t, game, cards.SeeTheFuture)
+	checkContinues(node)
+	node = playCard(t, node, cards.SeeTheFuture)
+	checkContinues(node)
+
+	node = playCard(t, node, cards.Shuffle)
+	if node.Type() != cfr.ChanceNodeType {
+		t.Fatalf("expected to shuffle, got %v", node)
+	}
+	node = node.GetChild(1).(*GameNode)
+	checkContinues(node)
+
+	// Player 1 gives player 0 a card, and then it is player 0's turn again.
+	node = playCard(t, node, cards.Cat)
+	if node.turnType != GiveCard || node.Player() != 1 {
+		t.Fatalf("expected player 1 to give a card, got %v", node)
+	}
+	node = node.GetChild(0).(*GameNode)
+	checkContinues(node)
+	if n := node.state.GetPlayerHand(gamestate.Player0).Len(); n != 2 {
+		t.Errorf("expected a Defuse and the given card in hand, got %d cards", n)
+	}
+
+	// Drawing ends the turn.
+	node = drawCard(t, node)
+	if drawn := node.LastAction().CardsSeen[0]; drawn == cards.ExplodingKitten {
+		if node.turnType != MustDefuse || node.Player() != 0 {
+			t.Errorf("expected player 0 to defuse, got %v", node)
+		}
+	} else if node.turnType != PlayTurn || node.Player() != 1 {
+		t.Errorf("expected player 1's turn after drawing %v, got %v", drawn, node)
+	}
+
+	h := node.GetHistory()
+	for i := 0; i < h.Len(); i++ {
+		if action := h.Get(i); action.Type == gamestate.PlayCard && action.Player != gamestate.Player0 {
+			t.Errorf("expected only player 0 to play cards, got %v", action)
+		}
+	}
+}
+
+func TestMaxHandSize(t *testing.T) {
+	drawPile := cards.NewStackFromCards([]cards.Card{cards.Cat, cards.ExplodingKitten})
+	p0Deal := cards.NewSetFromCards([]cards.Card{cards.Skip, cards.Shuffle, cards.Defuse})
+	p1Deal := cards.NewSetFromCards([]cards.Card{cards.Defuse})
+
+	// Without a limit, player 0 may draw.
+	game := NewGame(drawPile, p0Deal, p1Deal)
+	drawCard(t, game)
+
+	// With a full hand, player 0 must play a card.
+	game = NewGameWithOptions(drawPile, p0Deal, p1Deal, GameOptions{MaxHandSize: 3})
+	if game.NumChildren() != 2 {
+		t.Errorf("expected 2 children, got %d", game.NumChildren())
+	}
+
+	for i := 0; i < game.NumChildren(); i++ {
+		action := game.GetChild(i).(*GameNode).LastAction()
+		if action.Type == gamestate.DrawCard {
+			t.Errorf("expected draw to be pruned with a full hand, got child %d: %v", i, action)
+		}
+	}
+
+	// After playing a card, there is room to draw again.
+	child := playCard(t, game, cards.Shuffle).GetChild(0).(*GameNode)
+	drawCard(t, child)
+
+	// With only a Defuse, which cannot be played, the player may still draw.
+	game = NewGameWithOptions(drawPile, p1Deal, p0Deal, GameOptions{MaxHandSize: 1})
+	drawCard(t, game)
+}
+
+func checkZeroSum(t *testing.T, node cfr.GameTreeNode) {
+	if node.Type() == cfr.TerminalNodeType {
+		total := 0.0
+		utilities := node.(*GameNode).Utilities()
+		for player, u := range utilities {
+			total += u
+			if u != node.Utility(player) {
+				t.Errorf("Utilities()[%d] = %v, but Utility(%d) = %v", player, u, player, node.Utility(player))
+			}
+		}
+
+		if total != 0 {
+			t.Errorf("utilities %v do not sum to zero: %v", utilities, node)
+		}
+
+		return
+	}
+
+	for i := 0; i < node.NumChildren(); i++ {
+		child := node.GetChild(i)
+		checkZeroSum(t, child)
+		child.Close()
+	}
+}
+
+func TestUtilitiesZeroSum(t *testing.T) {
+	checkZeroSum(t, newTestDeckGame())
+}
+
+// A fixed opening position for benchmarks. It is the same as newTestDeckGame.
+const benchmarkSpec = "[DrawFromTheBottom, ExplodingKitten, Cat, Defuse]; " +
+	"{1 Defuse, 1 Slap1x, 1 SeeTheFuture}; {1 Defuse, 1 Skip, 1 Slap2x}"
+
+func TestNewGameFromSpec(t *testing.T) {
+	game, err := NewGameFromSpec(benchmarkSpec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := newTestDeckGame()
+	if game.GetState() != expected.GetState() {
+		t.Errorf("expected state %v, got %v", expected.GetState(), game.GetState())
+	}
+
+	state := game.GetState()
+	drawPile := state.GetDrawPile()
+	p0Hand := state.GetPlayerHand(gamestate.Player0)
+	p1Hand := state.GetPlayerHand(gamestate.Player1)
+	spec := drawPile.String() + "; " + p0Hand.String() + "; " + p1Hand.String()
+	if spec != benchmarkSpec {
+		t.Errorf("expected spec %q, got %q", benchmarkSpec, spec)
+	}
+
+	for _, invalid := range []string{
+		"",
+		"[Cat]; {1 Defuse}",
+		"[Cat]; {1 Defuse}; {1 Dog}",
+		"Cat; {1 Defuse}; {1 Defuse}",
+	} {
+		if _, err := NewGameFromSpec(invalid); err == nil {
+			t.Errorf("expected error parsing spec %q", invalid)
+		}
+	}
+}
+
+func BenchmarkBuildChildren(b *testing.B) {
+	game, err := NewGameFromSpec(benchmarkSpec)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		node := game.Clone()
+		node.NumChildren()
+		node.Close()
+	}
+}
+
+// GameState (including its History) is copied by value into each child, so
+// building the full tree only allocates for the pooled child slices.
+// BenchmarkFullTraversal 	      25	  46046126 ns/op	    3409 B/op	       5 allocs/op
+func BenchmarkFullTraversal(b *testing.B) {
+	game, err := NewGameFromSpec(benchmarkSpec)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		traverse(game.Clone())
+	}
+}
+
+// Visits every node in the game tree, returning the number of nodes.
+func traverse(node cfr.GameTreeNode) int {
+	defer node.Close()
+	total := 1
+	for i := 0; i < node.NumChildren(); i++ {
+		total += traverse(node.GetChild(i))
+	}
+
+	return total
+}
+
+func TestDebugPool(t *testing.T) {
+	game, err := NewGameFromSpec(benchmarkSpec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := traverse(game)
+
+	// A correct traversal never uses a node after closing its parent.
+	state := game.GetState()
+	opts := GameOptions{DebugPool: true}
+	game = NewGameWithOptions(state.GetDrawPile(),
+		state.GetPlayerHand(gamestate.Player0), state.GetPlayerHand(gamestate.Player1), opts)
+	if n := traverse(game); n != expected {
+		t.Errorf("expected %d nodes with DebugPool, got %d", expected, n)
+	}
+
+	// Holding on to a child after closing its parent is detected.
+	child := game.GetChild(0)
+	game.Close()
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("expected use of freed node to panic")
+		}
+	}()
+	child.NumChildren()
+}
+
+// Checks that expanding the subtree below each child of node does not
+// modify the history of the child, or of any of its siblings.
+func checkSiblingHistories(t *testing.T, node cfr.GameTreeNode, depth int) {
+	if depth == 0 || node.Type() == cfr.TerminalNodeType {
+		return
+	}
+
+	parent := node.(*GameNode).state.GetHistory()
+	n := node.NumChildren()
+	if node.Type() == cfr.ChanceNodeType && n > 4 {
+		n = 4 // Shuffle nodes may have many children.
+	}
+
+	children := make([]*GameNode, n)
+	histories := make([]gamestate.History, n)
+	for i := range children {
+		children[i] = node.GetChild(i).(*GameNode)
+		histories[i] = children[i].state.GetHistory()
+		if histories[i].Len() < parent.Len() {
+			t.Fatalf("child history %v is shorter than parent %v", histories[i], parent)
+		}
+
+		for j := 0; j < parent.Len(); j++ {
+			if histories[i].GetPacked(j) != parent.GetPacked(j) {
+				t.Fatalf("child history %v does not extend parent %v", histories[i], parent)
+			}
+		}
+	}
+
+	for _, child := range children {
+		checkSiblingHistories(t, child, depth-1)
+	}
+
+	for i, child := range children {
+		if h := child.state.GetHistory(); h != histories[i] {
+			t.Errorf("history of child %d changed from %v to %v", i, histories[i], h)
+		}
+	}
+}
+
+func TestSiblingHistoriesIndependent(t *testing.T) {
+	game, err := NewGameFromSpec(benchmarkSpec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkSiblingHistories(t, game, 6)
+}
+
+func TestMustDefuseSmallDrawPile(t *testing.T) {
+	testCases := []struct {
+		nCardsInDrawPile int
+		positions        []int
+	}{
+		{0, []int{1}},
+		{1, []int{1, 2}},
+		{5, []int{1, 2, 3, 4, 5, 6}},
+		{6, []int{1, 2, 3, 4, 5, 6, 7}},
+		{7, []int{1, 2, 3, 4, 5, 6, 8}},
+	}
+
+	for _, tc := range testCases {
+		drawPile := cards.NewStackFromCards([]cards.Card{cards.ExplodingKitten})
+		for i := 0; i < tc.nCardsInDrawPile; i++ {
+			drawPile.InsertCard(cards.Cat, drawPile.Len())
+		}
+		p0Deal := cards.NewSetFromCards([]cards.Card{cards.Defuse})
+		p1Deal := cards.NewSetFromCards([]cards.Card{cards.Defuse})
+		game := NewGame(drawPile, p0Deal, p1Deal)
+		node := drawCard(t, game)
+		if node.turnType != MustDefuse {
+			t.Fatalf("%d cards: expected %v, got %v", tc.nCardsInDrawPile, MustDefuse, node.turnType)
+		}
+
+		// One child for each position, plus one for random placement.
+		if node.NumChildren() != len(tc.positions)+1 {
+			t.Errorf("%d cards: expected %d children, got %d",
+				tc.nCardsInDrawPile, len(tc.positions)+1, node.NumChildren())
+		}
+
+		var positions []int
+		nRandom := 0
+		for i := 0; i < node.NumChildren(); i++ {
+			child := node.GetChild(i).(*GameNode)
+			action := child.LastAction()
+			if action.Type != gamestate.InsertExplodingKitten {
+				t.Errorf("%d cards: unexpected action %v", tc.nCardsInDrawPile, action)
+			}
+
+			if action.PositionInDrawPile == 0 {
+				nRandom++
+				if child.Type() != cfr.ChanceNodeType {
+					t.Errorf("%d cards: random insertion is not a chance node", tc.nCardsInDrawPile)
+				}
+				continue
+			}
+
+			positions = append(positions, int(action.PositionInDrawPile))
+			state := child.GetState()
+			childDrawPile := state.GetDrawPile()
+			if childDrawPile.Len() != tc.nCardsInDrawPile+1 {
+				t.Errorf("%d cards: expected %d cards in draw pile after insertion, got %v",
+					tc.nCardsInDrawPile, tc.nCardsInDrawPile+1, childDrawPile)
+			}
+
+			if card := childDrawPile.NthCard(int(action.PositionInDrawPile) - 1); card != cards.ExplodingKitten {
+				t.Errorf("%d cards: expected kitten at position %d, got %v",
+					tc.nCardsInDrawPile, action.PositionInDrawPile, childDrawPile)
+			}
+		}
+
+		if nRandom != 1 {
+			t.Errorf("%d cards: expected 1 random insertion, got %d", tc.nCardsInDrawPile, nRandom)
+		}
+
+		if len(positions) != len(tc.positions) {
+			t.Errorf("%d cards: expected positions %v, got %v", tc.nCardsInDrawPile, tc.positions, positions)
+			continue
+		}
+
+		for i, pos := range tc.positions {
+			if positions[i] != pos {
+				t.Errorf("%d cards: expected positions %v, got %v", tc.nCardsInDrawPile, tc.positions, positions)
+				break
+			}
+		}
+	}
+}
+
+// The number of cards in the initial draw pile is set when the game is dealt,
+// and must match the number recovered from the history of each node.
+func TestInitialDrawPileLen(t *testing.T) {
+	rng := rand.New(rand.NewSource(123))
+	deck := append(cards.CoreDeck.AsSlice(), cards.ImplodingKitten)
+	for i := 0; i < 20; i++ {
+		deal := NewRandomDeal(append([]cards.Card(nil), deck...), 4)
+		var node cfr.GameTreeNode = NewGame(deal.DrawPile, deal.P0Deal, deal.P1Deal)
+		for node.Type() != cfr.TerminalNodeType {
+			gn := node.(*GameNode)
+			if n, expected := gn.initialDrawPileLen(), gn.countInitialDrawPileCards(); n != expected {
+				t.Fatalf("expected %d cards in the initial draw pile, got %d: %v", expected, n, gn)
+			}
+
+			buf, err := gn.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var loaded GameNode
+			if err := loaded.UnmarshalBinary(buf); err != nil {
+				t.Fatal(err)
+			}
+			if n := loaded.initialDrawPileLen(); n != deal.DrawPile.Len() {
+				t.Fatalf("expected %d cards in the initial draw pile after unmarshaling, got %d",
+					deal.DrawPile.Len(), n)
+			}
+
+			if node.Type() == cfr.ChanceNodeType {
+				node, _ = node.SampleChild()
+			} else {
+				node = node.GetChild(rng.Intn(node.NumChildren()))
+			}
+		}
+	}
+}
+
+func TestImplodingKitten(t *testing.T) {
+	drawPile := cards.NewStackFromCards([]cards.Card{
+		cards.ImplodingKitten, cards.Cat, cards.Cat, cards.ExplodingKitten,
+	})
+	p0Deal := cards.NewSetFromCards([]cards.Card{cards.Skip, cards.Defuse})
+	p1Deal := cards.NewSetFromCards([]cards.Card{cards.Cat, cards.Defuse})
+	game := NewGame(drawPile, p0Deal, p1Deal)
+
+	// First draw: the player must put it back face up, without a Defuse.
+	node := drawCard(t, game)
+	if node.turnType != MustReinsert || node.player != gamestate.Player0 {
+		t.Fatalf("expected %v to reinsert the imploding kitten, got %v", gamestate.Player0, node)
+	}
+
+	positions := []int{1, 2, 3, 4}
+	if node.NumChildren() != len(positions) {
+		t.Fatalf("expected %d children, got %d", len(positions), node.NumChildren())
+	}
+	for i, pos := range positions {
+		action := node.GetChild(i).(*GameNode).LastAction()
+		if action.Type != gamestate.InsertExplodingKitten || action.Card != cards.ImplodingKitten ||
+			int(action.PositionInDrawPile) != pos {
+			t.Errorf("expected to insert the imploding kitten at %d, got %v", pos, action)
+		}
+	}
+
+	node, err := node.Step(gamestate.Action{
+		Player:             gamestate.Player0,
+		Type:               gamestate.InsertExplodingKitten,
+		Card:               cards.ImplodingKitten,
+		PositionInDrawPile: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if node.turnType != PlayTurn || node.player != gamestate.Player1 {
+		t.Fatalf("expected %v's turn, got %v", gamestate.Player1, node)
+	}
+	if hand := node.state.GetPlayerHand(gamestate.Player0); hand != p0Deal {
+		t.Errorf("expected %v to keep their hand %v, got %v", gamestate.Player0, p0Deal, hand)
+	}
+
+	// Its position is public.
+	for _, player := range []gamestate.Player{gamestate.Player0, gamestate.Player1} {
+		is := node.abstractedInfoSet(player)
+		if top := is.DrawPile.NthCard(0); top != cards.ImplodingKitten {
+			t.Errorf("expected %v to know the top card is the imploding kitten, got %v", player, is.DrawPile)
+		}
+	}
+
+	// Second draw: the player loses, even with a Defuse.
+	node = drawCard(t, node)
+	if node.Type() != cfr.TerminalNodeType {
+		t.Fatalf("expected game to be over: %v", node)
+	}
+	if winner, reason := node.GameOverReason(); winner != gamestate.Player0 || reason != OpponentImploded {
+		t.Errorf("expected %v to win by %v, got %v by %v", gamestate.Player0, OpponentImploded, winner, reason)
+	}
+
+	checkStep(t, NewGame(drawPile, p0Deal, p1Deal), make(map[turnType]bool))
+}
+
+// Checks that Step produces the same node as GetChild for every action
+// in the subtree rooted at node.
+func checkStep(t *testing.T, node *GameNode, seen map[turnType]bool) {
+	if node.Type() == cfr.TerminalNodeType || node.turnType == ShuffleDrawPile {
+		return
+	}
+
+	seen[node.turnType] = true
+	for i := 0; i < node.NumChildren(); i++ {
+		child := node.GetChild(i).(*GameNode)
+		stepped, err := node.Step(node.actions[i])
+		if err != nil {
+			t.Fatalf("Step(%v) failed: %v", node.actions[i], err)
+		}
+
+		if !sameNode(stepped, child) {
+			t.Errorf("Step(%v) = %v, expected %v", node.actions[i], stepped, child)
+		}
+
+		checkStep(t, child, seen)
+		child.Close()
+	}
+}
+
+// Whether two unexpanded nodes are identical.
+func sameNode(a, b *GameNode) bool {
+	return a.state == b.state && a.player == b.player && a.turnType == b.turnType &&
+		a.pendingTurns == b.pendingTurns && a.nDrawPileCards == b.nDrawPileCards &&
+		a.gameOverReason == b.gameOverReason && a.maxHandSize == b.maxHandSize &&
+		len(a.children) == 0 && len(b.children) == 0 &&
+		a.parent == b.parent && a.gnPool == b.gnPool && a.aPool == b.aPool
+}
+
+func TestStep(t *testing.T) {
+	seen := make(map[turnType]bool)
+	checkStep(t, newTestDeckGame(), seen)
+
+	drawPile := cards.NewStackFromCards([]cards.Card{
+		cards.Cat, cards.Skip, cards.ExplodingKitten, cards.Cat,
+	})
+	p0Deal := cards.NewSetFromCards([]cards.Card{cards.Cat, cards.Defuse})
+	p1Deal := cards.NewSetFromCards([]cards.Card{cards.Shuffle, cards.Defuse})
+	checkStep(t, NewGame(drawPile, p0Deal, p1Deal), seen)
+
+	for _, tt := range []turnType{PlayTurn, GiveCard, MustDefuse, InsertKittenRandom} {
+		if !seen[tt] {
+			t.Errorf("no %v nodes were checked", tt)
+		}
+	}
+}
+
+func TestStepIllegal(t *testing.T) {
+	game := newTestDeckGame()
+	for _, action := range []gamestate.Action{
+		{Player: gamestate.Player1, Type: gamestate.DrawCard},
+		{Player: gamestate.Player0, Type: gamestate.PlayCard, Card: cards.Skip},
+		{Player: gamestate.Player0, Type: gamestate.PlayCard, Card: cards.Defuse},
+		{Player: gamestate.Player0, Type: gamestate.GiveCard, Card: cards.Slap1x},
+		{Player: gamestate.Player0, Type: gamestate.DrawCard, CardsSeen: [3]cards.Card{cards.Cat}},
+	} {
+		if _, err := game.Step(action); err == nil {
+			t.Errorf("expected error stepping %v", action)
+		}
+	}
+
+	drawPile := cards.NewStackFromCards([]cards.Card{cards.ExplodingKitten, cards.Cat})
+	p0Deal := cards.NewSetFromCards([]cards.Card{cards.Shuffle})
+	game = NewGame(drawPile, p0Deal, cards.NewSet())
+	shuffle := playCard(t, game, cards.Shuffle)
+	if _, err := shuffle.Step(gamestate.Action{Player: gamestate.Player0}); err == nil {
+		t.Error("expected error stepping through a shuffle")
+	}
+}
+
+func TestSingleChildNodes(t *testing.T) {
+	game, err := NewGameFromSpec(forcedSpec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Player 0 holds only a Defuse, and so must draw.
+	if n := game.NumChildren(); n != 1 {
+		t.Fatalf("expected a single child, got %d", n)
+	}
+
+	child := game.GetChild(0).(*GameNode)
+	expected := gamestate.Action{
+		Player:    gamestate.Player0,
+		Type:      gamestate.DrawCard,
+		CardsSeen: [3]cards.Card{cards.Defuse},
+	}
+	if action := child.LastAction(); action != expected {
+		t.Errorf("expected %v, got %v", expected, action)
+	}
+	if game.GetChild(0) != child {
+		t.Error("expected the same child to be returned")
+	}
+
+	// Player 1 may play their Skip or draw.
+	if n := child.NumChildren(); n != 2 {
+		t.Errorf("expected 2 children, got %d", n)
+	}
+
+	// Children are rebuilt correctly after they are freed.
+	child.Close()
+	game.Close()
+	if n := game.NumChildren(); n != 1 {
+		t.Fatalf("expected a single child, got %d", n)
+	}
+	if action := game.GetChild(0).(*GameNode).LastAction(); action != expected {
+		t.Errorf("expected %v after rebuilding, got %v", expected, action)
+	}
+
+	// A single child is never used after it is freed.
+	state := game.GetState()
+	expectedNodes := traverse(game)
+	game = NewGameWithOptions(state.GetDrawPile(),
+		state.GetPlayerHand(gamestate.Player0), state.GetPlayerHand(gamestate.Player1),
+		GameOptions{DebugPool: true})
+	if n := traverse(game); n != expectedNodes {
+		t.Errorf("expected %d nodes with DebugPool, got %d", expectedNodes, n)
+	}
+}
+
+// A position in which most turns are forced draws, since neither player
+// holds a card that they can play for most of the game.
+const forcedSpec = "[Defuse, Defuse, Cat, Defuse, Defuse, ExplodingKitten, Defuse]; " +
+	"{1 Defuse}; {1 Defuse, 1 Skip}"
+
+// Each traversal starts with empty pools, as when building the games in
+// a BeliefState, so every open node allocates.
+// Before the single child fast path:
+// BenchmarkForcedTraversal 	      60	  18621400 ns/op	   97128 B/op	     153 allocs/op
+// After:
+// BenchmarkForcedTraversal 	      78	  14383331 ns/op	   65088 B/op	     124 allocs/op
+func BenchmarkForcedTraversal(b *testing.B) {
+	game, err := NewGameFromSpec(forcedSpec)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	state := game.GetState()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		traverse(NewGame(state.GetDrawPile(),
+			state.GetPlayerHand(gamestate.Player0), state.GetPlayerHand(gamestate.Player1)))
+	}
+}
+
+func TestChildMatching(t *testing.T) {
+	game := newTestDeckGame()
+	child, ok := game.ChildMatching(func(a gamestate.Action) bool {
+		return a.Type == gamestate.PlayCard && a.Card == cards.SeeTheFuture
+	})
+	if !ok {
+		t.Fatal("expected to find a child playing SeeTheFuture")
+	}
+
+	if expected := playCard(t, game, cards.SeeTheFuture); child != expected {
+		t.Errorf("expected child %v, got %v", expected, child)
+	}
+
+	// The child is the one reached by the matching available action.
+	is := game.InfoSet(game.Player()).(*AbstractedInfoSet)
+	for i, action := range is.AvailableActions {
+		isMatch := game.GetChild(i) == cfr.GameTreeNode(child)
+		if isMatch != (action.Card == cards.SeeTheFuture) {
+			t.Errorf("child %d (reached by %v): expected match = %v", i, action, !isMatch)
+		}
+	}
+
+	// Assume the opponent plays Skip after we draw.
+	node := drawCard(t, game)
+	skip, ok := node.ChildMatching(func(a gamestate.Action) bool {
+		return a.Card == cards.Skip
+	})
+	if !ok || skip.LastAction().Card != cards.Skip || skip.LastAction().Player != gamestate.Player1 {
+		t.Errorf("expected player 1 to play Skip, got %v", skip)
+	}
+
+	// Player 0 does not hold a Cat.
+	if child, ok := game.ChildMatching(func(a gamestate.Action) bool {
+		return a.Card == cards.Cat
+	}); ok {
+		t.Errorf("expected no child playing a Cat, got %v", child)
+	}
+
+	// Shuffles are not reached by an action.
+	drawPile := cards.NewStackFromCards([]cards.Card{cards.ExplodingKitten, cards.Cat})
+	p0Deal := cards.NewSetFromCards([]cards.Card{cards.Shuffle})
+	shuffle := playCard(t, NewGame(drawPile, p0Deal, cards.NewSet()), cards.Shuffle)
+	if _, ok := shuffle.ChildMatching(func(gamestate.Action) bool { return true }); ok {
+		t.Error("expected no matching child of a shuffle")
+	}
+}
+
+func TestGiveCardOptions(t *testing.T) {
+	drawPile := cards.NewStackFromCards([]cards.Card{
+		cards.Cat, cards.ExplodingKitten, cards.Cat, cards.Shuffle,
+	})
+	p0Deal := cards.NewSetFromCards([]cards.Card{cards.Cat, cards.Defuse})
+	p1Deal := cards.NewSetFromCards([]cards.Card{cards.Skip, cards.Skip, cards.Defuse, cards.SeeTheFuture})
+	game := playCard(t, NewGame(drawPile, p0Deal, p1Deal), cards.Cat)
+	if game.turnType != GiveCard {
+		t.Fatalf("expected player 1 to give a card, got %v", game)
+	}
+
+	options := game.GiveCardOptions()
+	if expected := p1Deal.Distinct(); !reflect.DeepEqual(options, expected) {
+		t.Errorf("expected options %v, got %v", expected, options)
+	}
+
+	is := game.InfoSet(game.Player()).(*AbstractedInfoSet)
+	if len(is.AvailableActions) != len(options) {
+		t.Errorf("expected %d options, got %d", len(is.AvailableActions), len(options))
+	}
+
+	for i, card := range options {
+		child := game.GetChild(i).(*GameNode)
+		action := child.LastAction()
+		if action.Type != gamestate.GiveCard || action.Card != card {
+			t.Errorf("option %d (%v): child reached by %v", i, card, action)
+		}
+
+		if is.AvailableActions[i].Card != card {
+			t.Errorf("option %d (%v): available action %v", i, card, is.AvailableActions[i])
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package gamestate
 
 import (
+	"encoding/binary"
 	"fmt"
 
 	"github.com/timpalpant/alphacats/cards"
@@ -48,11 +49,17 @@ func (gs *GameState) Apply(action Action, visible bool) {
 		// random placement. If the PositionInDrawPile is 0, it means that
 		// the player chose to insert the card randomly, and does not know
 		// where it ended up.
-		if action.Card != cards.Unknown {
+		if action.Card == cards.ImplodingKitten {
+			// The ImplodingKitten cannot be defused: it is put back face up,
+			// always at a chosen position.
+			gs.insertKitten(action.Player, cards.ImplodingKitten, int(action.PositionInDrawPile)-1)
+			break
+		}
+		if !action.Card.IsUnknown() {
 			gs.playCard(action)
 		}
 		if action.PositionInDrawPile != 0 {
-			gs.insertExplodingKitten(action.Player, int(action.PositionInDrawPile)-1)
+			gs.insertKitten(action.Player, cards.ExplodingKitten, int(action.PositionInDrawPile)-1)
 		}
 	default:
 		panic(fmt.Errorf("invalid action: %+v", action))
@@ -63,13 +70,13 @@ func (gs *GameState) Apply(action Action, visible bool) {
 	}
 }
 
-func (gs *GameState) insertExplodingKitten(player Player, position int) {
+func (gs *GameState) insertKitten(player Player, kitten cards.Card, position int) {
 	if player == Player0 {
-		gs.player0Hand.Remove(cards.ExplodingKitten)
+		gs.player0Hand.Remove(kitten)
 	} else {
-		gs.player1Hand.Remove(cards.ExplodingKitten)
+		gs.player1Hand.Remove(kitten)
 	}
-	gs.drawPile.InsertCard(cards.ExplodingKitten, position)
+	gs.drawPile.InsertCard(kitten, position)
 }
 
 func (gs *GameState) String() string {
@@ -113,6 +120,45 @@ func (gs *GameState) GetHistory() History {
 	return gs.history
 }
 
+// The binary encoding of a GameState is the draw pile and each player's
+// hand (uint64, little endian), followed by each packed action in the history.
+const gameStateHeaderSize = 3 * 8
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (gs *GameState) MarshalBinary() ([]byte, error) {
+	buf := make([]byte, gameStateHeaderSize, gameStateHeaderSize+3*gs.history.Len())
+	binary.LittleEndian.PutUint64(buf, uint64(gs.drawPile))
+	binary.LittleEndian.PutUint64(buf[8:], uint64(gs.player0Hand))
+	binary.LittleEndian.PutUint64(buf[16:], uint64(gs.player1Hand))
+	for i := 0; i < gs.history.Len(); i++ {
+		packed := gs.history.GetPacked(i)
+		buf = append(buf, packed[:]...)
+	}
+
+	return buf, nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (gs *GameState) UnmarshalBinary(buf []byte) error {
+	nActions := (len(buf) - gameStateHeaderSize) / 3
+	if len(buf) < gameStateHeaderSize || (len(buf)-gameStateHeaderSize)%3 != 0 || nActions > MaxNumActions {
+		return fmt.Errorf("invalid game state encoding (%d bytes)", len(buf))
+	}
+
+	gs.drawPile = cards.Stack(binary.LittleEndian.Uint64(buf))
+	gs.player0Hand = cards.Set(binary.LittleEndian.Uint64(buf[8:]))
+	gs.player1Hand = cards.Set(binary.LittleEndian.Uint64(buf[16:]))
+	buf = buf[gameStateHeaderSize:]
+	gs.history.Clear()
+	for i := 0; i < nActions; i++ {
+		var packed EncodedAction
+		copy(packed[:], buf[3*i:])
+		gs.history.AppendPacked(packed)
+	}
+
+	return nil
+}
+
 func (gs *GameState) giveCard(player Player, card cards.Card) {
 	if player == Player0 {
 		gs.player0Hand.Remove(card)
@@ -132,12 +178,17 @@ func (gs *GameState) playCard(action Action) Action {
 
 	switch action.Card {
 	case cards.SeeTheFuture:
-		action.CardsSeen = [3]cards.Card{
-			gs.drawPile.NthCard(0),
-			gs.drawPile.NthCard(1),
-			gs.drawPile.NthCard(2),
+		// If there are fewer than 3 cards in the draw pile, only those
+		// cards are seen and the remaining positions are left Unknown.
+		action.CardsSeen = [3]cards.Card{}
+		for i := 0; i < len(action.CardsSeen) && i < gs.drawPile.Len(); i++ {
+			action.CardsSeen[i] = gs.drawPile.NthCard(i)
 		}
 	case cards.DrawFromTheBottom:
+		if gs.drawPile.Len() == 0 {
+			panic(fmt.Errorf("%v cannot draw from the bottom of an empty draw pile", action.Player))
+		}
+
 		drawn := gs.drawPile.NthCard(gs.drawPile.Len() - 1)
 		action.CardsSeen[0] = drawn
 		gs.drawPile.RemoveCard(gs.drawPile.Len() - 1)
@@ -152,6 +203,10 @@ func (gs *GameState) playCard(action Action) Action {
 }
 
 func (gs *GameState) drawCard(action Action) Action {
+	if gs.drawPile.Len() == 0 {
+		panic(fmt.Errorf("%v cannot draw from an empty draw pile", action.Player))
+	}
+
 	drawn := gs.drawPile.NthCard(0)
 	gs.drawPile.RemoveCard(0)
 	if action.Player == Player0 {
@@ -159,8 +214,8 @@ func (gs *GameState) drawCard(action Action) Action {
 	} else {
 		gs.player1Hand.Add(drawn)
 	}
-	// Drawing the exploding kitten is public knowledge.
-	if drawn == cards.ExplodingKitten {
+	// Drawing the exploding (or imploding) kitten is public knowledge.
+	if drawn.Category() == cards.KittenCard {
 		action.Card = drawn
 	}
 	action.CardsSeen[0] = drawn
@@ -0,0 +1,84 @@
+package gamestate
+
+import (
+	"testing"
+
+	"github.com/timpalpant/alphacats/cards"
+)
+
+func TestSeeTheFutureShortDrawPile(t *testing.T) {
+	testCases := []struct {
+		drawPile []cards.Card
+		seen     [3]cards.Card
+	}{
+		{
+			drawPile: []cards.Card{cards.Cat, cards.ExplodingKitten},
+			seen:     [3]cards.Card{cards.Cat, cards.ExplodingKitten, cards.Unknown},
+		},
+		{
+			drawPile: []cards.Card{cards.ExplodingKitten},
+			seen:     [3]cards.Card{cards.ExplodingKitten, cards.Unknown, cards.Unknown},
+		},
+	}
+
+	for _, tc := range testCases {
+		drawPile := cards.NewStackFromCards(tc.drawPile)
+		p0Deal := cards.NewSetFromCards([]cards.Card{cards.SeeTheFuture, cards.Defuse})
+		p1Deal := cards.NewSetFromCards([]cards.Card{cards.Defuse})
+		state := New(drawPile, p0Deal, p1Deal)
+		state.Apply(Action{Player: Player0, Type: PlayCard, Card: cards.SeeTheFuture}, true)
+
+		if state.GetDrawPile() != drawPile {
+			t.Errorf("draw pile changed from %v to %v", drawPile, state.GetDrawPile())
+		}
+
+		h := state.GetHistory()
+		if seen := h.Get(h.Len() - 1).CardsSeen; seen != tc.seen {
+			t.Errorf("%v: expected to see %v, got %v", tc.drawPile, tc.seen, seen)
+		}
+
+		is := state.GetInfoSet(Player0)
+		if seen := is.History.Get(is.History.Len() - 1).CardsSeen; seen != tc.seen {
+			t.Errorf("%v: expected player 0 to see %v, got %v", tc.drawPile, tc.seen, seen)
+		}
+
+		is = state.GetInfoSet(Player1)
+		if seen := is.History.Get(is.History.Len() - 1).CardsSeen; seen != [3]cards.Card{} {
+			t.Errorf("%v: expected player 1 to see nothing, got %v", tc.drawPile, seen)
+		}
+	}
+}
+
+func TestImplodingKittenIsPublic(t *testing.T) {
+	drawPile := cards.NewStackFromCards([]cards.Card{
+		cards.ImplodingKitten, cards.Cat, cards.ExplodingKitten})
+	p0Deal := cards.NewSetFromCards([]cards.Card{cards.Defuse})
+	p1Deal := cards.NewSetFromCards([]cards.Card{cards.Defuse})
+	state := New(drawPile, p0Deal, p1Deal)
+	state.Apply(Action{Player: Player0, Type: DrawCard}, true)
+	insert := Action{
+		Player:             Player0,
+		Type:               InsertExplodingKitten,
+		Card:               cards.ImplodingKitten,
+		PositionInDrawPile: 2,
+	}
+	state.Apply(insert, true)
+
+	expectedDrawPile := cards.NewStackFromCards([]cards.Card{
+		cards.Cat, cards.ImplodingKitten, cards.ExplodingKitten})
+	if state.GetDrawPile() != expectedDrawPile {
+		t.Errorf("expected draw pile %v, got %v", expectedDrawPile, state.GetDrawPile())
+	}
+	if hand := state.GetPlayerHand(Player0); hand != p0Deal {
+		t.Errorf("expected player 0 to keep their Defuse, got %v", hand)
+	}
+
+	// Both the draw and the position it was put back are public.
+	is := state.GetInfoSet(Player1)
+	if draw := is.History.Get(0); draw.Card != cards.ImplodingKitten {
+		t.Errorf("expected player 1 to see the ImplodingKitten drawn, got %v", draw)
+	}
+	if public := is.History.Get(1); public != insert {
+		t.Errorf("expected player 1 to see %v, got %v", insert, public)
+	}
+}
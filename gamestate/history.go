@@ -48,6 +48,9 @@ type Action struct {
 	// distinguish no knowledge / random (0) from actual positions.
 	PositionInDrawPile uint8         // Private information.
 	CardsSeen          [3]cards.Card // Private information.
+	// Canceled is set if a played card was canceled (e.g. by a Nope),
+	// and had no effect. Only PlayCard actions may be canceled.
+	Canceled bool
 }
 
 func (a Action) HasPrivateInfo() bool {
@@ -57,7 +60,7 @@ func (a Action) HasPrivateInfo() bool {
 
 func (a Action) String() string {
 	s := fmt.Sprintf("%s:%s", a.Player, a.Type)
-	if a.Card != cards.Unknown {
+	if !a.Card.IsUnknown() {
 		s += ":" + a.Card.String()
 	}
 	if a.Type == InsertExplodingKitten {
@@ -67,13 +70,16 @@ func (a Action) String() string {
 			s += fmt.Sprintf(":%dth", a.PositionInDrawPile-1)
 		}
 	}
-	if a.CardsSeen[0] != cards.Unknown {
-		if a.CardsSeen[1] != cards.Unknown || a.CardsSeen[2] != cards.Unknown {
+	if !a.CardsSeen[0].IsUnknown() {
+		if !a.CardsSeen[1].IsUnknown() || !a.CardsSeen[2].IsUnknown() {
 			s += fmt.Sprintf(":%v", a.CardsSeen)
 		} else {
 			s += fmt.Sprintf(":%v", a.CardsSeen[0])
 		}
 	}
+	if a.Canceled {
+		s += ":CANCELED"
+	}
 	return s
 }
 
@@ -81,6 +87,10 @@ const MaxNumActions = 58
 
 // History records the history of game actions to reach this state.
 // It is pre-sized to avoid allocations and keep GameState easily copyable.
+//
+// Actions are stored in their packed EncodedAction form (3 bytes each) rather
+// than as full Action structs, so that copying a GameState with a long history
+// is a single small memcpy. Use Get to decode an Action.
 type History struct {
 	actions [MaxNumActions]EncodedAction
 	n       int
@@ -165,8 +175,7 @@ func (h *History) asViewedBy(player Player) History {
 	for i := 0; i < result.Len(); i++ {
 		if player != h.actions[i].Player() {
 			// Hide the non-public information.
-			result.actions[i][1] = 0
-			result.actions[i][2] = 0
+			result.actions[i] = result.actions[i].Public()
 		}
 	}
 
@@ -254,17 +263,28 @@ type EncodedAction [3]uint8
 // Action is packed as bits within a [3]uint8:
 //   [0] Player (0 or 1)
 //   [1-2] Type (1 - 4, encoded as 0-3)
-//   [3-6] Card (1 - 10)
+//   [3-6] Card (1 - 11)
 //   [7] Indicates whether there is additional private info (remaining bits) (0 or 1)
-//   [8-11] PositionInDrawPile (0 - 14)
-//   [12-24] 3 Cards (1 - 10)
-// Thus the first byte is public info, the second two bytes are private info.
+//   [8-11] PositionInDrawPile (0 - 14), or Canceled (0 or 1) for PlayCard actions
+//   [12-24] 3 Cards (1 - 11)
+// Thus the first byte is public info, the second two bytes are private info,
+// except for the Canceled bit and the position of the ImplodingKitten
+// (see Public).
 func EncodeAction(a Action) EncodedAction {
 	var result EncodedAction
 	result[0] = uint8(a.Player)
 	result[0] += uint8((a.Type - 1) << 1)
 	result[0] += uint8(a.Card << 3)
 	result[1] = uint8(a.PositionInDrawPile)
+	if a.Canceled {
+		if a.Type != PlayCard {
+			panic(fmt.Errorf("only PlayCard actions may be canceled: %v", a))
+		}
+
+		// PlayCard actions never have a position in the draw pile,
+		// so we reuse those bits.
+		result[1] = canceledBit
+	}
 	result[1] += uint8(a.CardsSeen[0] << 4)
 	result[2] = uint8(a.CardsSeen[1])
 	result[2] += uint8(a.CardsSeen[2] << 4)
@@ -276,9 +296,9 @@ func EncodeAction(a Action) EncodedAction {
 }
 
 func (packed EncodedAction) Decode() Action {
-	return Action{
+	action := Action{
 		Player:             Player(packed[0] & 0x1),
-		Type:               ActionType((packed[0]>>1)&0x3) + 1,
+		Type:               packed.Type(),
 		Card:               cards.Card((packed[0] >> 3) & 0xf),
 		PositionInDrawPile: uint8(packed[1] & 0xf),
 		CardsSeen: [3]cards.Card{
@@ -287,6 +307,40 @@ func (packed EncodedAction) Decode() Action {
 			cards.Card(packed[2] >> 4),
 		},
 	}
+
+	if action.Type == PlayCard {
+		action.Canceled = (action.PositionInDrawPile&canceledBit != 0)
+		action.PositionInDrawPile = 0
+	}
+
+	return action
+}
+
+// canceledBit is set in the PositionInDrawPile bits of canceled PlayCard actions.
+const canceledBit = 0x1
+
+// Public returns the action with all private information removed.
+// Whether a PlayCard action was canceled is public, and is retained, as is
+// the position of the ImplodingKitten, which is inserted face up.
+func (packed EncodedAction) Public() EncodedAction {
+	switch {
+	case packed.Type() == PlayCard:
+		packed[1] &= canceledBit
+	case packed.Type() == InsertExplodingKitten && packed.card() == cards.ImplodingKitten:
+		packed[1] &= 0xf
+	default:
+		packed[1] = 0
+	}
+	packed[2] = 0
+	return packed
+}
+
+func (packed EncodedAction) Type() ActionType {
+	return ActionType((packed[0]>>1)&0x3) + 1
+}
+
+func (packed EncodedAction) card() cards.Card {
+	return cards.Card((packed[0] >> 3) & 0xf)
 }
 
 func (packed EncodedAction) Player() Player {
@@ -43,6 +43,69 @@ func TestHasPrivateInfo(t *testing.T) {
 	}
 }
 
+func TestEncodeDecodeCanceled(t *testing.T) {
+	for _, canceled := range []bool{false, true} {
+		action := Action{
+			Player:    Player1,
+			Type:      PlayCard,
+			Card:      cards.SeeTheFuture,
+			CardsSeen: [3]cards.Card{cards.Cat, cards.Skip, cards.Defuse},
+			Canceled:  canceled,
+		}
+		packed := EncodeAction(action)
+		if decoded := packed.Decode(); decoded != action {
+			t.Errorf("input: %+v, output: %+v", action, decoded)
+		}
+
+		// Whether an action was canceled is public information.
+		public := packed.Public().Decode()
+		expected := Action{Player: Player1, Type: PlayCard, Card: cards.SeeTheFuture, Canceled: canceled}
+		if public != expected {
+			t.Errorf("expected public action %+v, got %+v", expected, public)
+		}
+
+		// Canceled actions must round-trip through the binary encoding,
+		// in both the full and censored histories.
+		h := NewHistoryFromActions([]Action{action})
+		for _, player := range []Player{Player0, Player1} {
+			is := h.GetInfoSet(player, cards.NewSet())
+			buf, err := is.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var unmarshaled InfoSet
+			if err := unmarshaled.UnmarshalBinary(buf); err != nil {
+				t.Fatal(err)
+			}
+
+			if unmarshaled != is {
+				t.Errorf("expected %v, got %v", is, unmarshaled)
+			}
+
+			if decoded := unmarshaled.History.Get(0); decoded.Canceled != canceled {
+				t.Errorf("expected canceled = %v, got %+v", canceled, decoded)
+			}
+		}
+	}
+
+	// Actions encoded before Canceled existed are not canceled.
+	packed := EncodedAction{uint8(PlayCard-1)<<1 + uint8(cards.Skip)<<3, 0, 0}
+	if action := packed.Decode(); action.Canceled {
+		t.Errorf("expected action to not be canceled: %+v", action)
+	}
+}
+
+func TestCancelNonPlayCard(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf("expected panic encoding canceled DrawCard action")
+		}
+	}()
+
+	EncodeAction(Action{Player: Player0, Type: DrawCard, Canceled: true})
+}
+
 func TestPackSequences(t *testing.T) {
 	testCases := [][]Action{
 		{
@@ -137,3 +200,60 @@ func TestMarshalInfoSet(t *testing.T) {
 		}
 	}
 }
+
+func makeLongHistory(n int) []Action {
+	actionTypes := []ActionType{DrawCard, PlayCard, GiveCard, InsertExplodingKitten}
+	result := make([]Action, n)
+	for i := range result {
+		action := Action{
+			Player: Player(i % 2),
+			Type:   actionTypes[i%len(actionTypes)],
+			Card:   cards.Card(1 + i%int(cards.Cat)),
+		}
+
+		switch action.Type {
+		case DrawCard:
+			action.CardsSeen[0] = cards.Card(1 + (i+3)%int(cards.Cat))
+		case PlayCard:
+			action.CardsSeen = [3]cards.Card{cards.Cat, cards.Skip, cards.Defuse}
+		case InsertExplodingKitten:
+			action.PositionInDrawPile = uint8(1 + i%13)
+		}
+
+		result[i] = action
+	}
+
+	return result
+}
+
+func TestCompactHistoryDecode(t *testing.T) {
+	actions := makeLongHistory(40)
+	h := NewHistoryFromActions(actions)
+	if h.Len() != len(actions) {
+		t.Fatalf("expected %d actions, got %d", len(actions), h.Len())
+	}
+
+	for i, action := range actions {
+		if decoded := h.Get(i); decoded != action {
+			t.Errorf("action %d: expected %v, got %v", i, action, decoded)
+		}
+	}
+}
+
+// Copying a GameState with a 40-action history is a flat copy of the packed actions.
+func BenchmarkGameStateCopy(b *testing.B) {
+	gs := New(cards.NewStack(), cards.NewSet(), cards.NewSet())
+	for _, action := range makeLongHistory(40) {
+		gs.history.Append(action)
+	}
+
+	var result GameState
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		result = NewShuffled(gs, cards.NewStack())
+	}
+
+	if result.history.Len() != gs.history.Len() {
+		b.Errorf("expected %d actions in copy, got %d", gs.history.Len(), result.history.Len())
+	}
+}
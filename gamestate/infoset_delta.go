@@ -0,0 +1,66 @@
+package gamestate
+
+import (
+	"fmt"
+
+	"github.com/timpalpant/alphacats/cards"
+)
+
+// InfoSetDelta is the change in a player's InfoSet between two points in the
+// same game: the actions taken in between, and how they changed the player's
+// hand. Recurrent models can consume each step of the game as a delta,
+// rather than the full info set at every step.
+type InfoSetDelta struct {
+	// Actions are the actions taken since the previous info set, as viewed
+	// by the player. They are packed exactly as in the History; use Decode
+	// to get each Action.
+	Actions []EncodedAction
+	// Gained are the cards added to the player's hand (drawn or received).
+	Gained cards.Set
+	// Lost are the cards removed from the player's hand (played or given away).
+	Lost cards.Set
+}
+
+// Diff returns the delta from prev to this info set. It is the inverse of
+// Apply, so that prev.Apply(is.Diff(prev)) == is.
+//
+// Diff panics if prev is not an earlier info set of the same player in
+// the same game.
+func (is *InfoSet) Diff(prev *InfoSet) InfoSetDelta {
+	if is.Player != prev.Player || prev.History.Len() > is.History.Len() {
+		panic(fmt.Errorf("%v is not an earlier info set of %v", prev, is))
+	}
+
+	for i := 0; i < prev.History.Len(); i++ {
+		if prev.History.GetPacked(i) != is.History.GetPacked(i) {
+			panic(fmt.Errorf("history of %v diverges from %v at action %d", prev, is, i))
+		}
+	}
+
+	var delta InfoSetDelta
+	for i := prev.History.Len(); i < is.History.Len(); i++ {
+		delta.Actions = append(delta.Actions, is.History.GetPacked(i))
+	}
+
+	for card := cards.Card(0); card < cards.Card(cards.NumTypes); card++ {
+		before, after := int(prev.Hand.CountOf(card)), int(is.Hand.CountOf(card))
+		if after > before {
+			delta.Gained.AddN(card, after-before)
+		} else if before > after {
+			delta.Lost.AddN(card, before-after)
+		}
+	}
+
+	return delta
+}
+
+// Apply returns the info set that results from applying the given delta.
+func (is InfoSet) Apply(delta InfoSetDelta) InfoSet {
+	for _, packed := range delta.Actions {
+		is.History.AppendPacked(packed)
+	}
+
+	is.Hand.RemoveAll(delta.Lost)
+	is.Hand.AddAll(delta.Gained)
+	return is
+}
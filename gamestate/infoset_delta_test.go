@@ -0,0 +1,62 @@
+package gamestate
+
+import (
+	"testing"
+
+	"github.com/timpalpant/alphacats/cards"
+)
+
+func TestInfoSetDiff(t *testing.T) {
+	drawPile := cards.NewStackFromCards([]cards.Card{
+		cards.Cat, cards.Skip, cards.ExplodingKitten, cards.Shuffle})
+	p0Deal := cards.NewSetFromCards([]cards.Card{cards.SeeTheFuture, cards.Defuse})
+	p1Deal := cards.NewSetFromCards([]cards.Card{cards.Slap1x, cards.Defuse})
+	state := New(drawPile, p0Deal, p1Deal)
+
+	actions := []Action{
+		{Player: Player0, Type: PlayCard, Card: cards.SeeTheFuture},
+		{Player: Player0, Type: DrawCard},
+		{Player: Player1, Type: PlayCard, Card: cards.Slap1x},
+		{Player: Player1, Type: DrawCard},
+		{Player: Player0, Type: GiveCard, Card: cards.Cat},
+	}
+
+	prev := [2]InfoSet{state.GetInfoSet(Player0), state.GetInfoSet(Player1)}
+	var deltas [2][]InfoSetDelta
+	for _, action := range actions {
+		state.Apply(action, true)
+		for _, player := range []Player{Player0, Player1} {
+			is := state.GetInfoSet(player)
+			delta := is.Diff(&prev[player])
+			if len(delta.Actions) != 1 {
+				t.Errorf("expected 1 new action, got %d", len(delta.Actions))
+			}
+
+			if result := prev[player].Apply(delta); result != is {
+				t.Errorf("applying %+v to %v: expected %v, got %v",
+					delta, prev[player], is, result)
+			}
+
+			deltas[player] = append(deltas[player], delta)
+			prev[player] = is
+		}
+	}
+
+	// Player 0 drew the Cat, and then gave it to player 1.
+	if p0Draw := deltas[Player0][1]; p0Draw.Gained != cards.NewSetFromCards([]cards.Card{cards.Cat}) {
+		t.Errorf("expected player 0 to gain a Cat, got %v", p0Draw.Gained)
+	}
+	if p1Draw := deltas[Player1][3]; p1Draw.Gained != cards.NewSetFromCards([]cards.Card{cards.Skip}) {
+		t.Errorf("expected player 1 to gain a Skip, got %v", p1Draw.Gained)
+	}
+	p0Give, p1Give := deltas[Player0][4], deltas[Player1][4]
+	if p0Give.Lost != p1Give.Gained || p0Give.Lost != cards.NewSetFromCards([]cards.Card{cards.Cat}) {
+		t.Errorf("expected Cat to move from player 0 to player 1, got %v and %v",
+			p0Give.Lost, p1Give.Gained)
+	}
+
+	// Player 1 does not see player 0's draws.
+	if opponentDraw := deltas[Player1][1]; opponentDraw.Gained.Len() != 0 || opponentDraw.Lost.Len() != 0 {
+		t.Errorf("expected no change to player 1's hand, got %+v", opponentDraw)
+	}
+}
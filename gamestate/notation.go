@@ -0,0 +1,98 @@
+package gamestate
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/timpalpant/alphacats/cards"
+)
+
+// Move notation is a compact text form of the action chosen by a player,
+// for sharing and scripting games:
+//
+//	D       Draw a card.
+//	P:Skip  Play a card.
+//	G:Cat   Give a card.
+//	I:3     Insert the exploding kitten at the given (1-based) position.
+//	I:R     Insert the exploding kitten randomly.
+//
+// The notation does not include the player, or any private information
+// revealed by taking the action (such as the card drawn).
+
+// FormatAction returns the move notation for the given action.
+func FormatAction(a Action) string {
+	switch a.Type {
+	case DrawCard:
+		return "D"
+	case PlayCard:
+		return "P:" + a.Card.String()
+	case GiveCard:
+		return "G:" + a.Card.String()
+	case InsertExplodingKitten:
+		if a.PositionInDrawPile == 0 {
+			return "I:R"
+		}
+
+		return fmt.Sprintf("I:%d", a.PositionInDrawPile)
+	default:
+		panic(fmt.Errorf("invalid action: %+v", a))
+	}
+}
+
+// ParseMove parses an action from its move notation. The Player of the
+// returned action is not set. Insertions of the exploding kitten are
+// returned as defused by the player, with a Defuse card.
+func ParseMove(s string) (Action, error) {
+	fields := strings.Split(strings.TrimSpace(s), ":")
+	switch {
+	case fields[0] == "D" && len(fields) == 1:
+		return Action{Type: DrawCard}, nil
+	case (fields[0] == "P" || fields[0] == "G") && len(fields) == 2:
+		card, err := cards.ParseCard(fields[1])
+		if err != nil || card.IsPlaceholder() {
+			return Action{}, fmt.Errorf("invalid card in move %q", s)
+		}
+
+		actionType := PlayCard
+		if fields[0] == "G" {
+			actionType = GiveCard
+		}
+
+		return Action{Type: actionType, Card: card}, nil
+	case fields[0] == "I" && len(fields) == 2:
+		action := Action{Type: InsertExplodingKitten, Card: cards.Defuse}
+		if fields[1] == "R" {
+			return action, nil
+		}
+
+		// NB: Positions must fit in the 4 bits available in an EncodedAction.
+		pos, err := strconv.Atoi(fields[1])
+		if err != nil || pos < 1 || pos > 0xf {
+			return Action{}, fmt.Errorf("invalid position in move %q", s)
+		}
+
+		action.PositionInDrawPile = uint8(pos)
+		return action, nil
+	}
+
+	return Action{}, fmt.Errorf("invalid move: %q", s)
+}
+
+// FindMove returns the index of the action in actions that is described by
+// the given move notation, or an error if there is no such action.
+func FindMove(s string, actions []Action) (int, error) {
+	move, err := ParseMove(s)
+	if err != nil {
+		return -1, err
+	}
+
+	notation := FormatAction(move)
+	for i, action := range actions {
+		if FormatAction(action) == notation {
+			return i, nil
+		}
+	}
+
+	return -1, fmt.Errorf("move %v is not one of the available actions", notation)
+}
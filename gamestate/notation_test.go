@@ -0,0 +1,70 @@
+package gamestate
+
+import (
+	"testing"
+
+	"github.com/timpalpant/alphacats/cards"
+)
+
+func TestMoveNotation(t *testing.T) {
+	testCases := []struct {
+		action   Action
+		notation string
+	}{
+		{Action{Type: DrawCard}, "D"},
+		{Action{Type: PlayCard, Card: cards.Skip}, "P:Skip"},
+		{Action{Type: PlayCard, Card: cards.SeeTheFuture}, "P:SeeTheFuture"},
+		{Action{Type: GiveCard, Card: cards.Cat}, "G:Cat"},
+		{Action{Type: InsertExplodingKitten, Card: cards.Defuse, PositionInDrawPile: 3}, "I:3"},
+		{Action{Type: InsertExplodingKitten, Card: cards.Defuse}, "I:R"},
+	}
+
+	for _, tc := range testCases {
+		if notation := FormatAction(tc.action); notation != tc.notation {
+			t.Errorf("expected %v to be formatted as %q, got %q", tc.action, tc.notation, notation)
+		}
+
+		action, err := ParseMove(tc.notation)
+		if err != nil {
+			t.Errorf("unable to parse %q: %v", tc.notation, err)
+			continue
+		}
+
+		if action != tc.action {
+			t.Errorf("expected %q to be parsed as %v, got %v", tc.notation, tc.action, action)
+		}
+	}
+}
+
+func TestParseMoveInvalid(t *testing.T) {
+	for _, invalid := range []string{
+		"", "X", "D:Cat", "P", "P:Dog", "P:TBD", "G:", "I", "I:0", "I:16", "I:x", "P:Skip:Skip",
+	} {
+		if action, err := ParseMove(invalid); err == nil {
+			t.Errorf("expected error parsing %q, got %v", invalid, action)
+		}
+	}
+}
+
+func TestFindMove(t *testing.T) {
+	actions := []Action{
+		{Player: Player1, Type: PlayCard, Card: cards.Skip},
+		{Player: Player1, Type: PlayCard, Card: cards.Cat},
+		{Player: Player1, Type: DrawCard},
+	}
+
+	for i, action := range actions {
+		found, err := FindMove(FormatAction(action), actions)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if found != i {
+			t.Errorf("expected to find %v at %d, got %d", action, i, found)
+		}
+	}
+
+	if _, err := FindMove("P:Shuffle", actions); err == nil {
+		t.Error("expected error finding unavailable move")
+	}
+}
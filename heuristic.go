@@ -0,0 +1,178 @@
+package alphacats
+
+import (
+	"fmt"
+
+	"github.com/timpalpant/go-cfr"
+
+	"github.com/timpalpant/alphacats/cards"
+	"github.com/timpalpant/alphacats/gamestate"
+)
+
+// HeuristicWeights are the tunable parameters of a Heuristic.
+type HeuristicWeights struct {
+	// Danger is the cost of drawing the ExplodingKitten. Drawing a card
+	// costs Danger times the probability that it is the ExplodingKitten,
+	// or the ImplodingKitten once it is face up.
+	Danger float64
+	// DefuseDiscount scales Danger if the player holds a Defuse, since then
+	// drawing the ExplodingKitten costs them the Defuse rather than the game.
+	// It does not apply to the ImplodingKitten, which cannot be defused.
+	DefuseDiscount float64
+	// CardValue is the cost of playing (or giving away) each card.
+	CardValue [cards.NumTypes]float64
+	// SeeTheFuture is the value of seeing the top of the draw pile when
+	// the top card is not already known.
+	SeeTheFuture float64
+	// Steal is the value of playing a Cat to take a card from the opponent
+	// when the player holds at most LowHandSize cards.
+	Steal       float64
+	LowHandSize int
+}
+
+// DefaultHeuristicWeights play sensibly: they avoid drawing a known
+// ExplodingKitten, and hold on to Defuses.
+var DefaultHeuristicWeights = HeuristicWeights{
+	Danger:         10.0,
+	DefuseDiscount: 0.3,
+	CardValue: [cards.NumTypes]float64{
+		cards.Defuse:            10.0,
+		cards.Skip:              1.0,
+		cards.Slap1x:            1.0,
+		cards.Slap2x:            1.5,
+		cards.SeeTheFuture:      0.5,
+		cards.Shuffle:           1.0,
+		cards.DrawFromTheBottom: 0.75,
+		cards.Cat:               0.5,
+	},
+	SeeTheFuture: 1.0,
+	Steal:        1.0,
+	LowHandSize:  2,
+}
+
+// Heuristic is a baseline strategy that does not require training. It scores
+// each available action by the danger of drawing the ExplodingKitten (see
+// DrawProbabilities) and the value of the cards it uses up, and chooses the
+// action with the highest score. It uses only the acting player's info set.
+//
+// Heuristic implements both ActionSource and mcts.Policy.
+type Heuristic struct {
+	Weights HeuristicWeights
+}
+
+// NewHeuristic returns a Heuristic with the DefaultHeuristicWeights.
+func NewHeuristic() *Heuristic {
+	return &Heuristic{Weights: DefaultHeuristicWeights}
+}
+
+// SelectAction implements ActionSource.
+func (h *Heuristic) SelectAction(game *GameNode, available []gamestate.Action) (int, error) {
+	if len(available) == 0 {
+		return 0, fmt.Errorf("no available actions: %v", game)
+	}
+
+	return argmax(h.Scores(game, available)), nil
+}
+
+// GetPolicy returns the deterministic policy that always chooses the
+// action selected by SelectAction.
+func (h *Heuristic) GetPolicy(node cfr.GameTreeNode) []float32 {
+	game := node.(*GameNode)
+	n := game.NumChildren()
+	p := make([]float32, n)
+	p[argmax(h.Scores(game, game.actions))] = 1.0
+	return p
+}
+
+// Scores returns the score of each of the available actions for the player
+// acting at the given node. Ties are broken toward the first action.
+func (h *Heuristic) Scores(game *GameNode, available []gamestate.Action) []float64 {
+	player := game.Player()
+	is := game.abstractedInfoSet(gamestate.Player(player))
+	w := &h.Weights
+	danger := w.Danger
+	if is.Hand.Contains(cards.Defuse) {
+		danger *= w.DefuseDiscount
+	}
+
+	// NB: The number of cards in the draw pile is public. Cards known from
+	// SeeTheFuture are at the top of the info set's draw pile.
+	nDrawPile := game.state.GetDrawPile().Len()
+	// NB: The ImplodingKitten is only a danger once it is face up. The first
+	// time it is drawn the player just puts it back.
+	faceUp := implodingKittenIsFaceUp(game.state.GetHistory())
+	var pKittenTop, pKittenBottom float64
+	var pImplodingTop, pImplodingBottom float64
+	topKnown := false
+	if nDrawPile > 0 {
+		p := game.DrawProbabilities(player)
+		pKittenTop = p[cards.ExplodingKitten]
+		pKittenBottom = pKittenTop
+		if faceUp {
+			pImplodingTop = p[cards.ImplodingKitten]
+			pImplodingBottom = pImplodingTop
+		}
+		if bottom := is.DrawPile.NthCard(nDrawPile - 1); !bottom.IsPlaceholder() {
+			pKittenBottom, pImplodingBottom = 0, 0
+			if bottom == cards.ExplodingKitten {
+				pKittenBottom = 1
+			} else if bottom == cards.ImplodingKitten && faceUp {
+				pImplodingBottom = 1
+			}
+		}
+		topKnown = !is.DrawPile.NthCard(0).IsPlaceholder()
+	}
+
+	// The cost of drawing a card that is the ExplodingKitten with
+	// probability pKitten and the ImplodingKitten with probability pImploding.
+	drawCost := func(pKitten, pImploding float64) float64 {
+		return danger*pKitten + w.Danger*pImploding
+	}
+
+	result := make([]float64, len(available))
+	for i, action := range available {
+		switch action.Type {
+		case gamestate.DrawCard:
+			result[i] = -drawCost(pKittenTop, pImplodingTop)
+		case gamestate.PlayCard:
+			result[i] = -w.CardValue[action.Card]
+			effect := cardEffects[action.Card]
+			switch {
+			case action.Card == cards.DrawFromTheBottom:
+				result[i] -= drawCost(pKittenBottom, pImplodingBottom)
+			case effect.endsTurn || effect.opponentTurns > 0:
+				// Avoids drawing a card.
+			case effect.shuffle:
+				// The player must still draw after the shuffle, but the
+				// kittens are equally likely to be anywhere.
+				pImploding := 0.0
+				if faceUp {
+					pImploding = 1 / float64(nDrawPile)
+				}
+				result[i] -= drawCost(1/float64(nDrawPile), pImploding)
+			case action.Card == cards.SeeTheFuture && !topKnown:
+				// The player can decide whether to draw once they have
+				// seen the top card.
+				result[i] += w.SeeTheFuture
+			default:
+				result[i] -= drawCost(pKittenTop, pImplodingTop)
+				if effect.targetsOpponent && is.Hand.Len() <= w.LowHandSize {
+					result[i] += w.Steal
+				}
+			}
+		case gamestate.GiveCard:
+			result[i] = -w.CardValue[action.Card]
+		case gamestate.InsertExplodingKitten:
+			// Prefer to put the ExplodingKitten on top, where the opponent
+			// is most likely to draw it. A random position is on average in
+			// the middle of the draw pile.
+			pos := float64(action.PositionInDrawPile)
+			if pos == 0 {
+				pos = float64(nDrawPile+1) / 2
+			}
+			result[i] = -pos
+		}
+	}
+
+	return result
+}
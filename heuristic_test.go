@@ -0,0 +1,123 @@
+package alphacats
+
+import (
+	"testing"
+
+	"github.com/timpalpant/go-cfr/mcts"
+
+	"github.com/timpalpant/alphacats/cards"
+	"github.com/timpalpant/alphacats/gamestate"
+)
+
+var _ ActionSource = &Heuristic{}
+var _ mcts.Policy = &Heuristic{}
+
+func selectHeuristicAction(t *testing.T, h *Heuristic, game *GameNode) gamestate.Action {
+	game.NumChildren()
+	selected, err := h.SelectAction(game, game.actions)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := h.GetPolicy(game)
+	if p[selected] != 1.0 {
+		t.Errorf("policy %v does not match selected action %d", p, selected)
+	}
+
+	return game.actions[selected]
+}
+
+func TestHeuristicSkipsKnownKitten(t *testing.T) {
+	drawPile := cards.NewStackFromCards([]cards.Card{
+		cards.ExplodingKitten, cards.Cat, cards.Cat, cards.Shuffle,
+	})
+	p1Deal := cards.NewSetFromCards([]cards.Card{cards.Slap1x, cards.Defuse})
+
+	for _, hasDefuse := range []bool{false, true} {
+		p0Deal := cards.NewSetFromCards([]cards.Card{cards.SeeTheFuture, cards.Skip})
+		if hasDefuse {
+			p0Deal.Add(cards.Defuse)
+		}
+
+		h := NewHeuristic()
+		game := NewGame(drawPile, p0Deal, p1Deal)
+		action := selectHeuristicAction(t, h, game)
+		if action.Type != gamestate.PlayCard || action.Card != cards.SeeTheFuture {
+			t.Errorf("expected to see the future when the top card is unknown, got %v", action)
+		}
+
+		game = playCard(t, game, cards.SeeTheFuture)
+		action = selectHeuristicAction(t, h, game)
+		if action.Type != gamestate.PlayCard || action.Card != cards.Skip {
+			t.Errorf("expected to skip the known kitten (with Defuse: %v), got %v", hasDefuse, action)
+		}
+	}
+}
+
+func TestHeuristicDrawsWithDefuse(t *testing.T) {
+	drawPile := cards.NewStackFromCards([]cards.Card{
+		cards.Cat, cards.ExplodingKitten, cards.Cat, cards.Shuffle,
+	})
+	p0Deal := cards.NewSetFromCards([]cards.Card{cards.Skip, cards.Defuse})
+	p1Deal := cards.NewSetFromCards([]cards.Card{cards.Slap1x, cards.Defuse})
+	game := NewGame(drawPile, p0Deal, p1Deal)
+
+	// Without knowing the top card, the risk of drawing the kitten
+	// is worth less than the Skip when the player holds a Defuse.
+	action := selectHeuristicAction(t, NewHeuristic(), game)
+	if action.Type != gamestate.DrawCard {
+		t.Errorf("expected to draw, got %v", action)
+	}
+
+	// Unless they are very averse to danger.
+	h := NewHeuristic()
+	h.Weights.Danger = 100
+	action = selectHeuristicAction(t, h, game)
+	if action.Type != gamestate.PlayCard || action.Card != cards.Skip {
+		t.Errorf("expected to skip with high danger weight, got %v", action)
+	}
+}
+
+func TestHeuristicKeepsDefuse(t *testing.T) {
+	drawPile := cards.NewStackFromCards([]cards.Card{
+		cards.Cat, cards.ExplodingKitten, cards.Cat, cards.Shuffle,
+	})
+	p0Deal := cards.NewSetFromCards([]cards.Card{cards.Cat, cards.Defuse})
+	p1Deal := cards.NewSetFromCards([]cards.Card{cards.Skip, cards.Defuse})
+	game := NewGame(drawPile, p0Deal, p1Deal)
+
+	game = playCard(t, game, cards.Cat)
+	if game.turnType != GiveCard {
+		t.Fatalf("expected player 1 to give a card, got %v", game)
+	}
+
+	action := selectHeuristicAction(t, NewHeuristic(), game)
+	if action.Type != gamestate.GiveCard || action.Card != cards.Skip {
+		t.Errorf("expected to give away Skip rather than Defuse, got %v", action)
+	}
+}
+
+func TestHeuristicSkipsFaceUpImplodingKitten(t *testing.T) {
+	drawPile := cards.NewStackFromCards([]cards.Card{
+		cards.ImplodingKitten, cards.Cat, cards.Cat, cards.ExplodingKitten,
+	})
+	p0Deal := cards.NewSetFromCards([]cards.Card{cards.Cat, cards.Defuse})
+	p1Deal := cards.NewSetFromCards([]cards.Card{cards.Skip, cards.Defuse})
+	game := NewGame(drawPile, p0Deal, p1Deal)
+
+	node, err := drawCard(t, game).Step(gamestate.Action{
+		Player:             gamestate.Player0,
+		Type:               gamestate.InsertExplodingKitten,
+		Card:               cards.ImplodingKitten,
+		PositionInDrawPile: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The Defuse does not help against the ImplodingKitten.
+	action := selectHeuristicAction(t, NewHeuristic(), node)
+	if action.Type != gamestate.PlayCard || action.Card != cards.Skip {
+		t.Errorf("expected to skip the face up imploding kitten, got %v", action)
+	}
+}
@@ -5,24 +5,69 @@ import (
 	"encoding/gob"
 	"fmt"
 
+	"github.com/timpalpant/go-cfr"
+
 	"github.com/timpalpant/alphacats/cards"
 	"github.com/timpalpant/alphacats/gamestate"
 )
 
+// infoSetEncodingVersion is the first byte of the binary encodings of
+// InfoSetWithAvailableActions and AbstractedInfoSet, which are saved with
+// training samples. It must be incremented whenever either encoding changes,
+// so that data saved with an incompatible encoding is rejected rather than
+// silently misread.
+//
+// NOTE: Encodings from before the version was added start with the player
+// (0 or 1) or the low byte of the player's hand (0 or 64), so that versions
+// start at 2 to tell them apart.
+const infoSetEncodingVersion = 4
+
+// checkEncodingVersion returns the given encoding without its version byte,
+// or an error if it was written with an incompatible version.
+func checkEncodingVersion(buf []byte) ([]byte, error) {
+	if len(buf) == 0 {
+		return nil, fmt.Errorf("empty info set encoding")
+	}
+
+	if buf[0] < 2 || buf[0] >= 64 {
+		// See the NOTE on infoSetEncodingVersion.
+		return nil, fmt.Errorf("unversioned info set encoding: data was saved before " +
+			"the ImplodingKitten was added, and packs its cards differently")
+	}
+
+	if buf[0] != infoSetEncodingVersion {
+		return nil, fmt.Errorf("unsupported info set encoding version %d (expected %d): "+
+			"data was saved by an incompatible version", buf[0], infoSetEncodingVersion)
+	}
+
+	return buf[1:], nil
+}
+
 type InfoSetWithAvailableActions struct {
 	gamestate.InfoSet
 	AvailableActions []gamestate.Action
+	// InitialDrawPileLen is the number of cards in the draw pile at the
+	// start of the game, which depends on the deck the game was dealt from.
+	InitialDrawPileLen int
+}
+
+// Abstract returns the AbstractedInfoSet for this info set. Its Key is the
+// same as the key of the info set returned by GameNode.InfoSet, and so can be
+// used to look up policies recorded during play.
+func (is *InfoSetWithAvailableActions) Abstract() AbstractedInfoSet {
+	return newAbstractedInfoSet(&is.InfoSet, is.AvailableActions, is.InitialDrawPileLen)
 }
 
 func (is *InfoSetWithAvailableActions) MarshalBinary() ([]byte, error) {
-	bufSize := is.InfoSet.MarshalBinarySize() + len(is.AvailableActions) + 1
+	bufSize := 2 + is.InfoSet.MarshalBinarySize() + len(is.AvailableActions) + 1
 	for _, action := range is.AvailableActions {
-		if action.HasPrivateInfo() {
+		if gamestate.EncodeAction(action).HasPrivateInfo() {
 			bufSize += 2
 		}
 	}
 
 	buf := make([]byte, 0, bufSize)
+	buf = append(buf, infoSetEncodingVersion, uint8(is.InitialDrawPileLen))
 	buf, err := is.InfoSet.MarshalTo(buf)
 	if err != nil {
 		return nil, err
@@ -37,7 +82,7 @@ func (is *InfoSetWithAvailableActions) MarshalBinary() ([]byte, error) {
 		// Actions are "varint" encoded: we only copy the private bits
 		// if they are non-zero, which is indicated by the last bit of
 		// the first byte.
-		if action.HasPrivateInfo() {
+		if packed.HasPrivateInfo() {
 			buf = append(buf, packed[1], packed[2])
 		}
 	}
@@ -50,6 +95,14 @@ func (is *InfoSetWithAvailableActions) MarshalBinary() ([]byte, error) {
 }
 
 func (is *InfoSetWithAvailableActions) UnmarshalBinary(buf []byte) error {
+	buf, err := checkEncodingVersion(buf)
+	if err != nil {
+		return err
+	}
+
+	is.InitialDrawPileLen = int(buf[0])
+	buf = buf[1:]
+
 	nAvailableActionBytes := int(uint8(buf[len(buf)-1]))
 	buf = buf[:len(buf)-1]
 
@@ -101,14 +154,16 @@ func (a AbstractedInfoSet) String() string {
 		a.Player, a.Hand, a.DrawPile, a.PublicHistory, a.P0PlayedCards, a.P1PlayedCards, a.AvailableActions)
 }
 
-func newAbstractedInfoSet(is *gamestate.InfoSet, availableActions []gamestate.Action) AbstractedInfoSet {
+// newAbstractedInfoSet returns the abstraction of the given info set, in a
+// game that started with nInitialDrawPile cards in the draw pile.
+func newAbstractedInfoSet(is *gamestate.InfoSet, availableActions []gamestate.Action, nInitialDrawPile int) AbstractedInfoSet {
 	result := AbstractedInfoSet{
 		Player:           is.Player,
 		Hand:             is.Hand,
 		AvailableActions: availableActions,
 	}
 	// TODO(palpant): This duplicates most of gamestate logic, but from the POV of a single player.
-	for i := 0; i < 13; i++ {
+	for i := 0; i < nInitialDrawPile; i++ {
 		result.DrawPile.SetNthCard(i, cards.TBD)
 	}
 	for i := 0; i < is.History.Len(); i++ {
@@ -127,7 +182,7 @@ func newAbstractedInfoSet(is *gamestate.InfoSet, availableActions []gamestate.Ac
 			switch action.Card {
 			case cards.SeeTheFuture:
 				for i, card := range action.CardsSeen {
-					if card != cards.Unknown {
+					if !card.IsUnknown() {
 						result.DrawPile.SetNthCard(i, card)
 					}
 				}
@@ -137,6 +192,12 @@ func newAbstractedInfoSet(is *gamestate.InfoSet, availableActions []gamestate.Ac
 				result.DrawPile = clearDrawPile(result.DrawPile)
 			}
 		case gamestate.InsertExplodingKitten:
+			if action.Card == cards.ImplodingKitten {
+				// Put back face up, so both players know where it is.
+				result.DrawPile.InsertCard(cards.ImplodingKitten, int(action.PositionInDrawPile-1))
+				break
+			}
+
 			if action.Player == gamestate.Player0 {
 				result.P0PlayedCards.Add(cards.Defuse)
 			} else {
@@ -160,6 +221,22 @@ func newAbstractedInfoSet(is *gamestate.InfoSet, availableActions []gamestate.Ac
 	return result
 }
 
+// truncateHistory drops all but the last k actions of the public history,
+// for imperfect recall abstraction. If k <= 0, the full history is retained.
+func (a *AbstractedInfoSet) truncateHistory(k int) {
+	n := a.PublicHistory.Len()
+	if k <= 0 || n <= k {
+		return
+	}
+
+	var truncated gamestate.History
+	for i := n - k; i < n; i++ {
+		truncated.AppendPacked(a.PublicHistory.GetPacked(i))
+	}
+
+	a.PublicHistory = truncated
+}
+
 func clearDrawPile(drawPile cards.Stack) cards.Stack {
 	for j := 0; j < drawPile.Len(); j++ {
 		drawPile.SetNthCard(j, cards.TBD)
@@ -168,15 +245,31 @@ func clearDrawPile(drawPile cards.Stack) cards.Stack {
 }
 
 func hidePrivateInfo(a gamestate.EncodedAction) gamestate.EncodedAction {
-	a[1] = 0
-	a[2] = 0
-	return a
+	return a.Public()
 }
 
-// Key implements cfr.InfoSet.
+// InfoSetKeyVersion is the version of the format of AbstractedInfoSet.Key.
+// It must be incremented whenever the format changes, so that tables keyed by
+// an older format (such as saved policy tables) are rejected rather than
+// silently never matching any info set.
+//
+// NOTE: Keys from before the ImplodingKitten was added pack each count in
+// cards.Set into 6 bits rather than 5, and number TBD differently. They are
+// unversioned: they were only saved in policy table versions 1 and 2, and in
+// unversioned info set encodings (see checkEncodingVersion), which are both
+// rejected when loaded.
+const InfoSetKeyVersion = 1
+
+// Key implements cfr.InfoSet. The format of the key is versioned by
+// InfoSetKeyVersion.
 func (is *AbstractedInfoSet) Key() []byte {
 	// Doing extra work to exactly size the buffer (and avoid any additional
 	// allocations ends up being faster than letting it auto-size)
+	buf := make([]byte, 0, is.keySize())
+	return is.AppendKey(buf)
+}
+
+func (is *AbstractedInfoSet) keySize() int {
 	historySize := is.PublicHistory.Len() + 1
 	for i := 0; i < is.PublicHistory.Len(); i++ {
 		packed := is.PublicHistory.GetPacked(i)
@@ -188,14 +281,18 @@ func (is *AbstractedInfoSet) Key() []byte {
 	cardsSize := 4 * 8
 	availableActionsSize := len(is.AvailableActions) + 1
 	for _, action := range is.AvailableActions {
-		if action.HasPrivateInfo() {
+		if gamestate.EncodeAction(action).HasPrivateInfo() {
 			availableActionsSize += 2
 		}
 	}
 
-	bufSize := cardsSize + historySize + availableActionsSize
-	buf := make([]byte, 0, bufSize)
+	return cardsSize + historySize + availableActionsSize
+}
 
+// AppendKey appends the Key of this info set to buf, and returns the
+// extended buffer. Reusing the same buffer for many info sets avoids
+// allocating a new key for each one.
+func (is *AbstractedInfoSet) AppendKey(buf []byte) []byte {
 	// First do sets of cards.
 	var hBuf [8]byte
 	binary.LittleEndian.PutUint64(hBuf[:], uint64(is.Hand))
@@ -231,7 +328,7 @@ func (is *AbstractedInfoSet) Key() []byte {
 		// Actions are "varint" encoded: we only copy the private bits
 		// if they are non-zero, which is indicated by the last bit of
 		// the first byte.
-		if action.HasPrivateInfo() {
+		if packed.HasPrivateInfo() {
 			buf = append(buf, packed[1], packed[2])
 		}
 	}
@@ -239,11 +336,20 @@ func (is *AbstractedInfoSet) Key() []byte {
 	return buf
 }
 
+// MarshalBinary implements encoding.BinaryMarshaler. The encoding is the
+// Key, prefixed by a version byte.
 func (is *AbstractedInfoSet) MarshalBinary() ([]byte, error) {
-	return is.Key(), nil
+	buf := make([]byte, 0, 1+is.keySize())
+	buf = append(buf, infoSetEncodingVersion)
+	return is.AppendKey(buf), nil
 }
 
 func (is *AbstractedInfoSet) UnmarshalBinary(buf []byte) error {
+	buf, err := checkEncodingVersion(buf)
+	if err != nil {
+		return err
+	}
+
 	is.PublicHistory.Clear()
 
 	is.Hand = cards.Set(binary.LittleEndian.Uint64(buf))
@@ -303,3 +409,64 @@ func init() {
 	gob.Register(&InfoSetWithAvailableActions{})
 	gob.Register(&AbstractedInfoSet{})
 }
+
+// EnumerateInfoSets walks the full game tree from the given deal, and calls cb
+// once for each distinct info set that the given player may face.
+// Children are freed as the tree is traversed, so the memory used is
+// bounded by the depth of the tree (and the number of distinct info sets).
+func EnumerateInfoSets(deal Deal, player int, cb func(*InfoSetWithAvailableActions)) {
+	EnumerateInfoSetsWithBudget(deal, player, 0, cb)
+}
+
+// EnumerateInfoSetsWithBudget is like EnumerateInfoSets, but stops once budget
+// nodes of the game tree have been visited (0 = unlimited). It returns the
+// number of nodes visited, and whether the full tree was traversed.
+func EnumerateInfoSetsWithBudget(deal Deal, player int, budget int, cb func(*InfoSetWithAvailableActions)) (nodes int, complete bool) {
+	game := NewGame(deal.DrawPile, deal.P0Deal, deal.P1Deal)
+	seen := make(map[string]struct{})
+	complete = enumerateInfoSets(game, gamestate.Player(player), seen, budget, &nodes, cb)
+	game.Close()
+	return nodes, complete
+}
+
+// enumerateInfoSets returns false if the budget ran out before the subtree
+// rooted at node was fully traversed.
+func enumerateInfoSets(node *GameNode, player gamestate.Player, seen map[string]struct{},
+	budget int, nodes *int, cb func(*InfoSetWithAvailableActions)) bool {
+	if budget > 0 && *nodes >= budget {
+		return false
+	}
+
+	*nodes++
+	if node.Type() == cfr.TerminalNodeType {
+		return true
+	}
+
+	n := node.NumChildren()
+	if node.Type() == cfr.PlayerNodeType && node.player == player {
+		is := &InfoSetWithAvailableActions{
+			InfoSet:            node.GetInfoSet(player),
+			AvailableActions:   append([]gamestate.Action(nil), node.actions...),
+			InitialDrawPileLen: node.initialDrawPileLen(),
+		}
+
+		key, err := is.MarshalBinary()
+		if err != nil {
+			panic(err)
+		}
+
+		if _, ok := seen[string(key)]; !ok {
+			seen[string(key)] = struct{}{}
+			cb(is)
+		}
+	}
+
+	complete := true
+	for i := 0; i < n && complete; i++ {
+		child := node.GetChild(i).(*GameNode)
+		complete = enumerateInfoSets(child, player, seen, budget, nodes, cb)
+		child.Close()
+	}
+
+	return complete
+}
@@ -1,9 +1,16 @@
 package alphacats
 
 import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math/rand"
 	"reflect"
+	"strings"
 	"testing"
 
+	"github.com/timpalpant/go-cfr"
+
 	"github.com/timpalpant/alphacats/cards"
 	"github.com/timpalpant/alphacats/gamestate"
 )
@@ -16,6 +23,7 @@ func TestMarshalInfoset(t *testing.T) {
 				{Player: gamestate.Player0, Type: gamestate.DrawCard},
 				{Player: gamestate.Player1, Type: gamestate.PlayCard, Card: cards.Shuffle},
 				{Player: gamestate.Player1, Type: gamestate.InsertExplodingKitten},
+				{Player: gamestate.Player0, Type: gamestate.PlayCard, Card: cards.Skip, Canceled: true},
 			}),
 			Hand: cards.NewSetFromCards([]cards.Card{cards.Cat, cards.Defuse, cards.Skip}),
 		},
@@ -23,7 +31,9 @@ func TestMarshalInfoset(t *testing.T) {
 			{Player: gamestate.Player0, Type: gamestate.DrawCard},
 			{Player: gamestate.Player1, Type: gamestate.PlayCard, Card: cards.Shuffle},
 			{Player: gamestate.Player1, Type: gamestate.InsertExplodingKitten},
+			{Player: gamestate.Player1, Type: gamestate.PlayCard, Card: cards.Cat, Canceled: true},
 		},
+		InitialDrawPileLen: 13,
 	}
 
 	buf, err := isWithAvailableActions.MarshalBinary()
@@ -40,7 +50,7 @@ func TestMarshalInfoset(t *testing.T) {
 		t.Errorf("expected: %v, got: %v", isWithAvailableActions, reloaded)
 	}
 
-	abstracted := newAbstractedInfoSet(isWithAvailableActions.InfoSet, isWithAvailableActions.AvailableActions)
+	abstracted := isWithAvailableActions.Abstract()
 	buf, err = abstracted.MarshalBinary()
 	if err != nil {
 		t.Error(err)
@@ -51,7 +61,513 @@ func TestMarshalInfoset(t *testing.T) {
 		t.Error(err)
 	}
 
-	if !reflect.DeepEqual(*abstracted, reloadedAbstracted) {
+	if !reflect.DeepEqual(abstracted, reloadedAbstracted) {
 		t.Errorf("expected: %v, got: %v", abstracted, reloadedAbstracted)
 	}
 }
+
+func TestMarshalInfosetVersion(t *testing.T) {
+	is := InfoSetWithAvailableActions{
+		InfoSet: gamestate.InfoSet{
+			Player: gamestate.Player1,
+			History: gamestate.NewHistoryFromActions([]gamestate.Action{
+				{Player: gamestate.Player0, Type: gamestate.DrawCard},
+			}),
+			Hand: cards.NewSetFromCards([]cards.Card{cards.ExplodingKitten, cards.Defuse}),
+		},
+		AvailableActions: []gamestate.Action{
+			{Player: gamestate.Player1, Type: gamestate.InsertExplodingKitten, Card: cards.Defuse},
+		},
+		InitialDrawPileLen: 13,
+	}
+	abstracted := is.Abstract()
+
+	for _, m := range []interface {
+		MarshalBinary() ([]byte, error)
+		UnmarshalBinary([]byte) error
+	}{&is, &abstracted} {
+		buf, err := m.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := m.UnmarshalBinary(buf); err != nil {
+			t.Errorf("current version failed to round-trip: %v", err)
+		}
+
+		// Encoded without a version, as before versioning was added.
+		if err := m.UnmarshalBinary(buf[1:]); err == nil {
+			t.Errorf("expected unversioned encoding of %T to be rejected", m)
+		}
+
+		old := append([]byte{infoSetEncodingVersion - 1}, buf[1:]...)
+		if err := m.UnmarshalBinary(old); err == nil {
+			t.Errorf("expected old version of %T to be rejected", m)
+		}
+	}
+
+	// Encoded before the ImplodingKitten was added: unversioned, with 6 bits
+	// per count in the hand {ExplodingKitten, Defuse}.
+	baseline := abstracted.Key()
+	binary.LittleEndian.PutUint64(baseline, 1<<6|1<<12)
+	var reloaded AbstractedInfoSet
+	if err := reloaded.UnmarshalBinary(baseline); err == nil || !strings.Contains(err.Error(), "unversioned") {
+		t.Errorf("expected encoding from before the ImplodingKitten to be rejected, got %v", err)
+	}
+
+	// The version is not part of the key, so saved policy tables are unaffected.
+	buf, _ := abstracted.MarshalBinary()
+	if !bytes.Equal(buf[1:], abstracted.Key()) {
+		t.Errorf("expected encoding to be the versioned key, got %v", buf)
+	}
+}
+
+func TestShuffleClearsDrawPileKnowledge(t *testing.T) {
+	drawPile := cards.NewStackFromCards([]cards.Card{
+		cards.Cat, cards.Skip, cards.ExplodingKitten, cards.Cat,
+	})
+	p0Deal := cards.NewSetFromCards([]cards.Card{cards.SeeTheFuture, cards.Shuffle})
+	p1Deal := cards.NewSetFromCards([]cards.Card{cards.Defuse})
+	game := NewGame(drawPile, p0Deal, p1Deal)
+
+	game = playCard(t, game, cards.SeeTheFuture)
+	is := game.InfoSet(int(gamestate.Player0)).(*AbstractedInfoSet)
+	for i, card := range []cards.Card{cards.Cat, cards.Skip, cards.ExplodingKitten} {
+		if is.DrawPile.NthCard(i) != card {
+			t.Fatalf("expected player 0 to know %v at position %d, got %v", card, i, is.DrawPile)
+		}
+	}
+
+	game = playCard(t, game, cards.Shuffle)
+	if game.Type() != cfr.ChanceNodeType {
+		t.Fatalf("expected shuffle to be a chance node: %v", game)
+	}
+
+	for i := 0; i < game.NumChildren(); i++ {
+		child := game.GetChild(i).(*GameNode)
+		for _, player := range []gamestate.Player{gamestate.Player0, gamestate.Player1} {
+			is := child.InfoSet(int(player)).(*AbstractedInfoSet)
+			for j := 0; j < is.DrawPile.Len(); j++ {
+				if card := is.DrawPile.NthCard(j); card != cards.TBD {
+					t.Errorf("expected %v to not know position %d after shuffle, got %v: %v",
+						player, j, card, is.DrawPile)
+				}
+			}
+		}
+	}
+}
+
+func TestSeeTheFutureIsPrivate(t *testing.T) {
+	// The games differ only in the order of the draw pile, which the
+	// opponent must not learn when player 0 sees the future.
+	drawPiles := []cards.Stack{
+		cards.NewStackFromCards([]cards.Card{cards.Cat, cards.Skip, cards.ExplodingKitten, cards.Cat}),
+		cards.NewStackFromCards([]cards.Card{cards.Skip, cards.Cat, cards.Cat, cards.ExplodingKitten}),
+	}
+	p0Deal := cards.NewSetFromCards([]cards.Card{cards.SeeTheFuture, cards.Shuffle})
+	p1Deal := cards.NewSetFromCards([]cards.Card{cards.Defuse})
+
+	var p0Keys, p1Keys []string
+	for _, drawPile := range drawPiles {
+		game := NewGame(drawPile, p0Deal, p1Deal)
+		game = playCard(t, game, cards.SeeTheFuture)
+
+		p0IS := game.InfoSet(int(gamestate.Player0)).(*AbstractedInfoSet)
+		for i := 0; i < 3; i++ {
+			if card := p0IS.DrawPile.NthCard(i); card != drawPile.NthCard(i) {
+				t.Errorf("expected player 0 to know %v at position %d, got %v",
+					drawPile.NthCard(i), i, p0IS.DrawPile)
+			}
+		}
+
+		p1IS := game.InfoSet(int(gamestate.Player1)).(*AbstractedInfoSet)
+		for i := 0; i < p1IS.DrawPile.Len(); i++ {
+			if card := p1IS.DrawPile.NthCard(i); card != cards.TBD {
+				t.Errorf("expected player 1 to not know position %d, got %v: %v",
+					i, card, p1IS.DrawPile)
+			}
+		}
+
+		infoSet := game.GetInfoSet(gamestate.Player1)
+		lastAction := infoSet.History.Get(infoSet.History.Len() - 1)
+		if lastAction.CardsSeen != [3]cards.Card{} {
+			t.Errorf("cards seen by player 0 leaked into player 1's history: %v", lastAction)
+		}
+
+		if err := validateBelief(game, infoSet, game.deck()); err != nil {
+			t.Errorf("player 1's info set is not valid: %v", err)
+		}
+
+		p0Keys = append(p0Keys, string(game.InfoSetKey(int(gamestate.Player0))))
+		p1Keys = append(p1Keys, string(game.InfoSetKey(int(gamestate.Player1))))
+	}
+
+	if p0Keys[0] == p0Keys[1] {
+		t.Error("expected player 0's info set to depend on the cards they saw")
+	}
+	if p1Keys[0] != p1Keys[1] {
+		t.Error("expected player 1's info set to not depend on the cards player 0 saw")
+	}
+}
+
+func TestEnumerateInfoSets(t *testing.T) {
+	deal := Deal{
+		DrawPile: cards.NewStackFromCards([]cards.Card{cards.Cat, cards.ExplodingKitten}),
+		P0Deal:   cards.NewSetFromCards([]cards.Card{cards.Skip}),
+		P1Deal:   cards.NewSetFromCards([]cards.Card{cards.Skip}),
+	}
+
+	var result []string
+	EnumerateInfoSets(deal, int(gamestate.Player0), func(is *InfoSetWithAvailableActions) {
+		result = append(result, fmt.Sprintf("%v %v %v", is.History.AsSlice(), is.Hand, is.AvailableActions))
+	})
+
+	expected := []string{
+		"[] {1 Skip} [Player0:PlayCard:Skip Player0:DrawCard]",
+		"[Player0:PlayCard:Skip Player1:PlayCard:Skip] {} [Player0:DrawCard]",
+		"[Player0:PlayCard:Skip Player1:DrawCard] {} [Player0:DrawCard]",
+		"[Player0:DrawCard:Cat Player1:PlayCard:Skip] {1 Skip, 1 Cat} [Player0:PlayCard:Skip Player0:PlayCard:Cat Player0:DrawCard]",
+		"[Player0:DrawCard:Cat Player1:PlayCard:Skip Player0:PlayCard:Cat] {1 Skip} [Player0:PlayCard:Skip Player0:DrawCard]",
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("expected info sets:\n%s\ngot:\n%s",
+			strings.Join(expected, "\n"), strings.Join(result, "\n"))
+	}
+}
+
+func TestEnumerateInfoSetsWithBudget(t *testing.T) {
+	deal := Deal{
+		DrawPile: cards.NewStackFromCards([]cards.Card{cards.Cat, cards.ExplodingKitten}),
+		P0Deal:   cards.NewSetFromCards([]cards.Card{cards.Skip}),
+		P1Deal:   cards.NewSetFromCards([]cards.Card{cards.Skip}),
+	}
+
+	nInfoSets := 0
+	count := func(is *InfoSetWithAvailableActions) { nInfoSets++ }
+	closed := nodesVisited.Value()
+	nodes, complete := EnumerateInfoSetsWithBudget(deal, int(gamestate.Player0), 0, count)
+	if !complete || nInfoSets != 5 {
+		t.Fatalf("expected to enumerate all 5 info sets, got %d (complete: %v)", nInfoSets, complete)
+	}
+	// Each node visited is closed exactly once.
+	if closed = nodesVisited.Value() - closed; closed != int64(nodes) {
+		t.Errorf("expected %d nodes to be closed, got %d", nodes, closed)
+	}
+
+	nInfoSets = 0
+	budget := nodes / 2
+	nodes, complete = EnumerateInfoSetsWithBudget(deal, int(gamestate.Player0), budget, count)
+	if complete || nodes != budget {
+		t.Errorf("expected to stop after %d nodes, visited %d (complete: %v)", budget, nodes, complete)
+	}
+	if nInfoSets == 0 || nInfoSets >= 5 {
+		t.Errorf("expected some of the 5 info sets within the budget, got %d", nInfoSets)
+	}
+}
+
+func TestEnumerateInfoSetsAfterShuffle(t *testing.T) {
+	deal := Deal{
+		DrawPile: cards.NewStackFromCards([]cards.Card{cards.Cat, cards.Skip, cards.ExplodingKitten}),
+		P0Deal:   cards.NewSetFromCards([]cards.Card{cards.Shuffle}),
+		P1Deal:   cards.NewSet(),
+	}
+
+	// Player0 shuffles then draws. The card drawn is private, so all 6
+	// shuffles are indistinguishable to Player1.
+	n := 0
+	EnumerateInfoSets(deal, int(gamestate.Player1), func(is *InfoSetWithAvailableActions) {
+		if is.History.Len() == 2 && is.History.Get(0).Card == cards.Shuffle {
+			n++
+		}
+	})
+
+	if n != 1 {
+		t.Errorf("expected 1 info set after shuffling and drawing, got %d", n)
+	}
+}
+
+type turnState struct {
+	turnType     turnType
+	pendingTurns int
+}
+
+// Checks that the acting player's info set at every node in the subtree
+// determines the turn type and number of pending turns.
+func checkInfoSetDeterminesTurn(t *testing.T, node cfr.GameTreeNode, seen map[string]turnState) {
+	gn := node.(*GameNode)
+	if node.Type() == cfr.PlayerNodeType {
+		key := string(gn.InfoSetKey(gn.Player()))
+		turn := turnState{gn.turnType, gn.pendingTurns}
+		if prev, ok := seen[key]; ok && prev != turn {
+			t.Errorf("info set %v is shared by %+v and %+v", gn.InfoSet(gn.Player()), prev, turn)
+		}
+		seen[key] = turn
+	}
+
+	for i := 0; i < node.NumChildren(); i++ {
+		child := node.GetChild(i)
+		checkInfoSetDeterminesTurn(t, child, seen)
+		child.Close()
+	}
+}
+
+// The number of pending turns is not encoded explicitly in the info set,
+// since it is a function of the public history (which includes any Slaps).
+func TestInfoSetDeterminesPendingTurns(t *testing.T) {
+	seen := make(map[string]turnState)
+	checkInfoSetDeterminesTurn(t, newTestDeckGame(), seen)
+
+	pendingTurns := make(map[int]bool)
+	for _, turn := range seen {
+		pendingTurns[turn.pendingTurns] = true
+	}
+	if len(pendingTurns) < 2 {
+		t.Errorf("expected to see more than one number of pending turns, got %v", pendingTurns)
+	}
+
+	drawPile := cards.NewStackFromCards([]cards.Card{cards.Cat, cards.ExplodingKitten})
+	p0Deal := cards.NewSetFromCards([]cards.Card{cards.Slap1x, cards.Slap2x})
+	p1Deal := cards.NewSetFromCards([]cards.Card{cards.Skip})
+	game := NewGame(drawPile, p0Deal, p1Deal)
+	slap1x := playCard(t, game, cards.Slap1x)
+	slap2x := playCard(t, game, cards.Slap2x)
+	if slap1x.pendingTurns == slap2x.pendingTurns {
+		t.Fatalf("expected different pending turns, got %d", slap1x.pendingTurns)
+	}
+
+	is1x := slap1x.InfoSet(slap1x.Player()).(*AbstractedInfoSet)
+	is2x := slap2x.InfoSet(slap2x.Player()).(*AbstractedInfoSet)
+	if is1x.String() == is2x.String() {
+		t.Errorf("expected different info sets with %d and %d pending turns: %v",
+			slap1x.pendingTurns, slap2x.pendingTurns, is1x)
+	}
+}
+
+// Checks that AppendInfoSetKey matches InfoSetKey for both players at
+// every node in the subtree.
+func checkAppendInfoSetKey(t *testing.T, node cfr.GameTreeNode, buf []byte) {
+	gn := node.(*GameNode)
+	for player := 0; player < numPlayers; player++ {
+		expected := gn.InfoSetKey(player)
+		buf = gn.AppendInfoSetKey(buf[:0], player)
+		if !bytes.Equal(buf, expected) {
+			t.Fatalf("AppendInfoSetKey = %v, expected %v", buf, expected)
+		}
+
+		prefixed := gn.AppendInfoSetKey([]byte("prefix"), player)
+		if !bytes.Equal(prefixed, append([]byte("prefix"), expected...)) {
+			t.Fatalf("AppendInfoSetKey did not append to buffer: %v", prefixed)
+		}
+	}
+
+	for i := 0; i < node.NumChildren(); i++ {
+		child := node.GetChild(i)
+		checkAppendInfoSetKey(t, child, buf)
+		child.Close()
+	}
+}
+
+func TestAppendInfoSetKey(t *testing.T) {
+	drawPile := cards.NewStackFromCards([]cards.Card{
+		cards.Cat, cards.Skip, cards.ExplodingKitten, cards.SeeTheFuture,
+	})
+	p0Deal := cards.NewSetFromCards([]cards.Card{cards.SeeTheFuture, cards.Defuse})
+	p1Deal := cards.NewSetFromCards([]cards.Card{cards.Cat, cards.Slap1x, cards.Defuse})
+	checkAppendInfoSetKey(t, NewGame(drawPile, p0Deal, p1Deal), nil)
+
+	game := newTestDeckGame()
+	buf := game.AppendInfoSetKey(nil, 0)
+	allocs := testing.AllocsPerRun(100, func() {
+		buf = game.AppendInfoSetKey(buf[:0], 0)
+	})
+	if allocs != 0 {
+		t.Errorf("expected no allocations, got %v per run", allocs)
+	}
+}
+
+// Collects the available actions of the acting player's info set at every
+// node in the subtree, and checks that they are the same for every node
+// that shares an info set.
+func collectInfoSetActions(t *testing.T, node cfr.GameTreeNode, seen map[string][]gamestate.Action) {
+	gn := node.(*GameNode)
+	if node.Type() == cfr.PlayerNodeType {
+		key := string(gn.InfoSetKey(gn.Player()))
+		if prev, ok := seen[key]; ok && !reflect.DeepEqual(prev, gn.actions) {
+			t.Errorf("info set %v has actions %v and %v", gn.InfoSet(gn.Player()), prev, gn.actions)
+		}
+		seen[key] = append([]gamestate.Action(nil), gn.actions...)
+	}
+
+	for i := 0; i < node.NumChildren(); i++ {
+		child := node.GetChild(i)
+		collectInfoSetActions(t, child, seen)
+		child.Close()
+	}
+}
+
+func TestRecallDepth(t *testing.T) {
+	numInfoSets := func(recallDepth int) int {
+		game := newTestDeckGame()
+		state := game.GetState()
+		game = NewGameWithOptions(state.GetDrawPile(),
+			state.GetPlayerHand(gamestate.Player0), state.GetPlayerHand(gamestate.Player1),
+			GameOptions{RecallDepth: recallDepth})
+		seen := make(map[string][]gamestate.Action)
+		collectInfoSetActions(t, game, seen)
+		return len(seen)
+	}
+
+	full := numInfoSets(0)
+	bounded := numInfoSets(2)
+	t.Logf("%d info sets with perfect recall, %d with recall depth 2", full, bounded)
+	if bounded >= full {
+		t.Errorf("expected fewer than %d info sets with recall depth 2, got %d", full, bounded)
+	}
+
+	// A recall depth longer than any game is equivalent to perfect recall.
+	if n := numInfoSets(100); n != full {
+		t.Errorf("expected %d info sets with recall depth 100, got %d", full, n)
+	}
+}
+
+// checkInfoSetConsistency returns an error if the info sets of either player
+// at the given node differ from those derived via other paths to the same
+// game: a clone of the node, a copy decoded from its binary encoding, and a
+// replay of its history from the initial deal.
+func checkInfoSetConsistency(node *GameNode, deal Deal) error {
+	decoded := &GameNode{}
+	buf, err := node.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	if err := decoded.UnmarshalBinary(buf); err != nil {
+		return err
+	}
+
+	h := node.GetHistory()
+	replayed, err := NewGameFromHistory(deal, h.AsSlice())
+	if err != nil {
+		return err
+	}
+	// NB: Chance outcomes are not in the history, so the replay stops at
+	// the chance node if the last action led to one. Inserting the kitten
+	// randomly only removes it from the player's hand once it is placed.
+	for replayed.Type() == cfr.ChanceNodeType && node.Type() != cfr.ChanceNodeType {
+		replayed = replayed.GetChild(0).(*GameNode)
+	}
+
+	others := map[string]*GameNode{
+		"clone":    node.Clone(),
+		"decoded":  decoded,
+		"replayed": replayed,
+	}
+
+	for _, player := range []gamestate.Player{gamestate.Player0, gamestate.Player1} {
+		is := node.GetInfoSet(player)
+		key := is.Key()
+		for name, other := range others {
+			otherIS := other.GetInfoSet(player)
+			if otherIS != is {
+				return fmt.Errorf("%s info set for %v differs: %v / %v, expected %v / %v",
+					name, player, otherIS.Hand, otherIS.History, is.Hand, is.History)
+			}
+
+			if otherIS.Key() != key {
+				return fmt.Errorf("%s info set key for %v differs", name, player)
+			}
+		}
+
+		// The encoding of the info set round-trips as well.
+		buf, err := is.MarshalBinary()
+		if err != nil {
+			return err
+		}
+
+		var unmarshaled gamestate.InfoSet
+		if err := unmarshaled.UnmarshalBinary(buf); err != nil {
+			return err
+		}
+
+		if unmarshaled != is || unmarshaled.Key() != key {
+			return fmt.Errorf("decoded info set for %v differs: %v / %v, expected %v / %v",
+				player, unmarshaled.Hand, unmarshaled.History, is.Hand, is.History)
+		}
+	}
+
+	return nil
+}
+
+// infoSetFailure is a game in which the info sets derived via different
+// paths disagree, after the given history.
+type infoSetFailure struct {
+	seed    int64
+	deal    Deal
+	history []gamestate.Action
+	err     error
+}
+
+func TestInfoSetConsistency(t *testing.T) {
+	const numGames = 200
+	var minimal *infoSetFailure
+	nFailed := 0
+	for seed := int64(0); seed < numGames; seed++ {
+		rand.Seed(seed)
+		rng := rand.New(rand.NewSource(seed))
+		deal := NewRandomDeal(cards.CoreDeck.AsSlice(), 4)
+		var node cfr.GameTreeNode = NewGame(deal.DrawPile, deal.P0Deal, deal.P1Deal)
+		for {
+			gn := node.(*GameNode)
+			// NB: The first failure along the game is its shortest prefix
+			// that fails, so later steps need not be checked.
+			if err := checkInfoSetConsistency(gn, deal); err != nil {
+				h := gn.GetHistory()
+				failure := &infoSetFailure{seed, deal, h.AsSlice(), err}
+				if minimal == nil || len(failure.history) < len(minimal.history) {
+					minimal = failure
+				}
+				nFailed++
+				break
+			}
+
+			if node.Type() == cfr.TerminalNodeType {
+				break
+			} else if node.Type() == cfr.ChanceNodeType {
+				node, _ = node.SampleChild()
+			} else {
+				node = node.GetChild(rng.Intn(node.NumChildren()))
+			}
+		}
+	}
+
+	if minimal != nil {
+		moves := make([]string, len(minimal.history))
+		for i, action := range minimal.history {
+			moves[i] = fmt.Sprintf("%v:%s", action.Player, gamestate.FormatAction(action))
+		}
+
+		t.Errorf("%d of %d games have inconsistent info sets. Shortest failure (seed %d): "+
+			"draw pile %v, P0 %v, P1 %v, after %d actions [%s]: %v",
+			nFailed, numGames, minimal.seed, minimal.deal.DrawPile, minimal.deal.P0Deal,
+			minimal.deal.P1Deal, len(moves), strings.Join(moves, " "), minimal.err)
+	}
+}
+
+func BenchmarkInfoSetKey(b *testing.B) {
+	game := newTestDeckGame()
+	game.NumChildren()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		game.InfoSetKey(0)
+	}
+}
+
+func BenchmarkAppendInfoSetKey(b *testing.B) {
+	game := newTestDeckGame()
+	buf := game.AppendInfoSetKey(nil, 0)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf = game.AppendInfoSetKey(buf[:0], 0)
+	}
+}
@@ -0,0 +1,20 @@
+// Package testgames provides small fixed deals for tests.
+package testgames
+
+import (
+	"github.com/timpalpant/alphacats/cards"
+)
+
+// TestDeck returns the draw pile and hands dealt to each player of a small
+// fixed game, with a draw pile of four cards and three cards in each hand,
+// that is small enough to traverse exhaustively in tests. For example:
+//
+//	game := alphacats.NewGame(testgames.TestDeck())
+func TestDeck() (drawPile cards.Stack, p0Deal, p1Deal cards.Set) {
+	drawPile = cards.NewStackFromCards([]cards.Card{
+		cards.DrawFromTheBottom, cards.ExplodingKitten, cards.Cat, cards.Defuse,
+	})
+	p0Deal = cards.NewSetFromCards([]cards.Card{cards.SeeTheFuture, cards.Slap1x, cards.Defuse})
+	p1Deal = cards.NewSetFromCards([]cards.Card{cards.Slap2x, cards.Skip, cards.Defuse})
+	return drawPile, p0Deal, p1Deal
+}
@@ -0,0 +1,284 @@
+package alphacats
+
+import (
+	"fmt"
+
+	"github.com/timpalpant/alphacats/cards"
+	"github.com/timpalpant/alphacats/gamestate"
+)
+
+// DrawProbabilities returns the probability distribution over the identity
+// of the top card of the draw pile, from the point of view of the given player.
+//
+// If the player knows the top card (e.g. from SeeTheFuture), it is returned with
+// probability 1. Otherwise the top card is equally likely to be any of the cards
+// whose location is unknown to the player. Cards the player gave to their
+// opponent are known to be in the opponent's hand (see givenCards).
+func (gn *GameNode) DrawProbabilities(player int) map[cards.Card]float64 {
+	is := gn.abstractedInfoSet(gamestate.Player(player))
+	if topCard := is.DrawPile.NthCard(0); !topCard.IsTBD() {
+		return map[cards.Card]float64{topCard: 1.0}
+	}
+
+	remaining := gn.remainingCards(&is)
+	n := float64(remaining.Len())
+	result := make(map[cards.Card]float64)
+	remaining.Iter(func(card cards.Card, count uint8) {
+		result[card] = float64(count) / n
+	})
+
+	return result
+}
+
+// OpponentHand returns the cards that the given player can infer must be in
+// their opponent's hand, and whether that accounts for the opponent's entire hand.
+//
+// Every card whose location is unknown to the player is either in the
+// opponent's hand or in one of the n undetermined positions of the draw pile.
+// So if there are more than n copies of a card left unaccounted for, the
+// excess copies must be in the opponent's hand. Late in the game this can
+// resolve the opponent's hand fully, for example once the player has seen
+// every card remaining in the draw pile. Cards that the player gave to their
+// opponent are also known to be in their hand (see givenCards).
+func (gn *GameNode) OpponentHand(player int) (cards.Set, bool) {
+	is := gn.abstractedInfoSet(gamestate.Player(player))
+	remaining := gn.remainingCards(&is)
+	known := gn.givenCards(gamestate.Player(player))
+	// NB: The number of cards in each hand is public knowledge.
+	opponentHandSize := gn.state.GetPlayerHand(nextPlayer(gamestate.Player(player))).Len()
+	numUndetermined := remaining.Len() - (opponentHandSize - known.Len())
+
+	remaining.Iter(func(card cards.Card, count uint8) {
+		if n := int(count) - numUndetermined; n > 0 {
+			known.AddN(card, n)
+		}
+	})
+
+	return known, known.Len() == opponentHandSize
+}
+
+// DrawPileComposition returns the cards in the draw pile, from the point of
+// view of the given player. Unlike GetDrawPile, it does not reveal any cards
+// that are hidden from the player.
+//
+// Cards whose position in the draw pile is known to the player (e.g. from
+// SeeTheFuture) are included, as are cards that the player can infer must be
+// in the draw pile because there are more copies unaccounted for than the
+// opponent's hand could hold (see OpponentHand). The rest of the draw pile
+// is counted as cards.TBD.
+func (gn *GameNode) DrawPileComposition(player int) cards.Set {
+	is := gn.abstractedInfoSet(gamestate.Player(player))
+	remaining := gn.remainingCards(&is)
+	// NB: The number of cards in the draw pile and in each hand is public.
+	nDrawPile := gn.state.GetDrawPile().Len()
+	opponentHandSize := gn.state.GetPlayerHand(nextPlayer(gamestate.Player(player))).Len()
+	opponentHandSize -= gn.givenCards(gamestate.Player(player)).Len()
+
+	var result cards.Set
+	for i := 0; i < nDrawPile; i++ {
+		if card := is.DrawPile.NthCard(i); !card.IsPlaceholder() {
+			result.Add(card)
+		}
+	}
+
+	remaining.Iter(func(card cards.Card, count uint8) {
+		if n := int(count) - opponentHandSize; n > 0 {
+			result.AddN(card, n)
+		}
+	})
+
+	result.AddN(cards.TBD, nDrawPile-result.Len())
+	return result
+}
+
+// Uncertainty returns the fraction of the cards outside of the given player's
+// hand (in their opponent's hand or the draw pile) whose location is unknown
+// to the player. It is 1 at the start of the game, and decreases as the player
+// learns where cards are, for example from SeeTheFuture or a Cat. This can be
+// used to scale the search budget with how much is left to be discovered.
+func (gn *GameNode) Uncertainty(player int) float64 {
+	is := gn.abstractedInfoSet(gamestate.Player(player))
+	remaining := gn.remainingCards(&is)
+	// NB: The number of cards in the draw pile and in each hand is public.
+	nDrawPile := gn.state.GetDrawPile().Len()
+	opponentHandSize := gn.state.GetPlayerHand(nextPlayer(gamestate.Player(player))).Len()
+	nGiven := gn.givenCards(gamestate.Player(player)).Len()
+	nUnknownPositions := nDrawPile
+	for i := 0; i < nDrawPile; i++ {
+		if !is.DrawPile.NthCard(i).IsPlaceholder() {
+			nUnknownPositions--
+		}
+	}
+
+	// Each card whose location is unknown fills one of the opponent's
+	// cards (other than those given to them) or undetermined positions
+	// of the draw pile.
+	if remaining.Len() != opponentHandSize-nGiven+nUnknownPositions {
+		panic(fmt.Errorf("%d cards are unaccounted for, but there are %d unknown positions: %v",
+			remaining.Len(), opponentHandSize-nGiven+nUnknownPositions, is))
+	}
+
+	n := opponentHandSize + nDrawPile
+	if n == 0 {
+		return 0
+	}
+
+	return float64(remaining.Len()) / float64(n)
+}
+
+// OpponentPossibleActions returns the actions that the given player's opponent
+// could take on their turn, along with the probability that the opponent is
+// able to take each of them, from the point of view of the given player.
+//
+// Like DrawProbabilities, this treats the opponent's hand as a uniformly random
+// subset of the cards whose location is unknown to the player, in addition to
+// the cards the player gave them (see givenCards). It uses only the player's info
+// set and so does not depend on the opponent's actual hand, unlike the
+// concrete actions available at a node (see NumChildren).
+func (gn *GameNode) OpponentPossibleActions(player int) map[gamestate.Action]float64 {
+	opponent := nextPlayer(gamestate.Player(player))
+	is := gn.abstractedInfoSet(gamestate.Player(player))
+	remaining := gn.remainingCards(&is)
+	given := gn.givenCards(gamestate.Player(player))
+	n := remaining.Len()
+	handSize := gn.state.GetPlayerHand(opponent).Len()
+	// The number of cards in the opponent's hand other than those given to them.
+	nUnknown := handSize - given.Len()
+
+	result := make(map[gamestate.Action]float64)
+	nPlayable := 0
+	holdsPlayable := false
+	all := remaining
+	all.AddAll(given)
+	all.Iter(func(card cards.Card, count uint8) {
+		if !isPlayable(card) {
+			return
+		}
+
+		action := gamestate.Action{
+			Player: opponent,
+			Type:   gamestate.PlayCard,
+			Card:   card,
+		}
+		if given.Contains(card) {
+			holdsPlayable = true
+			result[action] = 1.0
+			return
+		}
+
+		nPlayable += int(remaining.CountOf(card))
+		result[action] = probHoldsAny(n, int(remaining.CountOf(card)), nUnknown)
+	})
+
+	// The opponent may draw unless their hand is full and they hold
+	// a card that they are able to play.
+	pDraw := 1.0
+	if gn.maxHandSize > 0 && handSize >= gn.maxHandSize {
+		if holdsPlayable {
+			pDraw = 0.0
+		} else {
+			pDraw = 1.0 - probHoldsAny(n, nPlayable, nUnknown)
+		}
+	}
+
+	if pDraw > 0 {
+		action := gamestate.Action{
+			Player: opponent,
+			Type:   gamestate.DrawCard,
+		}
+		result[action] = pDraw
+	}
+
+	return result
+}
+
+// probHoldsAny returns the probability that a hand of k cards, drawn uniformly
+// without replacement from n cards, includes at least one of m marked cards.
+func probHoldsAny(n, m, k int) float64 {
+	// P(none) = C(n-m, k) / C(n, k)
+	pNone := 1.0
+	for i := 0; i < k; i++ {
+		if n-m-i <= 0 {
+			return 1.0
+		}
+
+		pNone *= float64(n-m-i) / float64(n-i)
+	}
+
+	return 1.0 - pNone
+}
+
+// abstractedInfoSet returns the given player's AbstractedInfoSet, without
+// building this node's children to determine the available actions.
+func (gn *GameNode) abstractedInfoSet(player gamestate.Player) AbstractedInfoSet {
+	is := gn.GetInfoSet(player)
+	return newAbstractedInfoSet(&is, nil, gn.initialDrawPileLen())
+}
+
+// remainingCards returns the cards whose location is not known from the given
+// info set. Each of them is either in the opponent's hand (other than the cards
+// given to them, see givenCards), or in one of the undetermined positions of
+// the draw pile.
+func (gn *GameNode) remainingCards(is *AbstractedInfoSet) cards.Set {
+	remaining := gn.deck()
+	remaining.RemoveAll(is.Hand)
+	remaining.RemoveAll(is.P0PlayedCards)
+	remaining.RemoveAll(is.P1PlayedCards)
+	remaining.RemoveAll(gn.givenCards(is.Player))
+	for i := 0; i < is.DrawPile.Len(); i++ {
+		card := is.DrawPile.NthCard(i)
+		if !card.IsPlaceholder() {
+			remaining.Remove(card)
+		}
+	}
+
+	return remaining
+}
+
+// givenCards returns the cards that the given player gave to their opponent
+// (e.g. when the opponent played a Cat) that the player knows are still in
+// the opponent's hand: one copy of a given card is accounted for each time
+// the opponent plays or gives back a card of the same kind.
+func (gn *GameNode) givenCards(player gamestate.Player) cards.Set {
+	var given cards.Set
+	h := gn.state.GetHistory()
+	for i := 0; i < h.Len(); i++ {
+		action := h.Get(i)
+		if action.Player == player {
+			if action.Type == gamestate.GiveCard {
+				given.Add(action.Card)
+			}
+		} else if action.Type == gamestate.PlayCard || action.Type == gamestate.GiveCard || isDefuse(action) {
+			if given.Contains(action.Card) {
+				given.Remove(action.Card)
+			}
+		}
+	}
+
+	return given
+}
+
+// deck returns all cards in play in this game. The composition of the deck
+// is public knowledge, even though the location of each card is not.
+//
+// NOTE: The game state must be fully determinized (no TBD cards).
+func (gn *GameNode) deck() cards.Set {
+	result := gn.state.GetPlayerHand(gamestate.Player0)
+	result.AddAll(gn.state.GetPlayerHand(gamestate.Player1))
+	result.AddAll(gn.state.GetDrawPile().ToSet())
+	h := gn.state.GetHistory()
+	for i := 0; i < h.Len(); i++ {
+		action := h.Get(i)
+		if action.Type == gamestate.PlayCard || isDefuse(action) {
+			result.Add(action.Card)
+		}
+	}
+
+	return result
+}
+
+// isDefuse returns whether the given action discarded a Defuse to put the
+// exploding kitten back. The imploding kitten is put back without one.
+func isDefuse(action gamestate.Action) bool {
+	return action.Type == gamestate.InsertExplodingKitten && action.Card == cards.Defuse
+}
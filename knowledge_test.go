@@ -0,0 +1,295 @@
+package alphacats
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/timpalpant/go-cfr"
+
+	"github.com/timpalpant/alphacats/cards"
+	"github.com/timpalpant/alphacats/gamestate"
+)
+
+// Returns the child of node reached by playing the given card.
+func playCard(t *testing.T, node *GameNode, card cards.Card) *GameNode {
+	for i := 0; i < node.NumChildren(); i++ {
+		child := node.GetChild(i).(*GameNode)
+		action := child.LastAction()
+		if action.Type == gamestate.PlayCard && action.Card == card {
+			return child
+		}
+	}
+
+	t.Fatalf("%v cannot play %v", node, card)
+	return nil
+}
+
+func checkProbabilities(t *testing.T, expected, actual map[cards.Card]float64) {
+	if len(expected) != len(actual) {
+		t.Errorf("expected %v, got %v", expected, actual)
+		return
+	}
+
+	for card, p := range expected {
+		if math.Abs(actual[card]-p) > 1e-9 {
+			t.Errorf("expected P(%v) = %v, got %v", card, p, actual[card])
+		}
+	}
+}
+
+func TestDrawProbabilities(t *testing.T) {
+	game := newTestDeckGame()
+	// Player 0 has {SeeTheFuture, Slap1x, Defuse}, so the top card
+	// could be any of the other 7 cards.
+	checkProbabilities(t, map[cards.Card]float64{
+		cards.Slap2x:            1.0 / 7,
+		cards.Skip:              1.0 / 7,
+		cards.Defuse:            2.0 / 7,
+		cards.DrawFromTheBottom: 1.0 / 7,
+		cards.ExplodingKitten:   1.0 / 7,
+		cards.Cat:               1.0 / 7,
+	}, game.DrawProbabilities(0))
+
+	// After playing SeeTheFuture, player 0 knows the top card.
+	child := playCard(t, game, cards.SeeTheFuture)
+	checkProbabilities(t, map[cards.Card]float64{
+		cards.DrawFromTheBottom: 1.0,
+	}, child.DrawProbabilities(0))
+
+	// But player 1 does not, and has only learned that SeeTheFuture was played.
+	checkProbabilities(t, map[cards.Card]float64{
+		cards.Slap1x:            1.0 / 6,
+		cards.Defuse:            2.0 / 6,
+		cards.DrawFromTheBottom: 1.0 / 6,
+		cards.ExplodingKitten:   1.0 / 6,
+		cards.Cat:               1.0 / 6,
+	}, child.DrawProbabilities(1))
+}
+
+func checkOpponentHand(t *testing.T, node *GameNode, player int, expected cards.Set, expectedComplete bool) {
+	known, complete := node.OpponentHand(player)
+	if known != expected || complete != expectedComplete {
+		t.Errorf("player %d: expected opponent hand %v (complete: %v), got %v (complete: %v)",
+			player, expected, expectedComplete, known, complete)
+	}
+}
+
+func TestOpponentHand(t *testing.T) {
+	game := newTestDeckGame()
+	// Nothing can be inferred at the start of the game.
+	checkOpponentHand(t, game, 0, cards.NewSet(), false)
+	checkOpponentHand(t, game, 1, cards.NewSet(), false)
+
+	// Player 0 sees [DrawFromTheBottom, ExplodingKitten, Cat]. That leaves
+	// {Slap2x, Skip, Defuse, Defuse} for player 1's hand and the one unseen
+	// card in the draw pile, so player 1 must have at least one Defuse.
+	node := playCard(t, game, cards.SeeTheFuture)
+	checkOpponentHand(t, node, 0, cards.NewSetFromCards([]cards.Card{cards.Defuse}), false)
+
+	node = drawCard(t, node)
+	node = playCard(t, node, cards.Skip)
+	// Player 0 draws the last unseen card (a Defuse) from the bottom, and
+	// now knows the location of every card other than player 1's hand.
+	node = playCard(t, node, cards.DrawFromTheBottom)
+	checkOpponentHand(t, node, 0, cards.NewSetFromCards([]cards.Card{cards.Slap2x, cards.Defuse}), true)
+	state := node.GetState()
+	if actual := state.GetPlayerHand(gamestate.Player1); actual != cards.NewSetFromCards([]cards.Card{cards.Slap2x, cards.Defuse}) {
+		t.Errorf("inferred hand does not match player 1's actual hand %v", actual)
+	}
+
+	// Player 1 still knows very little about player 0's hand.
+	checkOpponentHand(t, node, 1, cards.NewSet(), false)
+}
+
+func checkPossibleActions(t *testing.T, node *GameNode, player int, expected map[cards.Card]float64) {
+	actual := node.OpponentPossibleActions(player)
+	// Every expected card plus drawing.
+	if len(actual) != len(expected)+1 {
+		t.Errorf("player %d: expected %d possible actions, got %v", player, len(expected)+1, actual)
+	}
+
+	opponent := nextPlayer(gamestate.Player(player))
+	draw := gamestate.Action{Player: opponent, Type: gamestate.DrawCard}
+	if p := actual[draw]; p != 1.0 {
+		t.Errorf("player %d: expected opponent to be able to draw, got P = %v", player, p)
+	}
+
+	for card, p := range expected {
+		action := gamestate.Action{Player: opponent, Type: gamestate.PlayCard, Card: card}
+		if math.Abs(actual[action]-p) > 1e-9 {
+			t.Errorf("player %d: expected P(%v) = %v, got %v", player, action, p, actual[action])
+		}
+	}
+}
+
+func TestOpponentPossibleActions(t *testing.T) {
+	game := newTestDeckGame()
+	// Player 1 holds 3 of the 7 cards unknown to player 0, so each of
+	// the playable ones is held with probability 1 - C(6, 3) / C(7, 3).
+	checkPossibleActions(t, game, 0, map[cards.Card]float64{
+		cards.Slap2x:            3.0 / 7,
+		cards.Skip:              3.0 / 7,
+		cards.DrawFromTheBottom: 3.0 / 7,
+		cards.Cat:               3.0 / 7,
+	})
+
+	// After seeing the future, player 1 holds 3 of {Slap2x, Skip, Defuse, Defuse}.
+	node := playCard(t, game, cards.SeeTheFuture)
+	checkPossibleActions(t, node, 0, map[cards.Card]float64{
+		cards.Slap2x: 3.0 / 4,
+		cards.Skip:   3.0 / 4,
+	})
+
+	// Once player 1's hand is known to be {Slap2x, Defuse}, Slap2x is certain.
+	node = drawCard(t, node)
+	node = playCard(t, node, cards.Skip)
+	node = playCard(t, node, cards.DrawFromTheBottom)
+	checkPossibleActions(t, node, 0, map[cards.Card]float64{
+		cards.Slap2x: 1.0,
+	})
+
+	// The prediction does not depend on the opponent's actual hand.
+	other := NewGame(
+		cards.NewStackFromCards([]cards.Card{
+			cards.Slap2x, cards.ExplodingKitten, cards.Skip, cards.Defuse,
+		}),
+		cards.NewSetFromCards([]cards.Card{cards.SeeTheFuture, cards.Slap1x, cards.Defuse}),
+		cards.NewSetFromCards([]cards.Card{cards.Cat, cards.DrawFromTheBottom, cards.Defuse}))
+	if !reflect.DeepEqual(game.OpponentPossibleActions(0), other.OpponentPossibleActions(0)) {
+		t.Errorf("prediction depends on the opponent's hand: %v != %v",
+			game.OpponentPossibleActions(0), other.OpponentPossibleActions(0))
+	}
+}
+
+func checkDrawPileComposition(t *testing.T, node *GameNode, player int, expected []cards.Card) {
+	if actual := node.DrawPileComposition(player); actual != cards.NewSetFromCards(expected) {
+		t.Errorf("player %d: expected draw pile composition %v, got %v",
+			player, cards.NewSetFromCards(expected), actual)
+	}
+}
+
+func TestDrawPileComposition(t *testing.T) {
+	game := newTestDeckGame()
+	unknown := []cards.Card{cards.TBD, cards.TBD, cards.TBD, cards.TBD}
+	checkDrawPileComposition(t, game, 0, unknown)
+	checkDrawPileComposition(t, game, 1, unknown)
+
+	// Player 0 sees [DrawFromTheBottom, ExplodingKitten, Cat].
+	node := playCard(t, game, cards.SeeTheFuture)
+	checkDrawPileComposition(t, node, 0, []cards.Card{
+		cards.DrawFromTheBottom, cards.ExplodingKitten, cards.Cat, cards.TBD,
+	})
+	checkDrawPileComposition(t, node, 1, unknown)
+
+	// Player 0 draws the DrawFromTheBottom, and then draws the last unseen
+	// card from the bottom, leaving [ExplodingKitten, Cat].
+	node = drawCard(t, node)
+	node = playCard(t, node, cards.Skip)
+	node = playCard(t, node, cards.DrawFromTheBottom)
+	checkDrawPileComposition(t, node, 0, []cards.Card{cards.ExplodingKitten, cards.Cat})
+	checkDrawPileComposition(t, node, 1, []cards.Card{cards.TBD, cards.TBD})
+
+	// Player 1 holds a single card, so at least 2 of the 3 Cats
+	// that player 0 has not seen must be in the draw pile.
+	drawPile := cards.NewStackFromCards([]cards.Card{
+		cards.Cat, cards.Cat, cards.ExplodingKitten, cards.Cat,
+	})
+	p0Deal := cards.NewSetFromCards([]cards.Card{cards.Defuse})
+	p1Deal := cards.NewSetFromCards([]cards.Card{cards.Defuse})
+	game = NewGame(drawPile, p0Deal, p1Deal)
+	checkDrawPileComposition(t, game, 0, []cards.Card{cards.Cat, cards.Cat, cards.TBD, cards.TBD})
+}
+
+func TestUncertainty(t *testing.T) {
+	game := newTestDeckGame()
+	for player := 0; player < 2; player++ {
+		if u := game.Uncertainty(player); u != 1 {
+			t.Errorf("player %d: expected uncertainty 1 at the start of the game, got %v", player, u)
+		}
+	}
+
+	// Player 0 sees 3 of the 4 cards in the draw pile, leaving the 3 cards
+	// in player 1's hand and the bottom card unknown.
+	node := playCard(t, game, cards.SeeTheFuture)
+	if u := node.Uncertainty(0); math.Abs(u-4.0/7) > 1e-9 {
+		t.Errorf("expected uncertainty 4/7 after SeeTheFuture, got %v", u)
+	}
+
+	// Player 0 knows the remaining draw pile, [ExplodingKitten, Cat],
+	// but not the 2 cards left in player 1's hand.
+	node = drawCard(t, node)
+	node = playCard(t, node, cards.Skip)
+	node = playCard(t, node, cards.DrawFromTheBottom)
+	if u := node.Uncertainty(0); u != 0.5 {
+		t.Errorf("expected uncertainty 0.5 near the end of the game, got %v", u)
+	}
+	if u := node.Uncertainty(1); u != 1 {
+		t.Errorf("expected uncertainty 1 for player 1, got %v", u)
+	}
+
+	// Uncertainty is always a valid fraction (and Uncertainty panics if
+	// the unknown cards do not fill the unknown positions).
+	rng := rand.New(rand.NewSource(123))
+	for i := 0; i < 100; i++ {
+		deal := NewRandomDeal(cards.CoreDeck.AsSlice(), 4)
+		var node cfr.GameTreeNode = NewGame(deal.DrawPile, deal.P0Deal, deal.P1Deal)
+		for node.Type() != cfr.TerminalNodeType {
+			for player := 0; player < 2; player++ {
+				if u := node.(*GameNode).Uncertainty(player); u < 0 || u > 1 {
+					t.Fatalf("%v: invalid uncertainty for player %d: %v", node, player, u)
+				}
+			}
+
+			node = node.GetChild(rng.Intn(node.NumChildren()))
+		}
+	}
+}
+
+func TestGivenCards(t *testing.T) {
+	drawPile := cards.NewStackFromCards([]cards.Card{
+		cards.Cat, cards.SeeTheFuture, cards.ExplodingKitten, cards.Defuse,
+	})
+	p0Deal := cards.NewSetFromCards([]cards.Card{cards.Cat, cards.Defuse})
+	p1Deal := cards.NewSetFromCards([]cards.Card{cards.Skip, cards.Slap1x, cards.Defuse})
+	game := NewGame(drawPile, p0Deal, p1Deal)
+
+	// Player 0 plays a Cat, and player 1 gives them the Skip.
+	node, err := playCard(t, game, cards.Cat).Step(gamestate.Action{
+		Player: gamestate.Player1,
+		Type:   gamestate.GiveCard,
+		Card:   cards.Skip,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Player 1 knows that player 0 holds the Skip, and that the other card
+	// in their hand is one of the 5 cards unknown to player 1.
+	checkOpponentHand(t, node, 1, cards.NewSetFromCards([]cards.Card{cards.Skip}), false)
+	checkProbabilities(t, map[cards.Card]float64{
+		cards.Cat:             1.0 / 5,
+		cards.SeeTheFuture:    1.0 / 5,
+		cards.ExplodingKitten: 1.0 / 5,
+		cards.Defuse:          2.0 / 5,
+	}, node.DrawProbabilities(1))
+	checkPossibleActions(t, node, 1, map[cards.Card]float64{
+		cards.Skip:         1.0,
+		cards.Cat:          1.0 / 5,
+		cards.SeeTheFuture: 1.0 / 5,
+	})
+	// Player 0 has room for only one of the 2 Defuses that player 1 has
+	// not seen, so the other must be in the draw pile.
+	checkDrawPileComposition(t, node, 1, []cards.Card{cards.Defuse, cards.TBD, cards.TBD, cards.TBD})
+	if u := node.Uncertainty(1); math.Abs(u-5.0/6) > 1e-9 {
+		t.Errorf("expected uncertainty 5/6, got %v", u)
+	}
+
+	// Once player 0 plays the Skip, nothing is known about their hand.
+	node = playCard(t, node, cards.Skip)
+	checkOpponentHand(t, node, 1, cards.NewSet(), false)
+	if u := node.Uncertainty(1); u != 1 {
+		t.Errorf("expected uncertainty 1 after the given card is played, got %v", u)
+	}
+}
@@ -0,0 +1,52 @@
+package alphacats
+
+import (
+	"sync"
+)
+
+// Logger receives the log messages of this package. By default, all messages
+// are discarded. Use SetLogger to route them elsewhere.
+type Logger interface {
+	// V reports whether verbose messages at the given level are enabled.
+	V(level int) bool
+	Infof(format string, args ...interface{})
+	Warningf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+var (
+	loggerMx  sync.RWMutex
+	pkgLogger Logger = nopLogger{}
+)
+
+// SetLogger sets the Logger used by this package. If l is nil,
+// log messages are discarded.
+func SetLogger(l Logger) {
+	if l == nil {
+		l = nopLogger{}
+	}
+
+	loggerMx.Lock()
+	defer loggerMx.Unlock()
+	pkgLogger = l
+}
+
+func logger() Logger {
+	loggerMx.RLock()
+	defer loggerMx.RUnlock()
+	return pkgLogger
+}
+
+// vlogf logs an info message if verbose logging is enabled at the given level.
+func vlogf(level int, format string, args ...interface{}) {
+	if l := logger(); l.V(level) {
+		l.Infof(format, args...)
+	}
+}
+
+type nopLogger struct{}
+
+func (nopLogger) V(level int) bool                            { return false }
+func (nopLogger) Infof(format string, args ...interface{})    {}
+func (nopLogger) Warningf(format string, args ...interface{}) {}
+func (nopLogger) Errorf(format string, args ...interface{})   {}
@@ -0,0 +1,98 @@
+package alphacats
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/timpalpant/alphacats/cards"
+	"github.com/timpalpant/alphacats/gamestate"
+)
+
+type recordingLogger struct {
+	mx       sync.Mutex
+	level    int
+	messages []string
+}
+
+func (l *recordingLogger) V(level int) bool {
+	return level <= l.level
+}
+
+func (l *recordingLogger) record(severity, format string, args ...interface{}) {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	l.messages = append(l.messages, severity+": "+fmt.Sprintf(format, args...))
+}
+
+func (l *recordingLogger) Infof(format string, args ...interface{}) {
+	l.record("I", format, args...)
+}
+
+func (l *recordingLogger) Warningf(format string, args ...interface{}) {
+	l.record("W", format, args...)
+}
+
+func (l *recordingLogger) Errorf(format string, args ...interface{}) {
+	l.record("E", format, args...)
+}
+
+func (l *recordingLogger) contains(prefix string) bool {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	for _, msg := range l.messages {
+		if strings.HasPrefix(msg, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func TestSetLogger(t *testing.T) {
+	l := &recordingLogger{level: 1}
+	SetLogger(l)
+	defer SetLogger(nil)
+
+	vlogf(1, "enabled %d", 1)
+	vlogf(2, "disabled %d", 2)
+	if !l.contains("I: enabled 1") {
+		t.Errorf("expected enabled verbose message to be logged: %v", l.messages)
+	}
+	if l.contains("I: disabled") {
+		t.Errorf("expected disabled verbose message to be discarded: %v", l.messages)
+	}
+
+	SetLogger(nil)
+	vlogf(0, "discarded")
+	if l.contains("I: discarded") {
+		t.Errorf("expected message to be discarded after resetting logger: %v", l.messages)
+	}
+}
+
+func TestLoggerReceivesInvalidBeliefWarning(t *testing.T) {
+	l := &recordingLogger{}
+	SetLogger(l)
+	defer SetLogger(nil)
+
+	deal := NewRandomDeal(cards.CoreDeck.AsSlice(), 4)
+	game := NewGame(deal.DrawPile, deal.P0Deal, deal.P1Deal)
+	is := game.GetInfoSet(gamestate.Player0)
+	beliefs := NewBeliefState(uniformPolicy, is)
+	beliefs.EnableValidation()
+
+	// Inject a state in which the opponent holds an extra card.
+	invalid := beliefs.states[0].GetState()
+	opponentHand := invalid.GetPlayerHand(gamestate.Player1)
+	opponentHand.Add(cards.Cat)
+	bad := beliefs.states[0].CloneWithState(gamestate.New(
+		invalid.GetDrawPile(), invalid.GetPlayerHand(gamestate.Player0), opponentHand))
+	beliefs.states = append(beliefs.states, bad)
+	beliefs.reachProbs = append(beliefs.reachProbs, beliefs.reachProbs[0])
+
+	beliefs.Update(is)
+	if !l.contains("W: Dropping invalid belief state") {
+		t.Errorf("expected warning about dropped belief state, got: %v", l.messages)
+	}
+}
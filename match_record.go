@@ -0,0 +1,110 @@
+package alphacats
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+
+	"github.com/timpalpant/go-cfr"
+)
+
+// MatchRecord captures everything needed to regenerate one game exactly:
+// the initial deal, and the outcome sampled at every chance node and every
+// decision of the players. Unlike the history of a game (see Replay), this
+// includes the outcome of shuffles and the seed the game was played with, so
+// a game reported in a bug can be reproduced without the strategies.
+type MatchRecord struct {
+	// Game is the initial deal, in the format accepted by NewGameFromSpec.
+	Game string `json:"game"`
+	// Seed used to sample chance outcomes and actions.
+	Seed int64 `json:"seed"`
+	// Strategies identifies the strategy played by each player,
+	// e.g. by a hash of the file it was loaded from.
+	Strategies [2]string `json:"strategies"`
+	// Choices is the index of the child taken at each node of the game.
+	Choices []int `json:"choices"`
+	Winner  int   `json:"winner"`
+}
+
+// PlayMatch plays out a game from the given deal, with each player following
+// their policy, and records it. The game is deterministic given the seed,
+// provided that both policies are too.
+func PlayMatch(deal Deal, seed int64, strategies [2]string, policies [2]func(cfr.GameTreeNode) []float32) (*MatchRecord, *GameNode) {
+	record := &MatchRecord{
+		Game:       fmt.Sprintf("%v; %v; %v", deal.DrawPile, deal.P0Deal, deal.P1Deal),
+		Seed:       seed,
+		Strategies: strategies,
+	}
+
+	rng := rand.New(rand.NewSource(seed))
+	var game cfr.GameTreeNode = NewGame(deal.DrawPile, deal.P0Deal, deal.P1Deal)
+	for game.Type() != cfr.TerminalNodeType {
+		var selected int
+		if game.Type() == cfr.ChanceNodeType {
+			// All chance nodes are uniform random over their children,
+			// since NewGame enumerates every ordering of shuffles.
+			selected = rng.Intn(game.NumChildren())
+		} else {
+			p := policies[game.Player()](game)
+			selected = SampleAction(p, rng.Float32())
+		}
+
+		record.Choices = append(record.Choices, selected)
+		game = game.GetChild(selected)
+	}
+
+	record.Winner = game.Player()
+	return record, game.(*GameNode)
+}
+
+// Replay regenerates the recorded game, returning its terminal node.
+// An error is returned if the record does not describe a complete game
+// with the recorded winner.
+func (m *MatchRecord) Replay() (*GameNode, error) {
+	game, err := NewGameFromSpec(m.Game)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, selected := range m.Choices {
+		if game.Type() == cfr.TerminalNodeType {
+			return nil, fmt.Errorf("game ended after %d of %d choices", i, len(m.Choices))
+		}
+
+		if n := game.NumChildren(); selected < 0 || selected >= n {
+			return nil, fmt.Errorf("choice %d (%d) is out of range, node has %d children: %v",
+				i, selected, n, game)
+		}
+
+		game = game.GetChild(selected).(*GameNode)
+	}
+
+	if game.Type() != cfr.TerminalNodeType {
+		return nil, fmt.Errorf("game did not end after all %d choices: %v", len(m.Choices), game)
+	}
+
+	if game.Player() != m.Winner {
+		return nil, fmt.Errorf("player %d won the replayed game, expected player %d",
+			game.Player(), m.Winner)
+	}
+
+	return game, nil
+}
+
+// Save writes the record as JSON.
+func (m *MatchRecord) Save(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(m)
+}
+
+// LoadMatchRecord reads a record written by Save.
+func LoadMatchRecord(r io.Reader) (*MatchRecord, error) {
+	var m MatchRecord
+	if err := json.NewDecoder(r).Decode(&m); err != nil {
+		return nil, err
+	}
+
+	return &m, nil
+}
@@ -0,0 +1,81 @@
+package alphacats
+
+import (
+	"bytes"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/timpalpant/go-cfr"
+
+	"github.com/timpalpant/alphacats/cards"
+)
+
+func TestMatchRecordRoundTrip(t *testing.T) {
+	rand.Seed(123)
+	// NB: Shuffle is included so that the game has chance nodes.
+	deck := append(cards.CoreDeck.AsSlice(), cards.Shuffle, cards.Shuffle)
+	policies := [2]func(cfr.GameTreeNode) []float32{uniformPolicy, uniformPolicy}
+	for i := 0; i < 10; i++ {
+		deal := NewRandomDeal(deck, 4)
+		record, game := PlayMatch(deal, rand.Int63(), [2]string{"a", "b"}, policies)
+
+		var buf bytes.Buffer
+		if err := record.Save(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		loaded, err := LoadMatchRecord(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(loaded, record) {
+			t.Errorf("expected %+v, got %+v", record, loaded)
+		}
+
+		replayed, err := loaded.Replay()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if replayed.state != game.state || replayed.gameOverReason != game.gameOverReason {
+			t.Errorf("expected replay to reach %v, got %v", game, replayed)
+		}
+
+		// The same seed plays the same game.
+		again, _ := PlayMatch(deal, record.Seed, record.Strategies, policies)
+		if !reflect.DeepEqual(again, record) {
+			t.Errorf("expected %+v, got %+v", record, again)
+		}
+	}
+}
+
+func TestMatchRecordReplayErrors(t *testing.T) {
+	deal := Deal{
+		DrawPile: cards.NewStackFromCards([]cards.Card{cards.Cat, cards.ExplodingKitten}),
+		P0Deal:   cards.NewSetFromCards([]cards.Card{cards.Skip}),
+	}
+	policies := [2]func(cfr.GameTreeNode) []float32{alwaysSkipPolicy, alwaysSkipPolicy}
+	record, _ := PlayMatch(deal, 123, [2]string{}, policies)
+	if _, err := record.Replay(); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []func(m *MatchRecord){
+		func(m *MatchRecord) { m.Choices = m.Choices[:len(m.Choices)-1] },
+		func(m *MatchRecord) { m.Choices = append(m.Choices, 0) },
+		func(m *MatchRecord) { m.Choices[0] = 100 },
+		func(m *MatchRecord) { m.Winner = 1 - m.Winner },
+		func(m *MatchRecord) { m.Game = "invalid" },
+	}
+
+	for i, corrupt := range testCases {
+		m := *record
+		m.Choices = append([]int(nil), record.Choices...)
+		corrupt(&m)
+		if _, err := m.Replay(); err == nil {
+			t.Errorf("case %d: expected error replaying %+v", i, m)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+package model
+
+import (
+	"github.com/timpalpant/alphacats"
+)
+
+// BatchPredictor predicts the policy and value for many info sets at once,
+// amortizing the cost of inference over the batch.
+//
+// NB: TrainedLSTM also batches concurrent calls to Predict (for example, from
+// the leaves of parallel MCTS simulations), so the model-backed search does
+// not need to collect its leaves into batches itself.
+type BatchPredictor interface {
+	PredictBatch(infoSets []*alphacats.AbstractedInfoSet) (policies [][]float32, values []float32)
+}
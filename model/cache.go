@@ -0,0 +1,65 @@
+package model
+
+import (
+	"expvar"
+
+	"github.com/hashicorp/golang-lru"
+	"github.com/timpalpant/go-cfr"
+	"github.com/timpalpant/go-cfr/mcts"
+)
+
+var (
+	policyCacheHits    = expvar.NewInt("policy_cache/hits")
+	policyCacheMisses  = expvar.NewInt("policy_cache/misses")
+	policyCacheHitRate = expvar.NewFloat("policy_cache/hit_rate")
+)
+
+// CachedPolicy wraps a mcts.Policy with an LRU cache keyed by info set,
+// so that repeated queries for the same info set are not recomputed.
+// It is safe to call GetPolicy concurrently if the wrapped policy is.
+// The policies returned are copies, so callers may modify them without
+// corrupting the cache.
+type CachedPolicy struct {
+	policy mcts.Policy
+	cache  *lru.Cache
+}
+
+func NewCachedPolicy(policy mcts.Policy, cacheSize int) *CachedPolicy {
+	cache, err := lru.New(cacheSize)
+	if err != nil {
+		panic(err)
+	}
+
+	return &CachedPolicy{
+		policy: policy,
+		cache:  cache,
+	}
+}
+
+// GetPolicy implements mcts.Policy.
+func (cp *CachedPolicy) GetPolicy(node cfr.GameTreeNode) []float32 {
+	key := string(node.InfoSet(node.Player()).Key())
+	if cached, ok := cp.cache.Get(key); ok {
+		policyCacheHits.Add(1)
+		updatePolicyCacheHitRate()
+		return copyPolicy(cached.([]float32))
+	}
+
+	policyCacheMisses.Add(1)
+	updatePolicyCacheHitRate()
+	p := cp.policy.GetPolicy(node)
+	cp.cache.Add(key, copyPolicy(p))
+	return p
+}
+
+func copyPolicy(p []float32) []float32 {
+	result := make([]float32, len(p))
+	copy(result, p)
+	return result
+}
+
+func updatePolicyCacheHitRate() {
+	hits := float64(policyCacheHits.Value())
+	misses := float64(policyCacheMisses.Value())
+	policyCacheHitRate.Set(hits / (hits + misses))
+}
@@ -0,0 +1,63 @@
+package model
+
+import (
+	"reflect"
+	"sync/atomic"
+	"testing"
+
+	"github.com/timpalpant/go-cfr"
+
+	"github.com/timpalpant/alphacats"
+	"github.com/timpalpant/alphacats/cards"
+)
+
+// Returns a distinct, deterministic distribution for each node,
+// counting the number of times it has been called.
+type countingPolicy struct {
+	calls int64
+}
+
+func (p *countingPolicy) GetPolicy(node cfr.GameTreeNode) []float32 {
+	atomic.AddInt64(&p.calls, 1)
+	result := make([]float32, node.NumChildren())
+	for i := range result {
+		result[i] = float32(i+1) / float32(len(result))
+	}
+	return result
+}
+
+func TestCachedPolicy(t *testing.T) {
+	deal := alphacats.NewRandomDeal(cards.CoreDeck.AsSlice(), 4)
+	game := alphacats.NewGame(deal.DrawPile, deal.P0Deal, deal.P1Deal)
+	policy := &countingPolicy{}
+	cached := NewCachedPolicy(policy, 10)
+
+	nodes := []cfr.GameTreeNode{game}
+	for i := 0; i < game.NumChildren(); i++ {
+		nodes = append(nodes, game.GetChild(i))
+	}
+
+	var nPlayerNodes int64
+	for _, node := range nodes {
+		if node.Type() != cfr.PlayerNodeType {
+			continue
+		}
+
+		nPlayerNodes++
+		expected := policy.GetPolicy(node)
+		for i := 0; i < 3; i++ {
+			p := cached.GetPolicy(node)
+			if !reflect.DeepEqual(p, expected) {
+				t.Errorf("expected cached policy %v, got %v", expected, p)
+			}
+
+			// Modifying the returned policy must not corrupt the cache.
+			p[0] = -1
+		}
+	}
+
+	// Each info set is computed once by the cache, and once directly above.
+	if policy.calls != 2*nPlayerNodes {
+		t.Errorf("expected %d calls to the underlying policy, got %d", 2*nPlayerNodes, policy.calls)
+	}
+}
@@ -0,0 +1,253 @@
+package model
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/timpalpant/go-cfr"
+
+	"github.com/timpalpant/alphacats"
+)
+
+// AdvantageSample is a training sample for the advantage network of Deep CFR
+// (Brown et al., 2019): the sampled regret of each available action at an
+// info set visited by the traversing player.
+type AdvantageSample struct {
+	InfoSet alphacats.AbstractedInfoSet
+	// Advantages has one entry for each of InfoSet.AvailableActions,
+	// in the same order (like Sample.Policy).
+	Advantages []float32
+	// Iteration is the CFR iteration in which the sample was collected.
+	// Samples are weighted by their iteration during training (Linear CFR).
+	Iteration int
+}
+
+// AdvantagePolicy is the strategy of Deep CFR, which replaces the tabular
+// strategy profile: it plays regret matching over the advantages predicted
+// for the available actions by a BatchPredictor.
+//
+// NOTE: The predictor must return the raw (unnormalized) advantages of each
+// available action. TrainedLSTM renormalizes its outputs over the available
+// actions, so it cannot be used as an advantage network as is.
+type AdvantagePolicy struct {
+	predictor BatchPredictor
+}
+
+func NewAdvantagePolicy(predictor BatchPredictor) *AdvantagePolicy {
+	return &AdvantagePolicy{predictor}
+}
+
+// GetPolicy implements mcts.Policy.
+func (ap *AdvantagePolicy) GetPolicy(node cfr.GameTreeNode) []float32 {
+	return ap.GetPolicies([]cfr.GameTreeNode{node})[0]
+}
+
+// GetPolicies returns the policy at each of the given nodes, predicting
+// their advantages in a single batch.
+func (ap *AdvantagePolicy) GetPolicies(nodes []cfr.GameTreeNode) [][]float32 {
+	infoSets := make([]*alphacats.AbstractedInfoSet, len(nodes))
+	for i, node := range nodes {
+		infoSets[i] = node.InfoSet(node.Player()).(*alphacats.AbstractedInfoSet)
+	}
+
+	advantages, _ := ap.predictor.PredictBatch(infoSets)
+	result := make([][]float32, len(nodes))
+	for i, node := range nodes {
+		result[i] = regretMatching(advantages[i], node.NumChildren())
+	}
+
+	return result
+}
+
+// Returns the policy proportional to the positive part of the given
+// advantages, or uniform if none of them are positive.
+func regretMatching(advantages []float32, n int) []float32 {
+	if len(advantages) != n {
+		panic(fmt.Errorf("predicted %d advantages, but node has %d children", len(advantages), n))
+	}
+
+	result := make([]float32, n)
+	total := float32(0.0)
+	for i, v := range advantages {
+		if v > 0 {
+			result[i] = v
+			total += v
+		}
+	}
+
+	if total <= 0 {
+		return uniformDistribution(n)
+	}
+
+	for i := range result {
+		result[i] /= total
+	}
+
+	return result
+}
+
+// AdvantageCollector collects AdvantageSamples for Deep CFR by external
+// sampling traversals of the game tree: chance outcomes and the actions of
+// the opponent are sampled, and every action of the traversing player
+// is explored.
+type AdvantageCollector struct {
+	// Strategy is the current strategy of both players,
+	// such as AdvantagePolicy.GetPolicy.
+	Strategy func(node cfr.GameTreeNode) []float32
+	rng      *rand.Rand
+
+	samples []AdvantageSample
+}
+
+func NewAdvantageCollector(strategy func(node cfr.GameTreeNode) []float32, rng *rand.Rand) *AdvantageCollector {
+	return &AdvantageCollector{
+		Strategy: strategy,
+		rng:      rng,
+	}
+}
+
+// Traverse runs one traversal of the tree below node for the given player,
+// collecting a sample at each of their decision nodes visited, and returns
+// the sampled value of node to the player.
+func (c *AdvantageCollector) Traverse(node cfr.GameTreeNode, player, iteration int) float32 {
+	switch node.Type() {
+	case cfr.TerminalNodeType:
+		return float32(node.Utility(player))
+	case cfr.ChanceNodeType:
+		child, _ := node.(*alphacats.GameNode).SampleChildWithRand(c.rng)
+		value := c.Traverse(child, player, iteration)
+		child.Close()
+		return value
+	}
+
+	policy := c.Strategy(node)
+	if node.Player() != player {
+		child := node.GetChild(alphacats.SampleAction(policy, c.rng.Float32()))
+		value := c.Traverse(child, player, iteration)
+		child.Close()
+		return value
+	}
+
+	values := make([]float32, node.NumChildren())
+	var value float32
+	for i := range values {
+		child := node.GetChild(i)
+		values[i] = c.Traverse(child, player, iteration)
+		child.Close()
+		value += policy[i] * values[i]
+	}
+
+	advantages := make([]float32, len(values))
+	for i, v := range values {
+		advantages[i] = v - value
+	}
+
+	is := node.InfoSet(player).(*alphacats.AbstractedInfoSet)
+	c.samples = append(c.samples, AdvantageSample{
+		InfoSet:    *is,
+		Advantages: advantages,
+		Iteration:  iteration,
+	})
+
+	return value
+}
+
+// Samples returns the samples collected so far, and resets the collector.
+func (c *AdvantageCollector) Samples() []AdvantageSample {
+	result := c.samples
+	c.samples = nil
+	return result
+}
+
+// AdvantageTrainer trains the advantage networks of Deep CFR.
+type AdvantageTrainer interface {
+	// Train returns a network trained on the given samples, collected at
+	// the info sets of the given player, to predict their advantages
+	// (see AdvantagePolicy).
+	Train(player int, samples []AdvantageSample) BatchPredictor
+}
+
+// DeepCFR runs Deep CFR (Brown et al., 2019), in which a network for each
+// player approximates their strategy in place of the tabular strategy
+// profile of CFR. In each iteration, advantage samples are collected for
+// each player by external sampling traversals (see AdvantageCollector) under
+// the current strategy, and the player's network is retrained on all of the
+// samples collected for them so far (up to a reservoir of maxSamples).
+//
+// NOTE: GetPolicy returns the current strategy. The average strategy,
+// which converges to equilibrium, is not trained.
+type DeepCFR struct {
+	newGame                func(rng *rand.Rand) cfr.GameTreeNode
+	trainer                AdvantageTrainer
+	traversalsPerIteration int
+	maxSamples             int
+	rng                    *rand.Rand
+
+	policies [2]*AdvantagePolicy
+	samples  [2][]AdvantageSample
+	// The total number of samples collected for each player,
+	// including those that were not kept in the reservoir.
+	numSamples [2]int
+	iter       int
+}
+
+// NewDeepCFR returns a Deep CFR optimizer that traverses the games returned
+// by newGame (for example, random deals). Both players play uniformly at
+// random until their first network has been trained.
+func NewDeepCFR(newGame func(rng *rand.Rand) cfr.GameTreeNode, trainer AdvantageTrainer,
+	traversalsPerIteration, maxSamples int, rng *rand.Rand) *DeepCFR {
+	return &DeepCFR{
+		newGame:                newGame,
+		trainer:                trainer,
+		traversalsPerIteration: traversalsPerIteration,
+		maxSamples:             maxSamples,
+		rng:                    rng,
+	}
+}
+
+// Iterations returns the number of iterations that have been run.
+func (d *DeepCFR) Iterations() int {
+	return d.iter
+}
+
+// Run performs one iteration of Deep CFR, updating the network of each player.
+func (d *DeepCFR) Run() {
+	d.iter++
+	for player := 0; player < 2; player++ {
+		collector := NewAdvantageCollector(d.GetPolicy, d.rng)
+		for k := 0; k < d.traversalsPerIteration; k++ {
+			game := d.newGame(d.rng)
+			collector.Traverse(game, player, d.iter)
+			game.Close()
+		}
+
+		for _, sample := range collector.Samples() {
+			d.addSample(player, sample)
+		}
+
+		predictor := d.trainer.Train(player, d.samples[player])
+		d.policies[player] = NewAdvantagePolicy(predictor)
+	}
+}
+
+// Adds the sample to the player's reservoir, so that the reservoir is a
+// uniform sample of all of the samples collected for them.
+func (d *DeepCFR) addSample(player int, sample AdvantageSample) {
+	d.numSamples[player]++
+	if len(d.samples[player]) < d.maxSamples {
+		d.samples[player] = append(d.samples[player], sample)
+	} else if i := d.rng.Intn(d.numSamples[player]); i < d.maxSamples {
+		d.samples[player][i] = sample
+	}
+}
+
+// GetPolicy implements mcts.Policy, returning the current strategy of the
+// player to act in node.
+func (d *DeepCFR) GetPolicy(node cfr.GameTreeNode) []float32 {
+	policy := d.policies[node.Player()]
+	if policy == nil {
+		return uniformDistribution(node.NumChildren())
+	}
+
+	return policy.GetPolicy(node)
+}
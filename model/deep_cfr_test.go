@@ -0,0 +1,198 @@
+package model
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/timpalpant/go-cfr"
+
+	"github.com/timpalpant/alphacats"
+	"github.com/timpalpant/alphacats/gamestate"
+	"github.com/timpalpant/alphacats/internal/testgames"
+)
+
+// Predicts equal advantages for all available actions.
+type uniformAdvantagePredictor struct{}
+
+func (p uniformAdvantagePredictor) PredictBatch(infoSets []*alphacats.AbstractedInfoSet) ([][]float32, []float32) {
+	advantages := make([][]float32, len(infoSets))
+	for i, is := range infoSets {
+		advantages[i] = make([]float32, len(is.AvailableActions))
+		for j := range advantages[i] {
+			advantages[i][j] = 1.0
+		}
+	}
+
+	return advantages, make([]float32, len(infoSets))
+}
+
+func newTestDeckGame() *alphacats.GameNode {
+	return alphacats.NewGame(testgames.TestDeck())
+}
+
+func TestAdvantagePolicyUniform(t *testing.T) {
+	policy := NewAdvantagePolicy(uniformAdvantagePredictor{})
+	rng := rand.New(rand.NewSource(123))
+	for i := 0; i < 20; i++ {
+		var node cfr.GameTreeNode = newTestDeckGame()
+		for node.Type() != cfr.TerminalNodeType {
+			if node.Type() == cfr.ChanceNodeType {
+				node, _ = node.SampleChild()
+				continue
+			}
+
+			p := policy.GetPolicy(node)
+			expected := uniformDistribution(node.NumChildren())
+			for j := range expected {
+				if p[j] != expected[j] {
+					t.Fatalf("expected uniform policy %v, got %v", expected, p)
+				}
+			}
+
+			node = node.GetChild(rng.Intn(node.NumChildren()))
+		}
+	}
+}
+
+func TestRegretMatching(t *testing.T) {
+	p := regretMatching([]float32{-1.0, 1.0, 3.0}, 3)
+	expected := []float32{0.0, 0.25, 0.75}
+	for i := range expected {
+		if p[i] != expected[i] {
+			t.Errorf("expected %v, got %v", expected, p)
+		}
+	}
+
+	p = regretMatching([]float32{-1.0, 0.0}, 2)
+	if p[0] != 0.5 || p[1] != 0.5 {
+		t.Errorf("expected uniform policy without positive regrets, got %v", p)
+	}
+}
+
+func TestAdvantageCollector(t *testing.T) {
+	policy := NewAdvantagePolicy(uniformAdvantagePredictor{})
+	collector := NewAdvantageCollector(policy.GetPolicy, rand.New(rand.NewSource(123)))
+	for player := 0; player < 2; player++ {
+		collector.Traverse(newTestDeckGame(), player, 1)
+		samples := collector.Samples()
+		if len(samples) == 0 {
+			t.Fatalf("expected samples for player %d", player)
+		}
+
+		for _, sample := range samples {
+			if n := len(sample.InfoSet.AvailableActions); len(sample.Advantages) != n {
+				t.Errorf("expected %d advantages, got %d", n, len(sample.Advantages))
+			}
+
+			if sample.Iteration != 1 {
+				t.Errorf("expected iteration 1, got %d", sample.Iteration)
+			}
+
+			// Advantages under the uniform strategy sum to zero.
+			var total float32
+			for _, v := range sample.Advantages {
+				total += v
+			}
+			if total > 1e-4 || total < -1e-4 {
+				t.Errorf("expected advantages to sum to 0, got %v", sample.Advantages)
+			}
+		}
+	}
+
+	if samples := collector.Samples(); len(samples) != 0 {
+		t.Errorf("expected collector to be reset, got %d samples", len(samples))
+	}
+}
+
+// Trains a "network" that predicts the mean advantages of each info set
+// in its samples, and equal advantages for info sets it has not seen.
+type meanAdvantageTrainer struct {
+	calls [2]int
+}
+
+func (tr *meanAdvantageTrainer) Train(player int, samples []AdvantageSample) BatchPredictor {
+	tr.calls[player]++
+	totals := make(map[string][]float32)
+	counts := make(map[string]float32)
+	for _, s := range samples {
+		key := string(s.InfoSet.Key())
+		if totals[key] == nil {
+			totals[key] = make([]float32, len(s.Advantages))
+		}
+		for i, v := range s.Advantages {
+			totals[key][i] += v
+		}
+		counts[key]++
+	}
+
+	for key, total := range totals {
+		for i := range total {
+			total[i] /= counts[key]
+		}
+	}
+
+	return meanAdvantagePredictor(totals)
+}
+
+type meanAdvantagePredictor map[string][]float32
+
+func (p meanAdvantagePredictor) PredictBatch(infoSets []*alphacats.AbstractedInfoSet) ([][]float32, []float32) {
+	advantages, _ := uniformAdvantagePredictor{}.PredictBatch(infoSets)
+	for i, is := range infoSets {
+		if mean, ok := p[string(is.Key())]; ok {
+			advantages[i] = mean
+		}
+	}
+
+	return advantages, make([]float32, len(infoSets))
+}
+
+func TestDeepCFR(t *testing.T) {
+	trainer := &meanAdvantageTrainer{}
+	const maxSamples = 50
+	newGame := func(rng *rand.Rand) cfr.GameTreeNode { return newTestDeckGame() }
+	deepCFR := NewDeepCFR(newGame, trainer, 5, maxSamples, rand.New(rand.NewSource(123)))
+
+	// Uniform until the networks are trained.
+	game := newTestDeckGame()
+	if p := deepCFR.GetPolicy(game); !reflect.DeepEqual(p, uniformDistribution(game.NumChildren())) {
+		t.Errorf("expected uniform policy before training, got %v", p)
+	}
+
+	const n = 5
+	for i := 0; i < n; i++ {
+		deepCFR.Run()
+	}
+
+	if deepCFR.Iterations() != n {
+		t.Errorf("expected %d iterations, got %d", n, deepCFR.Iterations())
+	}
+
+	for player := 0; player < 2; player++ {
+		if trainer.calls[player] != n {
+			t.Errorf("expected player %d network to be trained %d times, got %d",
+				player, n, trainer.calls[player])
+		}
+
+		if len(deepCFR.samples[player]) != maxSamples || deepCFR.numSamples[player] <= maxSamples {
+			t.Errorf("expected reservoir of %d of %d samples for player %d, got %d",
+				maxSamples, deepCFR.numSamples[player], player, len(deepCFR.samples[player]))
+		}
+
+		for _, sample := range deepCFR.samples[player] {
+			if sample.InfoSet.Player != gamestate.Player(player) {
+				t.Errorf("player %d has sample for player %v", player, sample.InfoSet.Player)
+			}
+		}
+	}
+
+	p := deepCFR.GetPolicy(game)
+	var total float32
+	for _, x := range p {
+		total += x
+	}
+	if len(p) != game.NumChildren() || total < 0.999 || total > 1.001 {
+		t.Errorf("expected a distribution over %d actions, got %v", game.NumChildren(), p)
+	}
+}
@@ -3,6 +3,7 @@ package model
 import (
 	"fmt"
 
+	"github.com/timpalpant/alphacats"
 	"github.com/timpalpant/alphacats/cards"
 	"github.com/timpalpant/alphacats/gamestate"
 	"github.com/timpalpant/alphacats/model/internal/tffloats"
@@ -22,13 +23,47 @@ const (
 	numActionFeatures  = 16
 	numCardsInDeck     = 23
 	maxCardsInDrawPile = 13
-	// Vector size of output predictions: one for each card type,
-	// one for each insertion position, and one for drawing a card.
-	// One for each type of card to play or give.
-	maxInsertPositions = 8
-	outputDimension    = 2*cards.NumTypes + maxInsertPositions + 1
+	// The number of types of cards in the inputs and outputs of the network:
+	// Unknown through Cat, and TBD. The layouts are fixed to the card types
+	// from before the ImplodingKitten was added (see modelCardIndex), so that
+	// previously trained models (and train.py) remain compatible.
+	numModelCardTypes = 11
+	// Vector size of output predictions, laid out as alphacats.ActionSlot
+	// with numModelCardTypes card types (see modelSlot).
+	// NOTE: This is one larger than needed, for compatibility with
+	// previously trained models.
+	outputDimension = 2*numModelCardTypes + (alphacats.MaxActions - 2*cards.NumTypes) + 1
 )
 
+// modelCardIndex returns the index of the given card in the inputs and
+// outputs of the network. Models are only trained on games dealt from the
+// core deck, so the ImplodingKitten is not supported.
+func modelCardIndex(card cards.Card) int {
+	switch {
+	case card == cards.TBD:
+		return numModelCardTypes - 1
+	case card < cards.ImplodingKitten:
+		return int(card)
+	default:
+		panic(fmt.Errorf("%v is not supported by the model", card))
+	}
+}
+
+// modelSlot returns the index in the outputs of the network of the given
+// action, which is laid out as alphacats.ActionSlot, but with only
+// numModelCardTypes card types.
+func modelSlot(action gamestate.Action, numDrawPileCards int) int {
+	slot := alphacats.ActionSlot(action, numDrawPileCards)
+	switch {
+	case slot < cards.NumTypes: // Draw a card, or play each type of card.
+		return modelCardIndex(cards.Card(slot))
+	case slot < 2*cards.NumTypes: // Give each type of card.
+		return numModelCardTypes + modelCardIndex(cards.Card(slot-cards.NumTypes))
+	default: // Insert the exploding kitten.
+		return 2*numModelCardTypes + (slot - 2*cards.NumTypes)
+	}
+}
+
 func encodeHistoryTF(h gamestate.History, result []byte) {
 	// We encode actions directly, rather than reuse EncodeHistory,
 	// to avoid needing to allocate large intermediate one-hot [][]float32.
@@ -77,7 +112,7 @@ func encodeAction(action gamestate.Action, result []float32) {
 	clear(result)
 	result[int(action.Player)] = 1.0
 	result[2+int(action.Type)-1] = 1.0
-	result[6+int(action.Card)] = 1.0
+	result[6+modelCardIndex(action.Card)] = 1.0
 	// NOTE: Private action info is not included in the encoding.
 	// This is okay because our abstracted info set factors out private information
 	// about the draw pile and our hand separately.
@@ -112,7 +147,7 @@ func encodeHand(hand cards.Set, result []float32) {
 func newOneHotDrawPile() [][]float32 {
 	result := make([][]float32, maxCardsInDrawPile)
 	for i := range result {
-		result[i] = make([]float32, cards.NumTypes)
+		result[i] = make([]float32, numModelCardTypes)
 	}
 	return result
 }
@@ -122,11 +157,11 @@ func encodeDrawPileTF(drawPile cards.Stack, result []byte) {
 	// to avoid needing to allocate large intermediate one-hot [][]float32.
 	i := 0
 	drawPile.Iter(func(card cards.Card) {
-		encodeCardTF(drawPile.NthCard(i), result[4*cards.NumTypes*i:])
+		encodeCardTF(drawPile.NthCard(i), result[4*numModelCardTypes*i:])
 		i++
 	})
 
-	for idx := 4 * cards.NumTypes * i; idx < len(result); idx++ {
+	for idx := 4 * numModelCardTypes * i; idx < len(result); idx++ {
 		result[idx] = 0
 	}
 }
@@ -144,14 +179,14 @@ func encodeDrawPile(drawPile cards.Stack, result [][]float32) {
 }
 
 func encodeCardTF(card cards.Card, result []byte) {
-	var oneHot [cards.NumTypes]float32
+	var oneHot [numModelCardTypes]float32
 	encodeCard(card, oneHot[:])
 	tffloats.EncodeF32s(oneHot[:], result)
 }
 
 func encodeCard(card cards.Card, result []float32) {
 	clear(result)
-	result[card] = 1.0
+	result[modelCardIndex(card)] = 1.0
 }
 
 func encodeOutputMaskTF(numDrawPileCards int, availableActions []gamestate.Action, result []byte) {
@@ -163,88 +198,21 @@ func encodeOutputMaskTF(numDrawPileCards int, availableActions []gamestate.Actio
 func encodeOutputMask(numDrawPileCards int, availableActions []gamestate.Action, result []float32) {
 	clear(result)
 	for _, action := range availableActions {
-		switch action.Type {
-		case gamestate.DrawCard:
-			// First position is always the advantages of ending turn by drawing a card,
-			// since this corresponds to the "Unknown" card enum.
-			result[0] = 1.0
-		case gamestate.PlayCard:
-			// Next 10 positions correspond to playing each card type.
-			result[action.Card] = 1.0
-		case gamestate.GiveCard:
-			// Next 10 positions correspond to giving each card type.
-			result[cards.NumTypes+int(action.Card)] = 1.0
-		case gamestate.InsertExplodingKitten:
-			// Remaining correspond to inserting cat at each position.
-			// Position 0 -> insert on the bottom.
-			// Position 1 -> insert randomly.
-			// Position 2...N -> insert in the nth position.
-			idx := 2*cards.NumTypes + 1 + int(action.PositionInDrawPile)
-			if int(action.PositionInDrawPile) == numDrawPileCards+1 {
-				idx = 2 * cards.NumTypes
-			}
-
-			result[idx] = 1.0
-		default:
-			panic(fmt.Errorf("unsupported action: %v", action))
-		}
+		result[modelSlot(action, numDrawPileCards)] = 1.0
 	}
 }
 
 func encodeOutputs(numDrawPileCards int, availableActions []gamestate.Action, policy, result []float32) {
 	clear(result)
 	for i, action := range availableActions {
-		switch action.Type {
-		case gamestate.DrawCard:
-			// First position is always the advantages of ending turn by drawing a card,
-			// since this corresponds to the "Unknown" card enum.
-			result[0] = policy[i]
-		case gamestate.PlayCard:
-			// Next 10 positions correspond to playing each card type.
-			result[action.Card] = policy[i]
-		case gamestate.GiveCard:
-			// Next 10 positions correspond to giving each card type.
-			result[cards.NumTypes+int(action.Card)] = policy[i]
-		case gamestate.InsertExplodingKitten:
-			// Remaining correspond to inserting cat at each position.
-			// Position 0 -> insert on the bottom.
-			// Position 1 -> insert randomly.
-			// Position 2...N -> insert in the nth position.
-			idx := 2*cards.NumTypes + 1 + int(action.PositionInDrawPile)
-			if int(action.PositionInDrawPile) == numDrawPileCards+1 {
-				idx = 2 * cards.NumTypes
-			}
-
-			result[idx] = policy[i]
-		default:
-			panic(fmt.Errorf("unsupported action: %v", action))
-		}
+		result[modelSlot(action, numDrawPileCards)] = policy[i]
 	}
 }
 
 func decodeOutputs(numDrawPileCards int, availableActions []gamestate.Action, predictions []float32) []float32 {
 	policy := make([]float32, len(availableActions))
 	for i, action := range availableActions {
-		switch action.Type {
-		case gamestate.DrawCard:
-			// First position is always the advantages of ending turn by drawing a card,
-			// since this corresponds to the "Unknown" card enum.
-			policy[i] = predictions[0]
-		case gamestate.PlayCard:
-			// Next 9 positions correspond to playing/giving each card type.
-			policy[i] = predictions[action.Card]
-		case gamestate.GiveCard:
-			policy[i] = predictions[cards.NumTypes+int(action.Card)]
-		case gamestate.InsertExplodingKitten:
-			// Remaining correspond to inserting cat at each position.
-			idx := 2*cards.NumTypes + 1 + int(action.PositionInDrawPile)
-			if int(action.PositionInDrawPile) == numDrawPileCards+1 {
-				idx = 2 * cards.NumTypes
-			}
-			policy[i] = predictions[idx]
-		default:
-			panic(fmt.Errorf("unsupported action: %v", action))
-		}
+		policy[i] = predictions[modelSlot(action, numDrawPileCards)]
 	}
 
 	// Renormalize policy since some weight may have been given to invalid actions.
@@ -0,0 +1,54 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/timpalpant/alphacats/cards"
+	"github.com/timpalpant/alphacats/gamestate"
+)
+
+// The inputs and outputs of the network must keep the layout that previously
+// trained models (and train.py) were built with: 11 card types and 31 outputs.
+func TestModelLayout(t *testing.T) {
+	if outputDimension != 31 {
+		t.Errorf("expected 31 outputs, got %d", outputDimension)
+	}
+
+	if n := len(newOneHotDrawPile()[0]); n != 11 {
+		t.Errorf("expected 11 card types in the draw pile, got %d", n)
+	}
+
+	const nDrawPileCards = 10
+	testCases := []struct {
+		action gamestate.Action
+		slot   int
+	}{
+		{gamestate.Action{Type: gamestate.DrawCard}, 0},
+		{gamestate.Action{Type: gamestate.PlayCard, Card: cards.Skip}, 3},
+		{gamestate.Action{Type: gamestate.PlayCard, Card: cards.Cat}, 9},
+		{gamestate.Action{Type: gamestate.GiveCard, Card: cards.Defuse}, 13},
+		{gamestate.Action{Type: gamestate.GiveCard, Card: cards.Cat}, 20},
+		{gamestate.Action{Type: gamestate.InsertExplodingKitten, PositionInDrawPile: nDrawPileCards + 1}, 22},
+		{gamestate.Action{Type: gamestate.InsertExplodingKitten, PositionInDrawPile: 0}, 23},
+		{gamestate.Action{Type: gamestate.InsertExplodingKitten, PositionInDrawPile: 6}, 29},
+	}
+
+	for _, tc := range testCases {
+		if slot := modelSlot(tc.action, nDrawPileCards); slot != tc.slot {
+			t.Errorf("%v: expected output %d, got %d", tc.action, tc.slot, slot)
+		}
+	}
+
+	drawPile := make([]float32, numModelCardTypes)
+	encodeCard(cards.TBD, drawPile)
+	if drawPile[10] != 1 {
+		t.Errorf("expected TBD to be encoded as card type 10, got %v", drawPile)
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("expected encoding the ImplodingKitten to panic")
+		}
+	}()
+	encodeCard(cards.ImplodingKitten, drawPile)
+}
@@ -3,7 +3,6 @@ package model
 import (
 	"fmt"
 
-	"github.com/timpalpant/alphacats/cards"
 	"github.com/timpalpant/alphacats/gamestate"
 	"github.com/timpalpant/alphacats/model/internal/npyio"
 )
@@ -13,7 +12,7 @@ func saveTrainingData(batch []Sample, filename string) error {
 
 	histories := make([]float32, 0, nSamples*gamestate.MaxNumActions*numActionFeatures)
 	hands := make([]float32, 0, nSamples*(3*numCardsInDeck))
-	drawPiles := make([]float32, 0, nSamples*maxCardsInDrawPile*cards.NumTypes)
+	drawPiles := make([]float32, 0, nSamples*maxCardsInDrawPile*numModelCardTypes)
 	outputMasks := make([]float32, 0, nSamples*outputDimension)
 	yPolicy := make([]float32, 0, nSamples*outputDimension)
 	yValue := make([]float32, 0, nSamples)
@@ -20,7 +20,6 @@ import (
 	tf "github.com/tensorflow/tensorflow/tensorflow/go"
 
 	"github.com/timpalpant/alphacats"
-	"github.com/timpalpant/alphacats/cards"
 	"github.com/timpalpant/alphacats/gamestate"
 )
 
@@ -236,7 +235,7 @@ func (m *TrainedLSTM) Close() {
 const (
 	tfHistorySize    = 4 * gamestate.MaxNumActions * numActionFeatures
 	tfHandSize       = 4 * numCardsInDeck
-	tfDrawPileSize   = 4 * maxCardsInDrawPile * cards.NumTypes
+	tfDrawPileSize   = 4 * maxCardsInDrawPile * numModelCardTypes
 	tfOutputMaskSize = 4 * outputDimension
 )
 
@@ -248,7 +247,40 @@ var predictionRequestPool = sync.Pool{
 	},
 }
 
+// Predict returns the policy and value for the given info set. Concurrent
+// calls are predicted together in batches of up to MaxInferenceBatchSize.
 func (m *TrainedLSTM) Predict(is *alphacats.AbstractedInfoSet) ([]float32, float32) {
+	req := newPredictionRequest(is)
+	m.reqsCh <- req
+	prediction := <-req.resultCh
+	predictionRequestPool.Put(req)
+	policy := decodeOutputs(is.DrawPile.Len(), is.AvailableActions, prediction.policy)
+	return policy, prediction.value
+}
+
+// PredictBatch implements BatchPredictor. The info sets are submitted
+// together so that they will be predicted in as few batches as possible.
+func (m *TrainedLSTM) PredictBatch(infoSets []*alphacats.AbstractedInfoSet) ([][]float32, []float32) {
+	reqs := make([]*predictionRequest, len(infoSets))
+	for i, is := range infoSets {
+		reqs[i] = newPredictionRequest(is)
+		m.reqsCh <- reqs[i]
+	}
+
+	policies := make([][]float32, len(infoSets))
+	values := make([]float32, len(infoSets))
+	for i, req := range reqs {
+		prediction := <-req.resultCh
+		predictionRequestPool.Put(req)
+		is := infoSets[i]
+		policies[i] = decodeOutputs(is.DrawPile.Len(), is.AvailableActions, prediction.policy)
+		values[i] = prediction.value
+	}
+
+	return policies, values
+}
+
+func newPredictionRequest(is *alphacats.AbstractedInfoSet) *predictionRequest {
 	tfHistory := make([]byte, tfHistorySize)
 	encodeHistoryTF(is.PublicHistory, tfHistory)
 	tfHands := make([]byte, 3*tfHandSize)
@@ -264,12 +296,7 @@ func (m *TrainedLSTM) Predict(is *alphacats.AbstractedInfoSet) ([]float32, float
 	req.hands = tfHands
 	req.drawPile = tfDrawPile
 	req.outputMask = tfOutputMask
-
-	m.reqsCh <- req
-	prediction := <-req.resultCh
-	predictionRequestPool.Put(req)
-	policy := decodeOutputs(is.DrawPile.Len(), is.AvailableActions, prediction.policy)
-	return policy, prediction.value
+	return req
 }
 
 type predictionRequest struct {
@@ -384,7 +411,7 @@ func handleEncoding(model *tf.SavedModel, batchCh chan []*predictionRequest, out
 		}
 
 		drawPilesReader := bytes.NewReader(drawPilesBuf)
-		drawPilesShape := []int64{int64(len(batch)), int64(maxCardsInDrawPile), int64(cards.NumTypes)}
+		drawPilesShape := []int64{int64(len(batch)), int64(maxCardsInDrawPile), int64(numModelCardTypes)}
 		drawPilesTensor, err := tf.ReadTensor(tf.Float, drawPilesShape, drawPilesReader)
 		if err != nil {
 			glog.Fatal(err)
@@ -29,17 +29,17 @@ func BenchmarkPredict(b *testing.B) {
 	deck := cards.CoreDeck.AsSlice()
 	deal := alphacats.NewRandomDeal(deck, 4)
 	game := alphacats.NewGame(deal.DrawPile, deal.P0Deal, deal.P1Deal)
-	is := game.InfoSet(0).(*alphacats.InfoSetWithAvailableActions)
+	is := game.InfoSet(0).(*alphacats.AbstractedInfoSet)
 	model, err := LoadTrainedLSTM(testModel, testParams)
 	if err != nil {
 		b.Fatal(err)
 	}
 	defer model.Close()
-	model.Predict(is, len(is.AvailableActions)) // One time setup cost.
+	model.Predict(is) // One time setup cost.
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		model.Predict(is, len(is.AvailableActions))
+		model.Predict(is)
 	}
 }
 
@@ -47,18 +47,18 @@ func BenchmarkPredictParallel(b *testing.B) {
 	deck := cards.CoreDeck.AsSlice()
 	deal := alphacats.NewRandomDeal(deck, 4)
 	game := alphacats.NewGame(deal.DrawPile, deal.P0Deal, deal.P1Deal)
-	is := game.InfoSet(0).(*alphacats.InfoSetWithAvailableActions)
+	is := game.InfoSet(0).(*alphacats.AbstractedInfoSet)
 	model, err := LoadTrainedLSTM(testModel, testParams)
 	if err != nil {
 		b.Fatal(err)
 	}
 	defer model.Close()
-	model.Predict(is, len(is.AvailableActions)) // One time setup cost.
+	model.Predict(is) // One time setup cost.
 
 	b.ResetTimer()
 	b.RunParallel(func(pb *testing.PB) {
 		for pb.Next() {
-			model.Predict(is, len(is.AvailableActions))
+			model.Predict(is)
 		}
 	})
 }
@@ -74,9 +74,9 @@ func BenchmarkBatchSize(b *testing.B) {
 	deck := cards.CoreDeck.AsSlice()
 	deal := alphacats.NewRandomDeal(deck, 4)
 	game := alphacats.NewGame(deal.DrawPile, deal.P0Deal, deal.P1Deal)
-	is := game.InfoSet(0).(*alphacats.InfoSetWithAvailableActions)
+	is := game.InfoSet(0).(*alphacats.AbstractedInfoSet)
 	history := newOneHotHistory()
-	EncodeHistory(is.History, history)
+	EncodeHistory(is.PublicHistory, history)
 	hand := make([]float32, cards.NumTypes)
 	encodeHand(is.Hand, hand)
 	action := make([]float32, numActionFeatures)
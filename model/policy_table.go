@@ -0,0 +1,559 @@
+package model
+
+import (
+	"bufio"
+	"bytes"
+	"container/heap"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"sync"
+	"syscall"
+
+	"github.com/timpalpant/go-cfr"
+	"github.com/timpalpant/go-cfr/mcts"
+
+	"github.com/timpalpant/alphacats"
+)
+
+// PolicyTable is an in-memory tabular policy, keyed by info set.
+// Info sets that are not in the table are played uniformly at random.
+type PolicyTable map[string][]float32
+
+// Verify that we implement the interface.
+var _ mcts.Policy = PolicyTable{}
+
+// Record sets the policy for the info set of the given node.
+func (t PolicyTable) Record(node cfr.GameTreeNode, p []float32) {
+	key := node.InfoSet(node.Player()).Key()
+	t[string(key)] = p
+}
+
+// GetPolicy implements mcts.Policy.
+func (t PolicyTable) GetPolicy(node cfr.GameTreeNode) []float32 {
+	key := node.InfoSet(node.Player()).Key()
+	if p, ok := t[string(key)]; ok {
+		return p
+	}
+
+	return uniformDistribution(node.NumChildren())
+}
+
+// AveragePolicyTables ensembles the given tables (e.g. from training runs
+// with different seeds) by averaging the policy for each info set over the
+// tables that contain it. Info sets in only one of the tables keep their
+// policy from that table. All tables are weighted equally; to weight them
+// by how often each info set was visited, see AverageWeightedPolicyTables.
+func AveragePolicyTables(tables ...PolicyTable) (PolicyTable, error) {
+	result, _, err := AverageWeightedPolicyTables(tables, nil)
+	return result, err
+}
+
+// AverageWeightedPolicyTables is like AveragePolicyTables, but weights the
+// policy of each info set in each table by the number of times it was
+// visited in training, since rarely visited info sets are likely to be
+// poorly learned. visits has an entry for each table (e.g. from
+// DiskPolicy.Load), or is nil to weight all tables equally. Info sets that
+// were never visited in any of the tables are weighted equally.
+//
+// The visits of the result are the total visits over all of the tables.
+func AverageWeightedPolicyTables(tables []PolicyTable, visits []*VisitCounts) (PolicyTable, *VisitCounts, error) {
+	if visits != nil && len(visits) != len(tables) {
+		return nil, nil, fmt.Errorf("got visit counts for %d of %d tables", len(visits), len(tables))
+	}
+
+	// Each info set is averaged by visits if it was visited at all,
+	// and equally otherwise, so both sums are accumulated.
+	weighted := make(map[string][]float32)
+	unweighted := make(map[string][]float32)
+	weights := make(map[string]float32)
+	counts := make(map[string]int)
+	totalVisits := NewVisitCounts()
+	for i, t := range tables {
+		for key, p := range t {
+			sum, ok := unweighted[key]
+			if !ok {
+				sum = make([]float32, len(p))
+				unweighted[key] = sum
+				weighted[key] = make([]float32, len(p))
+			} else if len(sum) != len(p) {
+				return nil, nil, fmt.Errorf("policies for info set %q have different numbers of actions: %d != %d",
+					key, len(sum), len(p))
+			}
+
+			var w uint32
+			if visits != nil {
+				w = visits[i].Get([]byte(key))
+			}
+
+			wsum := weighted[key]
+			for j, x := range p {
+				sum[j] += x
+				wsum[j] += float32(w) * x
+			}
+			counts[key]++
+			weights[key] += float32(w)
+			totalVisits.Add([]byte(key), w)
+		}
+	}
+
+	result := make(PolicyTable, len(unweighted))
+	for key, p := range unweighted {
+		n := float32(counts[key])
+		if w := weights[key]; w > 0 {
+			p, n = weighted[key], w
+		}
+
+		for i := range p {
+			p[i] /= n
+		}
+		result[key] = p
+	}
+
+	return result, totalVisits, nil
+}
+
+// VisitCounts is the number of times each info set was visited during
+// training, keyed by info set like PolicyTable. The policy in info sets that
+// were rarely visited is likely to be poorly learned. It is safe to call
+// Visit concurrently, e.g. from games played in parallel.
+type VisitCounts struct {
+	mu     sync.Mutex
+	counts map[string]uint32
+}
+
+func NewVisitCounts() *VisitCounts {
+	return &VisitCounts{counts: make(map[string]uint32)}
+}
+
+// Visit increments the count for the info set of the given node.
+func (v *VisitCounts) Visit(node cfr.GameTreeNode) {
+	key := node.InfoSet(node.Player()).Key()
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.counts[string(key)]++
+}
+
+// Get returns the number of times the info set with the given key was visited.
+func (v *VisitCounts) Get(key []byte) uint32 {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.counts[string(key)]
+}
+
+// Add adds n visits to the info set with the given key, for example to
+// resume counting from a saved table (see DiskPolicy.Load).
+func (v *VisitCounts) Add(key []byte, n uint32) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.counts[string(key)] += n
+}
+
+// Len returns the number of distinct info sets visited.
+func (v *VisitCounts) Len() int {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return len(v.counts)
+}
+
+// InfoSetVisits is the visit count of the info set with the given key.
+type InfoSetVisits struct {
+	Key    []byte
+	Visits uint32
+}
+
+// The on-disk policy table format is a 24 byte header with the magic bytes,
+// version (uint32), number of records N (uint64), the version of the format
+// of the info set keys (uint32, see alphacats.InfoSetKeyVersion) and 4
+// reserved bytes, followed by the offset (uint64) of each record in the file,
+// sorted by key, followed by the records. Each record is the key length (uint16), the key, the policy length
+// (uint8) and the policy (float32s). In the visit version, each record is
+// followed by the number of times the info set was visited (uint32). All
+// integers are little endian.
+//
+// Versions 3 and 4 are the same formats with a 16 byte header, which ends
+// after N. Their keys are key version 1. Versions 1 and 2 are keyed by info
+// sets abstracted assuming the core deck. They are no longer supported,
+// since their keys do not match those of games dealt from other decks.
+const (
+	policyTableMagic              = "ACPT"
+	policyTableLegacyVersion      = 3
+	policyTableLegacyVisitVersion = 4
+	policyTableVersion            = 5
+	policyTableVisitVersion       = 6
+	policyTableLegacyHeader       = 16
+	policyTableHeader             = 24
+)
+
+// WritePolicyTable saves the given policy table to a file that
+// can be opened with OpenDiskPolicy.
+func WritePolicyTable(t PolicyTable, filename string) error {
+	return WritePolicyTableWithVisits(t, nil, filename)
+}
+
+// WritePolicyTableWithVisits saves the given policy table along with the
+// number of times each of its info sets was visited. Info sets that are not
+// in visits are saved with zero visits. If visits is nil, the table is saved
+// without visit counts, in the same format as WritePolicyTable.
+func WritePolicyTableWithVisits(t PolicyTable, visits *VisitCounts, filename string) error {
+	version := policyTableVersion
+	if visits != nil {
+		version = policyTableVisitVersion
+	}
+
+	keys := make([]string, 0, len(t))
+	for key, p := range t {
+		if len(key) > math.MaxUint16 || len(p) > math.MaxUint8 {
+			return fmt.Errorf("info set key (%d bytes) or policy (%d actions) is too large",
+				len(key), len(p))
+		}
+
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+
+	header := make([]byte, policyTableHeader)
+	copy(header, policyTableMagic)
+	binary.LittleEndian.PutUint32(header[4:], uint32(version))
+	binary.LittleEndian.PutUint64(header[8:], uint64(len(keys)))
+	binary.LittleEndian.PutUint32(header[16:], alphacats.InfoSetKeyVersion)
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+
+	offset := uint64(policyTableHeader + 8*len(keys))
+	var buf [8]byte
+	for _, key := range keys {
+		binary.LittleEndian.PutUint64(buf[:], offset)
+		if _, err := w.Write(buf[:]); err != nil {
+			return err
+		}
+
+		offset += uint64(2 + len(key) + 1 + 4*len(t[key]))
+		if version == policyTableVisitVersion {
+			offset += 4
+		}
+	}
+
+	// NB: bufio.Writer errors are sticky, and will be returned by Flush.
+	for _, key := range keys {
+		p := t[key]
+		binary.LittleEndian.PutUint16(buf[:], uint16(len(key)))
+		w.Write(buf[:2])
+		w.WriteString(key)
+		w.WriteByte(uint8(len(p)))
+		for _, x := range p {
+			binary.LittleEndian.PutUint32(buf[:], math.Float32bits(x))
+			w.Write(buf[:4])
+		}
+
+		if version == policyTableVisitVersion {
+			binary.LittleEndian.PutUint32(buf[:], visits.Get([]byte(key)))
+			w.Write(buf[:4])
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	return f.Close()
+}
+
+// DiskPolicy is a tabular policy backed by a memory-mapped file written by
+// WritePolicyTable. Only the pages of the table that are accessed are loaded
+// into memory, so the table may be much larger than available RAM.
+// It is safe to call GetPolicy concurrently.
+//
+// A DiskPolicy is read-only. To continue training from it, Load it into
+// a PolicyTable.
+type DiskPolicy struct {
+	data       []byte
+	n          int
+	headerSize int
+	hasVisits  bool
+}
+
+// Verify that we implement the interface.
+var _ mcts.Policy = &DiskPolicy{}
+
+func OpenDiskPolicy(filename string) (*DiskPolicy, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	if fi.Size() < policyTableLegacyHeader {
+		return nil, fmt.Errorf("%s is not a policy table", filename)
+	}
+
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(fi.Size()),
+		syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, err
+	}
+
+	if string(data[:4]) != policyTableMagic {
+		syscall.Munmap(data)
+		return nil, fmt.Errorf("%s is not a policy table", filename)
+	}
+
+	dp := &DiskPolicy{data: data}
+	keyVersion := uint32(1)
+	switch version := binary.LittleEndian.Uint32(data[4:]); version {
+	case policyTableLegacyVersion, policyTableLegacyVisitVersion:
+		dp.headerSize = policyTableLegacyHeader
+		dp.hasVisits = (version == policyTableLegacyVisitVersion)
+	case policyTableVersion, policyTableVisitVersion:
+		if len(data) < policyTableHeader {
+			syscall.Munmap(data)
+			return nil, fmt.Errorf("%s is corrupt: header is truncated", filename)
+		}
+
+		dp.headerSize = policyTableHeader
+		dp.hasVisits = (version == policyTableVisitVersion)
+		keyVersion = binary.LittleEndian.Uint32(data[16:])
+	default:
+		syscall.Munmap(data)
+		return nil, fmt.Errorf("unsupported policy table version: %d", version)
+	}
+
+	if keyVersion != alphacats.InfoSetKeyVersion {
+		syscall.Munmap(data)
+		return nil, fmt.Errorf("%s is keyed by info set key version %d, expected %d",
+			filename, keyVersion, alphacats.InfoSetKeyVersion)
+	}
+
+	n := binary.LittleEndian.Uint64(data[8:])
+	if n > uint64(len(data)-dp.headerSize)/8 {
+		syscall.Munmap(data)
+		return nil, fmt.Errorf("%s is corrupt: %d records do not fit in %d bytes", filename, n, len(data))
+	}
+	dp.n = int(n)
+
+	if err := dp.validate(); err != nil {
+		syscall.Munmap(data)
+		return nil, fmt.Errorf("%s is corrupt: %v", filename, err)
+	}
+
+	return dp, nil
+}
+
+// validate checks that every record lies within the file and that the
+// records are sorted by key, so that lookups never read out of bounds.
+func (dp *DiskPolicy) validate() error {
+	size := uint64(len(dp.data))
+	var prevKey []byte
+	for i := 0; i < dp.n; i++ {
+		offset := binary.LittleEndian.Uint64(dp.data[dp.headerSize+8*i:])
+		if offset < uint64(dp.headerSize+8*dp.n) || offset > size-2 {
+			return fmt.Errorf("record %d: offset %d is out of range", i, offset)
+		}
+
+		keyLen := uint64(binary.LittleEndian.Uint16(dp.data[offset:]))
+		if offset+2+keyLen+1 > size {
+			return fmt.Errorf("record %d: key of %d bytes is truncated", i, keyLen)
+		}
+
+		recordLen := 2 + keyLen + 1 + 4*uint64(dp.data[offset+2+keyLen])
+		if dp.hasVisits {
+			recordLen += 4
+		}
+		if offset+recordLen > size {
+			return fmt.Errorf("record %d: %d bytes at offset %d is truncated", i, recordLen, offset)
+		}
+
+		key := dp.recordKey(i)
+		if i > 0 && bytes.Compare(prevKey, key) >= 0 {
+			return fmt.Errorf("record %d: keys are not sorted", i)
+		}
+		prevKey = key
+	}
+
+	return nil
+}
+
+func (dp *DiskPolicy) Len() int {
+	return dp.n
+}
+
+// Lookup returns the policy for the info set with the given key,
+// and whether or not it was found in the table.
+func (dp *DiskPolicy) Lookup(key []byte) ([]float32, bool) {
+	i := sort.Search(dp.n, func(i int) bool {
+		return bytes.Compare(dp.recordKey(i), key) >= 0
+	})
+
+	if i >= dp.n || !bytes.Equal(dp.recordKey(i), key) {
+		return nil, false
+	}
+
+	return dp.recordPolicy(i), true
+}
+
+// Iter calls cb with the key, policy and visits (see Visits) of each info set
+// in the table, in order of key. The key must not be retained after cb returns.
+func (dp *DiskPolicy) Iter(cb func(key []byte, p []float32, visits uint32)) {
+	for i := 0; i < dp.n; i++ {
+		cb(dp.recordKey(i), dp.recordPolicy(i), dp.recordVisits(i))
+	}
+}
+
+// Load reads the whole table into memory, along with its visit counts,
+// so that training can be continued from it (see WritePolicyTableWithVisits).
+func (dp *DiskPolicy) Load() (PolicyTable, *VisitCounts) {
+	table := make(PolicyTable, dp.n)
+	visits := NewVisitCounts()
+	dp.Iter(func(key []byte, p []float32, n uint32) {
+		table[string(key)] = p
+		visits.Add(key, n)
+	})
+
+	return table, visits
+}
+
+// HasVisits returns whether the table was saved with visit counts
+// (see WritePolicyTableWithVisits).
+func (dp *DiskPolicy) HasVisits() bool {
+	return dp.hasVisits
+}
+
+// Visits returns the number of times the info set with the given key was
+// visited during training, and whether it was found in the table. Tables
+// saved without visit counts report zero visits.
+func (dp *DiskPolicy) Visits(key []byte) (uint32, bool) {
+	i := sort.Search(dp.n, func(i int) bool {
+		return bytes.Compare(dp.recordKey(i), key) >= 0
+	})
+
+	if i >= dp.n || !bytes.Equal(dp.recordKey(i), key) {
+		return 0, false
+	}
+
+	return dp.recordVisits(i), true
+}
+
+// LeastVisited returns the (at most) n info sets in the table that were
+// visited the fewest times during training, in increasing order of visits.
+// Ties are broken in order of key. Only n info sets are held in memory at
+// once, so n may be much smaller than the table.
+func (dp *DiskPolicy) LeastVisited(n int) []InfoSetVisits {
+	if n <= 0 {
+		return nil
+	}
+
+	// Holds the n least visited records seen so far, with the most
+	// visited of them on top so that it can be replaced.
+	h := make(visitsHeap, 0, n)
+	for i := 0; i < dp.n; i++ {
+		rv := recordVisits{i, dp.recordVisits(i)}
+		if len(h) < n {
+			heap.Push(&h, rv)
+		} else if rv.less(h[0]) {
+			h[0] = rv
+			heap.Fix(&h, 0)
+		}
+	}
+
+	sort.Slice(h, func(i, j int) bool { return h[i].less(h[j]) })
+	result := make([]InfoSetVisits, len(h))
+	for i, rv := range h {
+		result[i] = InfoSetVisits{dp.recordKey(rv.record), rv.visits}
+	}
+
+	return result
+}
+
+// recordVisits is the visit count of the i'th record of a DiskPolicy.
+type recordVisits struct {
+	record int
+	visits uint32
+}
+
+// NB: Records are sorted by key, so ties are broken in order of key.
+func (rv recordVisits) less(other recordVisits) bool {
+	if rv.visits != other.visits {
+		return rv.visits < other.visits
+	}
+
+	return rv.record < other.record
+}
+
+// visitsHeap is a max-heap of records by visits (see LeastVisited).
+type visitsHeap []recordVisits
+
+func (h visitsHeap) Len() int            { return len(h) }
+func (h visitsHeap) Less(i, j int) bool  { return h[j].less(h[i]) }
+func (h visitsHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *visitsHeap) Push(x interface{}) { *h = append(*h, x.(recordVisits)) }
+func (h *visitsHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// GetPolicy implements mcts.Policy.
+func (dp *DiskPolicy) GetPolicy(node cfr.GameTreeNode) []float32 {
+	key := node.InfoSet(node.Player()).Key()
+	if p, ok := dp.Lookup(key); ok {
+		return p
+	}
+
+	return uniformDistribution(node.NumChildren())
+}
+
+func (dp *DiskPolicy) Close() error {
+	return syscall.Munmap(dp.data)
+}
+
+func (dp *DiskPolicy) record(i int) []byte {
+	offset := binary.LittleEndian.Uint64(dp.data[dp.headerSize+8*i:])
+	return dp.data[offset:]
+}
+
+func (dp *DiskPolicy) recordKey(i int) []byte {
+	record := dp.record(i)
+	keyLen := int(binary.LittleEndian.Uint16(record))
+	return record[2 : 2+keyLen]
+}
+
+func (dp *DiskPolicy) recordPolicy(i int) []float32 {
+	record := dp.record(i)
+	keyLen := int(binary.LittleEndian.Uint16(record))
+	record = record[2+keyLen:]
+	result := make([]float32, record[0])
+	record = record[1:]
+	for j := range result {
+		result[j] = math.Float32frombits(binary.LittleEndian.Uint32(record[4*j:]))
+	}
+
+	return result
+}
+
+func (dp *DiskPolicy) recordVisits(i int) uint32 {
+	if !dp.hasVisits {
+		return 0
+	}
+
+	record := dp.record(i)
+	keyLen := int(binary.LittleEndian.Uint16(record))
+	record = record[2+keyLen:]
+	nActions := int(record[0])
+	return binary.LittleEndian.Uint32(record[1+4*nActions:])
+}
@@ -0,0 +1,523 @@
+package model
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/timpalpant/go-cfr"
+
+	"github.com/timpalpant/alphacats"
+	"github.com/timpalpant/alphacats/cards"
+	"github.com/timpalpant/alphacats/internal/testgames"
+)
+
+// Returns the player nodes visited in n random games,
+// along with a random policy for each of them.
+func makeRandomPolicyTable(rng *rand.Rand, n int) ([]cfr.GameTreeNode, PolicyTable) {
+	var nodes []cfr.GameTreeNode
+	table := make(PolicyTable)
+	for i := 0; i < n; i++ {
+		deal := alphacats.NewRandomDeal(cards.CoreDeck.AsSlice(), 4)
+		var node cfr.GameTreeNode = alphacats.NewGame(deal.DrawPile, deal.P0Deal, deal.P1Deal)
+		for node.Type() != cfr.TerminalNodeType {
+			if node.Type() == cfr.ChanceNodeType {
+				node, _ = node.SampleChild()
+				continue
+			}
+
+			p := make([]float32, node.NumChildren())
+			for j := range p {
+				p[j] = rng.Float32()
+			}
+			table.Record(node, p)
+			nodes = append(nodes, node)
+			node = node.GetChild(rng.Intn(node.NumChildren()))
+		}
+	}
+
+	return nodes, table
+}
+
+func writeTempPolicyTable(t testing.TB, table PolicyTable) (string, func()) {
+	dir, err := ioutil.TempDir("", "policy_table")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	filename := filepath.Join(dir, "policy.table")
+	if err := WritePolicyTable(table, filename); err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+
+	return filename, func() { os.RemoveAll(dir) }
+}
+
+func TestDiskPolicy(t *testing.T) {
+	rng := rand.New(rand.NewSource(123))
+	nodes, table := makeRandomPolicyTable(rng, 10)
+	filename, cleanup := writeTempPolicyTable(t, table)
+	defer cleanup()
+
+	dp, err := OpenDiskPolicy(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dp.Close()
+
+	if dp.Len() != len(table) {
+		t.Errorf("expected %d info sets, got %d", len(table), dp.Len())
+	}
+
+	for _, node := range nodes {
+		expected := table.GetPolicy(node)
+		if p := dp.GetPolicy(node); !reflect.DeepEqual(p, expected) {
+			t.Errorf("expected policy %v, got %v", expected, p)
+		}
+	}
+
+	// Info sets not in the table are played uniformly.
+	_, other := makeRandomPolicyTable(rng, 1)
+	for key := range other {
+		if _, ok := table[key]; ok {
+			continue
+		}
+
+		if p, ok := dp.Lookup([]byte(key)); ok {
+			t.Errorf("expected info set to not be found, got %v", p)
+		}
+	}
+}
+
+func TestDiskPolicyOldVersion(t *testing.T) {
+	filename, cleanup := writeTempPolicyTable(t, PolicyTable{})
+	defer cleanup()
+
+	// Rewrite the header as version 1, whose keys are no longer valid.
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	binary.LittleEndian.PutUint32(data[4:], 1)
+	if err := ioutil.WriteFile(filename, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if dp, err := OpenDiskPolicy(filename); err == nil {
+		dp.Close()
+		t.Error("expected error opening a version 1 policy table")
+	}
+}
+
+func TestDiskPolicyKeyVersion(t *testing.T) {
+	table := PolicyTable{"a": {0.25, 0.75}, "b": {1.0}}
+	filename, cleanup := writeTempPolicyTable(t, table)
+	defer cleanup()
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Tables keyed by another version of the key format are rejected.
+	changed := append([]byte(nil), data...)
+	binary.LittleEndian.PutUint32(changed[16:], alphacats.InfoSetKeyVersion+1)
+	if err := ioutil.WriteFile(filename, changed, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if dp, err := OpenDiskPolicy(filename); err == nil {
+		dp.Close()
+		t.Error("expected error opening a table with a different key version")
+	}
+
+	// Legacy (version 3) tables have a shorter header without the key
+	// version, and are still supported.
+	legacy := append([]byte(nil), data[:policyTableLegacyHeader]...)
+	binary.LittleEndian.PutUint32(legacy[4:], policyTableLegacyVersion)
+	shift := policyTableHeader - policyTableLegacyHeader
+	offsets := append([]byte(nil), data[policyTableHeader:policyTableHeader+8*len(table)]...)
+	for i := 0; i < len(table); i++ {
+		offset := binary.LittleEndian.Uint64(offsets[8*i:])
+		binary.LittleEndian.PutUint64(offsets[8*i:], offset-uint64(shift))
+	}
+	legacy = append(legacy, offsets...)
+	legacy = append(legacy, data[policyTableHeader+8*len(table):]...)
+	if err := ioutil.WriteFile(filename, legacy, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dp, err := OpenDiskPolicy(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dp.Close()
+	for key, expected := range table {
+		if p, ok := dp.Lookup([]byte(key)); !ok || !reflect.DeepEqual(p, expected) {
+			t.Errorf("%s: expected %v in legacy table, got %v", key, expected, p)
+		}
+	}
+}
+
+func TestDiskPolicyVisits(t *testing.T) {
+	// Sample n games on the test deck, recording a policy for and visiting
+	// each info set along the way.
+	const n = 20
+	rng := rand.New(rand.NewSource(123))
+	table := make(PolicyTable)
+	visits := NewVisitCounts()
+	var root cfr.GameTreeNode
+	for i := 0; i < n; i++ {
+		var node cfr.GameTreeNode = alphacats.NewGame(testgames.TestDeck())
+		root = node
+		for node.Type() != cfr.TerminalNodeType {
+			if node.Type() == cfr.ChanceNodeType {
+				node, _ = node.SampleChild()
+				continue
+			}
+
+			table.Record(node, uniformDistribution(node.NumChildren()))
+			visits.Visit(node)
+			node = node.GetChild(rng.Intn(node.NumChildren()))
+		}
+	}
+
+	rootKey := root.InfoSet(root.Player()).Key()
+	if v := visits.Get(rootKey); v != n {
+		t.Errorf("expected root info set to be visited %d times, got %d", n, v)
+	}
+
+	dir, err := ioutil.TempDir("", "policy_table")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "policy.table")
+	if err := WritePolicyTableWithVisits(table, visits, filename); err != nil {
+		t.Fatal(err)
+	}
+
+	dp, err := OpenDiskPolicy(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dp.Close()
+
+	if !dp.HasVisits() {
+		t.Error("expected table to have visit counts")
+	}
+
+	if v, ok := dp.Visits(rootKey); !ok || v != n {
+		t.Errorf("expected root info set to be visited %d times, got %d (found: %v)", n, v, ok)
+	}
+
+	// The policy is unchanged by the visit counts.
+	for key, expected := range table {
+		if p, ok := dp.Lookup([]byte(key)); !ok || !reflect.DeepEqual(p, expected) {
+			t.Errorf("expected policy %v, got %v", expected, p)
+		}
+	}
+
+	leastVisited := dp.LeastVisited(3)
+	if len(leastVisited) != 3 {
+		t.Fatalf("expected 3 least visited info sets, got %d", len(leastVisited))
+	}
+	for i, isv := range leastVisited {
+		if expected := visits.Get(isv.Key); isv.Visits != expected {
+			t.Errorf("expected %d visits, got %d", expected, isv.Visits)
+		}
+		if i > 0 && isv.Visits < leastVisited[i-1].Visits {
+			t.Errorf("least visited info sets are not sorted: %v", leastVisited)
+		}
+	}
+	if v := leastVisited[0].Visits; v != minVisits(visits) {
+		t.Errorf("expected least visited info set to have %d visits, got %d", minVisits(visits), v)
+	}
+
+	// LeastVisited matches sorting the whole table, with ties in order of key.
+	all := make([]InfoSetVisits, 0, len(table))
+	for key := range table {
+		all = append(all, InfoSetVisits{[]byte(key), visits.Get([]byte(key))})
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].Visits != all[j].Visits {
+			return all[i].Visits < all[j].Visits
+		}
+
+		return bytes.Compare(all[i].Key, all[j].Key) < 0
+	})
+	for _, k := range []int{0, 1, 3, len(all), len(all) + 5} {
+		expected := all
+		if k < len(expected) {
+			expected = expected[:k]
+		}
+
+		if got := dp.LeastVisited(k); len(got) != len(expected) || (k > 0 && !reflect.DeepEqual(got, expected)) {
+			t.Errorf("LeastVisited(%d): expected %v, got %v", k, expected, got)
+		}
+	}
+
+	// Tables saved without visit counts can still be read.
+	legacy, cleanup := writeTempPolicyTable(t, table)
+	defer cleanup()
+	dp2, err := OpenDiskPolicy(legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dp2.Close()
+
+	if dp2.HasVisits() {
+		t.Error("expected table saved without visits to not have visit counts")
+	}
+	if v, ok := dp2.Visits(rootKey); !ok || v != 0 {
+		t.Errorf("expected 0 visits for table saved without visits, got %d (found: %v)", v, ok)
+	}
+}
+
+func TestVisitCountsConcurrent(t *testing.T) {
+	game := alphacats.NewGame(testgames.TestDeck())
+	game.NumChildren()
+
+	const nWorkers, n = 8, 100
+	visits := NewVisitCounts()
+	var wg sync.WaitGroup
+	for i := 0; i < nWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < n; j++ {
+				visits.Visit(game)
+			}
+		}()
+	}
+	wg.Wait()
+
+	key := game.InfoSet(game.Player()).Key()
+	if v := visits.Get(key); v != nWorkers*n {
+		t.Errorf("expected %d visits, got %d", nWorkers*n, v)
+	}
+	if visits.Len() != 1 {
+		t.Errorf("expected 1 info set visited, got %d", visits.Len())
+	}
+}
+
+func minVisits(visits *VisitCounts) uint32 {
+	result := uint32(math.MaxUint32)
+	for _, v := range visits.counts {
+		if v < result {
+			result = v
+		}
+	}
+
+	return result
+}
+
+func TestAveragePolicyTables(t *testing.T) {
+	t1 := PolicyTable{
+		"a": {0.2, 0.8},
+		"b": {1.0, 0.0, 0.0},
+	}
+	t2 := PolicyTable{
+		"a": {0.6, 0.4},
+		"c": {0.5, 0.5},
+	}
+
+	avg, err := AveragePolicyTables(t1, t2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := PolicyTable{
+		"a": {0.4, 0.6},
+		"b": {1.0, 0.0, 0.0},
+		"c": {0.5, 0.5},
+	}
+	if len(avg) != len(expected) {
+		t.Errorf("expected %d info sets, got %d", len(expected), len(avg))
+	}
+
+	for key, p := range expected {
+		actual := avg[key]
+		if len(actual) != len(p) {
+			t.Errorf("%s: expected policy %v, got %v", key, p, actual)
+			continue
+		}
+
+		for i := range p {
+			if math.Abs(float64(actual[i]-p[i])) > 1e-6 {
+				t.Errorf("%s: expected policy %v, got %v", key, p, actual)
+				break
+			}
+		}
+	}
+
+	// The inputs are not modified.
+	if !reflect.DeepEqual(t1["a"], []float32{0.2, 0.8}) {
+		t.Errorf("input table was modified: %v", t1)
+	}
+
+	t2["b"] = []float32{0.5, 0.5}
+	if _, err := AveragePolicyTables(t1, t2); err == nil {
+		t.Error("expected error for policies with different numbers of actions")
+	}
+}
+
+func TestAverageWeightedPolicyTables(t *testing.T) {
+	t1 := PolicyTable{
+		"a": {0.2, 0.8},
+		"b": {1.0, 0.0},
+	}
+	t2 := PolicyTable{
+		"a": {0.6, 0.4},
+		"b": {0.0, 1.0},
+	}
+	v1, v2 := NewVisitCounts(), NewVisitCounts()
+	v1.Add([]byte("a"), 3)
+	v2.Add([]byte("a"), 1)
+
+	avg, visits, err := AverageWeightedPolicyTables([]PolicyTable{t1, t2}, []*VisitCounts{v1, v2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// "a" is weighted 3:1 by visits, and "b" was never visited so it is
+	// weighted equally.
+	expected := PolicyTable{
+		"a": {0.3, 0.7},
+		"b": {0.5, 0.5},
+	}
+	for key, p := range expected {
+		actual := avg[key]
+		if len(actual) != len(p) {
+			t.Errorf("%s: expected policy %v, got %v", key, p, actual)
+			continue
+		}
+
+		for i := range p {
+			if math.Abs(float64(actual[i]-p[i])) > 1e-6 {
+				t.Errorf("%s: expected policy %v, got %v", key, p, actual)
+				break
+			}
+		}
+	}
+
+	if v := visits.Get([]byte("a")); v != 4 {
+		t.Errorf("expected 4 total visits of a, got %d", v)
+	}
+
+	if _, _, err := AverageWeightedPolicyTables([]PolicyTable{t1, t2}, []*VisitCounts{v1}); err == nil {
+		t.Error("expected error for missing visit counts")
+	}
+}
+
+// BenchmarkDiskPolicyLookup-8   	  200000	       477 ns/op
+func BenchmarkDiskPolicyLookup(b *testing.B) {
+	rng := rand.New(rand.NewSource(123))
+	_, table := makeRandomPolicyTable(rng, 1000)
+	filename, cleanup := writeTempPolicyTable(b, table)
+	defer cleanup()
+
+	dp, err := OpenDiskPolicy(filename)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer dp.Close()
+
+	keys := make([][]byte, 0, len(table))
+	for key := range table {
+		keys = append(keys, []byte(key))
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		dp.Lookup(keys[i%len(keys)])
+	}
+}
+
+func TestDiskPolicyLoad(t *testing.T) {
+	rng := rand.New(rand.NewSource(123))
+	nodes, table := makeRandomPolicyTable(rng, 5)
+	visits := NewVisitCounts()
+	for _, node := range nodes {
+		visits.Visit(node)
+	}
+
+	dir, err := ioutil.TempDir("", "policy_table")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "policy.table")
+	if err := WritePolicyTableWithVisits(table, visits, filename); err != nil {
+		t.Fatal(err)
+	}
+
+	dp, err := OpenDiskPolicy(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dp.Close()
+
+	loaded, loadedVisits := dp.Load()
+	if !reflect.DeepEqual(loaded, table) {
+		t.Errorf("expected loaded table to match saved table")
+	}
+	if !reflect.DeepEqual(loadedVisits.counts, visits.counts) {
+		t.Errorf("expected loaded visits %v, got %v", visits.counts, loadedVisits.counts)
+	}
+}
+
+func TestOpenDiskPolicyCorrupt(t *testing.T) {
+	rng := rand.New(rand.NewSource(123))
+	_, table := makeRandomPolicyTable(rng, 1)
+	filename, cleanup := writeTempPolicyTable(t, table)
+	defer cleanup()
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	corrupt := func(name string, modify func(data []byte) []byte) {
+		modified := modify(append([]byte(nil), data...))
+		if err := ioutil.WriteFile(filename, modified, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		if dp, err := OpenDiskPolicy(filename); err == nil {
+			dp.Close()
+			t.Errorf("%s: expected error opening corrupt policy table", name)
+		}
+	}
+
+	corrupt("too many records", func(data []byte) []byte {
+		binary.LittleEndian.PutUint64(data[8:], uint64(len(data)))
+		return data
+	})
+	corrupt("offset out of range", func(data []byte) []byte {
+		binary.LittleEndian.PutUint64(data[policyTableHeader:], uint64(len(data)))
+		return data
+	})
+	corrupt("truncated", func(data []byte) []byte {
+		return data[:len(data)-1]
+	})
+	corrupt("unsorted", func(data []byte) []byte {
+		// Swap the offsets of the first two records.
+		first := data[policyTableHeader : policyTableHeader+8]
+		second := data[policyTableHeader+8 : policyTableHeader+16]
+		var tmp [8]byte
+		copy(tmp[:], first)
+		copy(first, second)
+		copy(second, tmp[:])
+		return data
+	})
+}
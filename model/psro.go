@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/gob"
 	"expvar"
+	"fmt"
 	"io"
 	"math/rand"
 	"sync"
@@ -76,7 +77,9 @@ func LoadMCTSPSRO(r io.Reader) (*MCTSPSRO, error) {
 		return nil, err
 	}
 	if err := dec.Decode(&m.samples); err != nil {
-		return nil, err
+		// NB: Samples saved before the ImplodingKitten was added are
+		// rejected, since their info sets pack cards differently.
+		return nil, fmt.Errorf("error loading samples: %v", err)
 	}
 	if err := dec.Decode(&m.maxSamples); err != nil {
 		return nil, err
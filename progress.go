@@ -0,0 +1,118 @@
+package alphacats
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ProgressLogger periodically logs the progress of a long-running loop
+// (iterations done, rate, elapsed time, ETA), along with the running mean
+// of a value reported with each iteration, such as the expected value.
+// It is safe to call Add concurrently.
+type ProgressLogger struct {
+	name     string
+	total    int
+	interval int
+	now      func() time.Time
+
+	mx      sync.Mutex
+	start   time.Time
+	done    int
+	sum     float64
+	nextLog int
+}
+
+// NewProgressLogger returns a ProgressLogger for a loop of total iterations,
+// that logs every 1% of iterations.
+func NewProgressLogger(name string, total int) *ProgressLogger {
+	interval := total / 100
+	if interval < 1 {
+		interval = 1
+	}
+
+	return newProgressLogger(name, total, interval, time.Now)
+}
+
+func newProgressLogger(name string, total, interval int, now func() time.Time) *ProgressLogger {
+	return &ProgressLogger{
+		name:     name,
+		total:    total,
+		interval: interval,
+		now:      now,
+		start:    now(),
+		nextLog:  interval,
+	}
+}
+
+// Add records n completed iterations, with the given value for each.
+func (p *ProgressLogger) Add(n int, value float64) {
+	p.mx.Lock()
+	p.done += n
+	p.sum += float64(n) * value
+	if p.done < p.nextLog {
+		p.mx.Unlock()
+		return
+	}
+
+	for p.nextLog <= p.done {
+		p.nextLog += p.interval
+	}
+	progress := p.progressLocked()
+	p.mx.Unlock()
+
+	logger().Infof("[%s] %v", p.name, progress)
+}
+
+// Progress returns a snapshot of the current progress.
+func (p *ProgressLogger) Progress() Progress {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	return p.progressLocked()
+}
+
+func (p *ProgressLogger) progressLocked() Progress {
+	result := Progress{
+		Done:    p.done,
+		Total:   p.total,
+		Elapsed: p.now().Sub(p.start),
+	}
+
+	if p.done > 0 {
+		result.MeanValue = p.sum / float64(p.done)
+	}
+
+	if result.Elapsed > 0 {
+		result.Rate = float64(p.done) / result.Elapsed.Seconds()
+	}
+
+	if result.Rate > 0 && p.done < p.total {
+		remaining := float64(p.total-p.done) / result.Rate
+		result.ETA = time.Duration(remaining * float64(time.Second))
+	}
+
+	return result
+}
+
+// Progress is a snapshot of the state of a ProgressLogger.
+type Progress struct {
+	Done, Total int
+	Elapsed     time.Duration
+	// Iterations per second.
+	Rate float64
+	// Estimated time remaining, assuming a constant rate.
+	ETA time.Duration
+	// Running mean of the values reported with each iteration.
+	MeanValue float64
+}
+
+func (p Progress) String() string {
+	pct := 0.0
+	if p.Total > 0 {
+		pct = 100 * float64(p.Done) / float64(p.Total)
+	}
+
+	return fmt.Sprintf("%d/%d (%.1f%%) done, %.2f/sec, elapsed %v, ETA %v, mean value %.4f",
+		p.Done, p.Total, pct, p.Rate, p.Elapsed.Round(time.Second),
+		p.ETA.Round(time.Second), p.MeanValue)
+}
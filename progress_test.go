@@ -0,0 +1,53 @@
+package alphacats
+
+import (
+	"testing"
+	"time"
+)
+
+func TestProgressLogger(t *testing.T) {
+	now := time.Unix(1000, 0)
+	clock := func() time.Time { return now }
+	p := newProgressLogger("test", 1000, 100, clock)
+
+	now = now.Add(10 * time.Second)
+	p.Add(200, 1.0)
+	p.Add(50, -1.0)
+
+	progress := p.Progress()
+	if progress.Done != 250 || progress.Total != 1000 {
+		t.Errorf("expected 250/1000 done, got %d/%d", progress.Done, progress.Total)
+	}
+
+	if progress.Elapsed != 10*time.Second {
+		t.Errorf("expected elapsed 10s, got %v", progress.Elapsed)
+	}
+
+	if progress.Rate != 25.0 {
+		t.Errorf("expected rate 25/sec, got %v", progress.Rate)
+	}
+
+	// 750 iterations remaining at 25/sec.
+	if progress.ETA != 30*time.Second {
+		t.Errorf("expected ETA 30s, got %v", progress.ETA)
+	}
+
+	if progress.MeanValue != 0.6 {
+		t.Errorf("expected mean value 0.6, got %v", progress.MeanValue)
+	}
+
+	p.Add(750, 0.0)
+	if progress := p.Progress(); progress.ETA != 0 {
+		t.Errorf("expected ETA 0 when done, got %v", progress.ETA)
+	}
+}
+
+func TestProgressLoggerNoElapsedTime(t *testing.T) {
+	now := time.Unix(1000, 0)
+	p := newProgressLogger("test", 10, 1, func() time.Time { return now })
+	p.Add(1, 1.0)
+	progress := p.Progress()
+	if progress.Rate != 0 || progress.ETA != 0 {
+		t.Errorf("expected no rate or ETA with no elapsed time, got %v", progress)
+	}
+}
@@ -0,0 +1,103 @@
+package alphacats
+
+import (
+	"sort"
+
+	"github.com/timpalpant/go-cfr"
+
+	"github.com/timpalpant/alphacats/gamestate"
+)
+
+// InfoSetRegret is the expected utility that a policy gives up at one of a
+// player's info sets, relative to the best response action.
+type InfoSetRegret struct {
+	InfoSet gamestate.InfoSet
+	// Actions available at the info set, and the mean value of each
+	// (as computed by BestResponsePolicy.ActionValues) over all visits.
+	Actions []gamestate.Action
+	Values  []float64
+	// The mean value of the policy's mix of actions over all visits.
+	PolicyValue float64
+	// Number of times the info set was reached while sampling games.
+	Visits int
+}
+
+// BestAction returns the action with the highest mean value.
+func (r *InfoSetRegret) BestAction() gamestate.Action {
+	return r.Actions[argmax(r.Values)]
+}
+
+// Regret returns how much lower the mean value of the policy is than that
+// of the best action.
+func (r *InfoSetRegret) Regret() float64 {
+	return r.Values[argmax(r.Values)] - r.PolicyValue
+}
+
+// SampleRegrets finds the info sets at which policy gives up the most
+// expected utility for the given player, sorted by decreasing regret.
+//
+// Games are sampled from the given node with both players following policy,
+// for a budget of numGames games. At each of the player's decisions, every
+// action is valued by playing a best response to policy for the rest of the
+// game (see BestResponsePolicy). The values are averaged over all visits to
+// the info set, so that the result reflects the hidden states that the
+// player is likely to face. Like BestResponsePolicy, this is only tractable
+// for small decks such as cards.TestDeck.
+func SampleRegrets(policy func(cfr.GameTreeNode) []float32, node *GameNode, player int, numGames int) []*InfoSetRegret {
+	br := NewBestResponsePolicy(player, policy)
+	byInfoSet := make(map[gamestate.InfoSet]*InfoSetRegret)
+	for i := 0; i < numGames; i++ {
+		root := node.Clone()
+		var game cfr.GameTreeNode = root
+		for game.Type() != cfr.TerminalNodeType {
+			if game.Type() == cfr.ChanceNodeType {
+				game, _ = game.SampleChild()
+				continue
+			}
+
+			p := policy(game)
+			if game.Player() == player {
+				gn := game.(*GameNode)
+				is := gn.GetInfoSet(gamestate.Player(player))
+				r, ok := byInfoSet[is]
+				if !ok {
+					ais := gn.InfoSet(player).(*AbstractedInfoSet)
+					r = &InfoSetRegret{
+						InfoSet: is,
+						Actions: ais.AvailableActions,
+						Values:  make([]float64, len(ais.AvailableActions)),
+					}
+					byInfoSet[is] = r
+				}
+
+				values := br.ActionValues(game)
+				policyValue := 0.0
+				for j, v := range values {
+					policyValue += float64(p[j]) * v
+				}
+
+				// Update the running means with this visit.
+				r.Visits++
+				for j, v := range values {
+					r.Values[j] += (v - r.Values[j]) / float64(r.Visits)
+				}
+				r.PolicyValue += (policyValue - r.PolicyValue) / float64(r.Visits)
+			}
+
+			game = game.GetChild(sampleOne(p))
+		}
+
+		root.Close()
+	}
+
+	result := make([]*InfoSetRegret, 0, len(byInfoSet))
+	for _, r := range byInfoSet {
+		result = append(result, r)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Regret() > result[j].Regret()
+	})
+
+	return result
+}
@@ -0,0 +1,84 @@
+package alphacats
+
+import (
+	"math"
+	"testing"
+
+	"github.com/timpalpant/go-cfr"
+
+	"github.com/timpalpant/alphacats/cards"
+	"github.com/timpalpant/alphacats/gamestate"
+)
+
+func alwaysDrawPolicy(node cfr.GameTreeNode) []float32 {
+	for i := 0; i < node.NumChildren(); i++ {
+		action := node.GetChild(i).(*GameNode).LastAction()
+		if action.Type == gamestate.DrawCard {
+			p := make([]float32, node.NumChildren())
+			p[i] = 1.0
+			return p
+		}
+	}
+
+	return uniformPolicy(node)
+}
+
+func TestSampleRegrets(t *testing.T) {
+	// Player 0 wins if they play Skip, since player 1 must then draw the
+	// kitten, and loses if they draw the kitten themselves.
+	drawPile := cards.NewStackFromCards([]cards.Card{cards.ExplodingKitten, cards.Cat})
+	p0Deal := cards.NewSetFromCards([]cards.Card{cards.Skip})
+	game := NewGame(drawPile, p0Deal, cards.NewSet())
+
+	testCases := []struct {
+		policy   func(cfr.GameTreeNode) []float32
+		expected float64
+	}{
+		{alwaysDrawPolicy, 2.0},
+		{uniformPolicy, 1.0},
+		{alwaysSkipPolicy, 0.0},
+	}
+
+	for _, tc := range testCases {
+		regrets := SampleRegrets(tc.policy, game, 0, 10)
+		if len(regrets) != 1 {
+			t.Fatalf("expected 1 info set for player 0, got %d", len(regrets))
+		}
+
+		r := regrets[0]
+		if r.InfoSet != game.GetInfoSet(gamestate.Player0) || r.Visits != 10 {
+			t.Errorf("expected 10 visits to the initial info set, got %d to %v",
+				r.Visits, r.InfoSet)
+		}
+
+		if math.Abs(r.Regret()-tc.expected) > 1e-6 {
+			t.Errorf("expected regret %v, got %v", tc.expected, r.Regret())
+		}
+
+		if best := r.BestAction(); best.Type != gamestate.PlayCard || best.Card != cards.Skip {
+			t.Errorf("expected best action to play Skip, got %v", best)
+		}
+	}
+}
+
+func TestSampleRegretsSorted(t *testing.T) {
+	game := newTestDeckGame()
+	regrets := SampleRegrets(uniformPolicy, game, 0, 20)
+	if len(regrets) == 0 {
+		t.Fatal("expected player 0 to have decisions")
+	}
+
+	for i := 1; i < len(regrets); i++ {
+		if regrets[i].Regret() > regrets[i-1].Regret() {
+			t.Errorf("regrets are not sorted: %v > %v at %d",
+				regrets[i].Regret(), regrets[i-1].Regret(), i)
+		}
+	}
+
+	for _, r := range regrets {
+		if r.Regret() < -1e-6 {
+			t.Errorf("best response action should never do worse than the policy, got regret %v",
+				r.Regret())
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package alphacats
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/timpalpant/alphacats/gamestate"
+)
+
+// RenderBoard returns a human-readable description of the game from the
+// point of view of the given player. Only information available to that
+// player is shown: their own hand, the sizes of the opponent's hand and
+// the draw pile, any cards they know in the draw pile, and the last action.
+func (gn *GameNode) RenderBoard(player int) string {
+	p := gamestate.Player(player)
+	is := gn.GetInfoSet(p)
+	abstracted := newAbstractedInfoSet(&is, nil, gn.initialDrawPileLen())
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%v's view of the board:\n", p)
+	fmt.Fprintf(&sb, "  Hand: %v\n", is.Hand)
+	opponentHand := gn.state.GetPlayerHand(1 - p)
+	fmt.Fprintf(&sb, "  Opponent's hand: %d cards\n", opponentHand.Len())
+
+	drawPile := gn.state.GetDrawPile()
+	fmt.Fprintf(&sb, "  Draw pile: %d cards\n", drawPile.Len())
+	var known []string
+	for i := 0; i < drawPile.Len(); i++ {
+		if card := abstracted.DrawPile.NthCard(i); !card.IsPlaceholder() {
+			known = append(known, fmt.Sprintf("%d: %v", i, card))
+		}
+	}
+	if len(known) > 0 {
+		fmt.Fprintf(&sb, "  Known cards in draw pile: %s\n", strings.Join(known, ", "))
+	}
+
+	if n := is.History.Len(); n > 0 {
+		fmt.Fprintf(&sb, "  Last action: %v\n", is.History.Get(n-1))
+	} else {
+		sb.WriteString("  Last action: none\n")
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,39 @@
+package alphacats
+
+import (
+	"testing"
+
+	"github.com/timpalpant/alphacats/cards"
+)
+
+func TestRenderBoard(t *testing.T) {
+	drawPile := cards.NewStackFromCards([]cards.Card{
+		cards.Cat, cards.Skip, cards.ExplodingKitten, cards.Cat, cards.Shuffle,
+	})
+	p0Deal := cards.NewSetFromCards([]cards.Card{cards.SeeTheFuture, cards.Slap1x, cards.Defuse})
+	p1Deal := cards.NewSetFromCards([]cards.Card{cards.Skip, cards.Defuse})
+	game := NewGame(drawPile, p0Deal, p1Deal)
+	game = playCard(t, game, cards.SeeTheFuture)
+
+	expected := map[int]string{
+		0: `Player0's view of the board:
+  Hand: {1 Defuse, 1 Slap1x}
+  Opponent's hand: 2 cards
+  Draw pile: 5 cards
+  Known cards in draw pile: 0: Cat, 1: Skip, 2: ExplodingKitten
+  Last action: Player0:PlayCard:SeeTheFuture:[Cat Skip ExplodingKitten]
+`,
+		1: `Player1's view of the board:
+  Hand: {1 Defuse, 1 Skip}
+  Opponent's hand: 2 cards
+  Draw pile: 5 cards
+  Last action: Player0:PlayCard:SeeTheFuture
+`,
+	}
+
+	for player, want := range expected {
+		if got := game.RenderBoard(player); got != want {
+			t.Errorf("player %d: expected:\n%s\ngot:\n%s", player, want, got)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package alphacats
+
+import (
+	"fmt"
+
+	"github.com/timpalpant/go-cfr"
+
+	"github.com/timpalpant/alphacats/cards"
+	"github.com/timpalpant/alphacats/gamestate"
+)
+
+// NewGameFromHistory replays the given history of actions from the initial
+// deal, returning the resulting game node.
+//
+// The history is expected to be the full (uncensored) history of a game,
+// as returned by GameNode.GetHistory. Chance outcomes are not recorded in the
+// history, so the draw pile is rearranged as necessary to be consistent with
+// the cards that were later drawn or seen.
+func NewGameFromHistory(deal Deal, history []gamestate.Action) (*GameNode, error) {
+	return Replay(deal, history, func(node *GameNode, selected int) {})
+}
+
+// Replay walks the game tree from the given deal, following the given history
+// of actions. The callback is invoked at each node along the way with the
+// index of the child that was taken. At chance nodes, the index is the
+// (arbitrary) outcome selected by the replay.
+func Replay(deal Deal, history []gamestate.Action, cb func(node *GameNode, selected int)) (*GameNode, error) {
+	node := NewGame(deal.DrawPile, deal.P0Deal, deal.P1Deal)
+	for i, action := range history {
+		// Advance through any chance nodes, which are not recorded in the history.
+		for node.Type() == cfr.ChanceNodeType {
+			cb(node, 0)
+			node = node.GetChild(0).(*GameNode)
+		}
+
+		if node.Type() == cfr.TerminalNodeType {
+			return nil, fmt.Errorf("game ended before action %d (%v)", i, action)
+		}
+
+		if err := node.arrangeDrawPileFor(action); err != nil {
+			return nil, fmt.Errorf("action %d (%v) is inconsistent with draw pile: %v", i, action, err)
+		}
+
+		selected := node.findChild(action)
+		if selected < 0 {
+			return nil, fmt.Errorf("action %d (%v) is not legal in %v", i, action, node)
+		}
+
+		cb(node, selected)
+		node = node.GetChild(selected).(*GameNode)
+	}
+
+	return node, nil
+}
+
+// findChild returns the index of the child reached by taking the given
+// action, or -1 if there is no such child.
+func (gn *GameNode) findChild(action gamestate.Action) int {
+	for i := 0; i < gn.NumChildren(); i++ {
+		if gn.children[i].LastAction() == action {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// arrangeDrawPileFor reorders the draw pile, without changing its composition,
+// so that any cards revealed by the given action are in the positions it
+// observed them. Must be called before this node's children are built.
+func (gn *GameNode) arrangeDrawPileFor(action gamestate.Action) error {
+	drawPile := gn.state.GetDrawPile()
+	var err error
+	switch {
+	case action.Type == gamestate.DrawCard:
+		err = arrangeCard(&drawPile, 0, action.CardsSeen[0])
+	case action.Type == gamestate.PlayCard && action.Card == cards.SeeTheFuture:
+		for i, card := range action.CardsSeen {
+			if err = arrangeCard(&drawPile, i, card); err != nil {
+				break
+			}
+		}
+	case action.Type == gamestate.PlayCard && action.Card == cards.DrawFromTheBottom:
+		err = arrangeCard(&drawPile, drawPile.Len()-1, action.CardsSeen[0])
+	}
+
+	if err != nil {
+		return err
+	}
+
+	gn.state = gamestate.NewShuffled(gn.state, drawPile)
+	return nil
+}
+
+// arrangeCard places the given card into the nth position of the draw pile
+// by swapping it with a copy of the card from a later position (or an earlier
+// one, if n is the bottom of the draw pile).
+func arrangeCard(drawPile *cards.Stack, n int, card cards.Card) error {
+	if card.IsUnknown() || drawPile.NthCard(n) == card {
+		return nil
+	}
+
+	for j := 0; j < drawPile.Len(); j++ {
+		if (j > n || n == drawPile.Len()-1) && j != n && drawPile.NthCard(j) == card {
+			drawPile.SetNthCard(j, drawPile.NthCard(n))
+			drawPile.SetNthCard(n, card)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("no %v to place in position %d of %v", card, n, drawPile)
+}
+
+// Annotate replays the given history and describes each step of the game.
+// At the given player's turns, the policy's probability for each of the
+// available actions is reported, with the action that was taken marked by
+// an asterisk. At chance and opponent nodes, only the outcome is reported.
+func Annotate(deal Deal, history []gamestate.Action, player int, policy func(cfr.GameTreeNode) []float32) ([]string, error) {
+	var result []string
+	_, err := Replay(deal, history, func(node *GameNode, selected int) {
+		if node.Type() == cfr.ChanceNodeType {
+			result = append(result, fmt.Sprintf("[chance] %v", node.turnType))
+			return
+		}
+
+		action := node.children[selected].LastAction()
+		if node.Player() != player {
+			result = append(result, fmt.Sprintf("[%v] %v", node.player, action))
+			return
+		}
+
+		p := policy(node)
+		probs := make([]string, len(p))
+		for i, x := range p {
+			probs[i] = fmt.Sprintf("%.3f", x)
+			if i == selected {
+				probs[i] = "*" + probs[i]
+			}
+		}
+
+		result = append(result, fmt.Sprintf("[strategy] %v with probability %.3f: %v",
+			action, p[selected], probs))
+	})
+
+	return result, err
+}
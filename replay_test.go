@@ -0,0 +1,81 @@
+package alphacats
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/timpalpant/go-cfr"
+
+	"github.com/timpalpant/alphacats/cards"
+)
+
+// Plays a game to completion with uniform random actions.
+func playRandomGame(rng *rand.Rand, deal Deal) *GameNode {
+	var node cfr.GameTreeNode = NewGame(deal.DrawPile, deal.P0Deal, deal.P1Deal)
+	for node.Type() != cfr.TerminalNodeType {
+		if node.Type() == cfr.ChanceNodeType {
+			node, _ = node.SampleChild()
+		} else {
+			node = node.GetChild(rng.Intn(node.NumChildren()))
+		}
+	}
+
+	return node.(*GameNode)
+}
+
+func TestNewGameFromHistory(t *testing.T) {
+	rng := rand.New(rand.NewSource(123))
+	for i := 0; i < 100; i++ {
+		deal := NewRandomDeal(cards.CoreDeck.AsSlice(), 4)
+		game := playRandomGame(rng, deal)
+		history := game.GetHistory()
+
+		replayed, err := NewGameFromHistory(deal, history.AsSlice())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if replayed.Type() != cfr.TerminalNodeType {
+			t.Errorf("replayed game is not over: %v", replayed)
+		}
+
+		if replayed.Player() != game.Player() {
+			t.Errorf("replayed game winner is %v, expected %v", replayed.Player(), game.Player())
+		}
+
+		if replayedHistory := replayed.GetHistory(); replayedHistory != history {
+			t.Errorf("expected history: %v, got: %v", history, replayedHistory)
+		}
+	}
+}
+
+func TestAnnotate(t *testing.T) {
+	deal := Deal{
+		DrawPile: cards.NewStackFromCards([]cards.Card{cards.Cat, cards.ExplodingKitten}),
+		P0Deal:   cards.NewSetFromCards([]cards.Card{cards.Skip, cards.Defuse}),
+		P1Deal:   cards.NewSetFromCards([]cards.Card{cards.Slap1x}),
+	}
+
+	// Player0 draws the Cat, then Player1 draws the kitten without a Defuse.
+	game := NewGame(deal.DrawPile, deal.P0Deal, deal.P1Deal)
+	game = drawCard(t, game)
+	game = drawCard(t, game)
+	if game.Type() != cfr.TerminalNodeType {
+		t.Fatalf("expected game to be over: %v", game)
+	}
+
+	history := game.GetHistory()
+	annotations, err := Annotate(deal, history.AsSlice(), 0, uniformPolicy)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"[strategy] Player0:DrawCard:Cat with probability 0.500: [0.500 *0.500]",
+		"[Player1] Player1:DrawCard:ExplodingKitten:ExplodingKitten",
+	}
+	if !reflect.DeepEqual(annotations, expected) {
+		t.Errorf("expected annotations: %q, got: %q", expected, annotations)
+	}
+}
@@ -0,0 +1,96 @@
+package alphacats
+
+import (
+	"fmt"
+
+	"github.com/timpalpant/alphacats/cards"
+	"github.com/timpalpant/alphacats/gamestate"
+)
+
+// cardEffect describes what happens to the turn when a card is played on a
+// normal turn. Any changes to the game state itself (e.g. the cards seen
+// with SeeTheFuture) are made by gamestate.GameState.Apply.
+//
+// At most one of shuffle, opponentTurns and targetsOpponent may be set.
+// If none are set, the player continues their turn, unless endsTurn is set.
+type cardEffect struct {
+	// playable is set if the card may be played on a normal turn.
+	playable bool
+	// endsTurn is set if playing the card uses up one of the player's
+	// pending turns, as if they had drawn a card.
+	endsTurn bool
+	// shuffle is set if the draw pile is shuffled, by a chance node.
+	shuffle bool
+	// opponentTurns, if positive, ends all of the player's pending turns and
+	// passes play to the opponent with this many pending turns. If the card
+	// was played in response to another card that passes turns, the
+	// player's pending turns may be passed on as well (see SlapBackRule).
+	opponentTurns int
+	// targetsOpponent is set if the opponent must give the player a card
+	// from their hand. If their hand is empty, this is a no-op.
+	targetsOpponent bool
+}
+
+// cardEffects is the rules table for the effect of playing each card.
+// Variants and new cards are supported by extending this table.
+var cardEffects = [cards.NumTypes]cardEffect{
+	cards.Skip:              {playable: true, endsTurn: true},
+	cards.Slap1x:            {playable: true, opponentTurns: 1},
+	cards.Slap2x:            {playable: true, opponentTurns: 2},
+	cards.SeeTheFuture:      {playable: true},
+	cards.Shuffle:           {playable: true, shuffle: true},
+	cards.DrawFromTheBottom: {playable: true, endsTurn: true},
+	cards.Cat:               {playable: true, targetsOpponent: true},
+}
+
+// SlapBackRule determines when a card that passes turns to the opponent
+// (see cardEffect.opponentTurns) "slaps back": if it is played immediately
+// in response to another such card, the player's pending turns are passed
+// on to the opponent as well.
+type SlapBackRule uint8
+
+const (
+	// Any card that passes turns slaps back, as in the standard game.
+	AnySlapBack SlapBackRule = iota
+	// A card only slaps back if it passes at least as many turns as the
+	// card it responds to, so that Slap2x may slap back Slap1x, but not
+	// the reverse.
+	EqualOrHigherSlapBack
+)
+
+var slapBackRuleStr = [...]string{
+	"AnySlapBack",
+	"EqualOrHigherSlapBack",
+}
+
+func (r SlapBackRule) String() string {
+	return slapBackRuleStr[r]
+}
+
+// slapsBack returns whether playing the given card slaps back the last
+// action, under this rule.
+func (r SlapBackRule) slapsBack(card cards.Card, lastAction gamestate.Action) bool {
+	if lastAction.Type != gamestate.PlayCard {
+		return false
+	}
+
+	turns := cardEffects[card].opponentTurns
+	lastTurns := cardEffects[lastAction.Card].opponentTurns
+	if turns <= 0 || lastTurns <= 0 {
+		return false
+	}
+
+	switch r {
+	case AnySlapBack:
+		return true
+	case EqualOrHigherSlapBack:
+		return turns >= lastTurns
+	default:
+		panic(fmt.Errorf("unknown slap back rule: %d", r))
+	}
+}
+
+// Whether the given card may be played on a normal turn.
+func isPlayable(card cards.Card) bool {
+	return cardEffects[card].playable
+}
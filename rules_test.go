@@ -0,0 +1,429 @@
+package alphacats
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/timpalpant/go-cfr"
+
+	"github.com/timpalpant/alphacats/cards"
+	"github.com/timpalpant/alphacats/gamestate"
+)
+
+// ruleCase is a canonical situation from the rules of the game. Starting
+// from the game spec (see NewGameFromSpec), the moves (in move notation, see
+// gamestate.ParseMove) are taken by whichever player is to act. The node
+// that results must then have the expected turn type, player and number of
+// pending turns. At GameOver nodes, the player is the winner and the reason
+// the game ended is checked instead of the pending turns.
+type ruleCase struct {
+	name         string
+	spec         string
+	moves        []string
+	turnType     turnType
+	player       gamestate.Player
+	pendingTurns int
+	reason       GameOverReason
+	// illegal is set if the last move must be rejected, in which case
+	// the expected fields describe the node it was rejected at.
+	illegal bool
+}
+
+var ruleCases = []ruleCase{
+	// Ending a turn.
+	{
+		name:     "drawing ends the turn",
+		spec:     "[Cat, ExplodingKitten]; {1 Skip}; {1 Skip}",
+		moves:    []string{"D"},
+		turnType: PlayTurn, player: gamestate.Player1, pendingTurns: 1,
+	},
+	{
+		name:     "skip ends the turn without drawing",
+		spec:     "[Cat, ExplodingKitten]; {1 Skip}; {1 Skip}",
+		moves:    []string{"P:Skip"},
+		turnType: PlayTurn, player: gamestate.Player1, pendingTurns: 1,
+	},
+	{
+		name:     "draw from the bottom ends the turn",
+		spec:     "[ExplodingKitten, Cat]; {1 DrawFromTheBottom}; {1 Skip}",
+		moves:    []string{"P:DrawFromTheBottom"},
+		turnType: PlayTurn, player: gamestate.Player1, pendingTurns: 1,
+	},
+	{
+		name:     "see the future does not end the turn",
+		spec:     "[Cat, ExplodingKitten]; {1 SeeTheFuture}; {1 Skip}",
+		moves:    []string{"P:SeeTheFuture"},
+		turnType: PlayTurn, player: gamestate.Player0, pendingTurns: 1,
+	},
+	{
+		name:     "shuffle is followed by a chance node",
+		spec:     "[Cat, ExplodingKitten]; {1 Shuffle}; {1 Skip}",
+		moves:    []string{"P:Shuffle"},
+		turnType: ShuffleDrawPile, player: gamestate.Player0, pendingTurns: 1,
+	},
+
+	// Slaps and pending turns.
+	{
+		name:     "slap 1x gives the opponent one turn",
+		spec:     "[Cat, ExplodingKitten]; {1 Slap1x}; {1 Skip}",
+		moves:    []string{"P:Slap1x"},
+		turnType: PlayTurn, player: gamestate.Player1, pendingTurns: 1,
+	},
+	{
+		name:     "slap 2x gives the opponent two turns",
+		spec:     "[Cat, ExplodingKitten]; {1 Slap2x}; {1 Skip}",
+		moves:    []string{"P:Slap2x"},
+		turnType: PlayTurn, player: gamestate.Player1, pendingTurns: 2,
+	},
+	{
+		name:     "skip with 2 pending turns leaves 1, same player",
+		spec:     "[Cat, ExplodingKitten]; {1 Slap2x}; {1 Skip}",
+		moves:    []string{"P:Slap2x", "P:Skip"},
+		turnType: PlayTurn, player: gamestate.Player1, pendingTurns: 1,
+	},
+	{
+		name:     "draw with 2 pending turns leaves 1, same player",
+		spec:     "[Cat, ExplodingKitten]; {1 Slap2x}; {1 Skip}",
+		moves:    []string{"P:Slap2x", "D"},
+		turnType: PlayTurn, player: gamestate.Player1, pendingTurns: 1,
+	},
+	{
+		name:     "second of 2 pending turns passes play",
+		spec:     "[Cat, Cat, ExplodingKitten]; {1 Slap2x}; {1 Skip}",
+		moves:    []string{"P:Slap2x", "D", "D"},
+		turnType: PlayTurn, player: gamestate.Player0, pendingTurns: 1,
+	},
+	{
+		name:     "slap back adds the pending turns",
+		spec:     "[Cat, ExplodingKitten]; {1 Slap1x}; {1 Slap1x}",
+		moves:    []string{"P:Slap1x", "P:Slap1x"},
+		turnType: PlayTurn, player: gamestate.Player0, pendingTurns: 2,
+	},
+	{
+		name:     "slap 2x back on slap 2x",
+		spec:     "[Cat, ExplodingKitten]; {1 Slap2x}; {1 Slap2x}",
+		moves:    []string{"P:Slap2x", "P:Slap2x"},
+		turnType: PlayTurn, player: gamestate.Player0, pendingTurns: 4,
+	},
+	{
+		name:     "slap after drawing does not add the pending turns",
+		spec:     "[Cat, ExplodingKitten]; {1 Slap2x}; {1 Slap1x}",
+		moves:    []string{"P:Slap2x", "D", "P:Slap1x"},
+		turnType: PlayTurn, player: gamestate.Player0, pendingTurns: 1,
+	},
+
+	// Cats.
+	{
+		name:     "cat against empty-handed opponent is a no-op",
+		spec:     "[Cat, ExplodingKitten]; {1 Cat}; {}",
+		moves:    []string{"P:Cat"},
+		turnType: PlayTurn, player: gamestate.Player0, pendingTurns: 1,
+	},
+	{
+		name:     "cat makes the opponent give a card",
+		spec:     "[Cat, ExplodingKitten]; {1 Cat}; {1 Skip}",
+		moves:    []string{"P:Cat"},
+		turnType: GiveCard, player: gamestate.Player1,
+	},
+	{
+		name:     "giving a card returns to the cat player's turn",
+		spec:     "[Cat, ExplodingKitten]; {1 Cat}; {1 Skip}",
+		moves:    []string{"P:Cat", "G:Skip"},
+		turnType: PlayTurn, player: gamestate.Player0, pendingTurns: 1,
+	},
+	{
+		name:     "giving a card keeps the cat player's pending turns",
+		spec:     "[Cat, ExplodingKitten]; {1 Slap2x, 1 Skip}; {1 Cat}",
+		moves:    []string{"P:Slap2x", "P:Cat", "G:Skip"},
+		turnType: PlayTurn, player: gamestate.Player1, pendingTurns: 2,
+	},
+
+	// Exploding kittens.
+	{
+		name:     "drawing the kitten without a defuse loses",
+		spec:     "[ExplodingKitten, Cat]; {1 Skip}; {1 Skip}",
+		moves:    []string{"D"},
+		turnType: GameOver, player: gamestate.Player1, reason: OpponentExploded,
+	},
+	{
+		name:     "drawing the kitten from the bottom without a defuse loses",
+		spec:     "[Cat, ExplodingKitten]; {1 DrawFromTheBottom}; {1 Skip}",
+		moves:    []string{"P:DrawFromTheBottom"},
+		turnType: GameOver, player: gamestate.Player1, reason: OpponentExplodedFromBottom,
+	},
+	{
+		name:     "drawing the kitten with a defuse must defuse it",
+		spec:     "[ExplodingKitten, Cat]; {1 Defuse}; {1 Skip}",
+		moves:    []string{"D"},
+		turnType: MustDefuse, player: gamestate.Player0, pendingTurns: 0,
+	},
+	{
+		name:     "defusing the kitten ends the turn",
+		spec:     "[ExplodingKitten, Cat]; {1 Defuse}; {1 Skip}",
+		moves:    []string{"D", "I:1"},
+		turnType: PlayTurn, player: gamestate.Player1, pendingTurns: 1,
+	},
+	{
+		name:     "defusing the kitten with 2 pending turns leaves 1",
+		spec:     "[ExplodingKitten, Cat]; {1 Slap2x}; {1 Defuse}",
+		moves:    []string{"P:Slap2x", "D", "I:2"},
+		turnType: PlayTurn, player: gamestate.Player1, pendingTurns: 1,
+	},
+	{
+		name:     "inserting the kitten randomly is followed by a chance node",
+		spec:     "[ExplodingKitten, Cat]; {1 Defuse}; {1 Skip}",
+		moves:    []string{"D", "I:R"},
+		turnType: InsertKittenRandom, player: gamestate.Player0, pendingTurns: 0,
+	},
+	{
+		name:     "the defused kitten explodes the next player to draw it",
+		spec:     "[ExplodingKitten, Cat]; {1 Defuse}; {1 Skip}",
+		moves:    []string{"D", "I:1", "D"},
+		turnType: GameOver, player: gamestate.Player0, reason: OpponentExploded,
+	},
+
+	// Illegal moves.
+	{
+		name:     "defuse cannot be played on a normal turn",
+		spec:     "[Cat, ExplodingKitten]; {1 Defuse}; {1 Skip}",
+		moves:    []string{"P:Defuse"},
+		turnType: PlayTurn, player: gamestate.Player0, pendingTurns: 1,
+		illegal: true,
+	},
+	{
+		name:     "cards not in hand cannot be played",
+		spec:     "[Cat, ExplodingKitten]; {1 Skip}; {1 Slap1x}",
+		moves:    []string{"P:Slap1x"},
+		turnType: PlayTurn, player: gamestate.Player0, pendingTurns: 1,
+		illegal: true,
+	},
+	{
+		name:     "cannot draw when a card must be given",
+		spec:     "[Cat, ExplodingKitten]; {1 Cat}; {1 Skip}",
+		moves:    []string{"P:Cat", "D"},
+		turnType: GiveCard, player: gamestate.Player1,
+		illegal: true,
+	},
+	{
+		name:     "cannot draw instead of defusing",
+		spec:     "[ExplodingKitten, Cat]; {1 Defuse}; {1 Skip}",
+		moves:    []string{"D", "D"},
+		turnType: MustDefuse, player: gamestate.Player0, pendingTurns: 0,
+		illegal: true,
+	},
+	{
+		name:     "cannot insert the kitten beyond the draw pile",
+		spec:     "[ExplodingKitten, Cat]; {1 Defuse}; {1 Skip}",
+		moves:    []string{"D", "I:3"},
+		turnType: MustDefuse, player: gamestate.Player0, pendingTurns: 0,
+		illegal: true,
+	},
+}
+
+func TestRules(t *testing.T) {
+	for _, tc := range ruleCases {
+		t.Run(tc.name, func(t *testing.T) {
+			node, err := NewGameFromSpec(tc.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i, move := range tc.moves {
+				action, err := gamestate.ParseMove(move)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				action.Player = node.player
+				child, err := node.Step(action)
+				if tc.illegal && i == len(tc.moves)-1 {
+					if err == nil {
+						t.Fatalf("expected %s to be illegal at %v", move, node)
+					}
+					break
+				} else if err != nil {
+					t.Fatal(err)
+				}
+
+				node = child
+			}
+
+			if node.turnType != tc.turnType || node.player != tc.player {
+				t.Errorf("expected %v node for %v, got %v", tc.turnType, tc.player, node)
+			}
+
+			switch node.turnType {
+			case GameOver:
+				if node.gameOverReason != tc.reason {
+					t.Errorf("expected game over reason %v, got %v", tc.reason, node.gameOverReason)
+				}
+			case GiveCard:
+				// The pending turns belong to the player who played the Cat.
+			default:
+				if node.pendingTurns != tc.pendingTurns {
+					t.Errorf("expected %d pending turns, got %v", tc.pendingTurns, node)
+				}
+			}
+		})
+	}
+}
+
+func TestSlapBackRules(t *testing.T) {
+	testCases := []struct {
+		name  string
+		spec  string
+		moves []string
+		// The expected player and pending turns under each rule.
+		player       gamestate.Player
+		pendingTurns map[SlapBackRule]int
+	}{
+		{
+			name:         "slap 1x back on slap 2x",
+			spec:         "[Cat, ExplodingKitten]; {1 Slap2x}; {1 Slap1x}",
+			moves:        []string{"P:Slap2x", "P:Slap1x"},
+			player:       gamestate.Player0,
+			pendingTurns: map[SlapBackRule]int{AnySlapBack: 3, EqualOrHigherSlapBack: 1},
+		},
+		{
+			name:         "slap 2x back on slap 1x",
+			spec:         "[Cat, ExplodingKitten]; {1 Slap1x}; {1 Slap2x}",
+			moves:        []string{"P:Slap1x", "P:Slap2x"},
+			player:       gamestate.Player0,
+			pendingTurns: map[SlapBackRule]int{AnySlapBack: 3, EqualOrHigherSlapBack: 3},
+		},
+		{
+			name:         "consecutive slap backs",
+			spec:         "[Cat, ExplodingKitten]; {1 Slap2x, 1 Slap1x}; {1 Slap2x}",
+			moves:        []string{"P:Slap2x", "P:Slap2x", "P:Slap1x"},
+			player:       gamestate.Player1,
+			pendingTurns: map[SlapBackRule]int{AnySlapBack: 5, EqualOrHigherSlapBack: 1},
+		},
+	}
+
+	for _, tc := range testCases {
+		for _, rule := range []SlapBackRule{AnySlapBack, EqualOrHigherSlapBack} {
+			node, err := NewGameFromSpec(tc.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			node.slapBackRule = rule
+			for _, move := range tc.moves {
+				action, err := gamestate.ParseMove(move)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				action.Player = node.player
+				if node, err = node.Step(action); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if node.turnType != PlayTurn || node.player != tc.player ||
+				node.pendingTurns != tc.pendingTurns[rule] {
+				t.Errorf("%s with %v: expected %d pending turns for %v, got %v",
+					tc.name, rule, tc.pendingTurns[rule], tc.player, node)
+			}
+		}
+	}
+}
+
+// applyCardSwitch is the hardcoded switch over each card that preceded the
+// rules table (see cardEffects), kept to check that the table preserves its
+// behavior for every card.
+func applyCardSwitch(gn, child *GameNode, action gamestate.Action) {
+	child.state.Apply(action, true)
+	switch action.Card {
+	case cards.SeeTheFuture:
+		makePlayTurnNode(child, gn.player, gn.pendingTurns)
+	case cards.Skip, cards.DrawFromTheBottom:
+		makePlayTurnNode(child, gn.player, gn.pendingTurns-1)
+	case cards.Shuffle:
+		child.turnType = ShuffleDrawPile
+		child.nDrawPileCards = gn.state.GetDrawPile().Len()
+	case cards.Slap1x, cards.Slap2x:
+		pendingTurns := 1
+		if action.Card == cards.Slap2x {
+			pendingTurns = 2
+		}
+
+		lastAction := gn.state.LastAction()
+		slapBack := lastAction.Type == gamestate.PlayCard && (lastAction.Card == cards.Slap1x || lastAction.Card == cards.Slap2x)
+		if slapBack {
+			pendingTurns += gn.pendingTurns
+		}
+
+		makePlayTurnNode(child, nextPlayer(gn.player), pendingTurns)
+	case cards.Cat:
+		if child.state.GetPlayerHand(nextPlayer(gn.player)).Len() == 0 {
+			makePlayTurnNode(child, gn.player, gn.pendingTurns)
+		} else {
+			makeGiveCardNode(child, nextPlayer(gn.player))
+		}
+	default:
+		panic(fmt.Errorf("Player playing unsupported %v card", action.Card))
+	}
+}
+
+// Checks that each card played at the node has the same effect as with
+// applyCardSwitch. Returns the number of cards checked.
+func checkCardEffects(t *testing.T, node *GameNode) int {
+	if node.turnType != PlayTurn {
+		return 0
+	}
+
+	n := 0
+	for i := 0; i < node.NumChildren(); i++ {
+		action := node.actions[i]
+		if action.Type != gamestate.PlayCard {
+			continue
+		}
+
+		expected := node.childPrototype()
+		applyCardSwitch(node, &expected, action)
+		if child := node.GetChild(i).(*GameNode); !sameNode(child, &expected) {
+			t.Errorf("playing %v at %v: expected %v, got %v", action.Card, node, &expected, child)
+		}
+		n++
+	}
+
+	return n
+}
+
+func TestCardEffectsTable(t *testing.T) {
+	for card := cards.Card(0); card < cards.Card(cards.NumTypes); card++ {
+		if isPlayable(card) != (card.IsActionCard() || card.IsCatCard()) {
+			t.Errorf("%v: expected playable = %v", card, !isPlayable(card))
+		}
+	}
+
+	rng := rand.New(rand.NewSource(123))
+	checked := make(map[cards.Card]int)
+	for i := 0; i < 1000; i++ {
+		deal := NewRandomDeal(cards.CoreDeck.AsSlice(), 4)
+		var node cfr.GameTreeNode = NewGame(deal.DrawPile, deal.P0Deal, deal.P1Deal)
+		for node.Type() != cfr.TerminalNodeType {
+			if node.Type() == cfr.ChanceNodeType {
+				node, _ = node.SampleChild()
+				continue
+			}
+
+			gn := node.(*GameNode)
+			if checkCardEffects(t, gn) > 0 {
+				for _, action := range gn.actions {
+					checked[action.Card]++
+				}
+			}
+
+			node = node.GetChild(rng.Intn(node.NumChildren()))
+		}
+	}
+
+	for card := cards.Card(0); card < cards.Card(cards.NumTypes); card++ {
+		if isPlayable(card) && checked[card] == 0 {
+			t.Errorf("no %v cards were checked", card)
+		}
+	}
+}
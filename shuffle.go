@@ -1,6 +1,7 @@
 package alphacats
 
 import (
+	"fmt"
 	"math/rand"
 
 	"github.com/timpalpant/alphacats/cards"
@@ -12,24 +13,242 @@ type Deal struct {
 	P1Deal   cards.Set
 }
 
+// KittenPlacement chooses the position in [0, n] at which to insert the
+// exploding kitten into a draw pile of n cards, using the source of
+// randomness of the deal (see DealConfig.Rand), or the global source if
+// rng is nil.
+type KittenPlacement func(n int, rng *rand.Rand) int
+
+// UniformKittenPlacement inserts the kitten at a uniformly random position.
+func UniformKittenPlacement(n int, rng *rand.Rand) int {
+	return randIntn(rng, n+1)
+}
+
+// NeverTopKittenPlacement inserts the kitten at a uniformly random position
+// other than the top of the draw pile (unless the draw pile is empty).
+func NeverTopKittenPlacement(n int, rng *rand.Rand) int {
+	if n == 0 {
+		return 0
+	}
+
+	return 1 + randIntn(rng, n)
+}
+
+// BottomKittenPlacement always inserts the kitten at the bottom of the draw pile.
+func BottomKittenPlacement(n int, rng *rand.Rand) int {
+	return n
+}
+
+// WeightedKittenPlacement inserts the kitten at position i with probability
+// proportional to weights[i]. Positions beyond the end of weights have
+// weight zero.
+func WeightedKittenPlacement(weights []float64) KittenPlacement {
+	return func(n int, rng *rand.Rand) int {
+		w := weights
+		if len(w) > n+1 {
+			w = w[:n+1]
+		}
+
+		total := 0.0
+		for _, x := range w {
+			total += x
+		}
+
+		if total <= 0 {
+			panic(fmt.Errorf("no valid kitten positions in draw pile of %d cards: %v", n, weights))
+		}
+
+		x := total * randFloat64(rng)
+		last := 0
+		for i := range w {
+			if w[i] <= 0 {
+				continue
+			}
+
+			x -= w[i]
+			if x < 0 {
+				return i
+			}
+
+			last = i
+		}
+
+		// x may not drop below zero due to floating point rounding.
+		return last
+	}
+}
+
+func randIntn(rng *rand.Rand, n int) int {
+	if rng != nil {
+		return rng.Intn(n)
+	}
+
+	return rand.Intn(n)
+}
+
+func randFloat64(rng *rand.Rand) float64 {
+	if rng != nil {
+		return rng.Float64()
+	}
+
+	return rand.Float64()
+}
+
+// DealConfig configures how NewRandomDealWithConfig deals the cards.
+type DealConfig struct {
+	// KittenPlacement determines where the exploding kitten is placed in
+	// the initial draw pile. Defaults to UniformKittenPlacement.
+	KittenPlacement KittenPlacement
+	// DefusesPerPlayer is the number of Defuse cards dealt to each player.
+	// One additional Defuse is always shuffled into the draw pile.
+	// Defaults to 1.
+	DefusesPerPlayer int
+	// Deck is the composition of the deck the cards are dealt from, without
+	// the Defuses and the Exploding Kitten (see cards.DeckConfig). It is
+	// used to enumerate the possible deals (see NewBeliefStateWithConfig).
+	// Defaults to cards.CoreDeck.
+	Deck cards.Set
+	// Rand is the source of randomness for the deal, including the
+	// KittenPlacement, so that a deal can be reproduced from its seed.
+	// Defaults to the global source.
+	Rand *rand.Rand
+}
+
+func (c DealConfig) intn(n int) int {
+	return randIntn(c.Rand, n)
+}
+
+func (c DealConfig) shuffle(n int, swap func(i, j int)) {
+	if c.Rand != nil {
+		c.Rand.Shuffle(n, swap)
+	} else {
+		rand.Shuffle(n, swap)
+	}
+}
+
+func (c DealConfig) defusesPerPlayer() int {
+	if c.DefusesPerPlayer <= 0 {
+		return 1
+	}
+
+	return c.DefusesPerPlayer
+}
+
+// numDefuses returns the total number of Defuse cards in play.
+func (c DealConfig) numDefuses() int {
+	return 2*c.defusesPerPlayer() + 1
+}
+
+// dealtDeck returns the deck the cards are dealt from, without the Defuses
+// and the Exploding Kitten.
+func (c DealConfig) dealtDeck() cards.Set {
+	if c.Deck.IsEmpty() {
+		return cards.CoreDeck
+	}
+
+	return c.Deck
+}
+
+// deck returns all of the cards in play for a game dealt with this config,
+// including the Defuses and the Exploding Kitten.
+func (c DealConfig) deck() cards.Set {
+	result := c.dealtDeck()
+	result.AddN(cards.Defuse, c.numDefuses())
+	result.Add(cards.ExplodingKitten)
+	return result
+}
+
+// MaxCardsPerPlayer returns the largest number of cards (not including
+// the Defuses) that can be dealt to each player from the given deck.
+func MaxCardsPerPlayer(deck []cards.Card) int {
+	n := 0
+	for _, card := range deck {
+		if card != cards.ImplodingKitten {
+			n++
+		}
+	}
+
+	return n / 2
+}
+
+// NewRandomDeal shuffles the deck (in place), deals cardsPerPlayer cards and
+// a Defuse to each player, and shuffles the exploding kitten and a Defuse
+// into the remaining cards to form the draw pile.
 func NewRandomDeal(deck []cards.Card, cardsPerPlayer int) Deal {
-	rand.Shuffle(len(deck), func(i, j int) {
+	return NewRandomDealWithConfig(deck, cardsPerPlayer, DealConfig{})
+}
+
+// NewRandomDealWithConfig is like NewRandomDeal, dealt with the given config.
+// With the zero config, it deals the same game as NewRandomDeal given the
+// same state of the global source.
+func NewRandomDealWithConfig(deck []cards.Card, cardsPerPlayer int, config DealConfig) Deal {
+	config.shuffle(len(deck), func(i, j int) {
 		deck[i], deck[j] = deck[j], deck[i]
 	})
 
-	p0Deal := cards.NewSetFromCards(deck[:cardsPerPlayer])
-	p0Deal.Add(cards.Defuse)
-	p1Deal := cards.NewSetFromCards(deck[cardsPerPlayer : 2*cardsPerPlayer])
-	p1Deal.Add(cards.Defuse)
-	drawPile := cards.NewStackFromCards(deck[2*cardsPerPlayer:])
-	randPos := rand.Intn(drawPile.Len() + 1)
-	drawPile.InsertCard(cards.ExplodingKitten, randPos)
-	randPos = rand.Intn(drawPile.Len() + 1)
-	drawPile.InsertCard(cards.Defuse, randPos)
+	// The imploding kitten (if the deck includes it) is never dealt,
+	// and is shuffled into the draw pile along with the Defuse.
+	dealt := make([]cards.Card, 0, len(deck))
+	nImplodingKittens := 0
+	for _, card := range deck {
+		if card == cards.ImplodingKitten {
+			nImplodingKittens++
+		} else {
+			dealt = append(dealt, card)
+		}
+	}
+
+	if 2*cardsPerPlayer > len(dealt) {
+		panic(fmt.Errorf("cannot deal %d cards per player from a deck of %d cards",
+			cardsPerPlayer, len(dealt)))
+	}
+
+	p0Deal := cards.NewSetFromCards(dealt[:cardsPerPlayer])
+	p0Deal.AddN(cards.Defuse, config.defusesPerPlayer())
+	p1Deal := cards.NewSetFromCards(dealt[cardsPerPlayer : 2*cardsPerPlayer])
+	p1Deal.AddN(cards.Defuse, config.defusesPerPlayer())
+	drawPile := cards.NewStackFromCards(dealt[2*cardsPerPlayer:])
+	if config.KittenPlacement == nil {
+		// NB: The kitten is inserted before the Defuse, as it always has been,
+		// so that existing seeds deal the same games.
+		drawPile.InsertCard(cards.ExplodingKitten, config.intn(drawPile.Len()+1))
+		drawPile.InsertCard(cards.Defuse, config.intn(drawPile.Len()+1))
+		insertImplodingKittens(&drawPile, nImplodingKittens, config)
+	} else {
+		// NB: The kitten is inserted last so that its position is not
+		// shifted by inserting the other cards.
+		drawPile.InsertCard(cards.Defuse, config.intn(drawPile.Len()+1))
+		insertImplodingKittens(&drawPile, nImplodingKittens, config)
+		drawPile.InsertCard(cards.ExplodingKitten, config.KittenPlacement(drawPile.Len(), config.Rand))
+	}
+
+	numDefuses := int(p0Deal.CountOf(cards.Defuse)) + int(p1Deal.CountOf(cards.Defuse)) + drawPile.CountOf(cards.Defuse)
+	if numDefuses != config.numDefuses() {
+		panic(fmt.Errorf("dealt %d Defuses, expected %d: deck should not include Defuses",
+			numDefuses, config.numDefuses()))
+	}
 
 	return Deal{drawPile, p0Deal, p1Deal}
 }
 
+func insertImplodingKittens(drawPile *cards.Stack, n int, config DealConfig) {
+	for i := 0; i < n; i++ {
+		drawPile.InsertCard(cards.ImplodingKitten, config.intn(drawPile.Len()+1))
+	}
+}
+
+// NewRandomGame returns the root of a new game dealt by NewRandomDeal.
+func NewRandomGame(deck []cards.Card, cardsPerPlayer int) *GameNode {
+	return NewRandomGameWithConfig(deck, cardsPerPlayer, DealConfig{})
+}
+
+// NewRandomGameWithConfig returns the root of a new game dealt by
+// NewRandomDealWithConfig.
+func NewRandomGameWithConfig(deck []cards.Card, cardsPerPlayer int, config DealConfig) *GameNode {
+	deal := NewRandomDealWithConfig(deck, cardsPerPlayer, config)
+	return NewGame(deal.DrawPile, deal.P0Deal, deal.P1Deal)
+}
+
 func NewRandomDealWithConstraints(drawPile cards.Stack, p1Hand cards.Set) Deal {
 	p1Hand.Remove(cards.Defuse)
 	remaining := cards.CoreDeck
@@ -75,7 +294,7 @@ func NewRandomDealWithConstraints(drawPile cards.Stack, p1Hand cards.Set) Deal {
 	nCardsInDrawPile := cards.CoreDeck.Len() - p0Hand.Len() - p1Hand.Len() + 2
 	for i := 0; i < nCardsInDrawPile; i++ {
 		nthCard := finalDrawPile.NthCard(i)
-		if nthCard == cards.Unknown {
+		if nthCard.IsUnknown() {
 			finalDrawPile.SetNthCard(i, r[0])
 			r = r[1:]
 		}
@@ -175,6 +394,9 @@ func CountDistinctShuffles(deck cards.Set) int {
 	return result
 }
 
+// EnumerateShuffles calls cb once for each distinct ordering of the given
+// cards. The order of enumeration is deterministic, since cards.Set.Iter
+// visits cards in ascending order.
 func EnumerateShuffles(deck cards.Set, cb func(shuffle cards.Stack)) {
 	enumerateShufflesHelper(deck, cards.NewStack(), 0, cb)
 }
@@ -1,6 +1,7 @@
 package alphacats
 
 import (
+	"math/rand"
 	"testing"
 
 	"github.com/timpalpant/alphacats/cards"
@@ -25,6 +26,40 @@ func TestEnumerateShuffles(t *testing.T) {
 	}
 }
 
+func TestEnumerateShufflesCount(t *testing.T) {
+	testCases := [][]cards.Card{
+		{cards.Skip},
+		{cards.Shuffle, cards.Skip, cards.Cat},
+		{cards.Cat, cards.Cat, cards.Cat},
+		{cards.Defuse, cards.Defuse, cards.Cat, cards.Cat, cards.ExplodingKitten},
+		{cards.Skip, cards.Skip, cards.Slap1x, cards.Slap2x, cards.Cat, cards.Cat, cards.Cat},
+	}
+
+	for _, tc := range testCases {
+		deck := cards.NewSetFromCards(tc)
+		var shuffles []cards.Stack
+		seen := make(map[cards.Stack]struct{})
+		EnumerateShuffles(deck, func(shuffle cards.Stack) {
+			shuffles = append(shuffles, shuffle)
+			seen[shuffle] = struct{}{}
+		})
+
+		if n := CountDistinctShuffles(deck); len(shuffles) != n || len(seen) != n {
+			t.Errorf("%v: expected %d distinct shuffles, got %d (%d distinct)",
+				tc, n, len(shuffles), len(seen))
+		}
+
+		// Enumeration order must be deterministic.
+		i := 0
+		EnumerateShuffles(deck, func(shuffle cards.Stack) {
+			if shuffle != shuffles[i] {
+				t.Errorf("%v: shuffle %d was %v, then %v", tc, i, shuffles[i], shuffle)
+			}
+			i++
+		})
+	}
+}
+
 func TestNthShuffle(t *testing.T) {
 	stack := cards.NewStackFromCards([]cards.Card{
 		cards.Shuffle,
@@ -41,3 +76,127 @@ func TestNthShuffle(t *testing.T) {
 		allShuffles[i] = shuffle
 	}
 }
+
+func TestKittenPlacement(t *testing.T) {
+	testCases := []struct {
+		placement KittenPlacement
+		isValid   func(pos, n int) bool
+	}{
+		{UniformKittenPlacement, func(pos, n int) bool { return pos >= 0 && pos <= n }},
+		{NeverTopKittenPlacement, func(pos, n int) bool { return pos > 0 && pos <= n }},
+		{BottomKittenPlacement, func(pos, n int) bool { return pos == n }},
+		{WeightedKittenPlacement([]float64{0, 0, 1}), func(pos, n int) bool { return pos == 2 }},
+		{WeightedKittenPlacement([]float64{0, 1, 0}), func(pos, n int) bool { return pos == 1 }},
+	}
+
+	deck := cards.CoreDeck.AsSlice()
+	for i, tc := range testCases {
+		for k := 0; k < 100; k++ {
+			deal := NewRandomDealWithConfig(deck, 4, DealConfig{KittenPlacement: tc.placement})
+			n := deal.DrawPile.Len()
+			pos := -1
+			for j := 0; j < n; j++ {
+				if deal.DrawPile.NthCard(j) == cards.ExplodingKitten {
+					pos = j
+				}
+			}
+
+			// Position is relative to the draw pile before the kitten was inserted.
+			if !tc.isValid(pos, n-1) {
+				t.Errorf("case %d: invalid kitten position %d in draw pile: %v",
+					i, pos, deal.DrawPile)
+				break
+			}
+		}
+	}
+}
+
+func TestKittenPlacementSeeded(t *testing.T) {
+	placements := []KittenPlacement{
+		UniformKittenPlacement,
+		NeverTopKittenPlacement,
+		WeightedKittenPlacement([]float64{1, 2, 3, 4}),
+	}
+
+	for i, placement := range placements {
+		deal := func() Deal {
+			config := DealConfig{
+				KittenPlacement: placement,
+				Rand:            rand.New(rand.NewSource(123)),
+			}
+			return NewRandomDealWithConfig(cards.CoreDeck.AsSlice(), 4, config)
+		}
+
+		expected := deal()
+		for k := 0; k < 10; k++ {
+			if d := deal(); d != expected {
+				t.Errorf("case %d: expected seeded deal %v, got %v", i, expected, d)
+				break
+			}
+		}
+	}
+}
+
+func TestNewRandomGameWithConfig(t *testing.T) {
+	config := DealConfig{KittenPlacement: BottomKittenPlacement}
+	for k := 0; k < 10; k++ {
+		game := NewRandomGameWithConfig(cards.CoreDeck.AsSlice(), 4, config)
+		drawPile := game.state.GetDrawPile()
+		if card := drawPile.NthCard(drawPile.Len() - 1); card != cards.ExplodingKitten {
+			t.Errorf("expected kitten at the bottom of the draw pile: %v", drawPile)
+		}
+	}
+}
+
+// The default deal must match the deal from before DealConfig was added,
+// so that existing seeds deal the same games.
+func TestNewRandomDealMatchesBaseline(t *testing.T) {
+	baselineDeal := func(deck []cards.Card, cardsPerPlayer int, rng *rand.Rand) Deal {
+		rng.Shuffle(len(deck), func(i, j int) {
+			deck[i], deck[j] = deck[j], deck[i]
+		})
+
+		p0Deal := cards.NewSetFromCards(deck[:cardsPerPlayer])
+		p0Deal.Add(cards.Defuse)
+		p1Deal := cards.NewSetFromCards(deck[cardsPerPlayer : 2*cardsPerPlayer])
+		p1Deal.Add(cards.Defuse)
+		drawPile := cards.NewStackFromCards(deck[2*cardsPerPlayer:])
+		drawPile.InsertCard(cards.ExplodingKitten, rng.Intn(drawPile.Len()+1))
+		drawPile.InsertCard(cards.Defuse, rng.Intn(drawPile.Len()+1))
+		return Deal{drawPile, p0Deal, p1Deal}
+	}
+
+	for seed := int64(0); seed < 20; seed++ {
+		expected := baselineDeal(cards.CoreDeck.AsSlice(), 4, rand.New(rand.NewSource(seed)))
+		deal := NewRandomDealWithConfig(cards.CoreDeck.AsSlice(), 4,
+			DealConfig{Rand: rand.New(rand.NewSource(seed))})
+		if deal != expected {
+			t.Errorf("seed %d: expected baseline deal %v, got %v", seed, expected, deal)
+		}
+	}
+}
+
+func TestMaxCardsPerPlayer(t *testing.T) {
+	deck := cards.TestDeck.AsSlice()
+	n := MaxCardsPerPlayer(deck)
+	if n != 3 {
+		t.Errorf("expected at most 3 cards per player, got %d", n)
+	}
+
+	// The ImplodingKitten is never dealt.
+	if got := MaxCardsPerPlayer(append(deck, cards.ImplodingKitten)); got != n {
+		t.Errorf("expected at most %d cards per player with the ImplodingKitten, got %d", n, got)
+	}
+
+	deal := NewRandomDeal(deck, n)
+	if deal.P0Deal.Len() != n+1 || deal.P1Deal.Len() != n+1 {
+		t.Errorf("expected %d cards per player, got %v and %v", n+1, deal.P0Deal, deal.P1Deal)
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf("expected to panic dealing %d cards per player", n+1)
+		}
+	}()
+	NewRandomDeal(deck, n+1)
+}
@@ -11,14 +11,25 @@ const maxPoolSize = 1024
 type gameNodeSlicePool struct {
 	mx   sync.Mutex
 	pool [][]GameNode
+	// Slices for nodes with a single child are kept separately, so
+	// that they are not handed out (and then regrown) for larger nodes.
+	singles [][]GameNode
+	// If debug is set, freed slices are cleared rather than reused,
+	// so that use after free can be detected (see GameOptions.DebugPool).
+	debug bool
 }
 
 func (p *gameNodeSlicePool) alloc(n int) []GameNode {
 	p.mx.Lock()
-	m := len(p.pool)
+	pool := &p.pool
+	if n == 1 {
+		pool = &p.singles
+	}
+
+	m := len(*pool)
 	if m > 0 {
-		next := p.pool[m-1]
-		p.pool = p.pool[:m-1]
+		next := (*pool)[m-1]
+		*pool = (*pool)[:m-1]
 		p.mx.Unlock()
 		return next[:0]
 	}
@@ -28,9 +39,23 @@ func (p *gameNodeSlicePool) alloc(n int) []GameNode {
 }
 
 func (p *gameNodeSlicePool) free(s []GameNode) {
+	if p.debug {
+		for i := range s {
+			// NB: The pool is kept so that the freed node is still
+			// checked (see GameNode.checkLive).
+			s[i] = GameNode{gnPool: p}
+		}
+		return
+	}
+
 	p.mx.Lock()
-	if len(p.pool) < maxPoolSize {
-		p.pool = append(p.pool, s[:0])
+	pool := &p.pool
+	if cap(s) == 1 {
+		pool = &p.singles
+	}
+
+	if len(*pool) < maxPoolSize {
+		*pool = append(*pool, s[:0])
 	}
 	p.mx.Unlock()
 }
@@ -0,0 +1,214 @@
+package alphacats
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+
+	"github.com/timpalpant/go-cfr"
+	"github.com/timpalpant/go-cfr/mcts"
+
+	"github.com/timpalpant/alphacats/cards"
+	"github.com/timpalpant/alphacats/gamestate"
+)
+
+// Minimax returns the value of the given node for player if both players
+// play optimally knowing the full state of the game, including the order of
+// the draw pile, and chance outcomes occur with their probability.
+//
+// NOTE: This is the clairvoyant value of the perfect-information game, in
+// which both players see the draw pile and each other's hands. It is not a
+// bound on the value of the real game for either player: a player can do
+// better than it against an opponent who cannot see the cards either, and
+// worse than it against one who can. Like ExpectedUtility, it expands the
+// full game tree below the node.
+func Minimax(node *GameNode, player int) float64 {
+	return playerValue(minimaxValue(node, nil), player)
+}
+
+// Tablebase holds the clairvoyant values (see Minimax) of endgame positions,
+// in which there are at most maxCards cards left in the draw pile. The game
+// below such positions is small enough to solve exactly, so searches can
+// look up their values rather than estimating them (see TablebaseEvaluator).
+//
+// Positions are keyed by the canonical state of the game, so transpositions
+// reached by different histories share an entry. It is safe to use a
+// Tablebase concurrently: positions are solved without holding a lock, so
+// concurrent lookups of unsolved positions proceed in parallel (and may
+// solve the same positions more than once).
+type Tablebase struct {
+	maxCards int
+
+	// values maps each tablebaseKey solved to its value (a float64).
+	values sync.Map
+	n      int64
+}
+
+// tablebaseKey is the part of a GameNode that determines its value.
+type tablebaseKey struct {
+	drawPile     cards.Stack
+	hands        [2]cards.Set
+	player       gamestate.Player
+	turnType     turnType
+	pendingTurns int
+	maxHandSize  int
+	// lastSlap is the card played by the last action if it passed turns
+	// to the player, since passing turns back may also pass their pending
+	// turns (see SlapBackRule).
+	lastSlap     cards.Card
+	slapBackRule SlapBackRule
+	// faceUp is whether the ImplodingKitten has been put back face up,
+	// in which case drawing it again ends the game.
+	faceUp bool
+}
+
+func newTablebaseKey(node *GameNode) tablebaseKey {
+	var lastSlap cards.Card
+	lastAction := node.state.LastAction()
+	if lastAction.Type == gamestate.PlayCard && cardEffects[lastAction.Card].opponentTurns > 0 {
+		lastSlap = lastAction.Card
+	}
+
+	return tablebaseKey{
+		drawPile: node.state.GetDrawPile(),
+		hands: [2]cards.Set{
+			node.state.GetPlayerHand(gamestate.Player0),
+			node.state.GetPlayerHand(gamestate.Player1),
+		},
+		player:       node.player,
+		turnType:     node.turnType,
+		pendingTurns: node.pendingTurns,
+		maxHandSize:  node.maxHandSize,
+		lastSlap:     lastSlap,
+		slapBackRule: node.slapBackRule,
+		faceUp:       implodingKittenIsFaceUp(node.state.GetHistory()),
+	}
+}
+
+// NewTablebase returns an empty Tablebase for positions with at most
+// maxCards cards in the draw pile.
+func NewTablebase(maxCards int) *Tablebase {
+	return &Tablebase{maxCards: maxCards}
+}
+
+// Len returns the number of positions that have been solved.
+func (tb *Tablebase) Len() int {
+	return int(atomic.LoadInt64(&tb.n))
+}
+
+// Contains returns whether the given node is within the tablebase.
+func (tb *Tablebase) Contains(node *GameNode) bool {
+	return node.state.GetDrawPile().Len() <= tb.maxCards
+}
+
+// Value returns the clairvoyant value of the given node for player (see Minimax),
+// and whether the node is within the tablebase. Positions that have not been
+// precomputed are solved the first time they are looked up.
+func (tb *Tablebase) Value(node *GameNode, player int) (float64, bool) {
+	if !tb.Contains(node) {
+		return 0, false
+	}
+
+	return playerValue(minimaxValue(node, tb), player), true
+}
+
+// Precompute solves all positions within the tablebase that are reachable
+// from the given node. Children are freed as the tree is traversed.
+func (tb *Tablebase) Precompute(node *GameNode) {
+	if tb.Contains(node) {
+		// NB: The draw pile never grows, since the exploding kitten is
+		// only reinserted after it is drawn, so everything below is
+		// also within the tablebase.
+		minimaxValue(node, tb)
+		return
+	}
+
+	if node.Type() == cfr.TerminalNodeType {
+		return
+	}
+
+	for i := 0; i < node.NumChildren(); i++ {
+		child := node.GetChild(i).(*GameNode)
+		tb.Precompute(child)
+		child.Close()
+	}
+}
+
+// minimaxValue returns the value of the given node for Player0. If tb
+// is not nil, it is used to memoize the values of the nodes visited.
+func minimaxValue(node *GameNode, tb *Tablebase) float64 {
+	var key tablebaseKey
+	if tb != nil {
+		key = newTablebaseKey(node)
+		if v, ok := tb.values.Load(key); ok {
+			return v.(float64)
+		}
+	}
+
+	var v float64
+	switch node.Type() {
+	case cfr.TerminalNodeType:
+		v = node.Utility(int(gamestate.Player0))
+	case cfr.ChanceNodeType:
+		for i := 0; i < node.NumChildren(); i++ {
+			p := node.GetChildProbability(i)
+			child := node.GetChild(i).(*GameNode)
+			v += p * minimaxValue(child, tb)
+			child.Close()
+		}
+	default:
+		// Player0 maximizes their value, and Player1 minimizes it.
+		sign := 1.0
+		if node.player == gamestate.Player1 {
+			sign = -1.0
+		}
+
+		v = math.Inf(-1)
+		for i := 0; i < node.NumChildren(); i++ {
+			child := node.GetChild(i).(*GameNode)
+			v = math.Max(v, sign*minimaxValue(child, tb))
+			child.Close()
+		}
+		v *= sign
+	}
+
+	if tb != nil {
+		if _, loaded := tb.values.LoadOrStore(key, v); !loaded {
+			atomic.AddInt64(&tb.n, 1)
+		}
+	}
+
+	return v
+}
+
+// playerValue converts a value for Player0 to the value for player,
+// since the game is zero-sum.
+func playerValue(v float64, player int) float64 {
+	if player == int(gamestate.Player1) {
+		return -v
+	}
+
+	return v
+}
+
+// TablebaseEvaluator is an mcts.Evaluator that looks up the value of
+// positions within a Tablebase, and evaluates all other positions with
+// Fallback. The prior policy of positions within the tablebase is uniform.
+//
+// NOTE: Tablebase values are clairvoyant (see Minimax), so the search
+// treats endgame positions as if both players could see the cards.
+type TablebaseEvaluator struct {
+	Tablebase *Tablebase
+	Fallback  mcts.Evaluator
+}
+
+// Evaluate implements mcts.Evaluator. The value is for the player acting
+// at the node.
+func (e *TablebaseEvaluator) Evaluate(rng *rand.Rand, node cfr.GameTreeNode, opponent mcts.Policy) ([]float32, float32) {
+	if v, ok := e.Tablebase.Value(node.(*GameNode), node.Player()); ok {
+		return uniformDistribution(node.NumChildren()), float32(v)
+	}
+
+	return e.Fallback.Evaluate(rng, node, opponent)
+}
@@ -0,0 +1,187 @@
+package alphacats
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+	"testing"
+
+	"github.com/timpalpant/go-cfr"
+	"github.com/timpalpant/go-cfr/mcts"
+
+	"github.com/timpalpant/alphacats/cards"
+	"github.com/timpalpant/alphacats/gamestate"
+)
+
+func TestMinimax(t *testing.T) {
+	testCases := []struct {
+		spec     string
+		expected float64
+	}{
+		// Player0 must draw the kitten.
+		{"[ExplodingKitten, Cat]; {}; {1 Skip}", -1},
+		// Player0 skips, and Player1 must draw the kitten.
+		{"[ExplodingKitten, Cat]; {1 Skip}; {}", 1},
+		// Player1 can skip back.
+		{"[ExplodingKitten, Cat]; {1 Skip}; {1 Skip}", -1},
+		// Player0 draws the Cat, then Player1 draws the kitten.
+		{"[Cat, ExplodingKitten]; {}; {}", 1},
+	}
+
+	for _, tc := range testCases {
+		game, err := NewGameFromSpec(tc.spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for player, sign := range []float64{1, -1} {
+			if v := Minimax(game, player); v != sign*tc.expected {
+				t.Errorf("%s: expected value %v for player %d, got %v",
+					tc.spec, sign*tc.expected, player, v)
+			}
+		}
+	}
+}
+
+func TestTablebase(t *testing.T) {
+	const maxCards = 2
+	tb := NewTablebase(maxCards)
+	tb.Precompute(newTestDeckGame())
+	n := tb.Len()
+	t.Logf("Tablebase has %d positions", n)
+	if n == 0 {
+		t.Fatal("no positions were precomputed")
+	}
+
+	rng := rand.New(rand.NewSource(123))
+	checked := 0
+	for i := 0; i < 100; i++ {
+		var node cfr.GameTreeNode = newTestDeckGame()
+		for node.Type() != cfr.TerminalNodeType {
+			gn := node.(*GameNode)
+			for player := 0; player < 2; player++ {
+				v, ok := tb.Value(gn, player)
+				if ok != (gn.GetDrawPile().Len() <= maxCards) {
+					t.Fatalf("%v: expected within tablebase = %v", gn, !ok)
+				}
+
+				if !ok {
+					continue
+				}
+
+				if expected := Minimax(gn, player); math.Abs(v-expected) > 1e-9 {
+					t.Errorf("%v: expected value %v for player %d, got %v", gn, expected, player, v)
+				}
+				checked++
+			}
+
+			node = node.GetChild(rng.Intn(node.NumChildren()))
+		}
+	}
+
+	if checked == 0 {
+		t.Error("no positions within the tablebase were checked")
+	}
+
+	// All reachable positions were precomputed.
+	if tb.Len() != n {
+		t.Errorf("expected %d positions, got %d after lookups", n, tb.Len())
+	}
+}
+
+// Concurrent lookups solve positions in parallel, and agree with Minimax.
+func TestTablebaseConcurrent(t *testing.T) {
+	const maxCards, nWorkers = 3, 8
+	tb := NewTablebase(maxCards)
+	var wg sync.WaitGroup
+	errs := make(chan error, nWorkers)
+	for w := 0; w < nWorkers; w++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(seed))
+			var node cfr.GameTreeNode = newTestDeckGame()
+			for node.Type() != cfr.TerminalNodeType {
+				gn := node.(*GameNode)
+				if v, ok := tb.Value(gn, 0); ok {
+					if expected := Minimax(gn, 0); math.Abs(v-expected) > 1e-9 {
+						errs <- fmt.Errorf("%v: expected value %v, got %v", gn, expected, v)
+						return
+					}
+				}
+
+				node = node.GetChild(rng.Intn(node.NumChildren()))
+			}
+		}(int64(w))
+	}
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestTablebaseKeyImplodingKittenFaceUp(t *testing.T) {
+	drawPile := cards.NewStackFromCards([]cards.Card{
+		cards.ImplodingKitten, cards.Cat, cards.ExplodingKitten,
+	})
+	p0Deal := cards.NewSetFromCards([]cards.Card{cards.Skip, cards.Defuse})
+	p1Deal := cards.NewSetFromCards([]cards.Card{cards.Cat, cards.Defuse})
+	game := NewGame(drawPile, p0Deal, p1Deal)
+	if newTablebaseKey(game).faceUp {
+		t.Error("expected the imploding kitten to be face down before it is drawn")
+	}
+
+	node, err := drawCard(t, game).Step(gamestate.Action{
+		Player:             gamestate.Player0,
+		Type:               gamestate.InsertExplodingKitten,
+		Card:               cards.ImplodingKitten,
+		PositionInDrawPile: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !newTablebaseKey(node).faceUp {
+		t.Error("expected the imploding kitten to be face up once it is put back")
+	}
+}
+
+// Evaluates every position with a constant value.
+type constantEvaluator float32
+
+func (c constantEvaluator) Evaluate(rng *rand.Rand, node cfr.GameTreeNode, opponent mcts.Policy) ([]float32, float32) {
+	return uniformDistribution(node.NumChildren()), float32(c)
+}
+
+func TestTablebaseEvaluator(t *testing.T) {
+	const maxCards = 2
+	e := &TablebaseEvaluator{
+		Tablebase: NewTablebase(maxCards),
+		Fallback:  constantEvaluator(0.5),
+	}
+
+	rng := rand.New(rand.NewSource(123))
+	var node cfr.GameTreeNode = newTestDeckGame()
+	for node.Type() != cfr.TerminalNodeType {
+		if node.Type() == cfr.PlayerNodeType {
+			gn := node.(*GameNode)
+			p, v := e.Evaluate(rng, node, nil)
+			if len(p) != node.NumChildren() {
+				t.Errorf("expected prior over %d actions, got %v", node.NumChildren(), p)
+			}
+
+			expected := float32(0.5)
+			if gn.GetDrawPile().Len() <= maxCards {
+				expected = float32(Minimax(gn, node.Player()))
+			}
+			if v != expected {
+				t.Errorf("%v: expected value %v, got %v", gn, expected, v)
+			}
+		}
+
+		node = node.GetChild(rng.Intn(node.NumChildren()))
+	}
+}
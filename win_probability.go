@@ -0,0 +1,75 @@
+package alphacats
+
+import (
+	"github.com/timpalpant/go-cfr"
+)
+
+// WinProbability returns the probability that the given player wins from
+// the given node, if both players play according to policy and chance
+// outcomes occur with their probability.
+//
+// Like BestResponsePolicy, this uses the true hidden state of the node and
+// expands the full game tree below it, which is only tractable for small
+// decks such as cards.TestDeck. Use SampleWinProbability for larger games.
+func WinProbability(policy func(cfr.GameTreeNode) []float32, node cfr.GameTreeNode, player int) float64 {
+	// Utilities are +1 for a win and -1 for a loss.
+	return (ExpectedUtility(policy, node, player) + 1) / 2
+}
+
+// ExpectedUtility returns the expected utility of the given player from the
+// given node, if both players play according to policy. Children are freed
+// as the tree is traversed.
+func ExpectedUtility(policy func(cfr.GameTreeNode) []float32, node cfr.GameTreeNode, player int) float64 {
+	switch node.Type() {
+	case cfr.TerminalNodeType:
+		return node.Utility(player)
+	case cfr.ChanceNodeType:
+		ev := 0.0
+		for i := 0; i < node.NumChildren(); i++ {
+			p := node.GetChildProbability(i)
+			child := node.GetChild(i)
+			ev += p * ExpectedUtility(policy, child, player)
+			child.Close()
+		}
+		return ev
+	}
+
+	ev := 0.0
+	for i, p := range policy(node) {
+		if p == 0 {
+			continue
+		}
+
+		child := node.GetChild(i)
+		ev += float64(p) * ExpectedUtility(policy, child, player)
+		child.Close()
+	}
+
+	return ev
+}
+
+// SampleWinProbability estimates WinProbability by playing out numGames
+// games from the given node, sampling each action from policy and each
+// chance outcome from its probability.
+func SampleWinProbability(policy func(cfr.GameTreeNode) []float32, node *GameNode, player int, numGames int) float64 {
+	wins := 0
+	for i := 0; i < numGames; i++ {
+		root := node.Clone()
+		var game cfr.GameTreeNode = root
+		for game.Type() != cfr.TerminalNodeType {
+			if game.Type() == cfr.ChanceNodeType {
+				game, _ = game.SampleChild()
+			} else {
+				game = game.GetChild(sampleOne(policy(game)))
+			}
+		}
+
+		if game.Utility(player) > 0 {
+			wins++
+		}
+
+		root.Close()
+	}
+
+	return float64(wins) / float64(numGames)
+}
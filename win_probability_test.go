@@ -0,0 +1,65 @@
+package alphacats
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/timpalpant/go-cfr"
+
+	"github.com/timpalpant/alphacats/cards"
+	"github.com/timpalpant/alphacats/gamestate"
+)
+
+// Always plays Skip if possible, and otherwise plays uniformly.
+func alwaysSkipPolicy(node cfr.GameTreeNode) []float32 {
+	for i := 0; i < node.NumChildren(); i++ {
+		action := node.GetChild(i).(*GameNode).LastAction()
+		if action.Type == gamestate.PlayCard && action.Card == cards.Skip {
+			p := make([]float32, node.NumChildren())
+			p[i] = 1.0
+			return p
+		}
+	}
+
+	return uniformPolicy(node)
+}
+
+func TestWinProbability(t *testing.T) {
+	// Player 0 wins if they play Skip, since player 1 must then draw the
+	// kitten, and loses if they draw the kitten themselves.
+	drawPile := cards.NewStackFromCards([]cards.Card{cards.ExplodingKitten, cards.Cat})
+	p0Deal := cards.NewSetFromCards([]cards.Card{cards.Skip})
+	game := NewGame(drawPile, p0Deal, cards.NewSet())
+
+	testCases := []struct {
+		policy   func(cfr.GameTreeNode) []float32
+		expected float64
+	}{
+		{uniformPolicy, 0.5},
+		{alwaysSkipPolicy, 1.0},
+	}
+
+	for _, tc := range testCases {
+		if p := WinProbability(tc.policy, game, 0); math.Abs(p-tc.expected) > 1e-6 {
+			t.Errorf("expected player 0 to win with probability %v, got %v", tc.expected, p)
+		}
+
+		if p := WinProbability(tc.policy, game, 1); math.Abs(p-(1-tc.expected)) > 1e-6 {
+			t.Errorf("expected player 1 to win with probability %v, got %v", 1-tc.expected, p)
+		}
+	}
+}
+
+func TestSampleWinProbability(t *testing.T) {
+	game := newTestDeckGame()
+	exact := WinProbability(uniformPolicy, game, 0)
+
+	rand.Seed(123)
+	n := 10000
+	sampled := SampleWinProbability(uniformPolicy, game, 0, n)
+	if tol := 4 * math.Sqrt(exact*(1-exact)/float64(n)); math.Abs(sampled-exact) > tol {
+		t.Errorf("exact win probability %v differs from sampled %v (tolerance %v)",
+			exact, sampled, tol)
+	}
+}